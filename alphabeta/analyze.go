@@ -0,0 +1,29 @@
+package alphabeta
+
+import (
+	"time"
+
+	"chessTomorrow/board"
+)
+
+// Analyze searches the position given as FEN, printing the UCI info lines
+// and bestmove a GUI would see. It searches to depth if that is above 0,
+// and for moveTime otherwise, showing the multiPV best lines.
+func Analyze(fen string, depth int, moveTime time.Duration, multiPV int) error {
+	if err := board.ValidateFEN(fen); err != nil {
+		return err
+	}
+
+	e := NewEngine()
+	e.ownBook = false
+	e.params.multiPV = max(1, multiPV)
+	e.HandleInput("position fen " + fen)
+
+	limits := searchLimits{depth: depth}
+	if depth <= 0 {
+		limits = searchLimits{moveTime: moveTime, softTime: moveTime}
+	}
+	e.startSearch(limits)
+	<-e.done
+	return nil
+}
@@ -0,0 +1,49 @@
+package alphabeta
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"chessTomorrow/arbiter"
+)
+
+const benchDepth = 4
+
+// bench searches the bench positions to depth (benchDepth if 0) and
+// returns the nodes searched. It uses one thread, one line and a fresh
+// default-size transposition table, so the count only changes when the
+// search or the evaluation does.
+func (e *Engine) bench(depth int) int64 {
+	if depth <= 0 {
+		depth = benchDepth
+	}
+	params := e.params
+	params.threads, params.multiPV = 1, 1
+	tt := newTransTable(defaultHashMB)
+
+	var nodes int64
+	for _, pos := range arbiter.BenchPositions() {
+		s := newSearcher(context.Background(), searchLimits{depth: depth}, params, tt)
+		s.quiet = true
+		s.search(pos, nil)
+		nodes += s.nodes.Load()
+	}
+	return nodes
+}
+
+// BenchMain runs the bench command outside UCI: the same search as "bench"
+// and the same report.
+func BenchMain(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	depth := fs.Int("depth", benchDepth, "depth to search each position to")
+	fs.Parse(args)
+
+	start := time.Now()
+	nodes := NewEngine().bench(*depth)
+	for _, line := range arbiter.BenchReport(nodes, time.Since(start)) {
+		fmt.Println(line)
+	}
+	return nil
+}
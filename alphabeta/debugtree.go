@@ -0,0 +1,213 @@
+package alphabeta
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+)
+
+// With "debug on", the main search thread writes every node it searches to
+// the DebugFile, one line per node once the node is done:
+//
+//	<ply> <move> <depth> <alpha> <beta> <score> <reason>
+//
+// move is the move that led to the node ("root" at ply 0, "0000" for a
+// null move), alpha and beta are the window it was searched with, and the
+// score is from the side to move's point of view. The reason says how the
+// node ended: pv, cut or all for a full search, or the shortcut taken
+// (tt, null, razor, eval, draw, mdp, mated, stalemate, abort). Moves skipped
+// by futility pruning get a line with reason futile and "-" for the
+// numbers. Because children are written before their parent, the tree can
+// be rebuilt from the plies alone; "chessEngine2 tree" does that.
+
+// nodeReason says how a node's search ended.
+type nodeReason string
+
+const (
+	nodeExact        nodeReason = "pv"
+	nodeCut          nodeReason = "cut"
+	nodeAll          nodeReason = "all"
+	nodeTT           nodeReason = "tt"
+	nodeNull         nodeReason = "null"
+	nodeRazor        nodeReason = "razor"
+	nodeEval         nodeReason = "eval"
+	nodeDraw         nodeReason = "draw"
+	nodeMateDistance nodeReason = "mdp"
+	nodeMated        nodeReason = "mated"
+	nodeStalemate    nodeReason = "stalemate"
+	nodeAborted      nodeReason = "abort"
+	nodeFutile       nodeReason = "futile"
+	nodeTablebase    nodeReason = "tb"
+)
+
+var boundReasons = [...]nodeReason{boundExact: nodeExact, boundLower: nodeCut, boundUpper: nodeAll}
+
+// treeWriter writes the search tree.
+type treeWriter struct {
+	f     *os.File
+	w     *bufio.Writer
+	moves []string // the moves leading to the current node, by ply
+}
+
+func createTree(path string) (*treeWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &treeWriter{f: f, w: bufio.NewWriter(f), moves: []string{"root"}}, nil
+}
+
+// enter records that the node at ply is reached by move, nil for a null
+// move.
+func (t *treeWriter) enter(ply int, move *chess.Move) {
+	for len(t.moves) <= ply {
+		t.moves = append(t.moves, "")
+	}
+	t.moves[ply] = "0000"
+	if move != nil {
+		t.moves[ply] = board.MoveToUCI(move)
+	}
+}
+
+func (t *treeWriter) node(ply, depth, alpha, beta, score int, reason nodeReason) {
+	fmt.Fprintf(t.w, "%d %s %d %d %d %d %s\n", ply, t.moves[ply], depth, alpha, beta, score, reason)
+}
+
+// pruned records a move at ply that was not searched.
+func (t *treeWriter) pruned(ply int, move *chess.Move, reason nodeReason) {
+	fmt.Fprintf(t.w, "%d %s - - - - %s\n", ply, board.MoveToUCI(move), reason)
+}
+
+func (t *treeWriter) Close() error {
+	if err := t.w.Flush(); err != nil {
+		t.f.Close()
+		return err
+	}
+	return t.f.Close()
+}
+
+// treeNode is a node read back from a dump.
+type treeNode struct {
+	ply      int
+	fields   []string // move, depth, alpha, beta, score, reason
+	children []*treeNode
+}
+
+func (n *treeNode) move() string { return n.fields[0] }
+
+func (n *treeNode) String() string {
+	f := n.fields
+	if f[1] == "-" {
+		return fmt.Sprintf("%s %s", f[0], f[5])
+	}
+	return fmt.Sprintf("%s d=%s [%s, %s] %s %s", f[0], f[1], f[2], f[3], f[4], f[5])
+}
+
+// readTree rebuilds the root nodes of a dump, one per root search (each
+// iteration, aspiration re-search and MultiPV line), in order.
+func readTree(path string) ([]*treeNode, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var stack []*treeNode
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("%s: line %d: want 7 fields, got %d", path, n, len(fields))
+		}
+		ply, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: %w", path, n, err)
+		}
+		node := &treeNode{ply: ply, fields: fields[1:]}
+
+		// The node's children are the nodes one ply deeper written since
+		// the last node at its own ply or above
+		i := len(stack)
+		for i > 0 && stack[i-1].ply == ply+1 {
+			i--
+		}
+		node.children = append(node.children, stack[i:]...)
+		stack = append(stack[:i], node)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var roots []*treeNode
+	for _, node := range stack {
+		if node.ply == 0 {
+			roots = append(roots, node)
+		}
+	}
+	return roots, nil
+}
+
+// TreeMain pretty-prints part of a dump: the node reached by the given
+// moves from the last root search, and its subtree.
+func TreeMain(args []string) error {
+	fs := flag.NewFlagSet("tree", flag.ExitOnError)
+	levels := fs.Int("depth", 1, "levels of children to print")
+	search := fs.Int("search", 0, "root search to start from, counting from 1 (default the last)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: chessEngine2 tree [flags] <dump file> [uci moves...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	roots, err := readTree(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if len(roots) == 0 {
+		return fmt.Errorf("%s: no root search", fs.Arg(0))
+	}
+	if *search < 0 || *search > len(roots) {
+		return fmt.Errorf("there are %d root searches", len(roots))
+	}
+	node := roots[len(roots)-1]
+	if *search > 0 {
+		node = roots[*search-1]
+	}
+
+	// A move can be searched more than once (reductions, re-searches);
+	// the last search is the one that counted
+	for _, move := range fs.Args()[1:] {
+		var next *treeNode
+		for _, child := range node.children {
+			if child.move() == move {
+				next = child
+			}
+		}
+		if next == nil {
+			return fmt.Errorf("move %s was not searched after %s", move, node.move())
+		}
+		node = next
+	}
+	printTree(node, 0, *levels)
+	return nil
+}
+
+func printTree(node *treeNode, indent, levels int) {
+	fmt.Printf("%s%s\n", strings.Repeat("  ", indent), node)
+	if levels == 0 {
+		return
+	}
+	for _, child := range node.children {
+		printTree(child, indent+1, levels-1)
+	}
+}
@@ -0,0 +1,379 @@
+package alphabeta
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"chessTomorrow/board"
+	"chessTomorrow/book"
+	"github.com/notnil/chess"
+)
+
+// startSearch searches the current position in the background so the UCI
+// loop can keep answering "isready", "stop" and "ponderhit". The bestmove
+// line is printed when the search ends.
+func (e *Engine) startSearch(limits searchLimits) {
+	e.stopSearch()
+
+	pos := e.game.Position()
+	if e.ownBook && !limits.infinite && !limits.ponder {
+		if move := e.openingBook().Pick(pos, nil); move != nil {
+			fmt.Println("bestmove", board.MoveToUCI(move))
+			os.Stdout.Sync()
+			return
+		}
+	}
+
+	params, limits := e.searchSettings(limits)
+	ctx, cancel := context.WithCancel(context.Background())
+	s := newSearcher(ctx, limits, params, e.tt)
+	done := make(chan struct{})
+	e.searcher, e.cancel, e.done = s, cancel, done
+
+	history := e.history()
+	if e.debug {
+		tree, err := createTree(e.debugFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "debug:", err)
+		}
+		s.tree = tree
+	}
+	go func() {
+		defer close(done)
+		bestMove, ponderMove := s.search(pos, history)
+		if s.tree != nil {
+			if err := s.tree.Close(); err != nil {
+				fmt.Fprintln(os.Stderr, "debug:", err)
+			}
+			fmt.Println("info string search tree written to", e.debugFile)
+		}
+		s.waitForRelease()
+		switch {
+		case bestMove == nil:
+			fmt.Println("bestmove 0000")
+		case ponderMove == nil:
+			fmt.Println("bestmove", board.MoveToUCI(bestMove))
+		default:
+			fmt.Println("bestmove", board.MoveToUCI(bestMove), "ponder", board.MoveToUCI(ponderMove))
+		}
+		os.Stdout.Sync()
+	}()
+}
+
+// searchSettings applies UCI_LimitStrength to the options and the limits
+// of a search. A weakened engine searches alone, so the helpers can't make
+// up for the limits.
+func (e *Engine) searchSettings(limits searchLimits) (searchParams, searchLimits) {
+	params := e.params
+	if e.limitStrength {
+		params.strength = strengthFor(e.elo)
+		params.threads = 1
+		limits = params.strength.limit(limits)
+	}
+	return params, limits
+}
+
+// openingBook returns the book, loading it on first use. A book that
+// fails to load is reported and replaced by an empty one.
+func (e *Engine) openingBook() *book.Book {
+	if e.book == nil {
+		b, err := book.Open(e.bookFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "book:", err)
+			b = book.New()
+		}
+		e.book = b
+	}
+	return e.book
+}
+
+// history returns the hashes of the game's positions before the current
+// one, for repetition detection.
+func (e *Engine) history() []uint64 {
+	positions := e.game.Positions()
+	hashes := make([]uint64, 0, len(positions)-1)
+	for _, pos := range positions[:len(positions)-1] {
+		hashes = append(hashes, board.Zobrist(pos))
+	}
+	return hashes
+}
+
+// stopSearch cancels the running search, if any, and waits until it has
+// reported its best move.
+func (e *Engine) stopSearch() {
+	if e.cancel == nil {
+		return
+	}
+	e.cancel()
+	<-e.done
+	e.searcher, e.cancel, e.done = nil, nil, nil
+}
+
+func (e *Engine) ponderhit() {
+	if e.searcher != nil {
+		e.searcher.ponderhit()
+	}
+}
+
+// === Evaluation ===
+
+// evaluate scores pos in centipawns from White's point of view.
+func evaluate(pos *chess.Position) int {
+	return newEvalInfo(pos.Board(), nil).evaluate()
+}
+
+// Material in the middlegame and the endgame, indexed by chess.PieceType.
+// Pawns and rooks gain value as the board empties, minor pieces lose some.
+var (
+	materialMG = [7]int{chess.Pawn: 100, chess.Knight: 320, chess.Bishop: 330, chess.Rook: 480, chess.Queen: 950}
+	materialEG = [7]int{chess.Pawn: 120, chess.Knight: 290, chess.Bishop: 310, chess.Rook: 530, chess.Queen: 950}
+)
+
+// The game phase counts the non-pawn material left, from totalPhase in the
+// opening down to 0 in a pawn ending.
+var phaseWeight = [7]int{chess.Knight: 1, chess.Bishop: 1, chess.Rook: 2, chess.Queen: 4}
+
+const totalPhase = 24
+
+// Piece activity terms, in centipawns.
+var (
+	bishopPairMG, bishopPairEG = 30, 50
+	outpostMG, outpostEG       = 25, 10
+	openFileMG, openFileEG     = 40, 20
+	semiOpenMG, semiOpenEG     = 20, 10
+)
+
+// evalInfo accumulates the evaluation of one position, together with what
+// the piece terms need to know about the pawns. Arrays are indexed by
+// chess.Color.
+type evalInfo struct {
+	board       *chess.Board
+	pawnAttacks [3]uint64          // squares attacked by pawns, bit i for square i
+	pawnRanks   [3][8][]chess.Rank // ranks of the pawns on each file
+	mg, eg      int                // from White's point of view
+	phase       int
+	trace       *evalTrace // if not nil, records which weights were used
+}
+
+// evalTrace counts how many times each weight contributed to an
+// evaluation, White's uses counting positive and Black's negative. The
+// evaluation is linear in the weights, so this is all the tuner needs.
+type evalTrace struct {
+	mg, eg map[*int]int
+	phase  int
+}
+
+func newEvalTrace() *evalTrace {
+	return &evalTrace{mg: map[*int]int{}, eg: map[*int]int{}}
+}
+
+func newEvalInfo(board *chess.Board, trace *evalTrace) *evalInfo {
+	info := &evalInfo{board: board, trace: trace}
+	for sq := chess.A1; sq <= chess.H8; sq++ {
+		piece := board.Piece(sq)
+		if piece.Type() != chess.Pawn {
+			continue
+		}
+		c, f := piece.Color(), sq.File()
+		info.pawnRanks[c][f] = append(info.pawnRanks[c][f], sq.Rank())
+		dr := 1
+		if c == chess.Black {
+			dr = -1
+		}
+		for _, df := range []int{-1, 1} {
+			if to, ok := step(sq, df, dr); ok {
+				info.pawnAttacks[c] |= 1 << uint(to)
+			}
+		}
+	}
+	return info
+}
+
+// add scores the weight pair (mg, eg) n times for color c.
+func (info *evalInfo) add(c chess.Color, mg, eg *int, n int) {
+	if c == chess.Black {
+		n = -n
+	}
+	info.mg += n * *mg
+	info.eg += n * *eg
+	if info.trace != nil {
+		info.trace.mg[mg] += n
+		info.trace.eg[eg] += n
+	}
+}
+
+// evaluate scores the position in centipawns from White's point of view.
+// Every term has a middlegame and an endgame value; the two totals are
+// blended by the game phase, so e.g. the king is told to hide early and to
+// come to the centre once the pieces are off.
+func (info *evalInfo) evaluate() int {
+	var bishops [3]int
+	for sq := chess.A1; sq <= chess.H8; sq++ {
+		piece := info.board.Piece(sq)
+		if piece == chess.NoPiece {
+			continue
+		}
+		c, t := piece.Color(), piece.Type()
+
+		// Material and square, then the terms that depend on the rest of
+		// the board
+		i := pstIndex(sq, c)
+		info.add(c, &materialMG[t], &materialEG[t], 1)
+		info.add(c, &psqt[t][0][i], &psqt[t][1][i], 1)
+		switch t {
+		case chess.Knight:
+			info.evaluateKnight(sq, c)
+		case chess.Bishop:
+			bishops[c]++
+			info.mobility(sq, c, &bishopMobility)
+		case chess.Rook:
+			info.evaluateRook(sq, c)
+		case chess.Queen:
+			info.mobility(sq, c, &queenMobility)
+		}
+		info.phase += phaseWeight[t]
+	}
+
+	// The bishop pair covers both square colors
+	for _, c := range []chess.Color{chess.White, chess.Black} {
+		if bishops[c] >= 2 {
+			info.add(c, &bishopPairMG, &bishopPairEG, 1)
+		}
+	}
+
+	phase := min(info.phase, totalPhase)
+	if info.trace != nil {
+		info.trace.phase = phase
+	}
+	return (info.mg*phase + info.eg*(totalPhase-phase)) / totalPhase
+}
+
+// safeFromPawns reports whether sq can never be attacked by a pawn of color
+// c: there is no such pawn on a neighbouring file that has yet to pass it.
+func (info *evalInfo) safeFromPawns(sq chess.Square, c chess.Color) bool {
+	for _, f := range []int{int(sq.File()) - 1, int(sq.File()) + 1} {
+		if f < 0 || f > 7 {
+			continue
+		}
+		for _, r := range info.pawnRanks[c][f] {
+			if (c == chess.Black && r > sq.Rank()) || (c == chess.White && r < sq.Rank()) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// === Mobility ===
+
+// mobilityWeights is the bonus per reachable square, and the square count
+// of an average placement, which scores zero.
+type mobilityWeights struct {
+	mg, eg, average int
+	steps           [][2]int
+	slides          bool
+}
+
+var (
+	knightSteps = [][2]int{{1, 2}, {2, 1}, {2, -1}, {1, -2}, {-1, -2}, {-2, -1}, {-2, 1}, {-1, 2}}
+	bishopSteps = [][2]int{{1, 1}, {-1, 1}, {-1, -1}, {1, -1}}
+	rookSteps   = [][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+	queenSteps  = append(append([][2]int{}, bishopSteps...), rookSteps...)
+
+	knightMobility = mobilityWeights{mg: 4, eg: 4, average: 4, steps: knightSteps}
+	bishopMobility = mobilityWeights{mg: 5, eg: 5, average: 6, steps: bishopSteps, slides: true}
+	rookMobility   = mobilityWeights{mg: 2, eg: 4, average: 7, steps: rookSteps, slides: true}
+	queenMobility  = mobilityWeights{mg: 1, eg: 2, average: 13, steps: queenSteps, slides: true}
+)
+
+// mobility counts the safe squares the piece on sq can move to: those not
+// held by its own pieces nor attacked by enemy pawns.
+func (info *evalInfo) mobility(sq chess.Square, c chess.Color, w *mobilityWeights) {
+	enemyPawns := info.pawnAttacks[c.Other()]
+	count := 0
+	for _, st := range w.steps {
+		to, ok := step(sq, st[0], st[1])
+		for ok {
+			occupant := info.board.Piece(to)
+			if occupant != chess.NoPiece && occupant.Color() == c {
+				break
+			}
+			if enemyPawns&(1<<uint(to)) == 0 {
+				count++
+			}
+			if occupant != chess.NoPiece || !w.slides {
+				break
+			}
+			to, ok = step(to, st[0], st[1])
+		}
+	}
+	info.add(c, &w.mg, &w.eg, count-w.average)
+}
+
+// step returns the square df files and dr ranks away from sq, or false if
+// that is off the board.
+func step(sq chess.Square, df, dr int) (chess.Square, bool) {
+	f, r := int(sq.File())+df, int(sq.Rank())+dr
+	if f < 0 || f > 7 || r < 0 || r > 7 {
+		return chess.NoSquare, false
+	}
+	return chess.NewSquare(chess.File(f), chess.Rank(r)), true
+}
+
+// === Knight Evaluation ===
+
+func (info *evalInfo) evaluateKnight(sq chess.Square, c chess.Color) {
+	info.mobility(sq, c, &knightMobility)
+
+	// An outpost is a square in the enemy half, defended by a pawn, that
+	// no enemy pawn can ever challenge
+	rank := int(sq.Rank())
+	if c == chess.Black {
+		rank = 7 - rank
+	}
+	if rank >= 3 && rank <= 5 &&
+		info.pawnAttacks[c]&(1<<uint(sq)) != 0 &&
+		info.safeFromPawns(sq, c.Other()) {
+		info.add(c, &outpostMG, &outpostEG, 1)
+	}
+}
+
+// === Rook Evaluation ===
+
+func (info *evalInfo) evaluateRook(sq chess.Square, c chess.Color) {
+	info.mobility(sq, c, &rookMobility)
+
+	// Rooks are more valuable on files without pawns, and still better off
+	// on one without their own pawns
+	f := sq.File()
+	switch {
+	case len(info.pawnRanks[chess.White][f])+len(info.pawnRanks[chess.Black][f]) == 0:
+		info.add(c, &openFileMG, &openFileEG, 1)
+	case len(info.pawnRanks[c][f]) == 0:
+		info.add(c, &semiOpenMG, &semiOpenEG, 1)
+	}
+}
+
+// === Helpers ===
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
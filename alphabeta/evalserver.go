@@ -0,0 +1,268 @@
+package alphabeta
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+)
+
+// The eval server searches batches of positions for other programs over
+// HTTP:
+//
+//	POST /eval   {"fens": ["<fen>", ...], "depth": 12, "movetime": 500}
+//
+// depth and movetime (milliseconds) are optional and default to the
+// server's limits; a search stops at whichever comes first. A whole
+// request also stops at the server's budget: the positions being searched
+// then are answered from their last completed depth, and those not yet
+// started get an error, so no request holds the workers for longer. The
+// reply
+// holds a result for each FEN, in order:
+//
+//	{"results": [{"fen": "...", "bestmove": "e2e4", "score": 31, "depth": 12,
+//	              "nodes": 81234, "pv": ["e2e4", "e7e5"]}, ...]}
+//
+// score is in centipawns for the side to move, from a search whose leaves
+// play out their captures first; a forced mate has "mate"
+// instead, in moves, negative when the side to move is mated. A position
+// without legal moves has "status" "checkmate" or "stalemate" and no
+// search, and a FEN that doesn't parse has an "error".
+
+// EvalRequest is a batch of positions to search.
+type EvalRequest struct {
+	FENs     []string `json:"fens"`
+	Depth    int      `json:"depth,omitempty"`
+	MoveTime int      `json:"movetime,omitempty"` // milliseconds
+}
+
+// EvalResult is what the search found in one position.
+type EvalResult struct {
+	FEN      string   `json:"fen"`
+	BestMove string   `json:"bestmove,omitempty"`
+	Score    *int     `json:"score,omitempty"`
+	Mate     int      `json:"mate,omitempty"`
+	Depth    int      `json:"depth,omitempty"`
+	Nodes    int64    `json:"nodes,omitempty"`
+	PV       []string `json:"pv,omitempty"`
+	Status   string   `json:"status,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// evalJob is one position of a batch, handed to a worker.
+type evalJob struct {
+	ctx    context.Context
+	fen    string
+	limits searchLimits
+	result *EvalResult
+	done   func()
+}
+
+// EvalServer searches the positions of every batch it is sent with a
+// fixed set of workers, each an Engine of its own searching one position
+// at a time.
+type EvalServer struct {
+	depth    int           // default and most depth a batch may ask for
+	moveTime time.Duration // default and most time a position may take
+	maxBatch int
+	budget   time.Duration // most time a request may take, or 0
+	jobs     chan evalJob
+}
+
+// NewEvalServer starts workers engines, configured by setup, that search
+// each position to depth plies or for moveTime, whichever comes first. A
+// zero depth leaves only the time limit. Each request is given budget to
+// search all its positions in; zero leaves it unlimited.
+func NewEvalServer(workers, depth int, moveTime time.Duration, maxBatch int, budget time.Duration, setup func(*Engine) error) (*EvalServer, error) {
+	s := &EvalServer{depth: depth, moveTime: moveTime, maxBatch: maxBatch, budget: budget, jobs: make(chan evalJob)}
+	engines := make([]*Engine, workers)
+	for i := range engines {
+		e := NewEngine()
+		// The workers already keep every core busy
+		e.params.threads = 1
+		if err := setup(e); err != nil {
+			return nil, err
+		}
+		engines[i] = e
+	}
+	for _, e := range engines {
+		go func() {
+			for job := range s.jobs {
+				*job.result = e.evaluate(job.ctx, job.fen, job.limits)
+				job.done()
+			}
+		}()
+	}
+	return s, nil
+}
+
+// Close stops the workers once the batches being searched are done.
+func (s *EvalServer) Close() {
+	close(s.jobs)
+}
+
+// limits returns the limits of a search for req, within the server's.
+func (s *EvalServer) limits(req EvalRequest) searchLimits {
+	limits := searchLimits{depth: s.depth, moveTime: s.moveTime}
+	if req.Depth > 0 && (s.depth == 0 || req.Depth < s.depth) {
+		limits.depth = req.Depth
+	}
+	if t := time.Duration(req.MoveTime) * time.Millisecond; t > 0 && t < s.moveTime {
+		limits.moveTime = t
+	}
+	limits.softTime = limits.moveTime
+	return limits
+}
+
+// Evaluate searches every position of req and returns the results in
+// order. Positions not started when ctx is done or the server's budget is
+// spent are left with an error.
+func (s *EvalServer) Evaluate(ctx context.Context, req EvalRequest) []EvalResult {
+	if s.budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.budget)
+		defer cancel()
+	}
+	results := make([]EvalResult, len(req.FENs))
+	limits := s.limits(req)
+	remaining := make(chan struct{}, len(req.FENs))
+	for i, fen := range req.FENs {
+		job := evalJob{ctx: ctx, fen: fen, limits: limits, result: &results[i], done: func() { remaining <- struct{}{} }}
+		select {
+		case s.jobs <- job:
+		case <-ctx.Done():
+			results[i] = EvalResult{FEN: fen, Error: ctx.Err().Error()}
+			remaining <- struct{}{}
+		}
+	}
+	for range req.FENs {
+		<-remaining
+	}
+	return results
+}
+
+// evaluate searches one position. The transposition table is kept from
+// one position to the next, as in a game.
+func (e *Engine) evaluate(ctx context.Context, fen string, limits searchLimits) EvalResult {
+	res := EvalResult{FEN: fen}
+	if err := ctx.Err(); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	pos, err := board.ParseFEN(fen, board.StrictFEN)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	switch pos.Status() {
+	case chess.Checkmate:
+		res.Status = "checkmate"
+		return res
+	case chess.Stalemate:
+		res.Status = "stalemate"
+		return res
+	}
+
+	s := newSearcher(ctx, limits, e.params, e.tt)
+	s.quiet = true
+	move, _ := s.search(pos, nil)
+	if move == nil {
+		res.Error = "no move found"
+		return res
+	}
+	info := searchInfo(s.result, s.nodes.Load())
+	res.BestMove = board.MoveToUCI(move)
+	res.Mate, res.Depth, res.Nodes, res.PV = info.Mate, info.Depth, info.Nodes, info.PV
+	if info.Mate == 0 {
+		res.Score = &info.Score
+	}
+	return res
+}
+
+// ServeHTTP answers POST /eval.
+func (s *EvalServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req EvalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.FENs) == 0 {
+		http.Error(w, "no fens to evaluate", http.StatusBadRequest)
+		return
+	}
+	if len(req.FENs) > s.maxBatch {
+		http.Error(w, fmt.Sprintf("%d fens is more than the %d a batch may have", len(req.FENs), s.maxBatch), http.StatusRequestEntityTooLarge)
+		return
+	}
+	start := time.Now()
+	results := s.Evaluate(r.Context(), req)
+	if r.Context().Err() != nil {
+		return // nobody to answer
+	}
+	log.Printf("Evaluated %d positions in %v", len(results), time.Since(start).Round(time.Millisecond))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]EvalResult{"results": results})
+}
+
+// optionList collects repeated -option flags.
+type optionList []string
+
+func (f *optionList) String() string { return strings.Join(*f, " ") }
+
+func (f *optionList) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// EvalServerMain runs the evalserver command: the engine as an HTTP
+// service that searches batches of FENs.
+func EvalServerMain(args []string) error {
+	fs := flag.NewFlagSet("evalserver", flag.ExitOnError)
+	addr := fs.String("addr", ":8090", "address to serve on")
+	workers := fs.Int("workers", runtime.NumCPU(), "positions searched at once, each by an engine with one thread")
+	depth := fs.Int("depth", 0, "depth to search to, and the most a request may ask for; 0 for no limit but -movetime")
+	moveTime := fs.Duration("movetime", time.Second, "time to search each position for, and the most a request may ask for")
+	maxBatch := fs.Int("max-batch", 1000, "most FENs one request may send")
+	budget := fs.Duration("budget", time.Minute, "most time one request may take, over all its FENs; 0 for no limit")
+	var options optionList
+	fs.Var(&options, "option", "UCI option Name=Value for every worker, such as Hash=64; repeat for more")
+	fs.Parse(args)
+	if *workers < 1 || *moveTime <= 0 || *budget < 0 {
+		return errors.New("need at least one worker, a positive -movetime and a -budget of 0 or more")
+	}
+
+	setup := func(e *Engine) error {
+		e.ownBook = false
+		for _, opt := range options {
+			name, value, _ := strings.Cut(opt, "=")
+			if err := e.options.SetOption(fmt.Sprintf("setoption name %s value %s", strings.TrimSpace(name), strings.TrimSpace(value))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	s, err := NewEvalServer(*workers, *depth, *moveTime, *maxBatch, *budget, setup)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /eval", s)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, `POST /eval {"fens": ["<fen>", ...], "depth": 12, "movetime": 500}`+"\n")
+	})
+	log.Printf("Evaluating on %s with %d workers, depth %d, movetime %v, budget %v", *addr, *workers, *depth, *moveTime, *budget)
+	return http.ListenAndServe(*addr, mux)
+}
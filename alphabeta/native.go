@@ -0,0 +1,130 @@
+package alphabeta
+
+import (
+	"context"
+	"errors"
+
+	"chessTomorrow/arbiter"
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+)
+
+// The Engine also plays in-process, as an arbiter.ChessEngine: the arbiter
+// passes the position and the clock instead of UCI commands, and options
+// are set through Options. Such searches print nothing.
+
+func (e *Engine) Name() string   { return "AlphaBetaEngine" }
+func (e *Engine) Author() string { return "You" }
+
+// Options returns the engine's UCI options, to configure it in-process.
+func (e *Engine) Options() *arbiter.Options { return e.options }
+
+// NewGame forgets what the engine learned in the previous game.
+func (e *Engine) NewGame() {
+	e.stopSearch()
+	e.tt.clear()
+}
+
+// GetMove searches pos within the time the clock allows, or until ctx is
+// done, and returns the best move, or arbiter.ErrResign if the Negotiation
+// set has the engine resign. Only pos is known, not the moves that
+// led to it, so repetitions of earlier positions are not seen.
+func (e *Engine) GetMove(ctx context.Context, pos *chess.Position, clock arbiter.ClockState) (*chess.Move, error) {
+	e.stopSearch()
+	e.last = arbiter.SearchInfo{}
+	if e.ownBook {
+		if move := e.openingBook().Pick(pos, nil); move != nil {
+			return move, nil
+		}
+	}
+
+	params, limits := e.searchSettings(clockLimits(clock, pos.Turn()))
+	s := newSearcher(ctx, limits, params, e.tt)
+	s.quiet = true
+	move, _ := s.search(pos, nil)
+	if move == nil {
+		return nil, errors.New("no legal moves")
+	}
+	e.last = searchInfo(s.result, s.nodes.Load())
+	if e.negotiation.Resigns(e.last) {
+		return nil, arbiter.ErrResign
+	}
+	return move, nil
+}
+
+// SetNegotiation implements arbiter.Negotiable: from now on GetMove
+// resigns, and the engine offers and accepts draws, as n decides on its
+// scores.
+func (e *Engine) SetNegotiation(n arbiter.Negotiation) {
+	e.negotiation = n
+}
+
+// WantsDraw implements arbiter.DrawNegotiator.
+func (e *Engine) WantsDraw(ctx context.Context, pos *chess.Position) bool {
+	return e.negotiation.WantsDraw(pos, e.last)
+}
+
+// AcceptDraw implements arbiter.DrawNegotiator.
+func (e *Engine) AcceptDraw(ctx context.Context, pos *chess.Position) bool {
+	return e.negotiation.AcceptsDraw(pos, e.last)
+}
+
+// SearchGame searches the position at the end of game, whose earlier
+// positions count for repetitions, within the clock's limits and to depth
+// plies if depth is above 0, or until ctx is done. info, if not nil, gets
+// what each completed iteration found. It returns the best move and the
+// reply the engine expects, which may be nil; a book move comes without
+// either a reply or any info.
+func (e *Engine) SearchGame(ctx context.Context, game *chess.Game, clock arbiter.ClockState, depth int, info func(arbiter.SearchInfo)) (best, ponder *chess.Move, err error) {
+	e.stopSearch()
+	e.last = arbiter.SearchInfo{}
+	pos := game.Position()
+	if e.ownBook {
+		if move := e.openingBook().Pick(pos, nil); move != nil {
+			return move, nil, nil
+		}
+	}
+
+	limits := clockLimits(clock, pos.Turn())
+	if depth > 0 {
+		limits.depth = depth
+		if clock.MoveTime <= 0 && clock.WhiteTime <= 0 && clock.BlackTime <= 0 {
+			limits.moveTime, limits.softTime = 0, 0
+		}
+	}
+	params, limits := e.searchSettings(limits)
+	s := newSearcher(ctx, limits, params, e.tt)
+	s.quiet, s.onInfo = true, info
+	var history []uint64
+	positions := game.Positions()
+	for _, p := range positions[:len(positions)-1] {
+		history = append(history, board.Zobrist(p))
+	}
+	best, ponder = s.search(pos, history)
+	if best == nil {
+		return nil, nil, errors.New("no legal moves")
+	}
+	e.last = searchInfo(s.result, s.nodes.Load())
+	return best, ponder, nil
+}
+
+// LastSearch implements arbiter.SearchReporter: the depth, score and
+// principal variation behind the last move GetMove returned, empty for a
+// book move.
+func (e *Engine) LastSearch() arbiter.SearchInfo {
+	return e.last
+}
+
+func searchInfo(r searchResult, nodes int64) arbiter.SearchInfo {
+	info := arbiter.SearchInfo{Depth: r.depth, Nodes: nodes, HasScore: true, Score: r.score}
+	switch {
+	case r.score > mateBound:
+		info.Mate = (mateScore - r.score + 1) / 2
+	case r.score < -mateBound:
+		info.Mate = -(mateScore + r.score) / 2
+	}
+	for _, move := range r.pv {
+		info.PV = append(info.PV, board.MoveToUCI(move))
+	}
+	return info
+}
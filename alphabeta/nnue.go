@@ -0,0 +1,162 @@
+package alphabeta
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+
+	"github.com/notnil/chess"
+)
+
+// The NNUE evaluation is a perspective network: 768 inputs, one for each
+// (side, piece type, square) seen from one color, feed a hidden layer of
+// n neurons. The layer is computed once from White's point of view and
+// once from Black's, with the squares flipped, and kept up to date move by
+// move in an accumulator instead of being recomputed. The side to move's
+// half and the other half go through a clipped ReLU into a single output.
+//
+// Network files use the usual quantized layout of such "768->n->1" nets,
+// as little-endian int16s: the input weights (768 rows of n), the hidden
+// biases (n), the output weights (2n, side to move first) and the output
+// bias. The size of the file gives n; trailing padding to a multiple of
+// 64 bytes is allowed.
+const (
+	nnueInputs = 768
+	nnueQA     = 255 // scale of the hidden layer
+	nnueQB     = 64  // scale of the output weights
+	nnueScale  = 400 // output to centipawns
+)
+
+type network struct {
+	hidden         int
+	featureWeights []int16
+	featureBias    []int16
+	outputWeights  []int16
+	outputBias     int16
+}
+
+// loadNetwork reads a network file.
+func loadNetwork(path string) (*network, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	words := len(data) / 2
+	n := (words - 1) / (nnueInputs + 3)
+	size := 2 * ((nnueInputs+3)*n + 1)
+	if n <= 0 || len(data)-size >= 64 {
+		return nil, fmt.Errorf("%s: %d bytes is not the size of a 768->n->1 network", path, len(data))
+	}
+
+	values := make([]int16, words)
+	for i := range values {
+		values[i] = int16(binary.LittleEndian.Uint16(data[2*i:]))
+	}
+	net := &network{hidden: n}
+	net.featureWeights, values = values[:nnueInputs*n], values[nnueInputs*n:]
+	net.featureBias, values = values[:n], values[n:]
+	net.outputWeights, values = values[:2*n], values[2*n:]
+	net.outputBias = values[0]
+	return net, nil
+}
+
+// accumulator is the hidden layer before activation, from each color's
+// point of view, indexed by chess.Color.
+type accumulator [3][]int16
+
+func (net *network) newAccumulator() accumulator {
+	var acc accumulator
+	acc[chess.White] = make([]int16, net.hidden)
+	acc[chess.Black] = make([]int16, net.hidden)
+	return acc
+}
+
+// nnueFeature returns the input for piece on sq as seen by perspective:
+// its own pieces come first, and Black sees the board upside down.
+func nnueFeature(perspective chess.Color, piece chess.Piece, sq chess.Square) int {
+	side, s := 0, int(sq)
+	if piece.Color() != perspective {
+		side = 1
+	}
+	if perspective == chess.Black {
+		s ^= 56
+	}
+	return (side*6+nnuePieceIndex[piece.Type()])*64 + s
+}
+
+var nnuePieceIndex = [7]int{chess.Pawn: 0, chess.Knight: 1, chess.Bishop: 2, chess.Rook: 3, chess.Queen: 4, chess.King: 5}
+
+// refresh computes acc from scratch for b.
+func (net *network) refresh(acc *accumulator, b *chess.Board) {
+	for _, c := range []chess.Color{chess.White, chess.Black} {
+		copy(acc[c], net.featureBias)
+	}
+	for sq, piece := range b.SquareMap() {
+		net.toggle(acc, piece, sq, 1)
+	}
+}
+
+// toggle adds (sign 1) or removes (sign -1) a piece in both halves of acc.
+func (net *network) toggle(acc *accumulator, piece chess.Piece, sq chess.Square, sign int16) {
+	for _, c := range []chess.Color{chess.White, chess.Black} {
+		row := net.featureWeights[nnueFeature(c, piece, sq)*net.hidden:][:net.hidden]
+		half := acc[c]
+		for i, w := range row {
+			half[i] += sign * w
+		}
+	}
+}
+
+// applyMove sets dst to src updated for move played in pos: the moving
+// piece, any captured piece, the castling rook and the promotion.
+func (net *network) applyMove(dst, src *accumulator, pos *chess.Position, move *chess.Move) {
+	copy(dst[chess.White], src[chess.White])
+	copy(dst[chess.Black], src[chess.Black])
+
+	b := pos.Board()
+	from, to := move.S1(), move.S2()
+	piece := b.Piece(from)
+	placed := piece
+	if move.Promo() != chess.NoPieceType {
+		placed = chess.NewPiece(move.Promo(), piece.Color())
+	}
+	net.toggle(dst, piece, from, -1)
+	net.toggle(dst, placed, to, 1)
+
+	switch {
+	case move.HasTag(chess.EnPassant):
+		sq := chess.NewSquare(to.File(), from.Rank())
+		net.toggle(dst, b.Piece(sq), sq, -1)
+	case b.Piece(to) != chess.NoPiece:
+		net.toggle(dst, b.Piece(to), to, -1)
+	case move.HasTag(chess.KingSideCastle):
+		rook := chess.NewPiece(chess.Rook, piece.Color())
+		net.toggle(dst, rook, chess.NewSquare(chess.FileH, from.Rank()), -1)
+		net.toggle(dst, rook, chess.NewSquare(chess.FileF, from.Rank()), 1)
+	case move.HasTag(chess.QueenSideCastle):
+		rook := chess.NewPiece(chess.Rook, piece.Color())
+		net.toggle(dst, rook, chess.NewSquare(chess.FileA, from.Rank()), -1)
+		net.toggle(dst, rook, chess.NewSquare(chess.FileD, from.Rank()), 1)
+	}
+}
+
+// evaluate scores the position held in acc for the side to move.
+func (net *network) evaluate(acc *accumulator, turn chess.Color) int {
+	us, them := acc[turn], acc[turn.Other()]
+	sum := int64(0)
+	for i := 0; i < net.hidden; i++ {
+		sum += int64(clippedReLU(us[i])) * int64(net.outputWeights[i])
+		sum += int64(clippedReLU(them[i])) * int64(net.outputWeights[net.hidden+i])
+	}
+	return int((sum + int64(net.outputBias)) * nnueScale / (nnueQA * nnueQB))
+}
+
+func clippedReLU(x int16) int16 {
+	switch {
+	case x < 0:
+		return 0
+	case x > nnueQA:
+		return nnueQA
+	}
+	return x
+}
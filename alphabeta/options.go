@@ -0,0 +1,85 @@
+package alphabeta
+
+import (
+	"fmt"
+	"os"
+
+	"chessTomorrow/arbiter"
+	"chessTomorrow/tablebase"
+)
+
+const (
+	maxThreads  = 64
+	maxMultiPV  = 64
+	maxContempt = 500
+
+	// defaultDebugFile receives the search tree after "debug on".
+	defaultDebugFile = "searchtree.txt"
+)
+
+// newOptions registers the engine's UCI options. Setters run with no
+// search in progress.
+func (e *Engine) newOptions() *arbiter.Options {
+	o := arbiter.NewOptions()
+	o.AddSpin("Hash", defaultHashMB, 1, maxHashMB, func(mb int) { e.tt = newTransTable(mb) })
+	o.AddSpin("Threads", defaultParams.threads, 1, maxThreads, func(n int) { e.params.threads = n })
+	o.AddSpin("MultiPV", defaultParams.multiPV, 1, maxMultiPV, func(n int) { e.params.multiPV = n })
+	o.AddCheck("Ponder", false, func(on bool) { e.ponder = on })
+	o.Add(arbiter.Option{Name: "Clear Hash", Type: arbiter.Button, Set: func(string) { e.tt.clear() }})
+	o.AddCheck("OwnBook", true, func(on bool) { e.ownBook = on })
+	o.AddString("BookFile", "", func(path string) { e.bookFile, e.book = path, nil })
+	o.AddString("EvalFile", "", e.setEvalFile)
+	o.AddCheck("UseNNUE", false, func(on bool) { e.useNNUE = on; e.selectEval() })
+	o.AddString("TablebasePath", "", e.setTablebasePath)
+	o.AddSpin("Contempt", defaultParams.contempt, -maxContempt, maxContempt, func(n int) { e.params.contempt = n })
+	o.AddCheck("UCI_LimitStrength", false, func(on bool) { e.limitStrength = on })
+	o.AddSpin("UCI_Elo", defaultElo, minElo, maxElo, func(elo int) { e.elo = elo })
+	o.AddString("DebugFile", defaultDebugFile, func(path string) { e.debugFile = path })
+
+	// Search tuning.
+	o.AddCheck("LMR", defaultParams.lmr, func(on bool) { e.params.lmr = on })
+	o.AddSpin("LMRMoves", defaultParams.lmrMoves, 1, 64, func(n int) { e.params.lmrMoves = n })
+	o.AddSpin("FutilityMargin", defaultParams.futilityMargin, 0, 1000, func(n int) { e.params.futilityMargin = n })
+	o.AddSpin("RazorMargin", defaultParams.razorMargin, 0, 1000, func(n int) { e.params.razorMargin = n })
+	return o
+}
+
+// setEvalFile loads the NNUE network at path. An empty path unloads it.
+func (e *Engine) setEvalFile(path string) {
+	e.network = nil
+	if path != "" {
+		net, err := loadNetwork(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "EvalFile:", err)
+		}
+		e.network = net
+	}
+	e.selectEval()
+}
+
+// setTablebasePath loads the endgame tables in dir, as written by the
+// tablebase command. An empty path unloads them.
+func (e *Engine) setTablebasePath(dir string) {
+	e.params.tablebase = nil
+	if dir != "" {
+		tb, err := tablebase.Open(dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "TablebasePath:", err)
+			return
+		}
+		e.params.tablebase = tb
+	}
+}
+
+// selectEval switches the search between the classical evaluation and
+// the network, as the UseNNUE option asks.
+func (e *Engine) selectEval() {
+	e.params.net = nil
+	if e.useNNUE {
+		if e.network == nil {
+			fmt.Fprintln(os.Stderr, "UseNNUE: no network loaded, set EvalFile; using the classical evaluation")
+			return
+		}
+		e.params.net = e.network
+	}
+}
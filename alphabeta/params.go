@@ -0,0 +1,103 @@
+package alphabeta
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/notnil/chess"
+)
+
+// paramGroup names a set of evaluation weights as they appear in a
+// parameter file.
+type paramGroup struct {
+	name    string
+	weights []*int
+}
+
+// evalParams lists the tunable evaluation weights. Mobility averages and
+// phase weights are structural and left out.
+func evalParams() []paramGroup {
+	return []paramGroup{
+		{"materialMG", refs(materialMG[chess.Queen:])},
+		{"materialEG", refs(materialEG[chess.Queen:])},
+		{"pawnMG", refs(pawnMG[:])},
+		{"pawnEG", refs(pawnEG[:])},
+		{"knightMG", refs(knightMG[:])},
+		{"knightEG", refs(knightEG[:])},
+		{"bishopMG", refs(bishopMG[:])},
+		{"bishopEG", refs(bishopEG[:])},
+		{"rookMG", refs(rookMG[:])},
+		{"rookEG", refs(rookEG[:])},
+		{"queenMG", refs(queenMG[:])},
+		{"queenEG", refs(queenEG[:])},
+		{"kingMG", refs(kingMG[:])},
+		{"kingEG", refs(kingEG[:])},
+		{"knightMobility", []*int{&knightMobility.mg, &knightMobility.eg}},
+		{"bishopMobility", []*int{&bishopMobility.mg, &bishopMobility.eg}},
+		{"rookMobility", []*int{&rookMobility.mg, &rookMobility.eg}},
+		{"queenMobility", []*int{&queenMobility.mg, &queenMobility.eg}},
+		{"bishopPair", []*int{&bishopPairMG, &bishopPairEG}},
+		{"outpost", []*int{&outpostMG, &outpostEG}},
+		{"openFile", []*int{&openFileMG, &openFileEG}},
+		{"semiOpenFile", []*int{&semiOpenMG, &semiOpenEG}},
+	}
+}
+
+func refs(values []int) []*int {
+	ptrs := make([]*int, len(values))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	return ptrs
+}
+
+// LoadEvalParams overrides the built-in weights with those in a JSON file
+// written by the tune command. Groups missing from the file keep their
+// values.
+func LoadEvalParams(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var values map[string][]int
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	groups := map[string][]*int{}
+	for _, g := range evalParams() {
+		groups[g.name] = g.weights
+	}
+	for name, vs := range values {
+		weights, ok := groups[name]
+		if !ok {
+			return fmt.Errorf("%s: unknown parameter %q", path, name)
+		}
+		if len(vs) != len(weights) {
+			return fmt.Errorf("%s: parameter %q has %d values, want %d", path, name, len(vs), len(weights))
+		}
+		for i, v := range vs {
+			*weights[i] = v
+		}
+	}
+	return nil
+}
+
+// saveEvalParams writes the current weights in the format LoadEvalParams
+// reads.
+func saveEvalParams(path string) error {
+	values := map[string][]int{}
+	for _, g := range evalParams() {
+		vs := make([]int, len(g.weights))
+		for i, w := range g.weights {
+			vs[i] = *w
+		}
+		values[g.name] = vs
+	}
+	data, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,766 @@
+package alphabeta
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"chessTomorrow/arbiter"
+	"chessTomorrow/board"
+	"chessTomorrow/tablebase"
+	"github.com/notnil/chess"
+)
+
+const (
+	infinity = 999999
+	maxDepth = 64
+
+	// Being mated n plies from the root scores -mateScore+n, so shorter
+	// mates score better. Scores beyond mateBound in either direction are
+	// mates; maxPly covers the deepest extended line.
+	mateScore = 100000
+	maxPly    = 2 * maxDepth
+	mateBound = mateScore - maxPly
+
+	// Null-move pruning is tried from nullMinDepth, and cutoffs are
+	// verified from nullVerifyDepth.
+	nullMinDepth    = 3
+	nullVerifyDepth = 6
+
+	// Aspiration windows start aspirationWindow centipawns either side of
+	// the previous score from aspirationMinDepth on, doubling on each
+	// failure and opening fully past aspirationMaxWindow.
+	aspirationMinDepth  = 4
+	aspirationWindow    = 50
+	aspirationMaxWindow = 1000
+)
+
+// searchLimits bounds a search. Zero fields are unlimited, but at least
+// depth 1 is always completed so there is a move to play. No iteration is
+// started after softTime; moveTime aborts the search.
+type searchLimits struct {
+	depth    int
+	nodes    int
+	moveTime time.Duration
+	softTime time.Duration
+	infinite bool
+	ponder   bool // no limits apply until ponderhit
+}
+
+// defaultLimits is used for a "go" without parameters.
+var defaultLimits = searchLimits{moveTime: 500 * time.Millisecond, softTime: 500 * time.Millisecond}
+
+// searchParams are the search settings exposed as UCI options. A zero
+// margin disables the corresponding pruning.
+type searchParams struct {
+	threads        int
+	multiPV        int // number of best lines to report
+	lmr            bool
+	lmrMoves       int // moves searched at full depth before reducing
+	futilityMargin int
+	razorMargin    int
+	net            *network             // NNUE evaluation, or nil for the classical one
+	strength       *strength            // UCI_LimitStrength level, or nil for full strength
+	contempt       int                  // centipawns a draw is worth less than zero to the engine
+	tablebase      *tablebase.Tablebase // endgame tables probed below the root, or nil
+}
+
+var defaultParams = searchParams{
+	threads:        1,
+	multiPV:        1,
+	lmr:            true,
+	lmrMoves:       4,
+	futilityMargin: 200,
+	razorMargin:    300,
+}
+
+// searcher runs one iterative deepening search. It stops early when ctx
+// is cancelled. With more than one thread, the main searcher (id 0) is
+// joined by helpers that share its transposition table and node count.
+type searcher struct {
+	ctx        context.Context
+	id         int
+	params     searchParams
+	nodes      *atomic.Int64 // shared by all threads
+	startDepth int
+	rootDepth  int
+	selDepth   int // deepest ply reached
+	aborted    bool
+	quiet      bool                     // no info lines, for bench
+	onInfo     func(arbiter.SearchInfo) // if set, gets the main line of every completed iteration
+	tt         *transTable
+	excluded   []*chess.Move // root moves skipped while searching MultiPV lines
+	path       []uint64      // hashes of the positions before this node, game history first
+	accs       []accumulator // NNUE accumulators by ply
+	tree       *treeWriter   // debug dump of the search tree, main thread only
+	result     searchResult  // what search settled on, for LastSearch
+
+	began    time.Time  // when "go" arrived, for "info time"
+	mu       sync.Mutex // guards limits and start, changed by ponderhit
+	limits   searchLimits
+	start    time.Time     // when the engine's clock started: at go, or at ponderhit
+	released chan struct{} // closed by ponderhit
+}
+
+func newSearcher(ctx context.Context, limits searchLimits, params searchParams, tt *transTable) *searcher {
+	return &searcher{
+		ctx:        ctx,
+		limits:     limits,
+		params:     params,
+		nodes:      new(atomic.Int64),
+		startDepth: 1,
+		began:      time.Now(),
+		start:      time.Now(),
+		tt:         tt,
+		released:   make(chan struct{}),
+	}
+}
+
+// ponderhit turns a ponder search into a normal search under the limits of
+// the original "go ponder" command. The engine's clock only starts running
+// now, so the time limits are measured from here; the search keeps the
+// depth it reached while pondering.
+func (s *searcher) ponderhit() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.limits.ponder {
+		s.limits.ponder = false
+		s.start = time.Now()
+		close(s.released)
+	}
+}
+
+// waitForRelease holds back the result of an infinite or ponder search,
+// which may only be reported after "stop" (or "ponderhit" when pondering).
+func (s *searcher) waitForRelease() {
+	limits, _ := s.snapshot()
+	switch {
+	case limits.infinite:
+		<-s.ctx.Done()
+	case limits.ponder:
+		select {
+		case <-s.ctx.Done():
+		case <-s.released:
+		}
+	}
+}
+
+func (s *searcher) snapshot() (searchLimits, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.limits, s.start
+}
+
+// iterate deepens one ply at a time until the limits run out and returns
+// the principal variation of the last completed iteration, empty if there
+// are no legal moves. The main searcher prints an "info" line per line
+// and iteration.
+func (s *searcher) iterate(pos *chess.Position, hash uint64) searchResult {
+	lines := s.params.multiPV
+	if s.params.strength != nil {
+		lines = max(lines, strengthLines)
+	}
+	lines = min(lines, len(pos.ValidMoves()))
+	if s.params.net != nil {
+		s.params.net.refresh(s.acc(0), pos.Board())
+	}
+	prevScores := make([]int, lines)
+
+	var result searchResult
+	for depth := s.startDepth; depth <= maxDepth; depth++ {
+		if limits, _ := s.snapshot(); !limits.ponder && limits.depth > 0 && depth > limits.depth {
+			break
+		}
+		s.rootDepth = depth
+
+		// With MultiPV, each further line is searched with the root moves
+		// of the better lines excluded.
+		s.excluded = s.excluded[:0]
+		for k := 0; k < lines; k++ {
+			pv, score := s.aspirationSearch(pos, hash, depth, prevScores[k])
+			if s.aborted || len(pv) == 0 {
+				break
+			}
+			prevScores[k] = score
+			if k == 0 {
+				// The lines are only replaced once all of them are done
+				result = searchResult{pv: pv, depth: depth, score: score, lines: result.lines}
+			}
+			s.excluded = append(s.excluded, pv[0])
+
+			if s.id == 0 && !s.quiet {
+				s.printInfo(depth, k, lines, score, pv)
+			}
+			if s.id == 0 && k == 0 && s.onInfo != nil {
+				s.onInfo(searchInfo(result, s.nodes.Load()))
+			}
+		}
+		if s.aborted || len(result.pv) == 0 {
+			break
+		}
+		result.lines = nil
+		for k, move := range s.excluded {
+			result.lines = append(result.lines, rootLine{move: move, score: prevScores[k]})
+		}
+
+		// Once a mate is found, deeper iterations can't find a shorter one
+		score := prevScores[0]
+		if s.pastSoftLimit() || mateScore-abs(score) <= depth {
+			break
+		}
+	}
+	return result
+}
+
+// printInfo reports a completed line in UCI "info" form. The multipv field
+// is only included when more than one line is searched.
+func (s *searcher) printInfo(depth, k, lines, score int, pv []*chess.Move) {
+	elapsed := time.Since(s.began)
+	nodes := s.nodes.Load()
+	nps := nodes * 1000 / max64(elapsed.Milliseconds(), 1)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "info depth %d seldepth %d", depth, s.selDepth)
+	if lines > 1 {
+		fmt.Fprintf(&b, " multipv %d", k+1)
+	}
+	fmt.Fprintf(&b, " score %s nodes %d nps %d hashfull %d time %d pv %s",
+		scoreString(score), nodes, nps, s.tt.hashfull(), elapsed.Milliseconds(), pvString(pv))
+	fmt.Println(b.String())
+	os.Stdout.Sync()
+}
+
+// scoreString renders a score as "cp N" or, for a forced mate, "mate N"
+// in moves (negative when the engine is getting mated).
+func scoreString(score int) string {
+	switch {
+	case score > mateBound:
+		return fmt.Sprintf("mate %d", (mateScore-score+1)/2)
+	case score < -mateBound:
+		return fmt.Sprintf("mate -%d", (mateScore+score)/2)
+	}
+	return fmt.Sprintf("cp %d", score)
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// aspirationSearch searches the root with a narrow window around the
+// previous iteration's score, widening it on the failing side until the
+// score falls inside. Shallow iterations use the full window.
+func (s *searcher) aspirationSearch(pos *chess.Position, hash uint64, depth, prevScore int) ([]*chess.Move, int) {
+	alpha, beta := -infinity, infinity
+	window := aspirationWindow
+	if depth >= aspirationMinDepth {
+		alpha, beta = prevScore-window, prevScore+window
+	}
+	for {
+		var pv []*chess.Move
+		score := s.negamax(pos, hash, depth, alpha, beta, 0, &pv, true)
+		switch {
+		case s.aborted:
+			return nil, 0
+		case score <= alpha && alpha > -infinity:
+			window *= 2
+			alpha = max(prevScore-window, -infinity)
+			if window > aspirationMaxWindow {
+				alpha = -infinity
+			}
+		case score >= beta && beta < infinity:
+			window *= 2
+			beta = min(prevScore+window, infinity)
+			if window > aspirationMaxWindow {
+				beta = infinity
+			}
+		default:
+			return pv, score
+		}
+	}
+}
+
+// negamax is alpha-beta search scoring from the side to move's point of
+// view. hash is the Zobrist key of pos and pv receives the principal
+// variation from this node. allowNull is false right after a null move
+// and during verification searches.
+func (s *searcher) negamax(pos *chess.Position, hash uint64, depth, alpha, beta, ply int, pv *[]*chess.Move, allowNull bool) int {
+	score, reason := s.searchNode(pos, hash, depth, alpha, beta, ply, pv, allowNull)
+	if s.tree != nil {
+		s.tree.node(ply, depth, alpha, beta, score, reason)
+	}
+	return score
+}
+
+// searchNode is negamax proper. It also says how the node ended, for the
+// debug tree.
+func (s *searcher) searchNode(pos *chess.Position, hash uint64, depth, alpha, beta, ply int, pv *[]*chess.Move, allowNull bool) (int, nodeReason) {
+	nodes := s.nodes.Add(1)
+	if ply > s.selDepth {
+		s.selDepth = ply
+	}
+	if s.rootDepth > 1 && nodes&1023 == 0 && s.outOfBudget() {
+		s.aborted = true
+	}
+	if s.aborted {
+		return 0, nodeAborted
+	}
+	if ply > 0 && s.isDraw(pos, hash) {
+		return s.drawScore(ply), nodeDraw
+	}
+	s.path = append(s.path, hash)
+	defer func() { s.path = s.path[:len(s.path)-1] }()
+
+	// Mate distance pruning: no line from here can do better than mating
+	// on the next move, nor worse than being mated right now.
+	if ply > 0 {
+		alpha = max(alpha, -mateScore+ply)
+		beta = min(beta, mateScore-ply-1)
+		if alpha >= beta {
+			return alpha, nodeMateDistance
+		}
+	}
+
+	// Positions in the tables are known exactly, to the mate
+	if ply > 0 && s.params.tablebase != nil {
+		if r, ok := s.params.tablebase.Probe(pos); ok {
+			return s.tablebaseScore(r, ply), nodeTablebase
+		}
+	}
+
+	entry, hit := s.tt.probe(hash)
+	if hit {
+		entry.score = scoreFromTT(entry.score, ply)
+	}
+	if hit && ply > 0 && entry.depth >= depth {
+		switch {
+		case entry.bound == boundExact,
+			entry.bound == boundLower && entry.score >= beta,
+			entry.bound == boundUpper && entry.score <= alpha:
+			return entry.score, nodeTT
+		}
+	}
+
+	moves := pos.ValidMoves()
+	if len(moves) == 0 {
+		if pos.Status() == chess.Checkmate {
+			return -mateScore + ply, nodeMated
+		}
+		return s.drawScore(ply), nodeStalemate
+	}
+	if depth <= 0 {
+		return s.quiesce(pos, alpha, beta, ply), nodeEval
+	}
+
+	inCheck := board.CheckersBitboard(pos) != 0
+	if allowNull && !inCheck && ply > 0 && depth >= nullMinDepth && beta < mateBound && s.nullMoveCutoff(pos, hash, depth, beta, ply) {
+		return beta, nodeNull
+	}
+
+	// Frontier pruning: when the static eval is far below alpha, razoring
+	// searches one ply less and futility pruning skips quiet moves at
+	// depth 1, since they are unlikely to recover that much.
+	futile := false
+	if !inCheck && ply > 0 && depth <= 2 && alpha > -mateBound {
+		eval := s.staticEval(pos, ply)
+		if s.params.razorMargin > 0 && eval+s.params.razorMargin*depth <= alpha {
+			depth--
+		}
+		futile = depth == 1 && s.params.futilityMargin > 0 && eval+s.params.futilityMargin <= alpha
+		if depth <= 0 {
+			return s.quiesce(pos, alpha, beta, ply), nodeRazor
+		}
+	}
+
+	var ttMove *chess.Move
+	if hit && entry.hasMove() {
+		ttMove = findMove(moves, entry.from, entry.to, entry.promo)
+	}
+	moves = orderMoves(moves, ttMove)
+	if ply == 0 && len(s.excluded) > 0 {
+		moves = withoutMoves(moves, s.excluded)
+	}
+
+	origAlpha := alpha
+	bestScore := -infinity
+	var bestMove *chess.Move
+	for i, move := range moves {
+		quiet := isQuiet(move)
+		if futile && i > 0 && quiet {
+			if s.tree != nil {
+				s.tree.pruned(ply+1, move, nodeFutile)
+			}
+			continue
+		}
+
+		var line []*chess.Move
+		child := pos.Update(move)
+		s.makeMove(pos, move, ply)
+		childHash := board.ZobristUpdate(hash, pos, move)
+		newDepth := s.adjustedDepth(depth, ply, move)
+
+		// Principal variation search: the first move gets the full window;
+		// the others only have to be proven worse with a null window, and
+		// are searched again properly if they turn out better.
+		var score int
+		if i == 0 {
+			score = -s.negamax(child, childHash, newDepth, -beta, -alpha, ply+1, &line, true)
+		} else {
+			// Late move reductions: quiet moves late in the ordering are
+			// searched shallower first.
+			r := 0
+			if s.params.lmr && !inCheck && quiet && i >= s.params.lmrMoves && depth >= 3 {
+				r = 1
+				if i >= 3*s.params.lmrMoves {
+					r = 2
+				}
+			}
+			score = -s.negamax(child, childHash, newDepth-r, -alpha-1, -alpha, ply+1, &line, true)
+			if score > alpha && r > 0 {
+				line = nil
+				score = -s.negamax(child, childHash, newDepth, -alpha-1, -alpha, ply+1, &line, true)
+			}
+			if score > alpha && score < beta {
+				line = nil
+				score = -s.negamax(child, childHash, newDepth, -beta, -alpha, ply+1, &line, true)
+			}
+		}
+		if s.aborted {
+			return 0, nodeAborted
+		}
+		if score > bestScore {
+			bestScore, bestMove = score, move
+			*pv = append([]*chess.Move{move}, line...)
+		}
+		alpha = max(alpha, score)
+		if alpha >= beta {
+			break
+		}
+	}
+
+	bound := boundExact
+	switch {
+	case bestScore <= origAlpha:
+		bound = boundUpper
+	case bestScore >= beta:
+		bound = boundLower
+	}
+	s.tt.store(hash, depth, scoreToTT(bestScore, ply), bound, bestMove)
+	return bestScore, boundReasons[bound]
+}
+
+// quiesce scores a leaf once its captures and promotions have played out,
+// so that no leaf is scored halfway through an exchange. The side to move
+// may stand pat on the static evaluation instead, unless it is in check,
+// when every move is searched. Its nodes are not written to the debug
+// tree: they show as the leaf's score.
+func (s *searcher) quiesce(pos *chess.Position, alpha, beta, ply int) int {
+	nodes := s.nodes.Add(1)
+	if ply > s.selDepth {
+		s.selDepth = ply
+	}
+	if s.rootDepth > 1 && nodes&1023 == 0 && s.outOfBudget() {
+		s.aborted = true
+	}
+	if s.aborted {
+		return 0
+	}
+	moves := pos.ValidMoves()
+	if len(moves) == 0 {
+		if pos.Status() == chess.Checkmate {
+			return -mateScore + ply
+		}
+		return s.drawScore(ply)
+	}
+
+	inCheck := board.CheckersBitboard(pos) != 0
+	best := -infinity
+	if !inCheck || ply >= maxPly {
+		best = s.staticEval(pos, ply)
+		if best >= beta || ply >= maxPly {
+			return best
+		}
+		alpha = max(alpha, best)
+	} else {
+		moves = orderMoves(moves, nil)
+	}
+	if !inCheck {
+		moves = orderCaptures(pos, moves)
+	}
+	for _, move := range moves {
+		child := pos.Update(move)
+		s.updateAcc(pos, move, ply)
+		score := -s.quiesce(child, -beta, -alpha, ply+1)
+		if s.aborted {
+			return 0
+		}
+		best = max(best, score)
+		alpha = max(alpha, score)
+		if alpha >= beta {
+			break
+		}
+	}
+	return best
+}
+
+// orderCaptures returns the captures and promotions among moves, most
+// valuable victim first and, among those, least valuable attacker first.
+func orderCaptures(pos *chess.Position, moves []*chess.Move) []*chess.Move {
+	value := func(move *chess.Move) int {
+		victim := pos.Board().Piece(move.S2()).Type()
+		if move.HasTag(chess.EnPassant) {
+			victim = chess.Pawn
+		}
+		attacker := pos.Board().Piece(move.S1()).Type()
+		return 10*(materialMG[victim]+materialMG[move.Promo()]) - materialMG[attacker]
+	}
+	captures := make([]*chess.Move, 0, len(moves))
+	for _, move := range moves {
+		if board.IsCapture(move) || move.Promo() != chess.NoPieceType {
+			captures = append(captures, move)
+		}
+	}
+	slices.SortStableFunc(captures, func(a, b *chess.Move) int { return value(b) - value(a) })
+	return captures
+}
+
+// isDraw reports whether the position is drawn by the fifty-move rule or
+// repeats an earlier one. A single repetition is enough: if the position
+// could be improved on, it could have been the first time.
+func (s *searcher) isDraw(pos *chess.Position, hash uint64) bool {
+	clock := pos.HalfMoveClock()
+	if clock >= 100 {
+		return true
+	}
+	// Only positions since the last capture or pawn move, with the same
+	// side to move, can repeat.
+	for i := len(s.path) - 2; i >= 0 && i >= len(s.path)-clock; i -= 2 {
+		if s.path[i] == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// drawScore scores a draw at ply for the side to move there. With a
+// positive contempt the engine, the side to move at the root, counts a
+// draw as that many centipawns lost and plays on; with a negative one it
+// welcomes draws.
+func (s *searcher) drawScore(ply int) int {
+	if ply%2 == 0 {
+		return -s.params.contempt
+	}
+	return s.params.contempt
+}
+
+// tablebaseScore turns a table's result into a score at ply, mates
+// counted from the root like the search's own.
+func (s *searcher) tablebaseScore(r tablebase.Result, ply int) int {
+	switch r.Outcome {
+	case tablebase.Win:
+		return mateScore - ply - r.Plies
+	case tablebase.Loss:
+		return -mateScore + ply + r.Plies
+	}
+	return s.drawScore(ply)
+}
+
+// scoreToTT converts a mate score from distance-to-root to distance-to-node
+// for storing, since the same position can be reached at different plies.
+// scoreFromTT converts back.
+func scoreToTT(score, ply int) int {
+	switch {
+	case score > mateBound:
+		return score + ply
+	case score < -mateBound:
+		return score - ply
+	}
+	return score
+}
+
+func scoreFromTT(score, ply int) int {
+	switch {
+	case score > mateBound:
+		return score - ply
+	case score < -mateBound:
+		return score + ply
+	}
+	return score
+}
+
+// nullMoveCutoff tries passing the move: if a reduced search still fails
+// high, the position is almost certainly good enough to cut. At high depth
+// the cutoff is confirmed by a reduced search of the real moves, which
+// catches most zugzwang positions. Null moves are never tried when the side
+// to move has only pawns left, where zugzwang is common; the caller rules
+// out positions in check.
+func (s *searcher) nullMoveCutoff(pos *chess.Position, hash uint64, depth, beta, ply int) bool {
+	if !hasPieces(pos, pos.Turn()) {
+		return false
+	}
+	r := 2
+	if depth > 6 {
+		r = 3
+	}
+
+	var line []*chess.Move
+	s.makeMove(pos, nil, ply)
+	score := -s.negamax(board.NullMove(pos), board.ZobristNullMove(hash, pos), depth-1-r, -beta, -beta+1, ply+1, &line, false)
+	if s.aborted || score < beta {
+		return false
+	}
+	if depth < nullVerifyDepth {
+		return true
+	}
+	return s.negamax(pos, hash, depth-r, beta-1, beta, ply, &line, false) >= beta
+}
+
+// hasPieces reports whether c has anything besides its king and pawns.
+func hasPieces(pos *chess.Position, c chess.Color) bool {
+	for _, p := range pos.Board().SquareMap() {
+		if p.Color() == c && p.Type() != chess.King && p.Type() != chess.Pawn {
+			return true
+		}
+	}
+	return false
+}
+
+// adjustedDepth extends captures and checks by not reducing the depth, as
+// long as the line is no more than twice the iteration's nominal depth.
+func (s *searcher) adjustedDepth(depth, ply int, move *chess.Move) int {
+	if ply < 2*s.rootDepth && (move.HasTag(chess.Capture) || move.HasTag(chess.Check)) {
+		return depth // keep current depth
+	}
+	return depth - 1
+}
+
+// pastSoftLimit reports whether another iteration should not be started.
+func (s *searcher) pastSoftLimit() bool {
+	if s.outOfBudget() {
+		return true
+	}
+	limits, start := s.snapshot()
+	return !limits.ponder && limits.softTime > 0 && time.Since(start) >= limits.softTime
+}
+
+// outOfBudget reports whether the search must stop now.
+func (s *searcher) outOfBudget() bool {
+	if s.ctx.Err() != nil {
+		return true
+	}
+	limits, start := s.snapshot()
+	if limits.ponder {
+		return false
+	}
+	if limits.nodes > 0 && s.nodes.Load() >= int64(limits.nodes) {
+		return true
+	}
+	return limits.moveTime > 0 && time.Since(start) >= limits.moveTime
+}
+
+// orderMoves puts the transposition table move first, then captures and
+// promotions, then the quiet moves.
+func orderMoves(moves []*chess.Move, ttMove *chess.Move) []*chess.Move {
+	ordered := make([]*chess.Move, 0, len(moves))
+	if ttMove != nil {
+		ordered = append(ordered, ttMove)
+	}
+	for _, quiet := range []bool{false, true} {
+		for _, move := range moves {
+			if move != ttMove && isQuiet(move) == quiet {
+				ordered = append(ordered, move)
+			}
+		}
+	}
+	return ordered
+}
+
+// withoutMoves returns moves minus the excluded ones.
+func withoutMoves(moves, excluded []*chess.Move) []*chess.Move {
+	kept := make([]*chess.Move, 0, len(moves))
+	for _, move := range moves {
+		if findMove(excluded, move.S1(), move.S2(), move.Promo()) == nil {
+			kept = append(kept, move)
+		}
+	}
+	return kept
+}
+
+// isQuiet reports whether the move is neither a capture, a promotion nor
+// a check.
+func isQuiet(move *chess.Move) bool {
+	return !board.IsCapture(move) && move.Promo() == chess.NoPieceType && !move.HasTag(chess.Check)
+}
+
+// staticEval is the evaluation from the side to move's point of view.
+func (s *searcher) staticEval(pos *chess.Position, ply int) int {
+	if s.params.net != nil {
+		return s.params.net.evaluate(s.acc(ply), pos.Turn())
+	}
+	score := evaluate(pos)
+	if pos.Turn() == chess.Black {
+		score = -score
+	}
+	return score
+}
+
+// acc returns the NNUE accumulator for ply, allocating it on first use.
+func (s *searcher) acc(ply int) *accumulator {
+	for len(s.accs) <= ply {
+		s.accs = append(s.accs, s.params.net.newAccumulator())
+	}
+	return &s.accs[ply]
+}
+
+// makeMove brings the NNUE accumulator for ply+1 up to date with move
+// played at ply, or with a null move if move is nil, and records the move
+// in the debug tree. Going back up the tree needs nothing: the parent's
+// accumulator is still in place.
+func (s *searcher) makeMove(pos *chess.Position, move *chess.Move, ply int) {
+	if s.tree != nil {
+		s.tree.enter(ply+1, move)
+	}
+	s.updateAcc(pos, move, ply)
+}
+
+// updateAcc is makeMove without the debug tree.
+func (s *searcher) updateAcc(pos *chess.Position, move *chess.Move, ply int) {
+	net := s.params.net
+	if net == nil {
+		return
+	}
+	dst := s.acc(ply + 1)
+	src := s.acc(ply)
+	if move == nil {
+		copy(dst[chess.White], src[chess.White])
+		copy(dst[chess.Black], src[chess.Black])
+		return
+	}
+	net.applyMove(dst, src, pos, move)
+}
+
+// findMove returns the move from-to (with the given promotion) among
+// moves, or nil.
+func findMove(moves []*chess.Move, from, to chess.Square, promo chess.PieceType) *chess.Move {
+	for _, move := range moves {
+		if move.S1() == from && move.S2() == to && move.Promo() == promo {
+			return move
+		}
+	}
+	return nil
+}
+
+func pvString(pv []*chess.Move) string {
+	parts := make([]string, len(pv))
+	for i, move := range pv {
+		parts[i] = board.MoveToUCI(move)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,103 @@
+package alphabeta
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+)
+
+// searchResult is what one thread found in its last completed iteration.
+type searchResult struct {
+	pv    []*chess.Move
+	depth int
+	score int
+	lines []rootLine // every line of the iteration, best first
+}
+
+// rootLine is the first move and score of one MultiPV line.
+type rootLine struct {
+	move  *chess.Move
+	score int
+}
+
+// search runs the iterative deepening search on params.threads goroutines
+// (Lazy SMP) and returns the best move, or nil if there are no legal moves,
+// together with the expected reply to ponder on (possibly nil).
+//
+// The helpers search the same root without any coordination beyond the
+// shared transposition table, which is what makes them useful: they fill
+// it with results the main thread then finds. Odd helpers start one ply
+// deeper so the threads don't all work on the same iteration. The main
+// thread owns the limits and the output; when it finishes, the helpers are
+// stopped and the deepest completed result wins.
+func (s *searcher) search(pos *chess.Position, history []uint64) (best, ponder *chess.Move) {
+	s.tt.newSearch()
+	hash := board.Zobrist(pos)
+	s.path = append(s.path[:0], history...)
+
+	helperCtx, stopHelpers := context.WithCancel(s.ctx)
+	results := make([]searchResult, max(s.params.threads, 1))
+	var wg sync.WaitGroup
+	for id := 1; id < len(results); id++ {
+		h := s.newHelper(helperCtx, id)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[id] = h.iterate(pos, hash)
+		}()
+	}
+	results[0] = s.iterate(pos, hash)
+	stopHelpers()
+	wg.Wait()
+
+	result := results[0]
+	for _, r := range results[1:] {
+		if r.depth > result.depth {
+			result = r
+		}
+	}
+	s.result = result
+	if len(result.pv) == 0 {
+		return nil, nil
+	}
+
+	best = result.pv[0]
+	if st := s.params.strength; st != nil && len(result.lines) > 0 {
+		if move := st.choose(result.lines, pos.ValidMoves()); move != best {
+			return move, nil
+		}
+	}
+	if len(result.pv) > 1 {
+		return best, result.pv[1]
+	}
+	// Transposition table cutoffs can cut the PV short; the table may
+	// still know the reply.
+	child := pos.Update(best)
+	if entry, ok := s.tt.probe(board.ZobristUpdate(hash, pos, best)); ok && entry.hasMove() {
+		ponder = findMove(child.ValidMoves(), entry.from, entry.to, entry.promo)
+	}
+	return best, ponder
+}
+
+// newHelper returns a helper thread for s. Helpers have no limits of their
+// own and run until ctx is cancelled; they search a single line.
+func (s *searcher) newHelper(ctx context.Context, id int) *searcher {
+	params := s.params
+	params.multiPV = 1
+	return &searcher{
+		ctx:        ctx,
+		id:         id,
+		params:     params,
+		nodes:      s.nodes,
+		startDepth: 1 + id%2,
+		tt:         s.tt,
+		path:       slices.Clone(s.path),
+		began:      s.began,
+		limits:     searchLimits{infinite: true},
+		start:      s.began,
+		released:   make(chan struct{}),
+	}
+}
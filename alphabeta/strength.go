@@ -0,0 +1,88 @@
+package alphabeta
+
+import (
+	"math/rand"
+
+	"github.com/notnil/chess"
+)
+
+// With UCI_LimitStrength on, the engine plays at about UCI_Elo by
+// searching less and choosing worse moves: the search is capped in depth
+// and nodes, it always looks at strengthLines root moves, and it picks
+// among them by score plus random noise. Now and then it plays a random
+// legal move instead, a blunder.
+//
+// The anchors below are rough estimates, to be refined by matches against
+// opponents of known strength; levels in between are interpolated.
+const (
+	minElo        = 600
+	maxElo        = 2400
+	defaultElo    = 1500
+	strengthLines = 4
+)
+
+// strength is how the engine plays at one Elo.
+type strength struct {
+	elo     int
+	depth   int
+	nodes   int
+	noise   int     // centipawns of noise added to each candidate's score
+	blunder float64 // chance of a random move
+}
+
+var strengthAnchors = []strength{
+	{elo: 600, depth: 1, nodes: 200, noise: 400, blunder: 0.20},
+	{elo: 1000, depth: 2, nodes: 1000, noise: 250, blunder: 0.10},
+	{elo: 1400, depth: 3, nodes: 5000, noise: 120, blunder: 0.04},
+	{elo: 1800, depth: 5, nodes: 30000, noise: 50, blunder: 0.01},
+	{elo: 2400, depth: 10, nodes: 1000000, noise: 0, blunder: 0},
+}
+
+// strengthFor interpolates the anchors at elo, clamped to their range.
+func strengthFor(elo int) *strength {
+	elo = max(minElo, min(elo, maxElo))
+	i := 1
+	for strengthAnchors[i].elo < elo {
+		i++
+	}
+	lo, hi := strengthAnchors[i-1], strengthAnchors[i]
+	t := float64(elo-lo.elo) / float64(hi.elo-lo.elo)
+	lerp := func(a, b int) int { return a + int(t*float64(b-a)) }
+	return &strength{
+		elo:     elo,
+		depth:   lerp(lo.depth, hi.depth),
+		nodes:   lerp(lo.nodes, hi.nodes),
+		noise:   lerp(lo.noise, hi.noise),
+		blunder: lo.blunder + t*(hi.blunder-lo.blunder),
+	}
+}
+
+// limit tightens the limits of a search to the level.
+func (st *strength) limit(limits searchLimits) searchLimits {
+	if limits.depth == 0 || limits.depth > st.depth {
+		limits.depth = st.depth
+	}
+	if limits.nodes == 0 || limits.nodes > st.nodes {
+		limits.nodes = st.nodes
+	}
+	return limits
+}
+
+// choose picks the move to play from the lines of the last completed
+// iteration, best first, or from all of moves when blundering.
+func (st *strength) choose(lines []rootLine, moves []*chess.Move) *chess.Move {
+	if rand.Float64() < st.blunder {
+		return moves[rand.Intn(len(moves))]
+	}
+	best, bestScore := lines[0].move, -infinity
+	for _, line := range lines {
+		score := line.score
+		if st.noise > 0 {
+			score += rand.Intn(st.noise)
+		}
+		if score > bestScore {
+			best, bestScore = line.move, score
+		}
+	}
+	return best
+}
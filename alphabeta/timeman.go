@@ -0,0 +1,143 @@
+package alphabeta
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"chessTomorrow/arbiter"
+	"github.com/notnil/chess"
+)
+
+const (
+	// moveOverhead is kept in reserve for process and pipe latency.
+	moveOverhead = 50 * time.Millisecond
+
+	// defaultMovesToGo is assumed when the GUI doesn't say how many moves
+	// remain until the next time control.
+	defaultMovesToGo = 30
+)
+
+// parseGo reads the parameters of a "go" command for the side to move. A
+// bare "go" gets defaultLimits. pondering says whether the GUI has the
+// Ponder option on.
+func parseGo(cmd string, turn chess.Color, pondering bool) searchLimits {
+	tokens := strings.Fields(cmd)
+	var (
+		limits               searchLimits
+		wtime, btime         time.Duration
+		winc, binc, movetime time.Duration
+		movesToGo            int
+		given                bool
+	)
+	for i := 1; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "infinite":
+			limits.infinite, given = true, true
+			continue
+		case "ponder":
+			limits.ponder, given = true, true
+			continue
+		}
+		if i+1 >= len(tokens) {
+			break
+		}
+		n, err := strconv.Atoi(tokens[i+1])
+		if err != nil {
+			continue
+		}
+		ms := time.Duration(n) * time.Millisecond
+		switch tokens[i] {
+		case "wtime":
+			wtime = ms
+		case "btime":
+			btime = ms
+		case "winc":
+			winc = ms
+		case "binc":
+			binc = ms
+		case "movestogo":
+			movesToGo = n
+		case "movetime":
+			movetime = ms
+		case "depth":
+			limits.depth = n
+		case "nodes":
+			limits.nodes = n
+		default:
+			continue
+		}
+		given = true
+		i++
+	}
+	if !given {
+		return defaultLimits
+	}
+	if limits.infinite {
+		return limits
+	}
+
+	remaining, inc := wtime, winc
+	if turn == chess.Black {
+		remaining, inc = btime, binc
+	}
+	return timeLimits(limits, remaining, inc, movetime, movesToGo, pondering)
+}
+
+// clockLimits are the limits for an in-process search under clock, which
+// has the same meaning as the parameters of "go". A clock without any time
+// gets defaultLimits.
+func clockLimits(clock arbiter.ClockState, turn chess.Color) searchLimits {
+	remaining, inc := clock.WhiteTime, clock.WhiteInc
+	if turn == chess.Black {
+		remaining, inc = clock.BlackTime, clock.BlackInc
+	}
+	if clock.MoveTime <= 0 && remaining <= 0 {
+		return defaultLimits
+	}
+	return timeLimits(searchLimits{}, remaining, inc, clock.MoveTime, clock.MovesToGo, false)
+}
+
+// timeLimits sets the time limits for a fixed time per move, or else for
+// the side to move's remaining time and increment.
+func timeLimits(limits searchLimits, remaining, inc, movetime time.Duration, movesToGo int, pondering bool) searchLimits {
+	switch {
+	case movetime > 0:
+		limits.moveTime = movetime - moveOverhead
+		if limits.moveTime < time.Millisecond {
+			limits.moveTime = time.Millisecond
+		}
+		limits.softTime = limits.moveTime
+	case remaining > 0:
+		limits.softTime, limits.moveTime = allocateTime(remaining, inc, movesToGo, pondering)
+	}
+	return limits
+}
+
+// allocateTime splits the remaining clock time: the soft limit is an even
+// share of the remaining time plus the increment, after which no new
+// iteration is started; the hard limit, which aborts the search, allows
+// three times that. Neither may run the clock out. When pondering, the
+// engine also thinks on the opponent's time and often gets moves for free
+// on a ponderhit, so it can afford a quarter more per move.
+func allocateTime(remaining, inc time.Duration, movesToGo int, pondering bool) (soft, hard time.Duration) {
+	if movesToGo <= 0 {
+		movesToGo = defaultMovesToGo
+	}
+	limit := remaining - moveOverhead
+	if limit < time.Millisecond {
+		limit = time.Millisecond
+	}
+	budget := remaining/time.Duration(movesToGo) + inc
+	if pondering {
+		budget += budget / 4
+	}
+	soft, hard = budget, 3*budget
+	if soft > limit {
+		soft = limit
+	}
+	if hard > limit {
+		hard = limit
+	}
+	return soft, hard
+}
@@ -0,0 +1,142 @@
+package alphabeta
+
+import (
+	"sync/atomic"
+
+	"github.com/notnil/chess"
+)
+
+const (
+	defaultHashMB = 16
+	maxHashMB     = 1024
+)
+
+type ttBound uint8
+
+const (
+	boundNone  ttBound = iota
+	boundExact         // score is exact
+	boundLower         // search failed high: score is a lower bound
+	boundUpper         // search failed low: score is an upper bound
+)
+
+// ttEntry packs a search result into two words. key holds the Zobrist key
+// xored with data, so an entry whose words were written by different
+// threads at the same time fails verification instead of returning
+// garbage. The words are read and written atomically, without locks.
+//
+// data layout, from bit 0: from square (6), to square (6), promotion piece
+// type (3), bound (2), age (7), depth (8), score (32).
+type ttEntry struct {
+	key  uint64
+	data uint64
+}
+
+// transTable is a fixed-size transposition table keyed by board.Zobrist.
+// When two positions map to the same slot, the deeper result is kept,
+// unless the stored one is left over from an earlier search.
+type transTable struct {
+	entries []ttEntry
+	mask    uint64
+	age     uint8
+}
+
+// newTransTable allocates a table of at most mb megabytes. The number of
+// entries is a power of two so the slot is a mask of the key.
+func newTransTable(mb int) *transTable {
+	n := uint64(1)
+	for n*2*16 <= uint64(mb)<<20 {
+		n *= 2
+	}
+	return &transTable{entries: make([]ttEntry, n), mask: n - 1}
+}
+
+// newSearch marks entries from earlier searches as replaceable.
+func (t *transTable) newSearch() {
+	t.age = (t.age + 1) & 0x7f
+}
+
+func (t *transTable) clear() {
+	clear(t.entries)
+	t.age = 0
+}
+
+// hashfull estimates how full the table is, in permille, from the first
+// thousand slots, counting only entries written by the current search.
+func (t *transTable) hashfull() int {
+	n := min(1000, len(t.entries))
+	used := 0
+	for i := range t.entries[:n] {
+		data := atomic.LoadUint64(&t.entries[i].data)
+		if data != 0 && unpackTT(data).age == t.age {
+			used++
+		}
+	}
+	return used * 1000 / n
+}
+
+// probe returns the entry stored for key, if any.
+func (t *transTable) probe(key uint64) (ttData, bool) {
+	e := &t.entries[key&t.mask]
+	data := atomic.LoadUint64(&e.data)
+	if atomic.LoadUint64(&e.key)^data != key || data == 0 {
+		return ttData{}, false
+	}
+	return unpackTT(data), true
+}
+
+// store records a search result for key, subject to the replacement policy.
+func (t *transTable) store(key uint64, depth, score int, bound ttBound, move *chess.Move) {
+	slot := &t.entries[key&t.mask]
+	oldData := atomic.LoadUint64(&slot.data)
+	if oldData != 0 && atomic.LoadUint64(&slot.key)^oldData != key {
+		old := unpackTT(oldData)
+		if old.age == t.age && old.depth > depth {
+			return
+		}
+	}
+	d := ttData{depth: depth, score: score, bound: bound, age: t.age}
+	if move != nil {
+		d.from, d.to, d.promo = move.S1(), move.S2(), move.Promo()
+	}
+	data := d.pack()
+	atomic.StoreUint64(&slot.key, key^data)
+	atomic.StoreUint64(&slot.data, data)
+}
+
+// ttData is an unpacked entry.
+type ttData struct {
+	from, to chess.Square
+	promo    chess.PieceType
+	bound    ttBound
+	age      uint8
+	depth    int
+	score    int
+}
+
+// hasMove reports whether the entry names a best move.
+func (d ttData) hasMove() bool {
+	return d.from != d.to
+}
+
+func (d ttData) pack() uint64 {
+	return uint64(d.from)&0x3f |
+		(uint64(d.to)&0x3f)<<6 |
+		(uint64(d.promo)&0x7)<<12 |
+		(uint64(d.bound)&0x3)<<15 |
+		(uint64(d.age)&0x7f)<<17 |
+		(uint64(uint8(d.depth)))<<24 |
+		uint64(uint32(int32(d.score)))<<32
+}
+
+func unpackTT(data uint64) ttData {
+	return ttData{
+		from:  chess.Square(data & 0x3f),
+		to:    chess.Square(data >> 6 & 0x3f),
+		promo: chess.PieceType(data >> 12 & 0x7),
+		bound: ttBound(data >> 15 & 0x3),
+		age:   uint8(data >> 17 & 0x7f),
+		depth: int(int8(data >> 24)),
+		score: int(int32(data >> 32)),
+	}
+}
@@ -0,0 +1,246 @@
+package alphabeta
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// The tune command fits the evaluation weights to the results of the games
+// a set of positions were taken from (Texel's method): the evaluation,
+// through a sigmoid, should predict the result. The evaluation is linear in
+// its weights, so each position is traced once and the error is then
+// minimized by gradient descent.
+
+// tuneSample is a traced position: the evaluation is the sum of coef times
+// weight over its features.
+type tuneSample struct {
+	features []tuneFeature
+	result   float64 // 1 for a White win, 0.5 for a draw, 0 for a loss
+}
+
+type tuneFeature struct {
+	index int
+	coef  float64
+}
+
+// TuneMain runs the tune command with its command-line arguments.
+func TuneMain(args []string) error {
+	fs := flag.NewFlagSet("tune", flag.ExitOnError)
+	iterations := fs.Int("iterations", 1000, "gradient descent steps")
+	rate := fs.Float64("rate", 1, "learning rate, in centipawns per step")
+	out := fs.String("out", "evalparams.json", "file to write the tuned parameters to")
+	params := fs.String("params", "", "start from the parameters in this file")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: chessEngine2 tune [flags] <positions file>")
+		fmt.Fprintln(fs.Output(), "Each line holds a FEN and the game result: 1-0, 0-1 or 1/2-1/2.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *params != "" {
+		if err := LoadEvalParams(*params); err != nil {
+			return err
+		}
+	}
+
+	var weights []*int
+	for _, g := range evalParams() {
+		weights = append(weights, g.weights...)
+	}
+	samples, err := loadSamples(fs.Arg(0), weights)
+	if err != nil {
+		return err
+	}
+	if len(samples) == 0 {
+		return fmt.Errorf("%s: no positions", fs.Arg(0))
+	}
+
+	w := make([]float64, len(weights))
+	for i, p := range weights {
+		w[i] = float64(*p)
+	}
+	k := fitScale(samples, w)
+	fmt.Printf("%d positions, K = %.3f, error %.6f\n", len(samples), k, tuneError(samples, w, k))
+
+	descend(samples, w, k, *iterations, *rate, func(i int) {
+		if i%50 == 0 || i == *iterations {
+			fmt.Printf("iteration %d: error %.6f\n", i, tuneError(samples, w, k))
+		}
+	})
+
+	for i, p := range weights {
+		*p = int(math.Round(w[i]))
+	}
+	if err := saveEvalParams(*out); err != nil {
+		return err
+	}
+	fmt.Println("wrote", *out)
+	return nil
+}
+
+// loadSamples reads and traces the positions in path. Blank lines and lines
+// starting with "#" are skipped.
+func loadSamples(path string, weights []*int) ([]tuneSample, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	index := map[*int]int{}
+	for i, p := range weights {
+		index[p] = i
+	}
+
+	var samples []tuneSample
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pos, result, err := parseSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: %w", path, n, err)
+		}
+		samples = append(samples, traceSample(pos, result, index))
+	}
+	return samples, scanner.Err()
+}
+
+// parseSample reads a line such as
+//
+//	rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1 [1/2-1/2]
+//
+// The FEN's move counters are optional, and the result may be written as
+// 1-0, 0-1, 1/2-1/2 or 1.0, 0.5, 0.0, in quotes or brackets.
+func parseSample(line string) (*chess.Position, float64, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 {
+		return nil, 0, fmt.Errorf("expected a FEN and a result")
+	}
+	opt, err := chess.FEN(strings.Join(fields[:4], " ") + " 0 1")
+	if err != nil {
+		return nil, 0, err
+	}
+	pos := chess.NewGame(opt).Position()
+
+	for _, field := range fields[4:] {
+		switch strings.Trim(field, "\"[];") {
+		case "1-0", "1.0":
+			return pos, 1, nil
+		case "0-1", "0.0":
+			return pos, 0, nil
+		case "1/2-1/2", "0.5":
+			return pos, 0.5, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("no game result")
+}
+
+// traceSample evaluates pos once, recording the weights used. The phase
+// blend is folded into the coefficients.
+func traceSample(pos *chess.Position, result float64, index map[*int]int) tuneSample {
+	trace := newEvalTrace()
+	newEvalInfo(pos.Board(), trace).evaluate()
+
+	coefs := map[int]float64{}
+	mgShare := float64(trace.phase) / totalPhase
+	for p, n := range trace.mg {
+		if i, ok := index[p]; ok && n != 0 {
+			coefs[i] += float64(n) * mgShare
+		}
+	}
+	for p, n := range trace.eg {
+		if i, ok := index[p]; ok && n != 0 {
+			coefs[i] += float64(n) * (1 - mgShare)
+		}
+	}
+
+	s := tuneSample{result: result}
+	for i, c := range coefs {
+		s.features = append(s.features, tuneFeature{i, c})
+	}
+	return s
+}
+
+func (s *tuneSample) eval(w []float64) float64 {
+	e := 0.0
+	for _, f := range s.features {
+		e += f.coef * w[f.index]
+	}
+	return e
+}
+
+// sigmoid maps a centipawn score to an expected result; k scales the
+// evaluation to the data.
+func sigmoid(k, score float64) float64 {
+	return 1 / (1 + math.Pow(10, -k*score/400))
+}
+
+// tuneError is the mean squared difference between the predicted and the
+// actual results.
+func tuneError(samples []tuneSample, w []float64, k float64) float64 {
+	sum := 0.0
+	for i := range samples {
+		d := samples[i].result - sigmoid(k, samples[i].eval(w))
+		sum += d * d
+	}
+	return sum / float64(len(samples))
+}
+
+// fitScale finds the k that makes the current weights fit the data best,
+// so the tuner changes their relative sizes rather than their scale.
+func fitScale(samples []tuneSample, w []float64) float64 {
+	lo, hi := 0.0, 4.0
+	for hi-lo > 0.001 {
+		a, b := lo+(hi-lo)/3, hi-(hi-lo)/3
+		if tuneError(samples, w, a) < tuneError(samples, w, b) {
+			hi = b
+		} else {
+			lo = a
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// descend runs Adam on the weights for the given number of steps, calling
+// progress after each.
+func descend(samples []tuneSample, w []float64, k float64, iterations int, rate float64, progress func(int)) {
+	const beta1, beta2, epsilon = 0.9, 0.999, 1e-8
+	m := make([]float64, len(w))
+	v := make([]float64, len(w))
+	grad := make([]float64, len(w))
+	scale := k * math.Ln10 / 400
+
+	for it := 1; it <= iterations; it++ {
+		clear(grad)
+		for i := range samples {
+			s := &samples[i]
+			p := sigmoid(k, s.eval(w))
+			g := -2 * (s.result - p) * p * (1 - p) * scale
+			for _, f := range s.features {
+				grad[f.index] += g * f.coef
+			}
+		}
+		for i := range w {
+			g := grad[i] / float64(len(samples))
+			m[i] = beta1*m[i] + (1-beta1)*g
+			v[i] = beta2*v[i] + (1-beta2)*g*g
+			mHat := m[i] / (1 - math.Pow(beta1, float64(it)))
+			vHat := v[i] / (1 - math.Pow(beta2, float64(it)))
+			w[i] -= rate * mHat / (math.Sqrt(vHat) + epsilon)
+		}
+		progress(it)
+	}
+}
@@ -0,0 +1,171 @@
+// Package alphabeta is the alpha-beta search engine behind chessEngine2.
+// The same Engine serves the UCI binary and in-process arbiters, so both
+// play with one search and one evaluation.
+package alphabeta
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"chessTomorrow/arbiter"
+	"chessTomorrow/board"
+	"chessTomorrow/book"
+	"github.com/notnil/chess"
+)
+
+// Engine is the alpha-beta engine. It speaks UCI through HandleInput, and
+// it implements arbiter.ChessEngine for arbiters that run it in-process.
+type Engine struct {
+	game    *chess.Game
+	tt      *transTable
+	params  searchParams
+	ponder  bool // the UCI Ponder option
+	options *arbiter.Options
+
+	// The opening book: OwnBook, BookFile (empty for the built-in book),
+	// and the book itself once loaded.
+	ownBook  bool
+	bookFile string
+	book     *book.Book
+
+	// The NNUE network from EvalFile, used when UseNNUE is on.
+	network *network
+	useNNUE bool
+
+	// UCI_LimitStrength and UCI_Elo.
+	limitStrength bool
+	elo           int
+
+	// With "debug on", searches dump their tree to debugFile.
+	debug     bool
+	debugFile string
+
+	// The running search, if any.
+	searcher *searcher
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	last        arbiter.SearchInfo  // of the last in-process GetMove
+	negotiation arbiter.Negotiation // for in-process games; see SetNegotiation
+}
+
+// NewEngine returns an engine at the starting position with the default
+// options.
+func NewEngine() *Engine {
+	e := &Engine{game: chess.NewGame(), tt: newTransTable(defaultHashMB), params: defaultParams, ownBook: true, elo: defaultElo, debugFile: defaultDebugFile}
+	e.options = e.newOptions()
+	return e
+}
+
+// HandleInput executes one UCI command, writing the replies to stdout. It
+// returns false on "quit".
+func (e *Engine) HandleInput(input string) bool {
+	switch {
+	case input == "uci":
+		fmt.Println("id name AlphaBetaEngine")
+		fmt.Println("id author You")
+		for _, line := range e.options.UCILines() {
+			fmt.Println(line)
+		}
+		fmt.Println("uciok")
+	case input == "isready":
+		// Loading the book takes a moment; do it before the game starts
+		if e.ownBook {
+			e.openingBook()
+		}
+		fmt.Println("readyok")
+	case input == "ucinewgame":
+		e.stopSearch()
+		e.tt.clear()
+	case strings.HasPrefix(input, "setoption"):
+		e.stopSearch()
+		if err := e.options.SetOption(input); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	case strings.HasPrefix(input, "position"):
+		e.setPosition(input)
+	case input == "go" || strings.HasPrefix(input, "go "):
+		e.startSearch(parseGo(input, e.game.Position().Turn(), e.ponder))
+	case input == "bench" || strings.HasPrefix(input, "bench "):
+		e.stopSearch()
+		depth := 0
+		if fields := strings.Fields(input); len(fields) > 1 {
+			depth, _ = strconv.Atoi(fields[1])
+		}
+		start := time.Now()
+		nodes := e.bench(depth)
+		for _, line := range arbiter.BenchReport(nodes, time.Since(start)) {
+			fmt.Println(line)
+		}
+	case strings.HasPrefix(input, "debug"):
+		e.debug = strings.TrimSpace(strings.TrimPrefix(input, "debug")) != "off"
+	case input == "stop":
+		e.stopSearch()
+	case input == "ponderhit":
+		e.ponderhit()
+	case input == "quit":
+		e.stopSearch()
+		return false
+	}
+	os.Stdout.Sync()
+	return true
+}
+
+func (e *Engine) setPosition(cmd string) {
+	tokens := strings.Fields(cmd)
+	if len(tokens) < 2 {
+		e.game = chess.NewGame()
+		return
+	}
+
+	switch tokens[1] {
+	case "startpos":
+		e.game = chess.NewGame()
+	case "fen":
+		fenParts := []string{}
+		i := 2
+		for i < len(tokens) && tokens[i] != "moves" {
+			fenParts = append(fenParts, tokens[i])
+			i++
+		}
+		fenStr := strings.Join(fenParts, " ")
+		pos, err := board.ParseFEN(fenStr, board.LenientFEN)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "invalid FEN:", err)
+			e.game = chess.NewGame()
+		} else {
+			opt, _ := chess.FEN(pos.String())
+			e.game = chess.NewGame(opt)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "invalid position command:", cmd)
+		return
+	}
+
+	for i, tok := range tokens {
+		if tok == "moves" {
+			e.applyMoves(tokens[i+1:])
+			break
+		}
+	}
+}
+
+// applyMoves plays UCI moves such as "e2e4", "e1g1" (castling) or "e7e8q"
+// on the current game. It stops at the first token that is malformed or
+// illegal, reporting it and keeping the position reached so far.
+func (e *Engine) applyMoves(moves []string) {
+	for _, s := range moves {
+		move, err := board.UCIToMove(e.game.Position(), s)
+		if err == nil {
+			err = e.game.Move(move)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "invalid move:", err)
+			return
+		}
+	}
+}
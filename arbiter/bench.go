@@ -0,0 +1,56 @@
+package arbiter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+// BenchFENs are the positions searched by the "bench" command: openings,
+// middlegames with tactics, and endgames.
+var BenchFENs = []string{
+	"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+	"r1bq1rk1/2p1bppp/p1np1n2/1p2p3/4P3/1BP2N1P/PP1P1PP1/RNBQR1K1 b - - 0 9",
+	"r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 4",
+	"r2q1rk1/pp2bppp/2n1pn2/3p4/2PP4/2N1PN2/PP3PPP/R2QKB1R w KQ - 0 9",
+	"2r3k1/pp3ppp/4p3/3pP3/3P4/P4N2/1q3PPP/R2Q2K1 b - - 0 22",
+	"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+	"8/8/4k3/8/2p5/8/B2K4/8 w - - 0 1",
+	"6k1/5ppp/8/8/8/8/5PPP/3R2K1 w - - 0 1",
+}
+
+// BenchEngine is implemented by engines served by ServeUCI that support
+// the "bench" command. Bench searches BenchFENs to the given depth, or to
+// the engine's default depth if depth is 0, and returns the total number
+// of nodes. The count must not depend on timing, so that it can tell
+// whether a change altered the search.
+type BenchEngine interface {
+	Bench(depth int) (nodes int64)
+}
+
+// BenchPositions returns BenchFENs as positions.
+func BenchPositions() []*chess.Position {
+	positions := make([]*chess.Position, len(BenchFENs))
+	for i, fen := range BenchFENs {
+		opt, err := chess.FEN(fen)
+		if err != nil {
+			panic("arbiter: bad bench FEN: " + err.Error())
+		}
+		positions[i] = chess.NewGame(opt).Position()
+	}
+	return positions
+}
+
+// BenchReport formats the summary printed at the end of "bench".
+func BenchReport(nodes int64, elapsed time.Duration) []string {
+	ms := elapsed.Milliseconds()
+	if ms == 0 {
+		ms = 1
+	}
+	return []string{
+		fmt.Sprintf("Total time (ms) : %d", ms),
+		fmt.Sprintf("Nodes searched  : %d", nodes),
+		fmt.Sprintf("Nodes/second    : %d", nodes*1000/ms),
+	}
+}
@@ -0,0 +1,290 @@
+package arbiter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+)
+
+// featureTimeout is how long an xboard engine gets to list its features
+// after "protover 2"; engines that predate features send none at all.
+const featureTimeout = 2 * time.Second
+
+// cecpBridge lets the adapter play an xboard engine as if it spoke UCI, as
+// Polyglot does the other way round: the adapter's UCI commands become
+// CECP commands, and the engine's CECP replies become UCI lines.
+//
+// Every search starts from "setboard" with the position the adapter sent,
+// so the engine needs the setboard feature, which every engine of the last
+// twenty years has.
+type cecpBridge struct {
+	mu       sync.Mutex
+	toUCI    func(line string) // passes a UCI line on to the adapter
+	toEngine func(line string) // sends a CECP line to the engine
+	closed   bool              // the engine has exited; nothing more is passed on
+	pos      *chess.Position   // the position of the last "position"
+	searched *chess.Position   // the position of the last "go"
+	ping     bool              // the engine answers "ping"
+	pings    int
+	timer    *time.Timer // ends the feature list of an engine that sends none
+	uciok    bool        // "uciok" has been passed on
+}
+
+// emit passes a UCI line on to the adapter unless the engine has exited.
+func (b *cecpBridge) emit(line string) {
+	if !b.closed {
+		b.toUCI(line)
+	}
+}
+
+// close stops passing lines on, once the engine has exited.
+func (b *cecpBridge) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+}
+
+// command translates a UCI command into the CECP commands to send.
+func (b *cecpBridge) command(cmd string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 {
+		return nil
+	}
+	switch fields[0] {
+	case "uci":
+		b.timer = time.AfterFunc(featureTimeout, func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if !b.uciok {
+				b.uciok = true
+				b.emit("uciok")
+			}
+		})
+		return []string{"xboard", "protover 2"}
+	case "isready":
+		if !b.ping {
+			b.emit("readyok")
+			return nil
+		}
+		b.pings++
+		return []string{fmt.Sprintf("ping %d", b.pings)}
+	case "setoption":
+		name, value := optionNameValue(fields[1:])
+		if value == "" {
+			return []string{"option " + name}
+		}
+		return []string{"option " + name + "=" + value}
+	case "ucinewgame":
+		// Thinking output feeds LastSearch; pondering is off as in UCI
+		return []string{"new", "force", "post", "easy"}
+	case "position":
+		pos, err := parsePosition(fields[1:])
+		if err != nil {
+			b.emit("info string " + err.Error())
+			return nil
+		}
+		b.pos = pos
+		return []string{"force", "setboard " + pos.String()}
+	case "go":
+		return b.goCommands(fields[1:])
+	case "stop":
+		return []string{"?"}
+	case "quit":
+		return []string{"quit"}
+	}
+	return nil
+}
+
+// optionNameValue splits the arguments of "setoption" into the option's
+// name and value, either of which may hold spaces.
+func optionNameValue(args []string) (name, value string) {
+	var words [2][]string
+	k := 0
+	for _, arg := range args {
+		switch arg {
+		case "name":
+			k = 0
+		case "value":
+			k = 1
+		default:
+			words[k] = append(words[k], arg)
+		}
+	}
+	return strings.Join(words[0], " "), strings.Join(words[1], " ")
+}
+
+// goCommands translates "go" into the time control, the clocks and "go".
+func (b *cecpBridge) goCommands(args []string) []string {
+	clock, _ := parseGo(args)
+	var cmds []string
+	for i, arg := range args {
+		if i+1 < len(args) && (arg == "depth" || arg == "nodes") {
+			// CECP limits nodes only by rate; a node limit is taken for
+			// the shallowest search, as the adapter means it
+			depth := args[i+1]
+			if arg == "nodes" {
+				depth = "1"
+			}
+			cmds = append(cmds, "sd "+depth)
+		}
+	}
+	turn := chess.White
+	if b.pos != nil {
+		turn = b.pos.Turn()
+	}
+	own, other, inc := clock.WhiteTime, clock.BlackTime, clock.WhiteInc
+	if turn == chess.Black {
+		own, other, inc = clock.BlackTime, clock.WhiteTime, clock.BlackInc
+	}
+	switch {
+	case clock.MoveTime > 0:
+		// st is in whole seconds
+		cmds = append(cmds, fmt.Sprintf("st %d", max(1, int((clock.MoveTime+time.Second-1)/time.Second))))
+	case own > 0 || other > 0:
+		// The base time hardly matters once the clocks are sent
+		base := int(own.Seconds())
+		cmds = append(cmds,
+			fmt.Sprintf("level %d %d:%02d %s", clock.MovesToGo, base/60, base%60, strconv.FormatFloat(inc.Seconds(), 'f', -1, 64)),
+			fmt.Sprintf("time %d", own.Milliseconds()/10),
+			fmt.Sprintf("otim %d", other.Milliseconds()/10))
+	}
+	b.searched = b.pos
+	return append(cmds, "go")
+}
+
+// reply translates a line from the engine into the UCI lines to pass on,
+// which the caller does so as not to block the adapter meanwhile.
+func (b *cecpBridge) reply(line string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	switch {
+	case fields[0] == "feature":
+		return b.features(line)
+	case fields[0] == "pong":
+		return []string{"readyok"}
+	case fields[0] == "move" && len(fields) > 1:
+		// The engine moves in the position it was told to search, and is
+		// in force mode again once the next position is set
+		return []string{"bestmove " + b.uciMove(b.searched, fields[1])}
+	case fields[0] == "resign":
+		return []string{"bestmove resign"}
+	case isThinkingLine(fields):
+		return []string{b.thinking(fields)}
+	}
+	return []string{"info string " + line}
+}
+
+// features takes in a "feature" line, accepting every feature but
+// interrupts by signal, and ends the handshake with done=1.
+func (b *cecpBridge) features(line string) (uci []string) {
+	rest := strings.TrimPrefix(line, "feature")
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		name, after, ok := strings.Cut(rest, "=")
+		if !ok {
+			return uci
+		}
+		var value string
+		if strings.HasPrefix(after, `"`) {
+			value, rest, _ = strings.Cut(after[1:], `"`)
+		} else {
+			value, rest, _ = strings.Cut(after, " ")
+		}
+		name = strings.TrimSpace(name)
+		switch name {
+		case "myname":
+			uci = append(uci, "id name "+value)
+		case "ping":
+			b.ping = value == "1"
+		case "done":
+			// done=0 asks for as long as the engine needs
+			b.timer.Stop()
+			if value != "0" && !b.uciok {
+				b.uciok = true
+				uci = append(uci, "uciok")
+			}
+			continue
+		case "sigint", "sigterm":
+			b.toEngine("rejected " + name)
+			continue
+		}
+		b.toEngine("accepted " + name)
+	}
+	return uci
+}
+
+// isThinkingLine reports whether fields are thinking output: ply, score,
+// time and nodes, then the principal variation.
+func isThinkingLine(fields []string) bool {
+	if len(fields) < 4 {
+		return false
+	}
+	for _, f := range fields[:4] {
+		if _, err := strconv.Atoi(strings.TrimRight(f, ".&")); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// thinking translates thinking output into an info line, with the
+// principal variation, usually in SAN, in UCI notation as far as it can
+// be read.
+func (b *cecpBridge) thinking(fields []string) string {
+	depth, _ := strconv.Atoi(strings.TrimRight(fields[0], ".&"))
+	score, _ := strconv.Atoi(fields[1])
+	centis, _ := strconv.Atoi(fields[2])
+	nodes, _ := strconv.Atoi(fields[3])
+	info := fmt.Sprintf("info depth %d score cp %d time %d nodes %d", depth, score, centis*10, nodes)
+	pos := b.searched
+	var pv []string
+	for _, word := range fields[4:] {
+		if pos == nil {
+			break
+		}
+		// Move numbers and annotations are skipped
+		if strings.HasSuffix(word, ".") || strings.HasPrefix(word, "(") || strings.HasPrefix(word, "{") {
+			continue
+		}
+		move := b.uciMove(pos, word)
+		mv, err := board.UCIToMove(pos, move)
+		if err != nil {
+			break
+		}
+		pv = append(pv, move)
+		pos = pos.Update(mv)
+	}
+	if len(pv) > 0 {
+		info += " pv " + strings.Join(pv, " ")
+	}
+	return info
+}
+
+// uciMove returns a move the engine wrote, in coordinates or SAN, in UCI
+// notation. A move that can't be read is passed on as it is, for the
+// adapter to reject.
+func (b *cecpBridge) uciMove(pos *chess.Position, move string) string {
+	if pos == nil {
+		return move
+	}
+	if mv, err := board.UCIToMove(pos, move); err == nil {
+		return board.MoveToUCI(mv)
+	}
+	if mv, err := (chess.AlgebraicNotation{}).Decode(pos, strings.TrimRight(move, "+#!?")); err == nil {
+		return board.MoveToUCI(mv)
+	}
+	return move
+}
@@ -0,0 +1,38 @@
+package arbiter
+
+import (
+	"fmt"
+	"time"
+)
+
+// ClockState is what an engine is told about the clocks before it moves.
+// The zero value means the game has no time control.
+type ClockState struct {
+	WhiteTime time.Duration
+	BlackTime time.Duration
+	WhiteInc  time.Duration
+	BlackInc  time.Duration
+	MoveTime  time.Duration
+	MovesToGo int // moves until the next time control, 0 if none
+}
+
+// GoCommand renders the clock as a UCI "go" command. Without any time
+// information it falls back to a single-node search.
+func (c ClockState) GoCommand() string {
+	switch {
+	case c.MoveTime > 0:
+		return fmt.Sprintf("go movetime %d", c.MoveTime.Milliseconds())
+	case c.WhiteTime > 0 || c.BlackTime > 0:
+		return fmt.Sprintf("go wtime %d btime %d winc %d binc %d",
+			c.WhiteTime.Milliseconds(), c.BlackTime.Milliseconds(),
+			c.WhiteInc.Milliseconds(), c.BlackInc.Milliseconds()) + c.movesToGo()
+	}
+	return "go nodes 1"
+}
+
+func (c ClockState) movesToGo() string {
+	if c.MovesToGo > 0 {
+		return fmt.Sprintf(" movestogo %d", c.MovesToGo)
+	}
+	return ""
+}
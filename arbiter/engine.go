@@ -0,0 +1,36 @@
+// Package arbiter defines how game-running arbiters talk to chess engines,
+// whether the engine runs in-process or behind a UCI pipe.
+package arbiter
+
+import (
+	"context"
+	"errors"
+
+	"github.com/notnil/chess"
+)
+
+// ChessEngine is anything that can choose a move for the side to move.
+//
+// GetMove must return promptly once ctx is done; the arbiter uses that to
+// cancel engines that exceed their time. The returned move only needs to
+// name the squares (and promotion piece); checking that it is legal is the
+// arbiter's job. Any error means the engine failed to produce a move.
+type ChessEngine interface {
+	GetMove(ctx context.Context, pos *chess.Position, clock ClockState) (*chess.Move, error)
+}
+
+// ErrResign is returned by GetMove when the engine resigns the game.
+var ErrResign = errors.New("arbiter: engine resigns")
+
+// DrawNegotiator is implemented by engines that take part in draw offers.
+// Engines that don't implement it never offer a draw and decline every
+// offer they receive. UCIEngineAdapter and the native engine implement it
+// with a Negotiation.
+type DrawNegotiator interface {
+	// WantsDraw is asked before the engine moves; true offers a draw to
+	// the opponent.
+	WantsDraw(ctx context.Context, pos *chess.Position) bool
+
+	// AcceptDraw is asked when the opponent offers a draw in pos.
+	AcceptDraw(ctx context.Context, pos *chess.Position) bool
+}
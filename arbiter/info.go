@@ -0,0 +1,93 @@
+package arbiter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SearchInfo is what an engine reported about a search in its "info"
+// lines: the latest values of the fields it sent.
+type SearchInfo struct {
+	Depth    int
+	Nodes    int64
+	HasScore bool
+	Score    int // centipawns, from the engine's point of view
+	Mate     int // moves to mate, negative when getting mated; 0 if Score applies
+	PV       []string
+}
+
+// SearchReporter is implemented by engines that can tell what the search
+// behind their last move found.
+type SearchReporter interface {
+	LastSearch() SearchInfo
+}
+
+// Update takes the fields of one "info" line into si and reports whether
+// line was an info line. Lines about other MultiPV lines than the first,
+// and "info string" text, are left out.
+func (si *SearchInfo) Update(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != "info" {
+		return false
+	}
+	for i := 1; i+1 < len(fields); i++ {
+		if fields[i] == "string" {
+			return true
+		}
+		if fields[i] == "multipv" && fields[i+1] != "1" {
+			return true
+		}
+	}
+
+	for i := 1; i+1 < len(fields); i++ {
+		switch fields[i] {
+		case "depth":
+			si.Depth, _ = strconv.Atoi(fields[i+1])
+		case "nodes":
+			si.Nodes, _ = strconv.ParseInt(fields[i+1], 10, 64)
+		case "score":
+			if i+2 >= len(fields) {
+				continue
+			}
+			n, err := strconv.Atoi(fields[i+2])
+			if err != nil {
+				continue
+			}
+			si.HasScore = true
+			switch fields[i+1] {
+			case "cp":
+				si.Score, si.Mate = n, 0
+			case "mate":
+				si.Mate = n
+			}
+		case "pv":
+			si.PV = append(si.PV[:0], fields[i+1:]...)
+			return true
+		}
+	}
+	return true
+}
+
+// String renders the score and depth the way match logs and PGN comments
+// usually do: "+0.35/12", or "+M3/12" for a mate in three.
+func (si SearchInfo) String() string {
+	if !si.HasScore {
+		return ""
+	}
+	if si.Mate != 0 {
+		sign := "+"
+		if si.Mate < 0 {
+			sign = "-"
+		}
+		return fmt.Sprintf("%sM%d/%d", sign, abs(si.Mate), si.Depth)
+	}
+	return fmt.Sprintf("%+.2f/%d", float64(si.Score)/100, si.Depth)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
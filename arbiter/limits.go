@@ -0,0 +1,78 @@
+package arbiter
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// limitPoll is how often a limited engine's resource use is checked.
+const limitPoll = 100 * time.Millisecond
+
+// Limits caps what an engine process may use, so that a runaway engine
+// can't take the host down with it. An engine that goes over a limit is
+// killed, which forfeits the game it is playing. Zero fields are no limit.
+type Limits struct {
+	// CPUTime is the CPU time the process may use from its start, over
+	// all its threads. Where the system has rlimits, the kernel also
+	// enforces it, a second later.
+	CPUTime time.Duration
+
+	// Memory is the most resident memory the process may use, in bytes.
+	// Only the engine process itself counts, not processes it starts.
+	Memory int64
+
+	// WallTime is the longest a single search may take, from "go" to
+	// "bestmove", however the engine treats "stop".
+	WallTime time.Duration
+}
+
+// watch kills proc once it goes over e's limits, until exited is closed,
+// and records which limit it went over.
+func (e *UCIEngineAdapter) watch(proc *os.Process, exited <-chan struct{}) {
+	tick := time.NewTicker(limitPoll)
+	defer tick.Stop()
+	for {
+		select {
+		case <-exited:
+			return
+		case <-tick.C:
+		}
+
+		var exceeded error
+		cpu, rss, err := processUsage(proc.Pid)
+		switch {
+		case err != nil:
+			// Exited between the tick and the reading, or the system
+			// can't tell; the wall-clock limit still holds
+		case e.limits.CPUTime > 0 && cpu > e.limits.CPUTime:
+			exceeded = fmt.Errorf("used %v of CPU time, over its limit of %v", cpu.Round(time.Millisecond), e.limits.CPUTime)
+		case e.limits.Memory > 0 && rss > e.limits.Memory:
+			exceeded = fmt.Errorf("used %d MB of memory, over its limit of %d MB", rss>>20, e.limits.Memory>>20)
+		}
+		if start := e.searchStart.Load(); exceeded == nil && e.limits.WallTime > 0 && start != 0 {
+			if took := time.Since(time.Unix(0, start)); took > e.limits.WallTime {
+				exceeded = fmt.Errorf("searched for %v, over its limit of %v", took.Round(time.Millisecond), e.limits.WallTime)
+			}
+		}
+		if exceeded != nil {
+			e.limitMu.Lock()
+			e.exceeded = exceeded
+			e.limitMu.Unlock()
+			e.logLine("!", "killed: "+exceeded.Error())
+			proc.Kill()
+			return
+		}
+	}
+}
+
+// exitError says why the engine process exited: the limit it went over,
+// if the adapter killed it for that.
+func (e *UCIEngineAdapter) exitError() error {
+	e.limitMu.Lock()
+	defer e.limitMu.Unlock()
+	if e.exceeded != nil {
+		return e.exceeded
+	}
+	return errEngineExited
+}
@@ -0,0 +1,52 @@
+package arbiter
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// usageSupported says whether processUsage can read CPU time and memory.
+const usageSupported = true
+
+// clockTicks is the unit of the CPU times in /proc/<pid>/stat, USER_HZ,
+// which is 100 on every Linux platform Go supports.
+const clockTicks = 100
+
+// setCPULimit has the kernel end the process once it has used cpu, as a
+// backstop to watch.
+func setCPULimit(pid int, cpu time.Duration) error {
+	secs := uint64((cpu + time.Second).Seconds())
+	return unix.Prlimit(pid, unix.RLIMIT_CPU, &unix.Rlimit{Cur: secs, Max: secs + 1}, nil)
+}
+
+// processUsage reads the CPU time and resident memory of a process from
+// /proc.
+func processUsage(pid int) (cpu time.Duration, rss int64, err error) {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, 0, err
+	}
+	// The command name in parentheses may hold spaces; the fields after it
+	// start with the state, field 3
+	i := strings.LastIndexByte(string(stat), ')')
+	if i < 0 {
+		return 0, 0, fmt.Errorf("malformed /proc/%d/stat", pid)
+	}
+	fields := strings.Fields(string(stat[i+1:]))
+	if len(fields) < 22 {
+		return 0, 0, fmt.Errorf("malformed /proc/%d/stat", pid)
+	}
+	utime, err1 := strconv.ParseInt(fields[11], 10, 64) // field 14
+	stime, err2 := strconv.ParseInt(fields[12], 10, 64) // field 15
+	pages, err3 := strconv.ParseInt(fields[21], 10, 64) // field 24
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0, 0, fmt.Errorf("malformed /proc/%d/stat", pid)
+	}
+	cpu = time.Duration(utime+stime) * time.Second / clockTicks
+	return cpu, pages * int64(os.Getpagesize()), nil
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package arbiter
+
+import (
+	"errors"
+	"time"
+)
+
+// Without /proc and prlimit only the wall-clock limit can be enforced.
+const usageSupported = false
+
+func setCPULimit(pid int, cpu time.Duration) error {
+	return nil
+}
+
+func processUsage(pid int) (cpu time.Duration, rss int64, err error) {
+	return 0, 0, errors.New("resource use is not available on this system")
+}
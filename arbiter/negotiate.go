@@ -0,0 +1,84 @@
+package arbiter
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// Negotiation decides draw offers and resignations for engines that can't
+// make them themselves, UCI having no words for either, from the scores
+// their searches report. A rule with zero moves is off, so the zero
+// Negotiation never offers, accepts or resigns.
+type Negotiation struct {
+	// From move DrawMoves on, the engine offers a draw while its score is
+	// within DrawMargin centipawns of zero, and accepts one while its
+	// score is no better than DrawMargin.
+	DrawMoves  int
+	DrawMargin int
+
+	// The engine resigns once its score has been ResignScore centipawns
+	// or more below zero, or a mate against it, for ResignMoves moves in a
+	// row.
+	ResignMoves int
+	ResignScore int
+
+	hopeless int // moves in a row that counted towards resigning
+}
+
+// DefaultNegotiation is what the match runner's -negotiate applies: draws
+// in level endgames and middlegames after move 40, and resigning after
+// five moves eight pawns down.
+var DefaultNegotiation = Negotiation{DrawMoves: 40, DrawMargin: 10, ResignMoves: 5, ResignScore: 800}
+
+// Negotiable is implemented by engines whose draw offers and resignations
+// a Negotiation decides. SetNegotiation also starts counting towards
+// resigning afresh, so it is called at the start of every game.
+type Negotiable interface {
+	SetNegotiation(n Negotiation)
+}
+
+// WantsDraw reports whether an engine whose last search found info offers
+// a draw before moving in pos.
+func (n *Negotiation) WantsDraw(pos *chess.Position, info SearchInfo) bool {
+	return n.drawn(pos, info) && abs(info.Score) <= n.DrawMargin
+}
+
+// AcceptsDraw reports whether an engine whose last search found info
+// accepts a draw offered in pos.
+func (n *Negotiation) AcceptsDraw(pos *chess.Position, info SearchInfo) bool {
+	return n.drawn(pos, info) && info.Score <= n.DrawMargin
+}
+
+// drawn reports whether draws may be agreed in pos at all on info.
+func (n *Negotiation) drawn(pos *chess.Position, info SearchInfo) bool {
+	return n.DrawMoves > 0 && moveNumber(pos) >= n.DrawMoves && info.HasScore && info.Mate == 0
+}
+
+// Resigns takes the search behind the engine's move and reports whether
+// the engine resigns instead of playing it. A move without a score, from
+// a book, leaves the count as it was.
+func (n *Negotiation) Resigns(info SearchInfo) bool {
+	switch {
+	case !info.HasScore:
+	case info.Mate < 0 || info.Mate == 0 && info.Score <= -n.ResignScore:
+		n.hopeless++
+	default:
+		n.hopeless = 0
+	}
+	return n.ResignMoves > 0 && n.hopeless >= n.ResignMoves
+}
+
+// moveNumber returns the full move number of pos, from its FEN.
+func moveNumber(pos *chess.Position) int {
+	fields := strings.Fields(pos.String())
+	if len(fields) < 6 {
+		return 1
+	}
+	n, err := strconv.Atoi(fields[5])
+	if err != nil {
+		return 1
+	}
+	return n
+}
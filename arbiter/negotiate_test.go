@@ -0,0 +1,74 @@
+package arbiter
+
+import (
+	"testing"
+
+	"github.com/notnil/chess"
+)
+
+func TestNegotiationDraws(t *testing.T) {
+	early := position(t, "8/8/4k3/8/8/4K3/8/8 w - - 0 12")
+	late := position(t, "8/8/4k3/8/8/4K3/8/8 w - - 0 41")
+	n := DefaultNegotiation
+	tests := []struct {
+		pos           *chess.Position
+		info          SearchInfo
+		offer, accept bool
+	}{
+		{late, SearchInfo{HasScore: true, Score: 5}, true, true},
+		{late, SearchInfo{HasScore: true, Score: -40}, false, true},
+		{late, SearchInfo{HasScore: true, Score: 40}, false, false},
+		{late, SearchInfo{HasScore: true, Mate: -3}, false, false},
+		{late, SearchInfo{}, false, false},
+		{early, SearchInfo{HasScore: true}, false, false},
+	}
+	for i, tt := range tests {
+		if got := n.WantsDraw(tt.pos, tt.info); got != tt.offer {
+			t.Errorf("%d: WantsDraw = %v, want %v", i, got, tt.offer)
+		}
+		if got := n.AcceptsDraw(tt.pos, tt.info); got != tt.accept {
+			t.Errorf("%d: AcceptsDraw = %v, want %v", i, got, tt.accept)
+		}
+	}
+	var off Negotiation
+	if off.WantsDraw(late, SearchInfo{HasScore: true}) || off.AcceptsDraw(late, SearchInfo{HasScore: true}) {
+		t.Error("the zero Negotiation agreed a draw")
+	}
+}
+
+func TestNegotiationResigns(t *testing.T) {
+	lost := SearchInfo{HasScore: true, Score: -900}
+	n := Negotiation{ResignMoves: 3, ResignScore: 800}
+	steps := []struct {
+		info   SearchInfo
+		resign bool
+	}{
+		{lost, false},
+		{lost, false},
+		{SearchInfo{HasScore: true, Score: -100}, false}, // starts the count again
+		{lost, false},
+		{SearchInfo{}, false}, // a book move leaves it
+		{SearchInfo{HasScore: true, Mate: -4}, false},
+		{lost, true},
+	}
+	for i, st := range steps {
+		if got := n.Resigns(st.info); got != st.resign {
+			t.Errorf("move %d: Resigns = %v, want %v", i+1, got, st.resign)
+		}
+	}
+	var off Negotiation
+	for range 10 {
+		if off.Resigns(lost) {
+			t.Fatal("the zero Negotiation resigned")
+		}
+	}
+}
+
+func position(t *testing.T, fen string) *chess.Position {
+	t.Helper()
+	opt, err := chess.FEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chess.NewGame(opt).Position()
+}
@@ -0,0 +1,182 @@
+package arbiter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OptionType is the UCI type of an engine option.
+type OptionType string
+
+const (
+	Spin   OptionType = "spin"
+	Check  OptionType = "check"
+	String OptionType = "string"
+	Combo  OptionType = "combo"
+	Button OptionType = "button"
+)
+
+// Option describes one UCI option. Set is called with the new value once it
+// has been validated against the type: a decimal number within [Min, Max]
+// for spin, "true" or "false" for check, one of Vars for combo, and the
+// empty string for button.
+type Option struct {
+	Name    string
+	Type    OptionType
+	Default string
+	Min     int
+	Max     int
+	Vars    []string
+	Set     func(value string)
+}
+
+// Options is an engine's option registry. It prints the "option" lines of
+// the uci handshake and applies "setoption" commands.
+type Options struct {
+	list   []*Option
+	values map[string]string // by lower-case name
+}
+
+// OptionsEngine is implemented by engines served by ServeUCI that have
+// options.
+type OptionsEngine interface {
+	Options() *Options
+}
+
+// NewOptions returns an empty registry.
+func NewOptions() *Options {
+	return &Options{values: map[string]string{}}
+}
+
+// Add registers an option. Options are listed in the order they were added.
+func (o *Options) Add(opt Option) {
+	o.list = append(o.list, &opt)
+	o.values[strings.ToLower(opt.Name)] = opt.Default
+}
+
+// AddSpin registers an integer option.
+func (o *Options) AddSpin(name string, def, min, max int, set func(int)) {
+	o.Add(Option{Name: name, Type: Spin, Default: strconv.Itoa(def), Min: min, Max: max,
+		Set: func(v string) { n, _ := strconv.Atoi(v); set(n) }})
+}
+
+// AddCheck registers a boolean option.
+func (o *Options) AddCheck(name string, def bool, set func(bool)) {
+	o.Add(Option{Name: name, Type: Check, Default: strconv.FormatBool(def),
+		Set: func(v string) { set(v == "true") }})
+}
+
+// AddString registers a free-form string option.
+func (o *Options) AddString(name, def string, set func(string)) {
+	o.Add(Option{Name: name, Type: String, Default: def, Set: set})
+}
+
+// AddCombo registers an option restricted to one of vars.
+func (o *Options) AddCombo(name, def string, vars []string, set func(string)) {
+	o.Add(Option{Name: name, Type: Combo, Default: def, Vars: vars, Set: set})
+}
+
+// UCILines returns the "option name ..." lines to print after the id lines.
+func (o *Options) UCILines() []string {
+	lines := make([]string, 0, len(o.list))
+	for _, opt := range o.list {
+		line := fmt.Sprintf("option name %s type %s", opt.Name, opt.Type)
+		switch opt.Type {
+		case Spin:
+			line += fmt.Sprintf(" default %s min %d max %d", opt.Default, opt.Min, opt.Max)
+		case Check:
+			line += " default " + opt.Default
+		case String:
+			def := opt.Default
+			if def == "" {
+				def = "<empty>"
+			}
+			line += " default " + def
+		case Combo:
+			line += " default " + opt.Default
+			for _, v := range opt.Vars {
+				line += " var " + v
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Value returns the current value of the named option, or "" if there is
+// no such option.
+func (o *Options) Value(name string) string {
+	return o.values[strings.ToLower(name)]
+}
+
+// SetOption applies a "setoption name <id> [value <x>]" command. Names are
+// matched case-insensitively and may contain spaces.
+func (o *Options) SetOption(cmd string) error {
+	tokens := strings.Fields(cmd)
+	if len(tokens) < 3 || tokens[0] != "setoption" || tokens[1] != "name" {
+		return fmt.Errorf("malformed setoption command %q", cmd)
+	}
+	tokens = tokens[2:]
+	nameEnd := len(tokens)
+	for i, tok := range tokens {
+		if tok == "value" {
+			nameEnd = i
+			break
+		}
+	}
+	name := strings.Join(tokens[:nameEnd], " ")
+	value := ""
+	if nameEnd < len(tokens) {
+		value = strings.Join(tokens[nameEnd+1:], " ")
+	}
+	return o.set(name, value)
+}
+
+func (o *Options) set(name, value string) error {
+	var opt *Option
+	for _, candidate := range o.list {
+		if strings.EqualFold(candidate.Name, name) {
+			opt = candidate
+		}
+	}
+	if opt == nil {
+		return fmt.Errorf("no such option: %s", name)
+	}
+
+	switch opt.Type {
+	case Spin:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < opt.Min || n > opt.Max {
+			return fmt.Errorf("option %s: value %q is not a number in [%d, %d]", opt.Name, value, opt.Min, opt.Max)
+		}
+		value = strconv.Itoa(n)
+	case Check:
+		value = strings.ToLower(value)
+		if value != "true" && value != "false" {
+			return fmt.Errorf("option %s: value %q is not true or false", opt.Name, value)
+		}
+	case String:
+		if value == "<empty>" {
+			value = ""
+		}
+	case Combo:
+		found := false
+		for _, v := range opt.Vars {
+			if strings.EqualFold(v, value) {
+				value, found = v, true
+			}
+		}
+		if !found {
+			return fmt.Errorf("option %s: value %q is not one of %s", opt.Name, value, strings.Join(opt.Vars, ", "))
+		}
+	case Button:
+		value = ""
+	}
+
+	o.values[strings.ToLower(opt.Name)] = value
+	if opt.Set != nil {
+		opt.Set(value)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package arbiter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// DefaultRegistry is the registry file read when none is named. It may
+// be missing, and then no engine has a name.
+const DefaultRegistry = "engines.json"
+
+// The protocols an engine can speak.
+const (
+	ProtocolUCI    = "uci"    // a binary that speaks UCI over stdin and stdout
+	ProtocolXBoard = "xboard" // a binary that speaks CECP over stdin and stdout
+	ProtocolGRPC   = "grpc"   // an engine served over gRPC at host:port
+)
+
+// grpcScheme starts the engine names that are served over gRPC rather
+// than run, as the match package's players take them.
+const grpcScheme = "grpc://"
+
+// Registry names engines and how to start them, so that command lines and
+// other files can name an engine rather than spell out its command. It is
+// read from a JSON file:
+//
+//	{"engines": [
+//	    {"name": "Maia 1900", "command": "./maia1900.sh"},
+//	    {"name": "Stockfish", "command": "stockfish", "options": {"Threads": "4"}},
+//	    {"name": "Crafty", "command": "crafty", "protocol": "xboard"},
+//	    {"name": "Remote", "command": "host:50051", "protocol": "grpc"}
+//	]}
+//
+// Relative commands and working directories are taken from the file's
+// directory. Fields for other programs, such as the web arbiter's levels,
+// are left for them to read.
+type Registry struct {
+	Engines []EngineSpec `json:"engines"`
+}
+
+// EngineSpec is how to start one engine.
+type EngineSpec struct {
+	Name     string            `json:"name"`
+	Command  string            `json:"command"`
+	Args     []string          `json:"args,omitempty"`
+	Dir      string            `json:"dir,omitempty"`      // working directory; the arbiter's if empty
+	Protocol string            `json:"protocol,omitempty"` // ProtocolUCI if empty
+	Options  map[string]string `json:"options,omitempty"`  // UCI options set whenever the engine starts
+
+	// Path is read as Command, as older web arbiter registries have it.
+	Path string `json:"path,omitempty"`
+}
+
+// LoadRegistry reads a registry file. A missing DefaultRegistry is an
+// empty registry.
+func LoadRegistry(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && path == DefaultRegistry {
+		return &Registry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var r Registry
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	for i := range r.Engines {
+		if err := r.Engines[i].resolve(dir); err != nil {
+			return nil, fmt.Errorf("%s: engine %d: %w", path, i+1, err)
+		}
+		if r.Find(r.Engines[i].Name) != &r.Engines[i] {
+			return nil, fmt.Errorf("%s: two engines are called %q", path, r.Engines[i].Name)
+		}
+	}
+	return &r, nil
+}
+
+// resolve checks s and makes its paths relative to dir.
+func (s *EngineSpec) resolve(dir string) error {
+	if s.Command == "" {
+		s.Command, s.Path = s.Path, ""
+	}
+	if s.Name == "" || s.Command == "" {
+		return errors.New("an engine needs a name and a command")
+	}
+	switch s.Protocol {
+	case "":
+		s.Protocol = ProtocolUCI
+	case ProtocolUCI, ProtocolXBoard, ProtocolGRPC:
+	default:
+		return fmt.Errorf("%s: unknown protocol %q", s.Name, s.Protocol)
+	}
+	// Bare commands are looked up on PATH, as a shell would
+	if s.Protocol != ProtocolGRPC && strings.ContainsRune(s.Command, filepath.Separator) && !filepath.IsAbs(s.Command) {
+		s.Command = filepath.Join(dir, s.Command)
+	}
+	if s.Dir != "" && !filepath.IsAbs(s.Dir) {
+		s.Dir = filepath.Join(dir, s.Dir)
+	}
+	return nil
+}
+
+// Find returns the named engine, or nil.
+func (r *Registry) Find(name string) *EngineSpec {
+	if r == nil {
+		return nil
+	}
+	for i := range r.Engines {
+		if r.Engines[i].Name == name {
+			return &r.Engines[i]
+		}
+	}
+	return nil
+}
+
+// Lookup returns the named engine, or for a name the registry doesn't
+// have, an engine whose command is the name: a UCI binary, or one served
+// over gRPC for grpc://host:port.
+func (r *Registry) Lookup(name string) EngineSpec {
+	if s := r.Find(name); s != nil {
+		return *s
+	}
+	if addr, ok := strings.CutPrefix(name, grpcScheme); ok {
+		return EngineSpec{Name: name, Command: addr, Protocol: ProtocolGRPC}
+	}
+	return EngineSpec{Name: name, Command: name, Protocol: ProtocolUCI}
+}
+
+// Start starts a UCI or xboard engine with its options set, killing it if
+// it goes over limits.
+func (s EngineSpec) Start(limits Limits) (*UCIEngineAdapter, error) {
+	if s.Protocol == ProtocolGRPC {
+		return nil, fmt.Errorf("arbiter: %s is served over gRPC, not run", s.Name)
+	}
+	if (limits.CPUTime > 0 || limits.Memory > 0) && !usageSupported {
+		return nil, errors.New("arbiter: CPU and memory limits are not available on this system")
+	}
+	eng := &UCIEngineAdapter{Name: s.Command, path: s.Command, args: s.Args, dir: s.Dir, xboard: s.Protocol == ProtocolXBoard, limits: limits}
+	if err := eng.start(); err != nil {
+		return nil, err
+	}
+	// In a fixed order, for engines whose options depend on each other
+	names := make([]string, 0, len(s.Options))
+	for name := range s.Options {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if err := eng.SetOption(name, s.Options[name]); err != nil {
+			eng.Close()
+			return nil, fmt.Errorf("%s: option %s: %w", s.Name, name, err)
+		}
+	}
+	return eng, nil
+}
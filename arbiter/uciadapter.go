@@ -0,0 +1,418 @@
+package arbiter
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+// stopGrace is how long a cancelled engine gets to answer "stop" with its
+// bestmove before the adapter gives up on it.
+const stopGrace = time.Second
+
+// handshakeTimeout bounds the wait for "uciok" and "readyok".
+const handshakeTimeout = 10 * time.Second
+
+// quitTimeout is how long Close waits for the engine to exit after "quit"
+// before killing it.
+const quitTimeout = time.Second
+
+var errEngineExited = errors.New("engine exited")
+
+// UCIEngineAdapter runs an external UCI binary (Stockfish, lc0/Maia, or one
+// of the engines in this repo) and implements ChessEngine on top of it. It
+// plays xboard engines too, through a bridge that translates the dialogue
+// between UCI and CECP.
+type UCIEngineAdapter struct {
+	Name        string
+	path        string
+	args        []string
+	dir         string
+	xboard      bool        // the engine speaks CECP, through bridge
+	bridge      *cecpBridge // for the running process
+	cmd         *exec.Cmd
+	stdinMu     sync.Mutex // the bridge writes too
+	stdin       io.WriteCloser
+	lines       chan string   // engine output, closed when the process exits
+	exited      chan struct{} // closed when the process's output ends
+	last        SearchInfo    // from the info lines of the last search
+	negotiation Negotiation   // draw offers and resignations, off unless set
+	broken      bool          // the engine exited or stopped answering
+
+	options [][2]string // name and latest value of each SetOption, for Restart
+
+	limits      Limits
+	searchStart atomic.Int64 // UnixNano of the running search's "go", 0 for none
+	limitMu     sync.Mutex
+	exceeded    error // the limit the process was killed for
+
+	logMu sync.Mutex
+	log   io.Writer // see SetLog
+}
+
+// NewUCIEngineAdapter starts the engine at path with the given arguments
+// and performs the uci/isready handshake.
+func NewUCIEngineAdapter(path string, args ...string) (*UCIEngineAdapter, error) {
+	eng := &UCIEngineAdapter{Name: path, path: path, args: args}
+	if err := eng.start(); err != nil {
+		return nil, err
+	}
+	return eng, nil
+}
+
+// Broken reports whether the engine has exited or ignored a "stop", so
+// that it can't be trusted with another move until it is restarted.
+func (e *UCIEngineAdapter) Broken() bool {
+	select {
+	case <-e.exited:
+		return true
+	default:
+		return e.broken
+	}
+}
+
+// Restart kills the engine process and starts a fresh one, with the
+// options set so far.
+func (e *UCIEngineAdapter) Restart() error {
+	e.Close()
+	if err := e.start(); err != nil {
+		return err
+	}
+	for _, opt := range e.options {
+		if err := e.sendOption(opt[0], opt[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Options returns the options set so far, in the order they were first
+// set, with their latest values.
+func (e *UCIEngineAdapter) Options() [][2]string {
+	return slices.Clone(e.options)
+}
+
+// SetOption sends "setoption" for the named option and waits until the
+// engine is ready again. value is ignored for button options. Setting an
+// option again replaces the value a restart sends.
+func (e *UCIEngineAdapter) SetOption(name, value string) error {
+	i := slices.IndexFunc(e.options, func(opt [2]string) bool { return opt[0] == name })
+	if i < 0 {
+		e.options = append(e.options, [2]string{name, value})
+	} else {
+		e.options[i][1] = value
+	}
+	return e.sendOption(name, value)
+}
+
+func (e *UCIEngineAdapter) sendOption(name, value string) error {
+	if value == "" {
+		e.Send("setoption name " + name)
+	} else {
+		e.Send("setoption name " + name + " value " + value)
+	}
+	e.Send("isready")
+	return e.Expect("readyok")
+}
+
+// SetLog records the engine's side of the dialogue in w from now on:
+// every command sent (">"), every line received ("<") and every line it
+// writes to stderr ("!"), timed and tagged with the engine's name. With a
+// nil w nothing is recorded and stderr goes to the arbiter's own.
+func (e *UCIEngineAdapter) SetLog(w io.Writer) {
+	e.logMu.Lock()
+	defer e.logMu.Unlock()
+	e.log = w
+}
+
+// logLine records one line of the dialogue, and reports whether there was
+// a log to record it in.
+func (e *UCIEngineAdapter) logLine(dir, line string) bool {
+	e.logMu.Lock()
+	defer e.logMu.Unlock()
+	if e.log == nil {
+		return false
+	}
+	fmt.Fprintf(e.log, "%s %s %s %s\n", time.Now().Format("15:04:05.000"), e.Name, dir, line)
+	return true
+}
+
+// start launches the process and performs the handshake.
+func (e *UCIEngineAdapter) start() error {
+	cmd := exec.Command(e.path, e.args...)
+	cmd.Dir = e.dir
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	lines := make(chan string, 256)
+	e.cmd, e.stdin, e.lines, e.broken = cmd, stdin, lines, false
+	e.bridge = nil
+	if e.xboard {
+		e.bridge = &cecpBridge{toUCI: func(line string) { lines <- line }, toEngine: e.write}
+	}
+	e.limitMu.Lock()
+	e.exceeded = nil
+	e.limitMu.Unlock()
+	exited := make(chan struct{})
+	e.exited = exited
+	if e.limits != (Limits{}) {
+		if e.limits.CPUTime > 0 {
+			if err := setCPULimit(cmd.Process.Pid, e.limits.CPUTime); err != nil {
+				cmd.Process.Kill()
+				cmd.Wait()
+				return fmt.Errorf("arbiter: limiting %s: %w", e.path, err)
+			}
+		}
+		go e.watch(cmd.Process, exited)
+	}
+
+	// Read output in the background so GetMove can give up on a silent
+	// engine instead of blocking on the pipe.
+	go func(bridge *cecpBridge) {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			e.logLine("<", scanner.Text())
+			if bridge == nil {
+				lines <- scanner.Text()
+				continue
+			}
+			for _, line := range bridge.reply(scanner.Text()) {
+				lines <- line
+			}
+		}
+		if bridge != nil {
+			bridge.close()
+		}
+		close(exited)
+		close(lines)
+	}(e.bridge)
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			if !e.logLine("!", scanner.Text()) {
+				fmt.Fprintln(os.Stderr, scanner.Text())
+			}
+		}
+	}()
+
+	e.Send("uci")
+	if err := e.readID(); err != nil {
+		e.Close()
+		return err
+	}
+
+	e.Send("isready")
+	if err := e.Expect("readyok"); err != nil {
+		e.Close()
+		return err
+	}
+
+	e.Send("ucinewgame")
+
+	return nil
+}
+
+// Send writes one command line to the engine. For an xboard engine the
+// command is UCI, and what it translates to is sent.
+func (e *UCIEngineAdapter) Send(cmd string) {
+	if e.bridge == nil {
+		e.write(cmd)
+		return
+	}
+	for _, line := range e.bridge.command(cmd) {
+		e.write(line)
+	}
+}
+
+// write writes one line to the engine as it is.
+func (e *UCIEngineAdapter) write(line string) {
+	e.logLine(">", line)
+	e.stdinMu.Lock()
+	defer e.stdinMu.Unlock()
+	fmt.Fprintf(e.stdin, "%s\n", line)
+}
+
+// Lines returns the engine's output, for a caller that carries on a
+// dialogue of its own, such as an infinite analysis. The channel is closed
+// when the process exits; Restart makes a new one.
+func (e *UCIEngineAdapter) Lines() <-chan string {
+	return e.lines
+}
+
+// Expect reads output until a line containing substr. It gives up after
+// handshakeTimeout.
+func (e *UCIEngineAdapter) Expect(substr string) error {
+	return e.expect(substr, func(string) {})
+}
+
+// readID waits for uciok, picking up the engine's "id name" on the way.
+func (e *UCIEngineAdapter) readID() error {
+	return e.expect("uciok", func(line string) {
+		if strings.HasPrefix(line, "id name ") {
+			e.Name = strings.TrimPrefix(line, "id name ")
+		}
+	})
+}
+
+func (e *UCIEngineAdapter) expect(substr string, seen func(line string)) error {
+	deadline := time.After(handshakeTimeout)
+	for {
+		select {
+		case line, ok := <-e.lines:
+			if !ok {
+				e.broken = true
+				return fmt.Errorf("arbiter: %s exited while waiting for %q: %w", e.Name, substr, e.exitError())
+			}
+			seen(line)
+			if strings.Contains(line, substr) {
+				return nil
+			}
+		case <-deadline:
+			e.broken = true
+			return fmt.Errorf("arbiter: %s did not send %q within %v", e.Name, substr, handshakeTimeout)
+		}
+	}
+}
+
+// GetMove implements ChessEngine by sending "position fen" and a "go"
+// command built from the clock. It returns ErrResign if an xboard engine
+// resigns, or the Negotiation set has it resign on the search's score
+// instead of playing its move. If ctx is done before the engine answers,
+// it sends "stop" and swallows the late bestmove so it cannot be mistaken
+// for the answer to the next position. The info lines the engine sends on
+// the way are kept for LastSearch.
+func (e *UCIEngineAdapter) GetMove(ctx context.Context, pos *chess.Position, clock ClockState) (*chess.Move, error) {
+	move, err := e.BestMove(ctx, "position fen "+pos.String(), clock.GoCommand())
+	if err != nil {
+		return nil, err
+	}
+	if move == "resign" && e.bridge != nil || e.negotiation.Resigns(e.last) {
+		return nil, ErrResign
+	}
+	return chess.UCINotation{}.Decode(nil, move)
+}
+
+// BestMove sends the position and go commands as they are and returns the
+// move of the engine's "bestmove", in UCI, keeping the info lines on the
+// way for LastSearch. If ctx is done before the engine answers, it sends
+// "stop" and swallows the late bestmove, waiting for it no longer than a
+// second; an engine that doesn't send it by then is Broken.
+func (e *UCIEngineAdapter) BestMove(ctx context.Context, position, goCmd string) (string, error) {
+	e.last = SearchInfo{}
+	e.Send(position)
+	e.Send(goCmd)
+	e.searchStart.Store(time.Now().UnixNano())
+	defer e.searchStart.Store(0)
+
+	for {
+		select {
+		case line, ok := <-e.lines:
+			if !ok {
+				e.broken = true
+				return "", e.exitError()
+			}
+			if e.last.Update(line) || !strings.HasPrefix(line, "bestmove") {
+				continue
+			}
+			parts := strings.Fields(line)
+			if len(parts) < 2 {
+				return "", fmt.Errorf("malformed reply %q", line)
+			}
+			return parts[1], nil
+		case <-ctx.Done():
+			e.Send("stop")
+			e.drainBestMove(stopGrace)
+			return "", ctx.Err()
+		}
+	}
+}
+
+// SetNegotiation implements Negotiable: from now on the engine offers and
+// accepts draws and resigns as n decides on the scores it reports.
+func (e *UCIEngineAdapter) SetNegotiation(n Negotiation) {
+	e.negotiation = n
+}
+
+// WantsDraw implements DrawNegotiator.
+func (e *UCIEngineAdapter) WantsDraw(ctx context.Context, pos *chess.Position) bool {
+	return e.negotiation.WantsDraw(pos, e.last)
+}
+
+// AcceptDraw implements DrawNegotiator.
+func (e *UCIEngineAdapter) AcceptDraw(ctx context.Context, pos *chess.Position) bool {
+	return e.negotiation.AcceptsDraw(pos, e.last)
+}
+
+// LastSearch implements SearchReporter.
+func (e *UCIEngineAdapter) LastSearch() SearchInfo {
+	return e.last
+}
+
+func (e *UCIEngineAdapter) drainBestMove(timeout time.Duration) {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case line, ok := <-e.lines:
+			if !ok {
+				e.broken = true
+				return
+			}
+			if strings.HasPrefix(line, "bestmove") {
+				return
+			}
+		case <-deadline:
+			e.broken = true
+			return
+		}
+	}
+}
+
+// Close asks the engine to quit and waits for it to exit, killing it if it
+// is still running after quitTimeout. Either way the process is reaped.
+func (e *UCIEngineAdapter) Close() {
+	e.Send("quit")
+	e.stdin.Close()
+
+	// Nobody reads the output any more; keep the reader from blocking
+	go func(lines chan string) {
+		for range lines {
+		}
+	}(e.lines)
+
+	exited := make(chan struct{})
+	go func() {
+		e.cmd.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(quitTimeout):
+		e.cmd.Process.Kill()
+		<-exited
+	}
+}
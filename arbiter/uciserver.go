@@ -0,0 +1,303 @@
+package arbiter
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+)
+
+// NamedEngine is implemented by engines that want to report their own
+// "id name" and "id author" over UCI.
+type NamedEngine interface {
+	Name() string
+	Author() string
+}
+
+// NewGameEngine is implemented by engines that keep state between moves and
+// want to reset it on "ucinewgame".
+type NewGameEngine interface {
+	NewGame()
+}
+
+// RunUCI speaks the UCI protocol on stdin/stdout for any ChessEngine, so an
+// in-process engine can be used from cutechess, Arena or the match runner.
+// If the first command is "xboard" it speaks CECP instead, as ServeXBoard.
+func RunUCI(engine ChessEngine) error {
+	return ServeUCI(engine, os.Stdin, os.Stdout)
+}
+
+// ServeUCI is RunUCI over arbitrary streams. It returns when it reads
+// "quit" or the input ends.
+func ServeUCI(engine ChessEngine, in io.Reader, out io.Writer) error {
+	s := &uciServer{
+		engine: engine,
+		out:    out,
+		pos:    chess.StartingPosition(),
+	}
+	defer s.stopSearch()
+
+	scanner := bufio.NewScanner(in)
+	for first := true; scanner.Scan(); first = false {
+		line := strings.TrimSpace(scanner.Text())
+		if first && line == "xboard" {
+			return serveXBoard(engine, scanner, out)
+		}
+		if !s.handle(line) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+type uciServer struct {
+	engine ChessEngine
+	pos    *chess.Position
+
+	outMu sync.Mutex
+	out   io.Writer
+
+	// Set while a search goroutine is running.
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// Set while pondering: the "go" arguments to search with on
+	// "ponderhit", and a channel closed then to drop the ponder search's
+	// move.
+	ponder []string
+	hit    chan struct{}
+}
+
+func (s *uciServer) println(a ...any) {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	fmt.Fprintln(s.out, a...)
+}
+
+// handle processes one command line and reports false on "quit".
+func (s *uciServer) handle(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return true
+	}
+
+	switch fields[0] {
+	case "uci":
+		name, author := "ChessEngine", "unknown"
+		if named, ok := s.engine.(NamedEngine); ok {
+			name, author = named.Name(), named.Author()
+		}
+		s.println("id name", name)
+		s.println("id author", author)
+		if oe, ok := s.engine.(OptionsEngine); ok {
+			for _, line := range oe.Options().UCILines() {
+				s.println(line)
+			}
+		}
+		s.println("uciok")
+	case "isready":
+		s.println("readyok")
+	case "setoption":
+		s.stopSearch()
+		oe, ok := s.engine.(OptionsEngine)
+		if !ok {
+			s.println("info string engine has no options")
+			return true
+		}
+		if err := oe.Options().SetOption(line); err != nil {
+			s.println("info string", err)
+		}
+	case "ucinewgame":
+		s.stopSearch()
+		if ng, ok := s.engine.(NewGameEngine); ok {
+			ng.NewGame()
+		}
+	case "position":
+		s.stopSearch()
+		pos, err := parsePosition(fields[1:])
+		if err != nil {
+			s.println("info string", err)
+			return true
+		}
+		s.pos = pos
+	case "go":
+		s.stopSearch()
+		s.startSearch(fields[1:])
+	case "bench":
+		s.stopSearch()
+		be, ok := s.engine.(BenchEngine)
+		if !ok {
+			s.println("info string engine does not support bench")
+			return true
+		}
+		depth := 0
+		if len(fields) > 1 {
+			depth, _ = strconv.Atoi(fields[1])
+		}
+		start := time.Now()
+		nodes := be.Bench(depth)
+		for _, line := range BenchReport(nodes, time.Since(start)) {
+			s.println(line)
+		}
+	case "ponderhit":
+		// The opponent played the move pondered on: search the same
+		// position again, now on the clock
+		if s.ponder == nil {
+			return true
+		}
+		args := s.ponder
+		close(s.hit)
+		s.stopSearch()
+		s.startSearch(args)
+	case "stop":
+		s.stopSearch()
+	case "quit":
+		return false
+	}
+	return true
+}
+
+// parsePosition handles the arguments of "position startpos|fen ... [moves ...]".
+func parsePosition(args []string) (*chess.Position, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("position: missing startpos or fen")
+	}
+
+	var pos *chess.Position
+	rest := args[1:]
+	switch args[0] {
+	case "startpos":
+		pos = chess.StartingPosition()
+	case "fen":
+		i := 0
+		for i < len(rest) && rest[i] != "moves" {
+			i++
+		}
+		var err error
+		pos, err = board.ParseFEN(strings.Join(rest[:i], " "), board.LenientFEN)
+		if err != nil {
+			return nil, err
+		}
+		rest = rest[i:]
+	default:
+		return nil, fmt.Errorf("position: unknown type %q", args[0])
+	}
+
+	if len(rest) > 0 && rest[0] == "moves" {
+		for _, s := range rest[1:] {
+			mv, err := board.UCIToMove(pos, s)
+			if err != nil {
+				return nil, err
+			}
+			pos = pos.Update(mv)
+		}
+	}
+	return pos, nil
+}
+
+// parseGo turns the arguments of "go" into a clock for the engine. It also
+// reports whether the search is "infinite" (or pondering) and must wait
+// for "stop" or "ponderhit".
+func parseGo(args []string) (clock ClockState, infinite bool) {
+	ms := func(i int) time.Duration {
+		if i+1 >= len(args) {
+			return 0
+		}
+		n, _ := strconv.Atoi(args[i+1])
+		return time.Duration(n) * time.Millisecond
+	}
+
+	for i, arg := range args {
+		switch arg {
+		case "wtime":
+			clock.WhiteTime = ms(i)
+		case "btime":
+			clock.BlackTime = ms(i)
+		case "winc":
+			clock.WhiteInc = ms(i)
+		case "binc":
+			clock.BlackInc = ms(i)
+		case "movetime":
+			clock.MoveTime = ms(i)
+		case "movestogo":
+			if i+1 < len(args) {
+				clock.MovesToGo, _ = strconv.Atoi(args[i+1])
+			}
+		case "infinite", "ponder":
+			infinite = true
+		}
+	}
+	return clock, infinite
+}
+
+func (s *uciServer) startSearch(args []string) {
+	pos := s.pos
+	clock, infinite := parseGo(args)
+	var hit chan struct{}
+	if i := slices.Index(args, "ponder"); i >= 0 {
+		hit = make(chan struct{})
+		s.ponder, s.hit = slices.Delete(slices.Clone(args), i, i+1), hit
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if clock.MoveTime > 0 && !infinite {
+		ctx, cancel = context.WithTimeout(context.Background(), clock.MoveTime)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	done := make(chan struct{})
+	s.cancel, s.done = cancel, done
+
+	go func() {
+		defer close(done)
+		mv, err := s.engine.GetMove(ctx, pos, clock)
+		if infinite {
+			// UCI forbids answering an infinite search before "stop".
+			<-ctx.Done()
+		}
+		select {
+		case <-hit:
+			// Pondering ended in a ponderhit; the search on the clock
+			// answers instead
+			return
+		default:
+		}
+		s.println("bestmove", bestMoveOrFallback(pos, mv, err))
+	}()
+}
+
+// stopSearch cancels a running search and waits for its bestmove.
+func (s *uciServer) stopSearch() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+	s.cancel, s.done = nil, nil
+	s.ponder, s.hit = nil, nil
+}
+
+// bestMoveOrFallback returns the engine's move if it is legal, and otherwise
+// any legal move, since a UCI engine has to answer with something.
+func bestMoveOrFallback(pos *chess.Position, mv *chess.Move, err error) string {
+	if err == nil && mv != nil {
+		if legal, err := board.UCIToMove(pos, board.MoveToUCI(mv)); err == nil {
+			return board.MoveToUCI(legal)
+		}
+	}
+	moves := pos.ValidMoves()
+	if len(moves) == 0 {
+		return "0000"
+	}
+	return board.MoveToUCI(moves[0])
+}
@@ -0,0 +1,105 @@
+package arbiter
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+// firstMoveEngine plays the first legal move at once, counting its
+// searches.
+type firstMoveEngine struct{ searches int }
+
+func (e *firstMoveEngine) GetMove(ctx context.Context, pos *chess.Position, clock ClockState) (*chess.Move, error) {
+	e.searches++
+	return pos.ValidMoves()[0], nil
+}
+
+// uciSession serves engine over pipes, returning a function that sends a
+// command and the engine's output lines.
+func uciSession(t *testing.T, engine ChessEngine) (send func(string), lines chan string) {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	go func() {
+		ServeUCI(engine, inR, outW)
+		outW.Close()
+	}()
+	lines = make(chan string, 16)
+	go func() {
+		scanner := bufio.NewScanner(outR)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	t.Cleanup(func() { inW.Close() })
+	return func(cmd string) { fmt.Fprintln(inW, cmd) }, lines
+}
+
+// expectLine waits for a line starting with prefix, failing on any other
+// bestmove first.
+func expectLine(t *testing.T, lines chan string, prefix string) string {
+	t.Helper()
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case line := <-lines:
+			if strings.HasPrefix(line, prefix) {
+				return line
+			}
+			if strings.HasPrefix(line, "bestmove") {
+				t.Fatalf("got %q waiting for %q", line, prefix)
+			}
+		case <-deadline:
+			t.Fatalf("no %q", prefix)
+		}
+	}
+}
+
+// TestPonderHit checks that a ponder search waits for the GUI, and that
+// "ponderhit" turns it into a search on the clock that answers by itself.
+func TestPonderHit(t *testing.T) {
+	engine := &firstMoveEngine{}
+	send, lines := uciSession(t, engine)
+	send("position startpos moves e2e4")
+	send("go ponder wtime 60000 btime 60000")
+	send("isready")
+	expectLine(t, lines, "readyok")
+	select {
+	case line := <-lines:
+		t.Fatalf("%q while pondering", line)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	send("ponderhit")
+	if got := expectLine(t, lines, "bestmove"); got != "bestmove "+firstReply(t) {
+		t.Errorf("%q after ponderhit", got)
+	}
+	send("isready")
+	expectLine(t, lines, "readyok")
+	if engine.searches != 2 {
+		t.Errorf("%d searches, want the ponder search and one on the clock", engine.searches)
+	}
+
+	// Without pondering "ponderhit" is ignored
+	send("ponderhit")
+	send("isready")
+	expectLine(t, lines, "readyok")
+}
+
+// firstReply is the move firstMoveEngine answers 1. e4 with.
+func firstReply(t *testing.T) string {
+	t.Helper()
+	pos, err := parsePosition(strings.Fields("startpos moves e2e4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pos.ValidMoves()[0].String()
+}
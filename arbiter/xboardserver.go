@@ -0,0 +1,377 @@
+package arbiter
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+)
+
+// ServeXBoard speaks the xboard protocol (CECP, version 2) on in and out
+// for any ChessEngine, so that an in-process engine can play in xboard,
+// WinBoard or any other program that only speaks CECP. It returns when it
+// reads "quit" or the input ends.
+func ServeXBoard(engine ChessEngine, in io.Reader, out io.Writer) error {
+	return serveXBoard(engine, bufio.NewScanner(in), out)
+}
+
+// serveXBoard is ServeXBoard on lines already being read, as ServeUCI
+// hands over when the first command is "xboard".
+func serveXBoard(engine ChessEngine, lines *bufio.Scanner, out io.Writer) error {
+	s := &xboardServer{engine: engine, out: out, pos: chess.StartingPosition()}
+	defer s.stopSearch(true)
+	for lines.Scan() {
+		if !s.handle(strings.TrimSpace(lines.Text())) {
+			return nil
+		}
+	}
+	return lines.Err()
+}
+
+type xboardServer struct {
+	engine ChessEngine
+
+	outMu sync.Mutex
+	out   io.Writer
+
+	// The game, which a search updates with its move
+	mu      sync.Mutex
+	pos     *chess.Position
+	history []*chess.Position // the positions before pos, for undo
+	force   bool              // play neither side, only take moves
+	post    bool              // show thinking
+
+	// The time control and clocks
+	movesPerControl int
+	base, inc       time.Duration
+	moveTime        time.Duration
+	own, opponent   time.Duration
+
+	// Set while a search goroutine is running
+	cancel  context.CancelFunc
+	done    chan struct{}
+	discard bool // the search's move is not to be played
+}
+
+func (s *xboardServer) println(a ...any) {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	fmt.Fprintln(s.out, a...)
+}
+
+// handle processes one command line and reports false on "quit".
+func (s *xboardServer) handle(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return true
+	}
+	arg := strings.TrimSpace(strings.TrimPrefix(line, fields[0]))
+	switch fields[0] {
+	case "protover":
+		s.features()
+	case "new":
+		s.stopSearch(true)
+		s.mu.Lock()
+		s.pos, s.history, s.force = chess.StartingPosition(), nil, false
+		s.moveTime, s.own, s.opponent = 0, 0, 0
+		s.mu.Unlock()
+		if ng, ok := s.engine.(NewGameEngine); ok {
+			ng.NewGame()
+		}
+	case "force", "result":
+		s.stopSearch(true)
+		s.force = true
+	case "go":
+		s.stopSearch(true)
+		s.force = false
+		s.startSearch()
+	case "playother":
+		s.stopSearch(true)
+		s.force = false
+	case "?":
+		s.stopSearch(false)
+	case "setboard":
+		s.stopSearch(true)
+		pos, err := board.ParseFEN(arg, board.LenientFEN)
+		if err != nil {
+			s.println("tellusererror Illegal position:", err)
+			return true
+		}
+		s.mu.Lock()
+		s.pos, s.history = pos, nil
+		s.mu.Unlock()
+	case "usermove":
+		s.userMove(arg)
+	case "undo", "remove":
+		s.stopSearch(true)
+		n := 1
+		if fields[0] == "remove" {
+			n = 2
+		}
+		s.mu.Lock()
+		for ; n > 0 && len(s.history) > 0; n-- {
+			s.pos, s.history = s.history[len(s.history)-1], s.history[:len(s.history)-1]
+		}
+		s.mu.Unlock()
+	case "ping":
+		s.println("pong", arg)
+	case "level":
+		s.level(fields[1:])
+	case "st":
+		seconds, _ := strconv.ParseFloat(arg, 64)
+		s.moveTime = time.Duration(seconds * float64(time.Second))
+	case "time", "otim":
+		centis, _ := strconv.Atoi(arg)
+		if fields[0] == "time" {
+			s.own = time.Duration(centis) * 10 * time.Millisecond
+		} else {
+			s.opponent = time.Duration(centis) * 10 * time.Millisecond
+		}
+	case "post":
+		s.post = true
+	case "nopost":
+		s.post = false
+	case "option":
+		s.setOption(arg)
+	case "quit":
+		return false
+	default:
+		// A bare move, from interfaces that ignore the usermove feature
+		s.mu.Lock()
+		_, err := board.UCIToMove(s.pos, fields[0])
+		s.mu.Unlock()
+		if err == nil {
+			s.userMove(fields[0])
+		}
+		// Everything else (xboard, accepted, hard, easy, draw, ...)
+		// needs no answer
+	}
+	return true
+}
+
+// features announces what the server understands.
+func (s *xboardServer) features() {
+	name := "ChessEngine"
+	if named, ok := s.engine.(NamedEngine); ok {
+		name = named.Name()
+	}
+	s.println(fmt.Sprintf("feature myname=%q setboard=1 usermove=1 ping=1 playother=1 colors=0 analyze=0 sigint=0 sigterm=0 reuse=1 done=0", name))
+	if oe, ok := s.engine.(OptionsEngine); ok {
+		for _, line := range oe.Options().UCILines() {
+			if opt, ok := cecpOption(line); ok {
+				s.println(fmt.Sprintf("feature option=%q", opt))
+			}
+		}
+	}
+	s.println("feature done=1")
+}
+
+// cecpOption turns a UCI "option" line into CECP's description of the
+// option, such as "Hash -spin 16 1 1024".
+func cecpOption(line string) (string, bool) {
+	var name, typ, def, min, max []string
+	var vars [][]string
+	var cur *[]string
+	for _, word := range strings.Fields(line)[1:] {
+		switch word {
+		case "name":
+			cur = &name
+		case "type":
+			cur = &typ
+		case "default":
+			cur = &def
+		case "min":
+			cur = &min
+		case "max":
+			cur = &max
+		case "var":
+			vars = append(vars, nil)
+			cur = &vars[len(vars)-1]
+		default:
+			if cur != nil {
+				*cur = append(*cur, word)
+			}
+		}
+	}
+	join := func(words []string) string { return strings.Join(words, " ") }
+	if len(name) == 0 || len(typ) == 0 {
+		return "", false
+	}
+	switch typ[0] {
+	case "spin":
+		return fmt.Sprintf("%s -spin %s %s %s", join(name), join(def), join(min), join(max)), true
+	case "check":
+		value := "0"
+		if join(def) == "true" {
+			value = "1"
+		}
+		return fmt.Sprintf("%s -check %s", join(name), value), true
+	case "string":
+		value := join(def)
+		if value == "<empty>" {
+			value = ""
+		}
+		return fmt.Sprintf("%s -string %s", join(name), value), true
+	case "button":
+		return join(name) + " -button", true
+	case "combo":
+		var choices []string
+		for _, v := range vars {
+			choice := join(v)
+			if choice == join(def) {
+				choice = "*" + choice
+			}
+			choices = append(choices, choice)
+		}
+		return fmt.Sprintf("%s -combo %s", join(name), strings.Join(choices, " /// ")), true
+	}
+	return "", false
+}
+
+// setOption sets an option from "option NAME=VALUE" or "option NAME".
+func (s *xboardServer) setOption(arg string) {
+	oe, ok := s.engine.(OptionsEngine)
+	if !ok {
+		s.println("telluser engine has no options")
+		return
+	}
+	s.stopSearch(true)
+	name, value, hasValue := strings.Cut(arg, "=")
+	cmd := "setoption name " + name
+	if hasValue {
+		cmd += " value " + value
+	}
+	if err := oe.Options().SetOption(cmd); err != nil {
+		s.println("telluser", err)
+	}
+}
+
+// level reads "level MPS BASE INC", BASE being minutes or minutes:seconds.
+func (s *xboardServer) level(args []string) {
+	if len(args) < 3 {
+		return
+	}
+	s.movesPerControl, _ = strconv.Atoi(args[0])
+	minutes, seconds, _ := strings.Cut(args[1], ":")
+	m, _ := strconv.Atoi(minutes)
+	sec, _ := strconv.Atoi(seconds)
+	s.base = time.Duration(m)*time.Minute + time.Duration(sec)*time.Second
+	inc, _ := strconv.ParseFloat(args[2], 64)
+	s.inc = time.Duration(inc * float64(time.Second))
+	s.moveTime = 0
+}
+
+// userMove plays the opponent's move and, unless in force mode, answers.
+func (s *xboardServer) userMove(uci string) {
+	s.stopSearch(true)
+	s.mu.Lock()
+	mv, err := board.UCIToMove(s.pos, uci)
+	if err != nil {
+		s.mu.Unlock()
+		s.println("Illegal move:", uci)
+		return
+	}
+	s.history = append(s.history, s.pos)
+	s.pos = s.pos.Update(mv)
+	s.mu.Unlock()
+	if !s.force {
+		s.startSearch()
+	}
+}
+
+// clock returns the clocks for the engine to move in pos.
+func (s *xboardServer) clock(pos *chess.Position) ClockState {
+	if s.moveTime > 0 {
+		return ClockState{MoveTime: s.moveTime}
+	}
+	own, opponent := s.own, s.opponent
+	if own == 0 && opponent == 0 {
+		// No "time" yet: the whole base is left
+		own, opponent = s.base, s.base
+	}
+	clock := ClockState{WhiteTime: own, BlackTime: opponent, WhiteInc: s.inc, BlackInc: s.inc}
+	if pos.Turn() == chess.Black {
+		clock.WhiteTime, clock.BlackTime = opponent, own
+	}
+	if s.movesPerControl > 0 {
+		fields := strings.Fields(pos.String())
+		moveNumber, _ := strconv.Atoi(fields[len(fields)-1])
+		clock.MovesToGo = s.movesPerControl - (moveNumber-1)%s.movesPerControl
+	}
+	return clock
+}
+
+func (s *xboardServer) startSearch() {
+	s.mu.Lock()
+	pos := s.pos
+	s.mu.Unlock()
+	if len(pos.ValidMoves()) == 0 {
+		return
+	}
+	clock := s.clock(pos)
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if clock.MoveTime > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), clock.MoveTime)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	done := make(chan struct{})
+	s.cancel, s.done, s.discard = cancel, done, false
+
+	start := time.Now()
+	go func() {
+		defer close(done)
+		mv, err := s.engine.GetMove(ctx, pos, clock)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.discard || s.pos != pos {
+			return
+		}
+		if errors.Is(err, ErrResign) {
+			s.println("resign")
+			return
+		}
+		move := bestMoveOrFallback(pos, mv, err)
+		legal, err := board.UCIToMove(pos, move)
+		if err != nil {
+			return
+		}
+		if reporter, ok := s.engine.(SearchReporter); ok && s.post {
+			if info := reporter.LastSearch(); info.Depth > 0 {
+				score := info.Score
+				if info.Mate > 0 {
+					score = 100000 - info.Mate
+				} else if info.Mate < 0 {
+					score = -100000 - info.Mate
+				}
+				s.println(info.Depth, score, time.Since(start).Milliseconds()/10, info.Nodes, strings.Join(info.PV, " "))
+			}
+		}
+		s.history = append(s.history, s.pos)
+		s.pos = pos.Update(legal)
+		s.println("move", move)
+	}()
+}
+
+// stopSearch ends a running search and waits for it, playing its move
+// unless discard is set.
+func (s *xboardServer) stopSearch(discard bool) {
+	if s.cancel == nil {
+		return
+	}
+	s.mu.Lock()
+	s.discard = discard
+	s.mu.Unlock()
+	s.cancel()
+	<-s.done
+	s.cancel, s.done = nil, nil
+}
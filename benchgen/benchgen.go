@@ -0,0 +1,208 @@
+// Package benchgen measures the board core that every engine and arbiter
+// here stands on: generating the legal moves of a position, checking a
+// move, making and unmaking moves, and perft. Each measurement is a
+// Workload, timed by the benchgen command and by the package's Go
+// benchmarks (go test -bench . ./benchgen) alike.
+//
+// The board core is github.com/notnil/chess, wrapped by the board package:
+// generating moves is Position.ValidMoves, checking a move is
+// board.UCIToMove, and as positions are immutable, making a move is
+// Position.Update and unmaking it is going back to the position before.
+package benchgen
+
+import (
+	"fmt"
+	"time"
+
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+)
+
+// perftDepth is how deep the Perft workload counts from each position.
+const perftDepth = 3
+
+// Position is a position of the suite with its published perft counts.
+type Position struct {
+	Name  string
+	FEN   string
+	Perft [perftDepth + 1]int64 // by depth
+}
+
+// Suite is the standard set of perft positions from the Chess Programming
+// Wiki: between them they have every kind of move, castling through and
+// out of check, en passant pins and promotions.
+var Suite = []Position{
+	{"initial", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", [...]int64{1, 20, 400, 8902}},
+	{"kiwipete", "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", [...]int64{1, 48, 2039, 97862}},
+	{"position 3", "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", [...]int64{1, 14, 191, 2812}},
+	{"position 4", "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", [...]int64{1, 6, 264, 9467}},
+	{"position 5", "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", [...]int64{1, 44, 1486, 62379}},
+	{"position 6", "r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10", [...]int64{1, 46, 2079, 89890}},
+}
+
+// positions returns the suite's positions, new so that none has its moves
+// generated yet.
+func positions() []*chess.Position {
+	pos := make([]*chess.Position, len(Suite))
+	for i, p := range Suite {
+		opt, err := chess.FEN(p.FEN)
+		if err != nil {
+			panic("benchgen: bad FEN of " + p.Name + ": " + err.Error())
+		}
+		pos[i] = chess.NewGame(opt).Position()
+	}
+	return pos
+}
+
+// Check runs perft from every position of the suite to every depth up to
+// perftDepth and returns an error if a count differs from the published
+// one, since timing a move generator that is wrong tells nothing.
+func Check() error {
+	for i, p := range positions() {
+		for depth := 1; depth <= perftDepth; depth++ {
+			if n := board.Perft(p, depth); n != Suite[i].Perft[depth] {
+				return fmt.Errorf("perft %d of %s: %d nodes, want %d", depth, Suite[i].Name, n, Suite[i].Perft[depth])
+			}
+		}
+	}
+	return nil
+}
+
+// Workload is one of the package's measurements. Prepare returns one
+// iteration of it, which fails if the board core gives a wrong answer, and
+// how many nodes the iteration goes through.
+type Workload struct {
+	Name    string
+	Prepare func() (run func() error, nodes int64)
+	// Fresh is set when an iteration spends what Prepare made, so that
+	// every iteration needs its own, prepared outside the timing.
+	Fresh bool
+}
+
+// Workloads are the package's measurements, cheapest first.
+var Workloads = []Workload{
+	{"GenerateValidMoves", generateValidMoves, true},
+	{"IsValidMove", isValidMove, false},
+	{"DoMoveUndoMove", doMoveUndoMove, false},
+	{"Perft", perft, false},
+}
+
+// Result is what Measure found.
+type Result struct {
+	N       int           // iterations
+	Elapsed time.Duration // spent in them, not counting preparation
+	Nodes   int64         // gone through in them
+}
+
+// NsPerOp returns the time an iteration took.
+func (r Result) NsPerOp() int64 {
+	if r.N == 0 {
+		return 0
+	}
+	return r.Elapsed.Nanoseconds() / int64(r.N)
+}
+
+// NodesPerSecond returns how many nodes a second the iterations went
+// through.
+func (r Result) NodesPerSecond() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Nodes) / r.Elapsed.Seconds()
+}
+
+// Measure runs w's iterations until they have taken d between them.
+func (w Workload) Measure(d time.Duration) (Result, error) {
+	var r Result
+	run, nodes := w.Prepare()
+	for r.Elapsed < d {
+		if w.Fresh && r.N > 0 {
+			run, nodes = w.Prepare()
+		}
+		start := time.Now()
+		err := run()
+		r.Elapsed += time.Since(start)
+		if err != nil {
+			return r, fmt.Errorf("%s: %w", w.Name, err)
+		}
+		r.N++
+		r.Nodes += nodes
+	}
+	return r, nil
+}
+
+// generateValidMoves generates the legal moves of every position of the
+// suite; the nodes are the positions. A position keeps the moves it
+// generated, so every iteration needs new positions.
+func generateValidMoves() (func() error, int64) {
+	pos := positions()
+	return func() error {
+		for _, p := range pos {
+			p.ValidMoves()
+		}
+		return nil
+	}, int64(len(pos))
+}
+
+// isValidMove checks every legal move of every position of the suite, and
+// a move that is illegal in all of them, as the arbiters check the moves
+// engines send; the nodes are the moves checked.
+func isValidMove() (func() error, int64) {
+	pos := positions()
+	var moves [][]string
+	var nodes int64
+	for _, p := range pos {
+		var uci []string
+		for _, m := range p.ValidMoves() {
+			uci = append(uci, board.MoveToUCI(m))
+		}
+		moves = append(moves, append(uci, "a1a1"))
+		nodes += int64(len(uci) + 1)
+	}
+	return func() error {
+		for i, p := range pos {
+			for _, m := range moves[i] {
+				board.UCIToMove(p, m)
+			}
+		}
+		return nil
+	}, nodes
+}
+
+// doMoveUndoMove makes and unmakes every legal move of every position of
+// the suite; the nodes are the moves.
+func doMoveUndoMove() (func() error, int64) {
+	pos := positions()
+	var nodes int64
+	for _, p := range pos {
+		nodes += int64(len(p.ValidMoves()))
+	}
+	return func() error {
+		for _, p := range pos {
+			for _, m := range p.ValidMoves() {
+				// Undoing is going on from p again
+				p.Update(m)
+			}
+		}
+		return nil
+	}, nodes
+}
+
+// perft runs perft to perftDepth from every position of the suite and
+// fails if a count differs from the published one; the nodes are the
+// leaves counted.
+func perft() (func() error, int64) {
+	pos := positions()
+	var nodes int64
+	for _, p := range Suite {
+		nodes += p.Perft[perftDepth]
+	}
+	return func() error {
+		for i, p := range pos {
+			if n := board.Perft(p, perftDepth); n != Suite[i].Perft[perftDepth] {
+				return fmt.Errorf("perft %d of %s: %d nodes, want %d", perftDepth, Suite[i].Name, n, Suite[i].Perft[perftDepth])
+			}
+		}
+		return nil
+	}, nodes
+}
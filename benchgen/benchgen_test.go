@@ -0,0 +1,34 @@
+package benchgen
+
+import "testing"
+
+func TestCheck(t *testing.T) {
+	if err := Check(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// benchmark runs w under b, preparing it again outside the timer for
+// every iteration if it is Fresh.
+func benchmark(b *testing.B, w Workload) {
+	run, nodes := w.Prepare()
+	b.ResetTimer()
+	for i := range b.N {
+		if w.Fresh && i > 0 {
+			b.StopTimer()
+			run, nodes = w.Prepare()
+			b.StartTimer()
+		}
+		if err := run(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if s := b.Elapsed().Seconds(); s > 0 {
+		b.ReportMetric(float64(nodes)*float64(b.N)/s, "nodes/s")
+	}
+}
+
+func BenchmarkGenerateValidMoves(b *testing.B) { benchmark(b, Workloads[0]) }
+func BenchmarkIsValidMove(b *testing.B)        { benchmark(b, Workloads[1]) }
+func BenchmarkDoMoveUndoMove(b *testing.B)     { benchmark(b, Workloads[2]) }
+func BenchmarkPerft(b *testing.B)              { benchmark(b, Workloads[3]) }
@@ -0,0 +1,37 @@
+package benchgen
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// BenchMain checks the perft counts of the suite, then measures the
+// workloads whose names match -bench and prints the time and nodes a
+// second of each, as go test -bench would.
+func BenchMain(args []string) error {
+	fs := flag.NewFlagSet("benchgen", flag.ExitOnError)
+	pattern := fs.String("bench", ".", "run only the workloads whose names match this regular expression")
+	benchTime := fs.Duration("benchtime", time.Second, "time to run each workload for")
+	fs.Parse(args)
+	match, err := regexp.Compile(*pattern)
+	if err != nil {
+		return err
+	}
+
+	if err := Check(); err != nil {
+		return err
+	}
+	for _, w := range Workloads {
+		if !match.MatchString(w.Name) {
+			continue
+		}
+		r, err := w.Measure(*benchTime)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-20s %10d %14d ns/op %14.0f nodes/s\n", w.Name, r.N, r.NsPerOp(), r.NodesPerSecond())
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package board
+
+import "github.com/notnil/chess"
+
+// Directions as (file, rank) steps.
+var (
+	knightSteps = [8][2]int{{1, 2}, {2, 1}, {2, -1}, {1, -2}, {-1, -2}, {-2, -1}, {-2, 1}, {-1, 2}}
+	kingSteps   = [8][2]int{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}}
+	rookSteps   = [4][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+	bishopSteps = [4][2]int{{1, 1}, {-1, 1}, {-1, -1}, {1, -1}}
+)
+
+// mailbox copies the board into an array indexed by square.
+func mailbox(b *chess.Board) [64]chess.Piece {
+	var sqs [64]chess.Piece
+	for sq, p := range b.SquareMap() {
+		sqs[sq] = p
+	}
+	return sqs
+}
+
+// offset returns the square df files and dr ranks away from sq, or false if
+// that falls off the board.
+func offset(sq chess.Square, df, dr int) (chess.Square, bool) {
+	f, r := int(sq.File())+df, int(sq.Rank())+dr
+	if f < 0 || f > 7 || r < 0 || r > 7 {
+		return chess.NoSquare, false
+	}
+	return chess.Square(r*8 + f), true
+}
+
+// attackersTo returns a bitboard (bit i set for square i) of the pieces of
+// color by that attack sq.
+func attackersTo(sqs *[64]chess.Piece, sq chess.Square, by chess.Color) uint64 {
+	var bb uint64
+	add := func(s chess.Square) { bb |= 1 << uint(s) }
+	is := func(s chess.Square, types ...chess.PieceType) bool {
+		p := sqs[s]
+		if p == chess.NoPiece || p.Color() != by {
+			return false
+		}
+		for _, t := range types {
+			if p.Type() == t {
+				return true
+			}
+		}
+		return false
+	}
+
+	// A white pawn attacks diagonally upwards, so it sits one rank below.
+	pawnRank := -1
+	if by == chess.Black {
+		pawnRank = 1
+	}
+	for _, df := range []int{-1, 1} {
+		if s, ok := offset(sq, df, pawnRank); ok && is(s, chess.Pawn) {
+			add(s)
+		}
+	}
+
+	for _, st := range knightSteps {
+		if s, ok := offset(sq, st[0], st[1]); ok && is(s, chess.Knight) {
+			add(s)
+		}
+	}
+	for _, st := range kingSteps {
+		if s, ok := offset(sq, st[0], st[1]); ok && is(s, chess.King) {
+			add(s)
+		}
+	}
+
+	slide := func(steps [4][2]int, types ...chess.PieceType) {
+		for _, st := range steps {
+			s, ok := offset(sq, st[0], st[1])
+			for ok {
+				if sqs[s] != chess.NoPiece {
+					if is(s, types...) {
+						add(s)
+					}
+					break
+				}
+				s, ok = offset(s, st[0], st[1])
+			}
+		}
+	}
+	slide(rookSteps, chess.Rook, chess.Queen)
+	slide(bishopSteps, chess.Bishop, chess.Queen)
+
+	return bb
+}
+
+// kingSquare returns where the king of color c stands, or chess.NoSquare.
+func kingSquare(sqs *[64]chess.Piece, c chess.Color) chess.Square {
+	king := chess.NewPiece(chess.King, c)
+	for sq, p := range sqs {
+		if p == king {
+			return chess.Square(sq)
+		}
+	}
+	return chess.NoSquare
+}
+
+// AttackersTo returns a bitboard (bit i set for square i) of the pieces of
+// color by that attack sq in pos. Pinned pieces count as attackers.
+func AttackersTo(pos *chess.Position, sq chess.Square, by chess.Color) uint64 {
+	sqs := mailbox(pos.Board())
+	return attackersTo(&sqs, sq, by)
+}
+
+// CheckersBitboard returns the enemy pieces giving check to the side to move.
+func CheckersBitboard(pos *chess.Position) uint64 {
+	sqs := mailbox(pos.Board())
+	king := kingSquare(&sqs, pos.Turn())
+	if king == chess.NoSquare {
+		return 0
+	}
+	return attackersTo(&sqs, king, pos.Turn().Other())
+}
+
+// IsPinned reports whether the piece on sq is absolutely pinned, i.e. it
+// stands between its own king and an enemy rook, bishop or queen that would
+// give check if it moved off the line.
+func IsPinned(pos *chess.Position, sq chess.Square) bool {
+	sqs := mailbox(pos.Board())
+	p := sqs[sq]
+	if p == chess.NoPiece || p.Type() == chess.King {
+		return false
+	}
+	king := kingSquare(&sqs, p.Color())
+	if king == chess.NoSquare {
+		return false
+	}
+
+	df := sign(int(sq.File()) - int(king.File()))
+	dr := sign(int(sq.Rank()) - int(king.Rank()))
+	fileDist := abs(int(sq.File()) - int(king.File()))
+	rankDist := abs(int(sq.Rank()) - int(king.Rank()))
+	if fileDist != 0 && rankDist != 0 && fileDist != rankDist {
+		return false // not on a line with the king
+	}
+	sliders := []chess.PieceType{chess.Rook, chess.Queen}
+	if df != 0 && dr != 0 {
+		sliders = []chess.PieceType{chess.Bishop, chess.Queen}
+	}
+
+	// Walk from the king: the first piece must be sq, the next an enemy slider.
+	passed := false
+	for s, ok := offset(king, df, dr); ok; s, ok = offset(s, df, dr) {
+		q := sqs[s]
+		if q == chess.NoPiece {
+			continue
+		}
+		if !passed {
+			if s != sq {
+				return false
+			}
+			passed = true
+			continue
+		}
+		if q.Color() == p.Color() {
+			return false
+		}
+		for _, t := range sliders {
+			if q.Type() == t {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// Squares lists the squares set in a bitboard, from A1 to H8.
+func Squares(bb uint64) []chess.Square {
+	var sqs []chess.Square
+	for s := chess.A1; s <= chess.H8; s++ {
+		if bb&(1<<uint(s)) != 0 {
+			sqs = append(sqs, s)
+		}
+	}
+	return sqs
+}
+
+func sign(x int) int {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	}
+	return 0
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,237 @@
+package board
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// FENMode selects how strictly ParseFEN reads a FEN.
+type FENMode int
+
+const (
+	// StrictFEN accepts only complete, canonical FENs of positions that
+	// can arise in a game.
+	StrictFEN FENMode = iota
+
+	// LenientFEN fills in missing trailing fields ("w - - 0 1"), accepts
+	// castling rights in any order or as Shredder-FEN's rook files for
+	// the corner rooks ("HAha"), accepts out-of-range move counters, and
+	// leaves the rules of the game to the caller: a position with two
+	// kings or a pawn on the back rank is read as written. The piece
+	// placement must still be well formed.
+	LenientFEN
+)
+
+// FENError reports what is wrong with a FEN.
+type FENError struct {
+	FEN    string
+	Field  string // "piece placement", "side to move", "castling", "en passant", "halfmove clock", "fullmove number", or "position" for a broken rule
+	Reason string
+}
+
+func (e *FENError) Error() string {
+	return fmt.Sprintf("board: FEN %q: %s: %s", e.FEN, e.Field, e.Reason)
+}
+
+// PositionError reports a position that cannot arise in a game, such as
+// one with a missing king. Setup.Validate returns it.
+type PositionError struct {
+	Reason string
+}
+
+func (e *PositionError) Error() string {
+	return "board: " + e.Reason
+}
+
+func invalidPosition(format string, args ...any) error {
+	return &PositionError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// ParseFEN reads fen into a position, with every fault reported as a
+// *FENError.
+func ParseFEN(fen string, mode FENMode) (*chess.Position, error) {
+	s, err := parseSetup(fen, mode)
+	if err != nil {
+		return nil, err
+	}
+	if mode == StrictFEN {
+		if err := s.Validate(); err != nil {
+			reason := err.Error()
+			var perr *PositionError
+			if errors.As(err, &perr) {
+				reason = perr.Reason
+			}
+			return nil, &FENError{FEN: fen, Field: "position", Reason: reason}
+		}
+	}
+	opt, err := chess.FEN(s.FEN())
+	if err != nil {
+		return nil, &FENError{FEN: fen, Field: "position", Reason: err.Error()}
+	}
+	return chess.NewGame(opt).Position(), nil
+}
+
+// ParseSetup reads fen into a Setup without checking that the position
+// could arise in a game, so that a caller can mend what it can, such as
+// with Setup.Tidy, before Setup.Position validates it. Faults in the FEN
+// itself are reported as a *FENError.
+func ParseSetup(fen string, mode FENMode) (*Setup, error) {
+	return parseSetup(fen, mode)
+}
+
+// ValidateFEN reports whether fen passes ParseFEN in strict mode.
+func ValidateFEN(fen string) error {
+	_, err := ParseFEN(fen, StrictFEN)
+	return err
+}
+
+func parseSetup(fen string, mode FENMode) (*Setup, error) {
+	fail := func(field, format string, args ...any) error {
+		return &FENError{FEN: fen, Field: field, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	fields := strings.Fields(fen)
+	if mode == StrictFEN && len(fields) != 6 {
+		return nil, fail("position", "has %d fields, want 6", len(fields))
+	}
+	if len(fields) == 0 || len(fields) > 6 {
+		return nil, fail("position", "has %d fields, want 1 to 6", len(fields))
+	}
+	fields = append(fields, []string{"w", "-", "-", "0", "1"}[len(fields)-1:]...)
+
+	s := NewSetup()
+	ranks := strings.Split(fields[0], "/")
+	if len(ranks) != 8 {
+		return nil, fail("piece placement", "has %d ranks, want 8", len(ranks))
+	}
+	for i, rank := range ranks {
+		r := 8 - i
+		file, lastDigit := 0, false
+		for _, c := range rank {
+			switch {
+			case c >= '1' && c <= '8':
+				if lastDigit && mode == StrictFEN {
+					return nil, fail("piece placement", "rank %d has two counts of empty squares in a row", r)
+				}
+				file += int(c - '0')
+				lastDigit = true
+				continue
+			case strings.ContainsRune("pnbrqkPNBRQK", c):
+				if file < 8 {
+					s.SetPiece(chess.NewSquare(chess.File(file), chess.Rank(r-1)), pieceFromFEN(c))
+				}
+				file++
+			default:
+				return nil, fail("piece placement", "rank %d has %q, which is neither a piece nor a count of empty squares", r, c)
+			}
+			lastDigit = false
+		}
+		if file != 8 {
+			return nil, fail("piece placement", "rank %d has %d squares, want 8", r, file)
+		}
+	}
+
+	switch turn := fields[1]; {
+	case turn == "w" || mode == LenientFEN && turn == "W":
+		s.SetTurn(chess.White)
+	case turn == "b" || mode == LenientFEN && turn == "B":
+		s.SetTurn(chess.Black)
+	default:
+		return nil, fail("side to move", "is %q, want w or b", turn)
+	}
+
+	castling := fields[2]
+	if castling != "-" {
+		var rights []byte
+		for _, c := range []byte(castling) {
+			if mode == LenientFEN {
+				c = shredderCastling(c)
+			}
+			if !strings.ContainsRune("KQkq", rune(c)) {
+				return nil, fail("castling", "has %q, want K, Q, k, q or -", c)
+			}
+			if slices.Contains(rights, c) {
+				return nil, fail("castling", "has %c twice", c)
+			}
+			rights = append(rights, c)
+		}
+		canonical := ""
+		for _, c := range "KQkq" {
+			if slices.Contains(rights, byte(c)) {
+				canonical += string(c)
+			}
+		}
+		if mode == StrictFEN && canonical != castling {
+			return nil, fail("castling", "is %q, want the order %q", castling, canonical)
+		}
+		castling = canonical
+	}
+	s.SetCastlingRights(chess.CastleRights(castling))
+
+	if ep := fields[3]; ep != "-" {
+		sq, ok := squareFromName(ep)
+		if !ok {
+			return nil, fail("en passant", "is %q, want a square or -", ep)
+		}
+		if sq.Rank() != chess.Rank3 && sq.Rank() != chess.Rank6 {
+			return nil, fail("en passant", "%s is not on the third or sixth rank", ep)
+		}
+		s.SetEnPassant(sq)
+	}
+
+	halfMoves, err := strconv.Atoi(fields[4])
+	if err != nil {
+		return nil, fail("halfmove clock", "is %q, want a number", fields[4])
+	}
+	moveNum, err := strconv.Atoi(fields[5])
+	if err != nil {
+		return nil, fail("fullmove number", "is %q, want a number", fields[5])
+	}
+	switch {
+	case mode == LenientFEN:
+		halfMoves, moveNum = max(halfMoves, 0), max(moveNum, 1)
+	case halfMoves < 0:
+		return nil, fail("halfmove clock", "is %d, want 0 or more", halfMoves)
+	case moveNum < 1:
+		return nil, fail("fullmove number", "is %d, want 1 or more", moveNum)
+	}
+	s.SetMoveCounters(halfMoves, moveNum)
+	return s, nil
+}
+
+// shredderCastling turns a Shredder-FEN castling letter, which names the
+// rook's file, into the standard one if the rook is in the corner. Other
+// files are left to be rejected: Chess960 castling isn't supported.
+func shredderCastling(c byte) byte {
+	switch c {
+	case 'H':
+		return 'K'
+	case 'A':
+		return 'Q'
+	case 'h':
+		return 'k'
+	case 'a':
+		return 'q'
+	}
+	return c
+}
+
+func pieceFromFEN(c rune) chess.Piece {
+	types := map[rune]chess.PieceType{'p': chess.Pawn, 'n': chess.Knight, 'b': chess.Bishop, 'r': chess.Rook, 'q': chess.Queen, 'k': chess.King}
+	if c >= 'a' {
+		return chess.NewPiece(types[c], chess.Black)
+	}
+	return chess.NewPiece(types[c+'a'-'A'], chess.White)
+}
+
+func squareFromName(name string) (chess.Square, bool) {
+	if len(name) != 2 || name[0] < 'a' || name[0] > 'h' || name[1] < '1' || name[1] > '8' {
+		return chess.NoSquare, false
+	}
+	return chess.NewSquare(chess.File(name[0]-'a'), chess.Rank(name[1]-'1')), true
+}
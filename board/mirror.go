@@ -0,0 +1,79 @@
+package board
+
+import (
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// FlipColors returns the same position with the colors swapped: the board
+// is mirrored top to bottom, every piece changes color, and the side to
+// move, castling rights and en passant square follow. A symmetric
+// evaluation must score pos and FlipColors(pos) the same for the side to
+// move.
+func FlipColors(pos *chess.Position) *chess.Position {
+	src := SetupFromPosition(pos)
+	dst := SetupFromPosition(pos)
+	for sq := chess.A1; sq <= chess.H8; sq++ {
+		p := src.Piece(sq)
+		if p != chess.NoPiece {
+			p = chess.NewPiece(p.Type(), p.Color().Other())
+		}
+		dst.SetPiece(flipRank(sq), p)
+	}
+	dst.SetTurn(pos.Turn().Other())
+	dst.SetCastlingRights(flipCastleRights(pos.CastleRights()))
+	if ep := pos.EnPassantSquare(); ep != chess.NoSquare {
+		dst.SetEnPassant(flipRank(ep))
+	}
+	return mustPosition(dst)
+}
+
+// MirrorHorizontal returns the position reflected left to right (a-file
+// becomes h-file). Castling rights are dropped since they don't survive
+// the reflection.
+func MirrorHorizontal(pos *chess.Position) *chess.Position {
+	src := SetupFromPosition(pos)
+	dst := SetupFromPosition(pos)
+	for sq := chess.A1; sq <= chess.H8; sq++ {
+		dst.SetPiece(flipFile(sq), src.Piece(sq))
+	}
+	dst.SetCastlingRights("-")
+	if ep := pos.EnPassantSquare(); ep != chess.NoSquare {
+		dst.SetEnPassant(flipFile(ep))
+	}
+	return mustPosition(dst)
+}
+
+func flipRank(sq chess.Square) chess.Square {
+	return chess.NewSquare(sq.File(), chess.Rank(7-int(sq.Rank())))
+}
+
+func flipFile(sq chess.Square) chess.Square {
+	return chess.NewSquare(chess.File(7-int(sq.File())), sq.Rank())
+}
+
+// flipCastleRights swaps White's and Black's rights, keeping FEN order.
+func flipCastleRights(cr chess.CastleRights) chess.CastleRights {
+	flipped := ""
+	for i, c := range castleChars {
+		// castleChars is "KQkq": index i^2 is the same side for the other color.
+		if strings.ContainsRune(string(cr), rune(castleChars[i^2])) {
+			flipped += string(c)
+		}
+	}
+	if flipped == "" {
+		flipped = "-"
+	}
+	return chess.CastleRights(flipped)
+}
+
+// mustPosition converts a setup derived from a legal position. The
+// transformations above keep positions legal, so failure is a bug.
+func mustPosition(s *Setup) *chess.Position {
+	opt, err := chess.FEN(s.FEN())
+	if err != nil {
+		panic("board: " + err.Error())
+	}
+	return chess.NewGame(opt).Position()
+}
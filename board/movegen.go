@@ -0,0 +1,38 @@
+// Package board holds position helpers shared by the engines and the
+// arbiters. It works on top of github.com/notnil/chess positions.
+package board
+
+import "github.com/notnil/chess"
+
+// IsCapture reports whether the move takes a piece. Unlike
+// move.HasTag(chess.Capture) it also counts en passant.
+func IsCapture(move *chess.Move) bool {
+	return move.HasTag(chess.Capture) || move.HasTag(chess.EnPassant)
+}
+
+// GenerateCaptures returns the legal capturing moves of the position,
+// including en passant and capturing promotions.
+func GenerateCaptures(pos *chess.Position) []*chess.Move {
+	captures, _ := SplitMoves(pos)
+	return captures
+}
+
+// GenerateQuietMoves returns the legal non-capturing moves of the position.
+// Promotions without a capture count as quiet.
+func GenerateQuietMoves(pos *chess.Position) []*chess.Move {
+	_, quiets := SplitMoves(pos)
+	return quiets
+}
+
+// SplitMoves generates the legal moves once and splits them into captures
+// and quiet moves, for staged move ordering.
+func SplitMoves(pos *chess.Position) (captures, quiets []*chess.Move) {
+	for _, move := range pos.ValidMoves() {
+		if IsCapture(move) {
+			captures = append(captures, move)
+		} else {
+			quiets = append(quiets, move)
+		}
+	}
+	return captures, quiets
+}
@@ -0,0 +1,30 @@
+package board
+
+import (
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// NullMove returns pos with the turn passed to the other side, as used by
+// null-move pruning. The en passant square is cleared. The result is only
+// a legal position if the side to move in pos is not in check.
+func NullMove(pos *chess.Position) *chess.Position {
+	fields := strings.Fields(pos.String())
+	fields[1] = pos.Turn().Other().String()
+	fields[3] = "-"
+	opt, err := chess.FEN(strings.Join(fields, " "))
+	if err != nil {
+		panic("board: " + err.Error())
+	}
+	return chess.NewGame(opt).Position()
+}
+
+// ZobristNullMove returns the hash of NullMove(pos) given the hash of pos.
+func ZobristNullMove(hash uint64, pos *chess.Position) uint64 {
+	hash ^= zobristBlack
+	if ep := pos.EnPassantSquare(); ep != chess.NoSquare {
+		hash ^= zobristEnPassant[ep.File()]
+	}
+	return hash
+}
@@ -0,0 +1,21 @@
+package board
+
+import "github.com/notnil/chess"
+
+// Perft counts the legal move sequences of the given length from pos. The
+// counts of well-known positions are published, which makes it the
+// standard check of a move generator.
+func Perft(pos *chess.Position, depth int) int64 {
+	if depth <= 0 {
+		return 1
+	}
+	moves := pos.ValidMoves()
+	if depth == 1 {
+		return int64(len(moves))
+	}
+	var nodes int64
+	for _, move := range moves {
+		nodes += Perft(pos.Update(move), depth-1)
+	}
+	return nodes
+}
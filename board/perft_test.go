@@ -0,0 +1,85 @@
+package board
+
+import (
+	"testing"
+
+	"github.com/notnil/chess"
+)
+
+// perftSuite is the Chess Programming Wiki's set of perft positions with
+// their published counts, by depth from 1. Between them they have every
+// kind of move: castling through and out of check, en passant pins and
+// promotions. The counts past shortDepth are only checked without -short.
+var perftSuite = []struct {
+	name       string
+	fen        string
+	counts     []int64
+	shortDepth int
+}{
+	{"startpos", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", []int64{20, 400, 8902, 197281}, 4},
+	{"kiwipete", "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", []int64{48, 2039, 97862, 4085603}, 3},
+	{"position 3", "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", []int64{14, 191, 2812, 43238, 674624}, 4},
+	{"position 4", "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", []int64{6, 264, 9467, 422333}, 3},
+	{"position 5", "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", []int64{44, 1486, 62379, 2103487}, 3},
+	{"position 6", "r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10", []int64{46, 2079, 89890, 3894594}, 3},
+}
+
+func TestPerft(t *testing.T) {
+	for _, tt := range perftSuite {
+		t.Run(tt.name, func(t *testing.T) {
+			pos := testPosition(t, tt.fen)
+			for i, want := range tt.counts {
+				depth := i + 1
+				if depth > tt.shortDepth && testing.Short() {
+					break
+				}
+				if got := Perft(pos, depth); got != want {
+					t.Errorf("depth %d: %d nodes, want %d", depth, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestSplitMoves checks that the captures and quiet moves of every
+// position two plies deep in the suite make up its legal moves.
+func TestSplitMoves(t *testing.T) {
+	for _, tt := range perftSuite {
+		walk(testPosition(t, tt.fen), 2, func(pos *chess.Position) {
+			captures, quiets := SplitMoves(pos)
+			if got, want := len(captures)+len(quiets), len(pos.ValidMoves()); got != want {
+				t.Fatalf("%s: %d captures and quiet moves, want %d", pos, got, want)
+			}
+			for _, m := range captures {
+				if !IsCapture(m) {
+					t.Errorf("%s: %s is no capture", pos, MoveToUCI(m))
+				}
+			}
+			for _, m := range quiets {
+				if IsCapture(m) {
+					t.Errorf("%s: %s is a capture", pos, MoveToUCI(m))
+				}
+			}
+		})
+	}
+}
+
+// walk calls visit on pos and every position up to depth plies from it.
+func walk(pos *chess.Position, depth int, visit func(*chess.Position)) {
+	visit(pos)
+	if depth == 0 {
+		return
+	}
+	for _, m := range pos.ValidMoves() {
+		walk(pos.Update(m), depth-1, visit)
+	}
+}
+
+func testPosition(t *testing.T, fen string) *chess.Position {
+	t.Helper()
+	opt, err := chess.FEN(fen)
+	if err != nil {
+		t.Fatalf("%s: %v", fen, err)
+	}
+	return chess.NewGame(opt).Position()
+}
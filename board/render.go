@@ -0,0 +1,75 @@
+package board
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// RenderOptions control how BoardToString draws a board.
+type RenderOptions struct {
+	Unicode     bool        // chess symbols instead of FEN letters
+	Coordinates bool        // file letters and rank numbers around the board
+	Flipped     bool        // Black at the bottom
+	LastMove    *chess.Move // its squares are drawn in brackets, if set
+}
+
+var asciiPieces = map[chess.Piece]string{
+	chess.WhiteKing: "K", chess.WhiteQueen: "Q", chess.WhiteRook: "R",
+	chess.WhiteBishop: "B", chess.WhiteKnight: "N", chess.WhitePawn: "P",
+	chess.BlackKing: "k", chess.BlackQueen: "q", chess.BlackRook: "r",
+	chess.BlackBishop: "b", chess.BlackKnight: "n", chess.BlackPawn: "p",
+}
+
+var unicodePieces = map[chess.Piece]string{
+	chess.WhiteKing: "♔", chess.WhiteQueen: "♕", chess.WhiteRook: "♖",
+	chess.WhiteBishop: "♗", chess.WhiteKnight: "♘", chess.WhitePawn: "♙",
+	chess.BlackKing: "♚", chess.BlackQueen: "♛", chess.BlackRook: "♜",
+	chess.BlackBishop: "♝", chess.BlackKnight: "♞", chess.BlackPawn: "♟",
+}
+
+// BoardToString draws b as text, one rank per line, for terminals and
+// for <pre> blocks on web pages. Every square takes three columns, so
+// that the brackets of the last move keep the files aligned.
+func BoardToString(b *chess.Board, opts RenderOptions) string {
+	pieces, empty := asciiPieces, "."
+	if opts.Unicode {
+		pieces, empty = unicodePieces, "·"
+	}
+
+	ranks := []chess.Rank{chess.Rank8, chess.Rank7, chess.Rank6, chess.Rank5, chess.Rank4, chess.Rank3, chess.Rank2, chess.Rank1}
+	files := []chess.File{chess.FileA, chess.FileB, chess.FileC, chess.FileD, chess.FileE, chess.FileF, chess.FileG, chess.FileH}
+	if opts.Flipped {
+		slices.Reverse(ranks)
+		slices.Reverse(files)
+	}
+
+	var sb strings.Builder
+	for _, r := range ranks {
+		if opts.Coordinates {
+			sb.WriteString(r.String() + " ")
+		}
+		for _, f := range files {
+			sq := chess.NewSquare(f, r)
+			symbol := empty
+			if p := b.Piece(sq); p != chess.NoPiece {
+				symbol = pieces[p]
+			}
+			if m := opts.LastMove; m != nil && (sq == m.S1() || sq == m.S2()) {
+				sb.WriteString("[" + symbol + "]")
+			} else {
+				sb.WriteString(" " + symbol + " ")
+			}
+		}
+		sb.WriteString("\n")
+	}
+	if opts.Coordinates {
+		sb.WriteString("  ")
+		for _, f := range files {
+			sb.WriteString(" " + f.String() + " ")
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
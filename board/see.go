@@ -0,0 +1,100 @@
+package board
+
+import "github.com/notnil/chess"
+
+// PieceValue is the material value in centipawns used by SEE. Kings get a
+// large value so they are always the last piece to join an exchange.
+func PieceValue(t chess.PieceType) int {
+	switch t {
+	case chess.Pawn:
+		return 100
+	case chess.Knight, chess.Bishop:
+		return 300
+	case chess.Rook:
+		return 500
+	case chess.Queen:
+		return 900
+	case chess.King:
+		return 20000
+	}
+	return 0
+}
+
+// SEE (static exchange evaluation) returns the material balance in
+// centipawns, from the moving side's point of view, of playing move and
+// then letting both sides recapture on the target square with their least
+// valuable attacker for as long as it pays. Positive means the capture wins
+// material, negative that it loses some. Pins are not taken into account.
+func SEE(pos *chess.Position, move *chess.Move) int {
+	sqs := mailbox(pos.Board())
+	target := move.S2()
+
+	var gain [32]int
+	if move.HasTag(chess.EnPassant) {
+		gain[0] = PieceValue(chess.Pawn)
+		behind, _ := offset(target, 0, -pawnForward(pos.Turn()))
+		sqs[behind] = chess.NoPiece
+	} else {
+		gain[0] = PieceValue(sqs[target].Type())
+	}
+
+	// Value of the piece now standing on the target, which the next
+	// recapture wins.
+	onTarget := PieceValue(sqs[move.S1()].Type())
+	if move.Promo() != chess.NoPieceType {
+		onTarget = PieceValue(move.Promo())
+		gain[0] += onTarget - PieceValue(chess.Pawn)
+	}
+	sqs[target] = sqs[move.S1()]
+	sqs[move.S1()] = chess.NoPiece
+
+	side := pos.Turn().Other()
+	d := 0
+	for d+1 < len(gain) {
+		from, ok := leastValuableAttacker(&sqs, target, side)
+		if !ok {
+			break
+		}
+		// A king may only recapture if the square is no longer defended.
+		if sqs[from].Type() == chess.King && attackersTo(&sqs, target, side.Other()) != 0 {
+			break
+		}
+
+		d++
+		gain[d] = onTarget - gain[d-1]
+		onTarget = PieceValue(sqs[from].Type())
+		sqs[target] = sqs[from]
+		sqs[from] = chess.NoPiece
+		side = side.Other()
+	}
+
+	// Either side may stop capturing when continuing would lose material.
+	for ; d > 0; d-- {
+		gain[d-1] = -max(-gain[d-1], gain[d])
+	}
+	return gain[0]
+}
+
+// leastValuableAttacker returns the square of the cheapest piece of color
+// by attacking sq.
+func leastValuableAttacker(sqs *[64]chess.Piece, sq chess.Square, by chess.Color) (chess.Square, bool) {
+	attackers := attackersTo(sqs, sq, by)
+	best, bestValue := chess.NoSquare, 0
+	for s := chess.A1; s <= chess.H8; s++ {
+		if attackers&(1<<uint(s)) == 0 {
+			continue
+		}
+		if v := PieceValue(sqs[s].Type()); best == chess.NoSquare || v < bestValue {
+			best, bestValue = s, v
+		}
+	}
+	return best, best != chess.NoSquare
+}
+
+// pawnForward is the rank step of a pawn of color c.
+func pawnForward(c chess.Color) int {
+	if c == chess.Black {
+		return -1
+	}
+	return 1
+}
@@ -0,0 +1,289 @@
+package board
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// Setup is an editable position for building study positions piece by
+// piece instead of writing FEN by hand. Call Validate (or Position, which
+// validates) once the position is complete.
+type Setup struct {
+	squares   [64]chess.Piece
+	turn      chess.Color
+	castling  chess.CastleRights
+	enPassant chess.Square
+	halfMoves int
+	moveNum   int
+}
+
+// NewSetup returns an empty board with White to move and no castling rights.
+func NewSetup() *Setup {
+	return &Setup{
+		turn:      chess.White,
+		castling:  "-",
+		enPassant: chess.NoSquare,
+		moveNum:   1,
+	}
+}
+
+// SetupFromPosition returns an editable copy of pos.
+func SetupFromPosition(pos *chess.Position) *Setup {
+	return &Setup{
+		squares:   mailbox(pos.Board()),
+		turn:      pos.Turn(),
+		castling:  pos.CastleRights(),
+		enPassant: pos.EnPassantSquare(),
+		halfMoves: pos.HalfMoveClock(),
+		moveNum:   moveNumber(pos),
+	}
+}
+
+// moveNumber reads the fullmove number, which Position does not export.
+func moveNumber(pos *chess.Position) int {
+	n := 1
+	fields := strings.Fields(pos.String())
+	if len(fields) == 6 {
+		fmt.Sscan(fields[5], &n)
+	}
+	return n
+}
+
+// SetPiece puts p on sq, replacing whatever stood there.
+func (s *Setup) SetPiece(sq chess.Square, p chess.Piece) {
+	s.squares[sq] = p
+}
+
+// RemovePiece empties sq.
+func (s *Setup) RemovePiece(sq chess.Square) {
+	s.squares[sq] = chess.NoPiece
+}
+
+// Piece returns the piece on sq.
+func (s *Setup) Piece(sq chess.Square) chess.Piece {
+	return s.squares[sq]
+}
+
+// SetTurn sets the side to move.
+func (s *Setup) SetTurn(c chess.Color) {
+	s.turn = c
+}
+
+// SetCastlingRights sets the castling rights in FEN form, e.g. "KQkq" or "-".
+func (s *Setup) SetCastlingRights(cr chess.CastleRights) {
+	if cr == "" {
+		cr = "-"
+	}
+	s.castling = cr
+}
+
+// SetEnPassant sets the en passant target square, or chess.NoSquare.
+func (s *Setup) SetEnPassant(sq chess.Square) {
+	s.enPassant = sq
+}
+
+// SetMoveCounters sets the halfmove clock and the fullmove number.
+func (s *Setup) SetMoveCounters(halfMoves, moveNumber int) {
+	s.halfMoves, s.moveNum = halfMoves, moveNumber
+}
+
+// FEN renders the setup as a FEN string. It does not validate.
+func (s *Setup) FEN() string {
+	pieces := map[chess.Square]chess.Piece{}
+	for sq, p := range s.squares {
+		if p != chess.NoPiece {
+			pieces[chess.Square(sq)] = p
+		}
+	}
+	ep := "-"
+	if s.enPassant != chess.NoSquare {
+		ep = s.enPassant.String()
+	}
+	return fmt.Sprintf("%s %s %s %s %d %d",
+		chess.NewBoard(pieces).String(), s.turn, s.castling, ep, s.halfMoves, s.moveNum)
+}
+
+// Position validates the setup and converts it into a playable position.
+func (s *Setup) Position() (*chess.Position, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	opt, err := chess.FEN(s.FEN())
+	if err != nil {
+		return nil, err
+	}
+	return chess.NewGame(opt).Position(), nil
+}
+
+// Validate rejects setups that cannot arise in a game: missing or extra
+// kings, pawns on the back ranks, too many pieces, the side not to move
+// being in check, and castling or en passant rights that don't match the
+// board. The error is a *PositionError.
+func (s *Setup) Validate() error {
+	if s.turn != chess.White && s.turn != chess.Black {
+		return invalidPosition("side to move is not set")
+	}
+
+	for _, c := range []chess.Color{chess.White, chess.Black} {
+		kings, pawns, total := 0, 0, 0
+		for _, p := range s.squares {
+			if p == chess.NoPiece || p.Color() != c {
+				continue
+			}
+			total++
+			switch p.Type() {
+			case chess.King:
+				kings++
+			case chess.Pawn:
+				pawns++
+			}
+		}
+		switch {
+		case kings == 0:
+			return invalidPosition("%s has no king", c.Name())
+		case kings > 1:
+			return invalidPosition("%s has %d kings", c.Name(), kings)
+		case pawns > 8:
+			return invalidPosition("%s has %d pawns", c.Name(), pawns)
+		case total > 16:
+			return invalidPosition("%s has %d pieces", c.Name(), total)
+		}
+	}
+
+	for sq, p := range s.squares {
+		r := chess.Square(sq).Rank()
+		if p.Type() == chess.Pawn && (r == chess.Rank1 || r == chess.Rank8) {
+			return invalidPosition("pawn on %s", chess.Square(sq))
+		}
+	}
+
+	notToMove := s.turn.Other()
+	if attackersTo(&s.squares, kingSquare(&s.squares, notToMove), s.turn) != 0 {
+		return invalidPosition("%s is in check but it is %s's move", notToMove.Name(), s.turn.Name())
+	}
+
+	if err := s.validateCastling(); err != nil {
+		return err
+	}
+	return s.validateEnPassant()
+}
+
+// castlingNeeds lists the king and rook each castling right needs in place.
+var castlingNeeds = map[rune][2]struct {
+	sq chess.Square
+	p  chess.Piece
+}{
+	'K': {{chess.E1, chess.WhiteKing}, {chess.H1, chess.WhiteRook}},
+	'Q': {{chess.E1, chess.WhiteKing}, {chess.A1, chess.WhiteRook}},
+	'k': {{chess.E8, chess.BlackKing}, {chess.H8, chess.BlackRook}},
+	'q': {{chess.E8, chess.BlackKing}, {chess.A8, chess.BlackRook}},
+}
+
+func (s *Setup) validateCastling() error {
+	if s.castling == "-" {
+		return nil
+	}
+	for _, r := range string(s.castling) {
+		if err := s.castlingRight(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// castlingRight reports whether the board allows castling right r.
+func (s *Setup) castlingRight(r rune) error {
+	req, ok := castlingNeeds[r]
+	if !ok {
+		return invalidPosition("invalid castling rights %q", s.castling)
+	}
+	for _, n := range req {
+		if s.squares[n.sq] != n.p {
+			return invalidPosition("castling right %c needs a %s on %s", r, pieceName(n.p), n.sq)
+		}
+	}
+	return nil
+}
+
+// Tidy drops the castling rights and en passant square that the board
+// doesn't allow, as board editors and some programs write "KQkq" or the
+// square behind every double push regardless, and returns why each was
+// dropped. An en passant square no pawn could capture on is dropped too,
+// so that the same position always has the same FEN.
+func (s *Setup) Tidy() []string {
+	var dropped []string
+	if s.castling != "-" {
+		kept := ""
+		for _, r := range string(s.castling) {
+			if err := s.castlingRight(r); err != nil {
+				dropped = append(dropped, err.(*PositionError).Reason)
+				continue
+			}
+			kept += string(r)
+		}
+		if kept == "" {
+			kept = "-"
+		}
+		s.castling = chess.CastleRights(kept)
+	}
+
+	if s.enPassant != chess.NoSquare {
+		if err := s.validateEnPassant(); err != nil {
+			dropped = append(dropped, err.(*PositionError).Reason)
+			s.enPassant = chess.NoSquare
+		} else if !s.canTakeEnPassant() {
+			dropped = append(dropped, fmt.Sprintf("no pawn can take en passant on %s", s.enPassant))
+			s.enPassant = chess.NoSquare
+		}
+	}
+	return dropped
+}
+
+// canTakeEnPassant reports whether a pawn of the side to move stands
+// beside the pawn that just moved two squares.
+func (s *Setup) canTakeEnPassant() bool {
+	dir := -1
+	if s.turn == chess.Black {
+		dir = 1
+	}
+	pawn := chess.NewPiece(chess.Pawn, s.turn)
+	for _, df := range []int{-1, 1} {
+		if sq, ok := offset(s.enPassant, df, dir); ok && s.squares[sq] == pawn {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Setup) validateEnPassant() error {
+	if s.enPassant == chess.NoSquare {
+		return nil
+	}
+	// The pawn that just moved two squares stands behind the target square,
+	// and both the target and the square it came from must be empty.
+	dir, wantRank := -1, chess.Rank6
+	pawn := chess.BlackPawn
+	if s.turn == chess.Black {
+		dir, wantRank = 1, chess.Rank3
+		pawn = chess.WhitePawn
+	}
+	if s.enPassant.Rank() != wantRank {
+		return invalidPosition("en passant square %s is on the wrong rank", s.enPassant)
+	}
+	pawnSq, _ := offset(s.enPassant, 0, dir)
+	fromSq, _ := offset(s.enPassant, 0, -dir)
+	if s.squares[pawnSq] != pawn || s.squares[s.enPassant] != chess.NoPiece || s.squares[fromSq] != chess.NoPiece {
+		return invalidPosition("en passant square %s does not follow a double pawn push", s.enPassant)
+	}
+	return nil
+}
+
+func pieceName(p chess.Piece) string {
+	names := map[chess.PieceType]string{
+		chess.King: "king", chess.Queen: "queen", chess.Rook: "rook",
+		chess.Bishop: "bishop", chess.Knight: "knight", chess.Pawn: "pawn",
+	}
+	return p.Color().Name() + " " + names[p.Type()]
+}
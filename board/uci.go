@@ -0,0 +1,35 @@
+package board
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// MoveToUCI returns the move in UCI long algebraic notation, e.g. "e2e4"
+// or "e7e8q" for a promotion.
+func MoveToUCI(move *chess.Move) string {
+	s := move.S1().String() + move.S2().String()
+	if move.Promo() != chess.NoPieceType {
+		s += move.Promo().String()
+	}
+	return s
+}
+
+// UCIToMove parses a UCI long algebraic move such as "e7e8q" and returns the
+// matching legal move of the position, with all its tags set. The promotion
+// letter may be given in either case.
+func UCIToMove(pos *chess.Position, s string) (*chess.Move, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) != 4 && len(s) != 5 {
+		return nil, fmt.Errorf("board: malformed UCI move %q", s)
+	}
+
+	for _, move := range pos.ValidMoves() {
+		if MoveToUCI(move) == s {
+			return move, nil
+		}
+	}
+	return nil, fmt.Errorf("board: move %q is not legal in %s", s, pos)
+}
@@ -0,0 +1,137 @@
+package board
+
+import (
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// Zobrist keys. They come from a fixed-seed generator so hashes are stable
+// between runs and processes.
+var (
+	zobristPiece     [13][64]uint64 // indexed by chess.Piece
+	zobristCastle    [4]uint64      // K, Q, k, q
+	zobristEnPassant [8]uint64      // by file
+	zobristBlack     uint64
+)
+
+const castleChars = "KQkq"
+
+func init() {
+	state := uint64(0x9E3779B97F4A7C15)
+	next := func() uint64 { // splitmix64
+		state += 0x9E3779B97F4A7C15
+		z := state
+		z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+		z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+		return z ^ (z >> 31)
+	}
+	for p := range zobristPiece {
+		for sq := range zobristPiece[p] {
+			zobristPiece[p][sq] = next()
+		}
+	}
+	for i := range zobristCastle {
+		zobristCastle[i] = next()
+	}
+	for i := range zobristEnPassant {
+		zobristEnPassant[i] = next()
+	}
+	zobristBlack = next()
+}
+
+// Zobrist computes the hash of a position from scratch. Positions that are
+// equal for repetition purposes (pieces, side to move, castling rights and
+// en passant square) hash equal.
+func Zobrist(pos *chess.Position) uint64 {
+	var h uint64
+	for sq, p := range pos.Board().SquareMap() {
+		h ^= zobristPiece[p][sq]
+	}
+	if pos.Turn() == chess.Black {
+		h ^= zobristBlack
+	}
+	h ^= castleHash(pos.CastleRights())
+	if ep := pos.EnPassantSquare(); ep != chess.NoSquare {
+		h ^= zobristEnPassant[ep.File()]
+	}
+	return h
+}
+
+// ZobristUpdate returns the hash after playing move in pos, given the hash
+// of pos. It is much cheaper than Zobrist(pos.Update(move)) and is meant to
+// be carried along a search.
+func ZobristUpdate(hash uint64, pos *chess.Position, move *chess.Move) uint64 {
+	b := pos.Board()
+	from, to := move.S1(), move.S2()
+	piece := b.Piece(from)
+	turn := pos.Turn()
+
+	h := hash ^ zobristBlack
+
+	// Captured piece, including a pawn taken en passant.
+	if captured := b.Piece(to); captured != chess.NoPiece {
+		h ^= zobristPiece[captured][to]
+	} else if piece.Type() == chess.Pawn && to == pos.EnPassantSquare() {
+		behind, _ := offset(to, 0, -pawnForward(turn))
+		h ^= zobristPiece[chess.NewPiece(chess.Pawn, turn.Other())][behind]
+	}
+
+	h ^= zobristPiece[piece][from]
+	if move.Promo() != chess.NoPieceType {
+		h ^= zobristPiece[chess.NewPiece(move.Promo(), turn)][to]
+	} else {
+		h ^= zobristPiece[piece][to]
+	}
+
+	// Castling also moves the rook.
+	if piece.Type() == chess.King {
+		rook := chess.NewPiece(chess.Rook, turn)
+		switch {
+		case from == chess.E1 && to == chess.G1, from == chess.E8 && to == chess.G8:
+			h ^= zobristPiece[rook][to+1] ^ zobristPiece[rook][to-1]
+		case from == chess.E1 && to == chess.C1, from == chess.E8 && to == chess.C8:
+			h ^= zobristPiece[rook][to-2] ^ zobristPiece[rook][to+1]
+		}
+	}
+
+	// Castling rights, mirroring notnil/chess's update rules.
+	cr := string(pos.CastleRights())
+	lose := func(c string, squares ...chess.Square) {
+		for _, sq := range squares {
+			if from == sq || to == sq {
+				cr = strings.ReplaceAll(cr, c, "")
+			}
+		}
+	}
+	if piece == chess.WhiteKing {
+		cr = strings.NewReplacer("K", "", "Q", "").Replace(cr)
+	}
+	if piece == chess.BlackKing {
+		cr = strings.NewReplacer("k", "", "q", "").Replace(cr)
+	}
+	lose("K", chess.H1)
+	lose("Q", chess.A1)
+	lose("k", chess.H8)
+	lose("q", chess.A8)
+	h ^= castleHash(pos.CastleRights()) ^ castleHash(chess.CastleRights(cr))
+
+	// En passant square: cleared, and set again after a double pawn push.
+	if ep := pos.EnPassantSquare(); ep != chess.NoSquare {
+		h ^= zobristEnPassant[ep.File()]
+	}
+	if piece.Type() == chess.Pawn && abs(int(to.Rank())-int(from.Rank())) == 2 {
+		h ^= zobristEnPassant[from.File()]
+	}
+	return h
+}
+
+func castleHash(cr chess.CastleRights) uint64 {
+	var h uint64
+	for i, c := range castleChars {
+		if strings.ContainsRune(string(cr), c) {
+			h ^= zobristCastle[i]
+		}
+	}
+	return h
+}
@@ -0,0 +1,101 @@
+package board
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/notnil/chess"
+)
+
+// TestZobristUpdate plays lines with every kind of move that changes the
+// hash in more than one place, and checks after each move that the hash
+// carried along with ZobristUpdate is the one Zobrist computes.
+func TestZobristUpdate(t *testing.T) {
+	tests := []struct {
+		name  string
+		fen   string
+		moves string
+	}{
+		{"quiet and captures", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e2e4 d7d5 e4d5 d8d5 b1c3 d5a5"},
+		{"castling both ways", "r3k2r/pppppppp/8/8/8/8/PPPPPPPP/R3K2R w KQkq - 0 1", "e1g1 e8c8 f1e1 d8e8"},
+		{"rook moves lose rights", "r3k2r/pppppppp/8/8/8/8/PPPPPPPP/R3K2R w KQkq - 0 1", "h1g1 a8b8 a1b1 h8g8"},
+		{"capture on a rook's square", "r3k2r/8/8/8/8/8/6B1/R3K2R w KQkq - 0 1", "g2a8 h8h1"},
+		{"en passant", "rnbqkbnr/ppp1pppp/8/8/3pP3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 1", "c2c4 d4c3 b2c3 e7e5"},
+		{"en passant square unused", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "a2a4 h7h5 a4a5 b7b5 a5b6"},
+		{"promotions", "r7/1P4P1/3k4/8/8/3K4/1p4p1/R7 w - - 0 1", "b7a8q b2a1n g7g8r g2g1b"},
+		{"king moves lose rights", "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8e7 e1d1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos := testPosition(t, tt.fen)
+			hash := Zobrist(pos)
+			for _, uci := range strings.Fields(tt.moves) {
+				move, err := UCIToMove(pos, uci)
+				if err != nil {
+					t.Fatalf("%s in %s: %v", uci, pos, err)
+				}
+				hash = ZobristUpdate(hash, pos, move)
+				pos = pos.Update(move)
+				if want := Zobrist(pos); hash != want {
+					t.Fatalf("after %s: hash %016x, want %016x (%s)", uci, hash, want, pos)
+				}
+			}
+		})
+	}
+}
+
+// TestZobristTree checks ZobristUpdate and ZobristNullMove against Zobrist
+// for every move three plies deep into the perft suite, or two with -short.
+func TestZobristTree(t *testing.T) {
+	depth := 2
+	if testing.Short() {
+		depth = 1
+	}
+	for _, tt := range perftSuite {
+		walk(testPosition(t, tt.fen), depth, func(pos *chess.Position) {
+			hash := Zobrist(pos)
+			for _, move := range pos.ValidMoves() {
+				if got, want := ZobristUpdate(hash, pos, move), Zobrist(pos.Update(move)); got != want {
+					t.Fatalf("%s: %s: hash %016x, want %016x", pos, MoveToUCI(move), got, want)
+				}
+			}
+			if CheckersBitboard(pos) == 0 {
+				if got, want := ZobristNullMove(hash, pos), Zobrist(NullMove(pos)); got != want {
+					t.Fatalf("%s: null move: hash %016x, want %016x", pos, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestZobristTranspositions checks that the same position reached by
+// different move orders hashes alike, and that side to move, castling
+// rights and the en passant square tell positions apart.
+func TestZobristTranspositions(t *testing.T) {
+	play := func(moves string) *chess.Position {
+		pos := chess.StartingPosition()
+		for _, uci := range strings.Fields(moves) {
+			move, err := UCIToMove(pos, uci)
+			if err != nil {
+				t.Fatalf("%s: %v", uci, err)
+			}
+			pos = pos.Update(move)
+		}
+		return pos
+	}
+	if a, b := play("g1f3 g8f6 b1c3 b8c6"), play("b1c3 b8c6 g1f3 g8f6"); Zobrist(a) != Zobrist(b) {
+		t.Error("a transposition hashes differently")
+	}
+	if a, b := play("g1f3 g8f6 f3g1 f6g8"), chess.StartingPosition(); Zobrist(a) != Zobrist(b) {
+		t.Error("the start position reached again hashes differently")
+	}
+	if a, b := play("g1f3 g8f6 f3g1"), play("g1f3 g8f6 f3g1 f6g8 g1f3 g8f6 f3g1"); Zobrist(a) != Zobrist(b) {
+		t.Error("a repetition hashes differently")
+	}
+	if a, b := play("e2e3 e7e6 e1e2 e8e7 e2e1 e7e8"), chess.StartingPosition(); Zobrist(a) == Zobrist(b) {
+		t.Error("castling rights make no difference")
+	}
+	if a, b := play("e2e4"), testPosition(t, "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1"); Zobrist(a) == Zobrist(b) {
+		t.Error("the en passant square makes no difference")
+	}
+}
@@ -0,0 +1,178 @@
+// Package book implements opening books for the engines. Books are keyed by
+// position, using Polyglot's keys, so book moves are found after
+// transpositions too.
+//
+// The built-in book holds the ECO opening lines that ship with
+// github.com/notnil/chess. Other books are read from PGN files: the first
+// plies of every game are added, each occurrence of a move counting towards
+// its weight. Files ending in .bin are read as books in Polyglot's layout,
+// such as the ones BuildMain writes.
+package book
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"slices"
+	"strings"
+
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+	"github.com/notnil/chess/opening"
+)
+
+// DefaultPlies is how deep into each game LoadPGN reads by default.
+const DefaultPlies = 20
+
+// Book maps positions to the moves played from them.
+type Book struct {
+	positions map[uint64][]Entry
+}
+
+// Entry is a book move, in UCI notation, and how often it was seen.
+type Entry struct {
+	Move   string
+	Weight int
+}
+
+// New returns an empty book.
+func New() *Book {
+	return &Book{positions: map[uint64][]Entry{}}
+}
+
+// Open returns the built-in book if path is empty, and otherwise reads the
+// file at path: with LoadPolyglot if its name ends in .bin, and with
+// LoadPGN if not.
+func Open(path string) (*Book, error) {
+	if path == "" {
+		return ECO(), nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var b *Book
+	if strings.HasSuffix(path, ".bin") {
+		b, err = LoadPolyglot(f)
+	} else {
+		b, err = LoadPGN(f, DefaultPlies)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return b, nil
+}
+
+// ECO returns a book of the named openings of the Encyclopaedia of Chess
+// Openings, every line weighted equally.
+func ECO() *Book {
+	b := New()
+	for _, o := range opening.NewBookECO().Possible(nil) {
+		// Despite the name, PGN returns the line as UCI moves
+		b.addUCI(o.PGN())
+	}
+	return b
+}
+
+// addUCI adds a line of moves such as "e2e4 e7e5 g1f3". It stops at the
+// first move that is not legal.
+func (b *Book) addUCI(line string) {
+	pos := chess.StartingPosition()
+	for _, tok := range strings.Fields(line) {
+		move, err := board.UCIToMove(pos, tok)
+		if err != nil {
+			return
+		}
+		b.Add(pos, move)
+		pos = pos.Update(move)
+	}
+}
+
+// LoadPGN reads a book from the games in r, taking at most maxPlies moves
+// from each.
+func LoadPGN(r io.Reader, maxPlies int) (*Book, error) {
+	b := New()
+	scanner := chess.NewScanner(r)
+	for scanner.Scan() {
+		b.AddGame(scanner.Next(), maxPlies)
+	}
+	if err := scanner.Err(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return b, nil
+}
+
+// AddGame adds the first maxPlies moves of g, or all of them if maxPlies
+// is negative.
+func (b *Book) AddGame(g *chess.Game, maxPlies int) {
+	positions, moves := g.Positions(), g.Moves()
+	for i, move := range moves {
+		if maxPlies >= 0 && i >= maxPlies {
+			break
+		}
+		b.Add(positions[i], move)
+	}
+}
+
+// Add records one occurrence of move in pos. Entries are kept sorted by
+// move, so that picks from a seeded source don't depend on the order the
+// book was built in.
+func (b *Book) Add(pos *chess.Position, move *chess.Move) {
+	b.add(polyglotKey(pos), board.MoveToUCI(move), 1)
+}
+
+// add adds weight to the entry for uci under key, making one if needed.
+func (b *Book) add(key uint64, uci string, weight int) {
+	entries := b.positions[key]
+	i, found := slices.BinarySearchFunc(entries, uci, func(e Entry, uci string) int {
+		return strings.Compare(e.Move, uci)
+	})
+	if found {
+		entries[i].Weight += weight
+		return
+	}
+	b.positions[key] = slices.Insert(entries, i, Entry{Move: uci, Weight: weight})
+}
+
+// Len returns the number of positions in the book.
+func (b *Book) Len() int {
+	return len(b.positions)
+}
+
+// Entries returns the book moves for pos.
+func (b *Book) Entries(pos *chess.Position) []Entry {
+	return b.positions[polyglotKey(pos)]
+}
+
+// Pick chooses a book move for pos at random, in proportion to the
+// weights, drawing from rng, or from the default source if rng is nil. It
+// returns nil when pos is not in the book.
+func (b *Book) Pick(pos *chess.Position, rng *rand.Rand) *chess.Move {
+	entries := b.Entries(pos)
+	total := 0
+	for _, e := range entries {
+		total += e.Weight
+	}
+	if total == 0 {
+		return nil
+	}
+	var n int
+	if rng != nil {
+		n = rng.Intn(total)
+	} else {
+		n = rand.Intn(total)
+	}
+	for _, e := range entries {
+		if n -= e.Weight; n < 0 {
+			// Hash collisions are possible, so the move is checked
+			move, err := bookMove(pos, e.Move)
+			if err != nil {
+				return nil
+			}
+			return move
+		}
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package book
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/notnil/chess"
+)
+
+// buildProgress is how many games BuildMain reads between progress lines.
+const buildProgress = 100000
+
+// Builder gathers the moves of many games into a book: how often each move
+// was played in each position, and how those games went for the side that
+// played it.
+type Builder struct {
+	maxPlies  int
+	positions map[uint64]map[uint16]*MoveStats // by Polyglot key, then Polyglot move
+	games     int
+}
+
+// MoveStats counts the games a move was played in by their result for the
+// side that played it.
+type MoveStats struct {
+	Wins, Draws, Losses int
+}
+
+// Games returns how many games the move was played in.
+func (m *MoveStats) Games() int {
+	return m.Wins + m.Draws + m.Losses
+}
+
+// Weight is the move's weight in a book: its score in half points, so
+// moves that did well are played more often and moves that only lost are
+// never played.
+func (m *MoveStats) Weight() int {
+	return 2*m.Wins + m.Draws
+}
+
+// NewBuilder returns a Builder that takes at most maxPlies moves from each
+// game, or all of them if maxPlies is negative.
+func NewBuilder(maxPlies int) *Builder {
+	return &Builder{maxPlies: maxPlies, positions: map[uint64]map[uint16]*MoveStats{}}
+}
+
+// AddGame adds the opening of g. Games without a result say nothing about
+// how good their moves were, so they are left out and AddGame returns
+// false.
+func (b *Builder) AddGame(g *chess.Game) bool {
+	outcome := g.Outcome()
+	if outcome == chess.NoOutcome {
+		return false
+	}
+	b.games++
+	positions, moves := g.Positions(), g.Moves()
+	for i, move := range moves {
+		if b.maxPlies >= 0 && i >= b.maxPlies {
+			break
+		}
+		pos := positions[i]
+		key := polyglotKey(pos)
+		if b.positions[key] == nil {
+			b.positions[key] = map[uint16]*MoveStats{}
+		}
+		code := polyglotMove(move)
+		stats := b.positions[key][code]
+		if stats == nil {
+			stats = &MoveStats{}
+			b.positions[key][code] = stats
+		}
+		switch {
+		case outcome == chess.Draw:
+			stats.Draws++
+		case (outcome == chess.WhiteWon) == (pos.Turn() == chess.White):
+			stats.Wins++
+		default:
+			stats.Losses++
+		}
+	}
+	return true
+}
+
+// Games returns how many games were added.
+func (b *Builder) Games() int {
+	return b.games
+}
+
+// WritePolyglot writes the book to w in Polyglot's layout and returns how
+// many positions and moves it holds. A move needs minGames games and a
+// score of minScore (a fraction, 0.5 for even) to be kept, and moves with
+// no weight are dropped. Weights too big for Polyglot's 16 bits are all
+// scaled down alike.
+func (b *Builder) WritePolyglot(w io.Writer, minGames int, minScore float64) (positions, moves int, err error) {
+	var entries []polyglotEntry
+	var weights []int
+	heaviest := 0
+	for key, byMove := range b.positions {
+		kept := false
+		for code, stats := range byMove {
+			games, weight := stats.Games(), stats.Weight()
+			if games < minGames || weight == 0 || float64(weight) < 2*minScore*float64(games) {
+				continue
+			}
+			entries = append(entries, polyglotEntry{Key: key, Move: code})
+			weights = append(weights, weight)
+			heaviest = max(heaviest, weight)
+			kept = true
+		}
+		if kept {
+			positions++
+		}
+	}
+	scale := 1.0
+	if heaviest > math.MaxUint16 {
+		scale = float64(math.MaxUint16) / float64(heaviest)
+	}
+	for i, weight := range weights {
+		entries[i].Weight = uint16(max(1, int(float64(weight)*scale)))
+	}
+	return positions, len(entries), writePolyglot(w, entries)
+}
+
+// BuildMain reads PGN files into a book in Polyglot's layout that the
+// engines' BookFile option can load.
+func BuildMain(args []string) error {
+	fs := flag.NewFlagSet("book", flag.ExitOnError)
+	out := fs.String("o", "book.bin", "the book file to write")
+	plies := fs.Int("plies", DefaultPlies, "moves to take from each game (-1 for all)")
+	minGames := fs.Int("min-games", 3, "games a move needs to be in the book")
+	minScore := fs.Float64("min-score", 0, "score a move needs to be in the book, as a percentage for the side playing it")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: book [flags] <file.pgn>...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	b := NewBuilder(*plies)
+	for _, path := range fs.Args() {
+		if err := b.addFile(path); err != nil {
+			return err
+		}
+	}
+
+	tmp := *out + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	positions, moves, err := b.WritePolyglot(f, *minGames, *minScore/100)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, *out)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	fmt.Printf("%d games, %d positions, %d moves written to %s\n", b.Games(), positions, moves, *out)
+	return nil
+}
+
+// addFile adds the games of the PGN file at path. Games that don't parse
+// are skipped and counted; an error from reading the file itself stops it.
+func (b *Builder) addFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	read, unfinished, bad := 0, 0, 0
+	var first, last error
+	scanner := chess.NewScanner(f)
+	for {
+		if scanner.Scan() {
+			if read++; read%buildProgress == 0 {
+				fmt.Fprintf(os.Stderr, "%s: %d games\n", path, read)
+			}
+			if !b.AddGame(scanner.Next()) {
+				unfinished++
+			}
+			continue
+		}
+		err := scanner.Err()
+		if err == io.EOF {
+			break
+		}
+		// A game that doesn't parse is a new error each time, while a
+		// read error comes back unchanged from every Scan
+		if err == last {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if first == nil {
+			first = err
+		}
+		last = err
+		bad++
+	}
+	if unfinished > 0 {
+		fmt.Fprintf(os.Stderr, "%s: skipped %d games without a result\n", path, unfinished)
+	}
+	if bad > 0 {
+		fmt.Fprintf(os.Stderr, "%s: skipped %d games that don't parse, the first: %v\n", path, bad, first)
+	}
+	return nil
+}
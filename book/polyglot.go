@@ -0,0 +1,188 @@
+package book
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+)
+
+// Polyglot books are files of 16-byte big-endian entries sorted by key:
+// the position's key, the move, its weight and a learn field that this
+// package leaves zero. The keys are Polyglot's own (see polyglotKey), so
+// books are shared both ways with other programs that read or write them.
+const polyglotEntrySize = 16
+
+// Offsets into polyglotRandom of the keys after the pieces'.
+const (
+	polyglotCastleKeys    = 768 // White short, White long, Black short, Black long
+	polyglotEnPassantKeys = 772 // by file
+	polyglotTurnKey       = 780 // White to move
+)
+
+// polyglotPieceKinds orders the piece types as Polyglot's piece keys do,
+// each with the black piece first and then the white one.
+var polyglotPieceKinds = map[chess.PieceType]int{
+	chess.Pawn: 0, chess.Knight: 1, chess.Bishop: 2, chess.Rook: 3, chess.Queen: 4, chess.King: 5,
+}
+
+// polyglotKey returns Polyglot's key for pos. Unlike board.Zobrist it
+// counts the en passant file only when a pawn of the side to move stands
+// next to the pawn that has just moved two squares, and so could take it.
+func polyglotKey(pos *chess.Position) uint64 {
+	var key uint64
+	for sq, p := range pos.Board().SquareMap() {
+		kind := 2 * polyglotPieceKinds[p.Type()]
+		if p.Color() == chess.White {
+			kind++
+		}
+		key ^= polyglotRandom[64*kind+int(sq)]
+	}
+
+	rights := pos.CastleRights()
+	for i, c := range []struct {
+		color chess.Color
+		side  chess.Side
+	}{{chess.White, chess.KingSide}, {chess.White, chess.QueenSide}, {chess.Black, chess.KingSide}, {chess.Black, chess.QueenSide}} {
+		if rights.CanCastle(c.color, c.side) {
+			key ^= polyglotRandom[polyglotCastleKeys+i]
+		}
+	}
+
+	if ep := pos.EnPassantSquare(); ep != chess.NoSquare {
+		// The pawn that moved stands beside the capturing pawns' square
+		turn := pos.Turn()
+		rank := chess.Rank5
+		if turn == chess.Black {
+			rank = chess.Rank4
+		}
+		pawn := chess.NewPiece(chess.Pawn, turn)
+		for _, file := range []int{int(ep.File()) - 1, int(ep.File()) + 1} {
+			if file >= 0 && file < 8 && pos.Board().Piece(chess.NewSquare(chess.File(file), rank)) == pawn {
+				key ^= polyglotRandom[polyglotEnPassantKeys+int(ep.File())]
+				break
+			}
+		}
+	}
+
+	if pos.Turn() == chess.White {
+		key ^= polyglotRandom[polyglotTurnKey]
+	}
+	return key
+}
+
+// polyglotPromotions are the promotion pieces in the order of their codes,
+// from 1.
+const polyglotPromotions = "nbrq"
+
+// polyglotCastling maps castling, which Polyglot writes as the king taking
+// its own rook, to UCI.
+var polyglotCastling = map[string]string{"e1h1": "e1g1", "e1a1": "e1c1", "e8h8": "e8g8", "e8a8": "e8c8"}
+
+// polyglotEntry is one entry of a Polyglot book.
+type polyglotEntry struct {
+	Key    uint64
+	Move   uint16
+	Weight uint16
+	Learn  uint32
+}
+
+// polyglotMove encodes move the way Polyglot does: the to square in bits
+// 0-5, the from square in bits 6-11 and the promotion piece in bits 12-14.
+func polyglotMove(move *chess.Move) uint16 {
+	from, to := move.S1(), move.S2()
+	switch {
+	case move.HasTag(chess.KingSideCastle):
+		to = chess.NewSquare(chess.FileH, from.Rank())
+	case move.HasTag(chess.QueenSideCastle):
+		to = chess.NewSquare(chess.FileA, from.Rank())
+	}
+	code := uint16(to) | uint16(from)<<6
+	if promo := move.Promo(); promo != chess.NoPieceType {
+		code |= uint16(strings.Index(polyglotPromotions, promo.String())+1) << 12
+	}
+	return code
+}
+
+// polyglotUCI decodes a Polyglot move into UCI. Castling stays the king
+// taking its rook; bookMove reads it once the position is known.
+func polyglotUCI(code uint16) (string, error) {
+	to, from, promo := chess.Square(code&63), chess.Square(code>>6&63), int(code>>12&7)
+	if promo > len(polyglotPromotions) {
+		return "", fmt.Errorf("move %#04x has unknown promotion %d", code, promo)
+	}
+	uci := from.String() + to.String()
+	if promo > 0 {
+		uci += polyglotPromotions[promo-1 : promo]
+	}
+	return uci, nil
+}
+
+// bookMove returns the move uci in pos, reading castling written as the
+// king taking its own rook too.
+func bookMove(pos *chess.Position, uci string) (*chess.Move, error) {
+	move, err := board.UCIToMove(pos, uci)
+	if err == nil {
+		return move, nil
+	}
+	if castle, ok := polyglotCastling[uci]; ok {
+		if move, err := board.UCIToMove(pos, castle); err == nil &&
+			(move.HasTag(chess.KingSideCastle) || move.HasTag(chess.QueenSideCastle)) {
+			return move, nil
+		}
+	}
+	return nil, err
+}
+
+// LoadPolyglot reads a book in Polyglot's layout from r.
+func LoadPolyglot(r io.Reader) (*Book, error) {
+	b := New()
+	br := bufio.NewReader(r)
+	for n := 0; ; n++ {
+		var e polyglotEntry
+		if err := binary.Read(br, binary.BigEndian, &e); err != nil {
+			if err == io.EOF {
+				return b, nil
+			}
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				err = fmt.Errorf("entry %d is cut short; Polyglot entries are %d bytes", n, polyglotEntrySize)
+			}
+			return nil, err
+		}
+		uci, err := polyglotUCI(e.Move)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", n, err)
+		}
+		b.add(e.Key, uci, int(e.Weight))
+	}
+}
+
+// writePolyglot writes entries to w in the order Polyglot readers search
+// them: by key, and the heaviest move first within a position.
+func writePolyglot(w io.Writer, entries []polyglotEntry) error {
+	slices.SortFunc(entries, func(a, b polyglotEntry) int {
+		switch {
+		case a.Key != b.Key:
+			if a.Key < b.Key {
+				return -1
+			}
+			return 1
+		case a.Weight != b.Weight:
+			return int(b.Weight) - int(a.Weight)
+		}
+		return int(a.Move) - int(b.Move)
+	})
+	bw := bufio.NewWriter(w)
+	for _, e := range entries {
+		if err := binary.Write(bw, binary.BigEndian, e); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,71 @@
+package book
+
+import (
+	"bytes"
+	"testing"
+
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+)
+
+// TestPolyglotKey checks polyglotKey against the keys given in Polyglot's
+// book format description. The lines include an en passant square that
+// counts (f7f5 next to the pawn on e5) and ones that don't.
+func TestPolyglotKey(t *testing.T) {
+	tests := []struct {
+		moves string
+		key   uint64
+	}{
+		{"", 0x463b96181691fc9c},
+		{"e2e4", 0x823c9b50fd114196},
+		{"e2e4 d7d5", 0x0756b94461c50fb0},
+		{"e2e4 d7d5 e4e5", 0x662fafb965db29d4},
+		{"e2e4 d7d5 e4e5 f7f5", 0x22a48b5a8e47ff78},
+		{"e2e4 d7d5 e4e5 f7f5 e1e2", 0x652a607ca3f242c1},
+		{"e2e4 d7d5 e4e5 f7f5 e1e2 e8f7", 0x00fdd303c946bdd9},
+		{"a2a4 b7b5 h2h4 b5b4 c2c4", 0x3c8123ea7b067637},
+		{"a2a4 b7b5 h2h4 b5b4 c2c4 b4c3 a1a3", 0x5c3f9b829b279560},
+	}
+	for _, tt := range tests {
+		pos := chess.StartingPosition()
+		for _, uci := range bytes.Fields([]byte(tt.moves)) {
+			move, err := board.UCIToMove(pos, string(uci))
+			if err != nil {
+				t.Fatalf("%q: %v", tt.moves, err)
+			}
+			pos = pos.Update(move)
+		}
+		if got := polyglotKey(pos); got != tt.key {
+			t.Errorf("%q: key %016x, want %016x", tt.moves, got, tt.key)
+		}
+	}
+}
+
+// TestPolyglotRoundTrip writes a book and reads it back.
+func TestPolyglotRoundTrip(t *testing.T) {
+	b := NewBuilder(-1)
+	game := chess.NewGame()
+	for _, san := range []string{"e4", "e5", "Nf3", "Nc6", "Bc4", "Bc5", "O-O"} {
+		if err := game.MoveStr(san); err != nil {
+			t.Fatal(err)
+		}
+	}
+	game.Resign(chess.Black)
+	b.AddGame(game)
+
+	var buf bytes.Buffer
+	if _, moves, err := b.WritePolyglot(&buf, 1, 0); err != nil || moves == 0 {
+		t.Fatalf("WritePolyglot: %d moves, %v", moves, err)
+	}
+	read, err := LoadPolyglot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if move := read.Pick(chess.StartingPosition(), nil); move == nil || board.MoveToUCI(move) != "e2e4" {
+		t.Errorf("start position: book move %v, want e2e4", move)
+	}
+	positions := game.Positions()
+	if move := read.Pick(positions[6], nil); move == nil || !move.HasTag(chess.KingSideCastle) {
+		t.Errorf("castling: book move %v, want e1g1", move)
+	}
+}
@@ -1,120 +1,97 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"log"
+	"math"
 	"math/rand"
-	"os"
-	"strings"
 	"time"
 
+	"chessTomorrow/arbiter"
+	"chessTomorrow/board"
+	"chessTomorrow/book"
 	"github.com/notnil/chess"
 )
 
+// RandomEngine plays a uniformly random legal move, after the opening
+// book runs out. It implements arbiter.ChessEngine, so it can be served
+// over UCI or used in-process.
+//
+// Its random numbers come from the Seed option, and the generator is
+// reseeded on every new game, so a game replays exactly given the same
+// opponent moves. A seed of -1 seeds from the clock instead.
 type RandomEngine struct {
-	game *chess.Game
+	options  *arbiter.Options
+	seed     int64
+	rng      *rand.Rand
+	ownBook  bool
+	bookFile string     // empty for the built-in book
+	book     *book.Book // loaded on first use
 }
 
-// NewRandomEngine initializes the engine with a fresh game
+// NewRandomEngine creates the engine
 func NewRandomEngine() *RandomEngine {
-	return &RandomEngine{game: chess.NewGame()}
+	e := &RandomEngine{ownBook: true}
+	e.NewGame()
+	e.options = arbiter.NewOptions()
+	e.options.AddSpin("Seed", 0, -1, math.MaxInt32, func(n int) { e.seed = int64(n); e.NewGame() })
+	e.options.AddCheck("OwnBook", true, func(on bool) { e.ownBook = on })
+	e.options.AddString("BookFile", "", func(path string) { e.bookFile, e.book = path, nil })
+	return e
 }
 
-// HandleInput routes a single UCI command string
-func (e *RandomEngine) HandleInput(input string) {
-	switch {
-	case input == "uci":
-		fmt.Println("id name RandomEngine")
-		fmt.Println("id author You")
-		fmt.Println("uciok")
-	case input == "isready":
-		fmt.Println("readyok")
-	case strings.HasPrefix(input, "position"):
-		e.setPosition(input)
-	case input[:2] == "go":
-		e.playMove()
-	case input == "quit":
-		os.Exit(0)
+func (e *RandomEngine) Options() *arbiter.Options { return e.options }
+
+// NewGame restarts the random sequence.
+func (e *RandomEngine) NewGame() {
+	seed := e.seed
+	if seed < 0 {
+		seed = time.Now().UnixNano()
 	}
-	os.Stdout.Sync()
+	e.rng = rand.New(rand.NewSource(seed))
 }
 
-// setPosition handles the "position" command and optionally applies a move list
-func (e *RandomEngine) setPosition(command string) {
-	tokens := strings.Fields(command)
-	e.game = nil
+func (e *RandomEngine) Name() string   { return "RandomEngine" }
+func (e *RandomEngine) Author() string { return "You" }
 
-	if len(tokens) < 2 {
-		fmt.Fprintln(os.Stderr, "invalid position command")
-		e.game = chess.NewGame()
-		return
+// GetMove selects a random legal move
+func (e *RandomEngine) GetMove(ctx context.Context, pos *chess.Position, clock arbiter.ClockState) (*chess.Move, error) {
+	moves := pos.ValidMoves()
+	if len(moves) == 0 {
+		return nil, errors.New("no legal moves")
 	}
 
-	switch tokens[1] {
-	case "startpos":
-		e.game = chess.NewGame()
-	case "fen":
-		// Collect FEN string (6 parts), then optionally parse "moves"
-		i := 2
-		fenParts := []string{}
-		for i < len(tokens) && tokens[i] != "moves" {
-			fenParts = append(fenParts, tokens[i])
-			i++
+	if e.ownBook {
+		if e.book == nil {
+			b, err := book.Open(e.bookFile)
+			if err != nil {
+				// Report it once and play on without a book
+				log.Printf("Cannot open the opening book: %v", err)
+				b = book.New()
+			}
+			e.book = b
 		}
-		fenStr := strings.Join(fenParts, " ")
-		pos, err := chess.FEN(fenStr)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "invalid FEN:", err)
-			e.game = chess.NewGame()
-		} else {
-			e.game = chess.NewGame(pos)
+		if move := e.book.Pick(pos, e.rng); move != nil {
+			return move, nil
 		}
-	default:
-		fmt.Fprintln(os.Stderr, "unknown position type:", tokens[1])
-		e.game = chess.NewGame()
 	}
 
-	if e.game == nil {
-		e.game = chess.NewGame()
-	}
-
-	// Apply moves (if any)
-	// for i, token := range tokens {
-	// 	if token == "moves" {
-	// 		for _, moveStr := range tokens[i+1:] {
-	// 			move := uciToMove(e.game, moveStr)
-	// 			if move == nil {
-	// 				fmt.Fprintln(os.Stderr, "invalid move:", moveStr)
-	// 				continue
-	// 			}
-	// 			if err := e.game.Move(move); err != nil {
-	// 				fmt.Fprintln(os.Stderr, "could not apply move:", moveStr, err)
-	// 			}
-	// 		}
-	// 		break
-	// 	}
-	// }
+	return moves[e.rng.Intn(len(moves))], nil
 }
 
-// playMove selects a random legal move and prints it as the bestmove
-func (e *RandomEngine) playMove() {
-	if e.game == nil {
-		fmt.Fprintln(os.Stderr, "no game initialized")
-		fmt.Println("bestmove 0000")
-		return
+// Bench counts the legal move sequences of the given length (perft) from
+// each bench position, which exercises the move generation the engine
+// relies on. The default depth is benchDepth.
+func (e *RandomEngine) Bench(depth int) int64 {
+	if depth <= 0 {
+		depth = benchDepth
 	}
-
-	moves := e.game.ValidMoves()
-	if len(moves) == 0 {
-		fmt.Println("bestmove 0000")
-		return
-	}
-
-	rand.Seed(time.Now().UnixNano())
-	move := moves[rand.Intn(len(moves))]
-	moveStr := move.S1().String() + move.S2().String()
-	if move.Promo() != chess.NoPieceType {
-		moveStr += strings.ToLower(move.Promo().String())
+	var nodes int64
+	for _, pos := range arbiter.BenchPositions() {
+		nodes += board.Perft(pos, depth)
 	}
-	fmt.Println("bestmove", moveStr)
-	os.Stdout.Sync()
+	return nodes
 }
+
+const benchDepth = 3
@@ -1,14 +1,13 @@
 package main
 
 import (
-	"bufio"
-	"os"
+	"log"
+
+	"chessTomorrow/arbiter"
 )
 
 func main() {
-	engine := NewRandomEngine()
-	scanner := bufio.NewScanner(os.Stdin)
-	for scanner.Scan() {
-		engine.HandleInput(scanner.Text())
+	if err := arbiter.RunUCI(NewRandomEngine()); err != nil {
+		log.Fatal(err)
 	}
 }
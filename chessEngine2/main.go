@@ -2,71 +2,16 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"os"
-	"github.com/notnil/chess"
+	"chessTomorrow/alphabeta"
+	"chessTomorrow/arbiter"
 	"fmt"
 	"strings"
 )
 
 
 
-type Engine struct {
-	game *chess.Game
-}
-
-func NewEngine() *Engine {
-	return &Engine{game: chess.NewGame()}
-}
-
-// === UCI Engine Core ===
-
-func (e *Engine) HandleInput(input string) {
-	switch {
-	case input == "uci":
-		fmt.Println("id name AlphaBetaEngine")
-		fmt.Println("id author You")
-		fmt.Println("uciok")
-	case input == "isready":
-		fmt.Println("readyok")
-	case strings.HasPrefix(input, "position"):
-		e.setPosition(input)
-	case input[:2] == "go":
-		e.makeMove()
-	case input == "quit":
-		os.Exit(0)
-	}
-	os.Stdout.Sync()
-}
-
-func (e *Engine) setPosition(cmd string) {
-	tokens := strings.Fields(cmd)
-	if len(tokens) < 2 {
-		e.game = chess.NewGame()
-		return
-	}
-
-	switch tokens[1] {
-	case "startpos":
-		e.game = chess.NewGame()
-	case "fen":
-		fenParts := []string{}
-		i := 2
-		for i < len(tokens) && tokens[i] != "moves" {
-			fenParts = append(fenParts, tokens[i])
-			i++
-		}
-		fenStr := strings.Join(fenParts, " ")
-		pos, err := chess.FEN(fenStr)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "invalid FEN:", err)
-			e.game = chess.NewGame()
-		} else {
-			e.game = chess.NewGame(pos)
-		}
-	}
-}
-
-
 func NewScanner(r *os.File) *Scanner {
 	return &Scanner{r: r}
 }
@@ -97,9 +42,43 @@ func (s *Scanner) Text() string {
 }
 
 func main() {
-	engine := NewEngine()
-	scanner := bufio.NewScanner(os.Stdin)
+	if len(os.Args) > 1 {
+		commands := map[string]func([]string) error{"tune": alphabeta.TuneMain, "tree": alphabeta.TreeMain}
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	params := flag.String("params", "", "load evaluation parameters written by the tune command from this file")
+	flag.Parse()
+	if *params != "" {
+		if err := alphabeta.LoadEvalParams(*params); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	engine := alphabeta.NewEngine()
+	in := bufio.NewReader(os.Stdin)
+	first, _ := in.ReadString('\n')
+	if strings.TrimSpace(first) == "xboard" {
+		if err := arbiter.ServeXBoard(engine, in, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if !engine.HandleInput(strings.TrimRight(first, "\r\n")) {
+		return
+	}
+	scanner := bufio.NewScanner(in)
 	for scanner.Scan() {
-		engine.HandleInput(scanner.Text())
+		if !engine.HandleInput(scanner.Text()) {
+			return
+		}
 	}
 }
@@ -0,0 +1,21 @@
+// Command bookbuilder builds an opening book from PGN files: the moves of
+// the games' openings, weighted by how they scored, written in Polyglot's
+// layout for the engines' BookFile option. It is the book command of
+// chessengine on its own.
+//
+//	bookbuilder [-o book.bin] [-plies n] [-min-games n] [-min-score pct] <file.pgn>...
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"chessTomorrow/book"
+)
+
+func main() {
+	if err := book.BuildMain(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "bookbuilder: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,79 @@
+// Command chessengine runs the engines, arbiters and tools of this
+// repository from one binary:
+//
+//	chessengine play [flags] <white> <black>
+//	chessengine match [flags] [engine...]
+//	chessengine serve [flags]
+//	chessengine perft [flags]
+//	chessengine bench [flags]
+//	chessengine benchgen [flags]
+//	chessengine analyze [flags]
+//	chessengine book [flags] <file.pgn>...
+//	chessengine tablebase [flags] [ending...]
+//	chessengine spsa [flags] -param Name=value,min,max... <engine>
+//	chessengine evalserver [flags]
+//	chessengine rpcserve [flags]
+//	chessengine ratings [flags] <file.pgn>...
+//
+// Each command takes -h for its flags.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"chessTomorrow/alphabeta"
+	"chessTomorrow/benchgen"
+	"chessTomorrow/book"
+	"chessTomorrow/enginerpc"
+	"chessTomorrow/match"
+	"chessTomorrow/ratings"
+	"chessTomorrow/tablebase"
+	"chessTomorrow/webarbiter"
+)
+
+type command struct {
+	run  func(args []string) error
+	help string
+}
+
+var commands = map[string]command{
+	"play":       {match.PlayMain, "play one game between two engines and print it as PGN"},
+	"match":      {match.MatchMain, "play a match or tournament between UCI engines"},
+	"serve":      {webarbiter.ServeMain, "serve a web page to play against an engine"},
+	"perft":      {perftMain, "count the legal move sequences from a position"},
+	"bench":      {alphabeta.BenchMain, "search the bench positions and report the node count"},
+	"benchgen":   {benchgen.BenchMain, "benchmark move generation, move checking, make/unmake and perft"},
+	"analyze":    {match.AnalyzeMain, "annotate games with engine evaluations, or analyze a position"},
+	"book":       {book.BuildMain, "build an opening book from PGN files"},
+	"tablebase":  {tablebase.GenerateMain, "generate endgame tablebases, or look a position up in them"},
+	"spsa":       {match.SPSAMain, "tune an engine's UCI options by SPSA over short self-play matches"},
+	"evalserver": {alphabeta.EvalServerMain, "serve the engine's evaluations of batches of FENs over HTTP"},
+	"rpcserve":   {enginerpc.ServeMain, "serve the engine over gRPC, for play and analyze on other machines"},
+	"ratings":    {ratings.RatingsMain, "fit Elo ratings with error bars to the games of PGN files"},
+}
+
+// order lists the commands for the usage text.
+var order = []string{"play", "match", "serve", "perft", "bench", "benchgen", "analyze", "book", "tablebase", "spsa", "evalserver", "rpcserve", "ratings"}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: chessengine <command> [flags] [args]\n\ncommands:")
+	for _, name := range order {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].help)
+	}
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+)
+
+func perftMain(args []string) error {
+	fs := flag.NewFlagSet("perft", flag.ExitOnError)
+	fen := fs.String("fen", chess.StartingPosition().String(), "position to count from")
+	depth := fs.Int("depth", 4, "length of the move sequences")
+	divide := fs.Bool("divide", false, "also print the count after each legal move")
+	fs.Parse(args)
+
+	pos, err := board.ParseFEN(*fen, board.StrictFEN)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	var nodes int64
+	if *divide && *depth > 0 {
+		for _, move := range pos.ValidMoves() {
+			n := board.Perft(pos.Update(move), *depth-1)
+			fmt.Printf("%s: %d\n", board.MoveToUCI(move), n)
+			nodes += n
+		}
+		fmt.Println()
+	} else {
+		nodes = board.Perft(pos, *depth)
+	}
+	elapsed := time.Since(start)
+	fmt.Printf("Nodes: %d\n", nodes)
+	fmt.Printf("Time:  %v (%.0f nodes/s)\n", elapsed.Round(time.Millisecond), float64(nodes)/elapsed.Seconds())
+	return nil
+}
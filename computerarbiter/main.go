@@ -1,5 +1,15 @@
 package main
 
+import (
+	"fmt"
+	"os"
+
+	"chessTomorrow/match"
+)
+
 func main() {
-	Play("./chessEngine2/randomengine2", "./maia1900.sh", 10)
+	if err := match.MatchMain(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
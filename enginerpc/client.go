@@ -0,0 +1,137 @@
+package enginerpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"chessTomorrow/arbiter"
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Scheme starts the engine names that Dial takes, such as
+// "grpc://host:50051".
+const Scheme = "grpc://"
+
+// callTimeout bounds every call but Search, and how long a stopped search
+// may take to send its move.
+const callTimeout = 10 * time.Second
+
+// Client plays an engine served over gRPC as an arbiter.ChessEngine, with
+// a game of its own on the server.
+type Client struct {
+	Name   string
+	Author string
+
+	conn   *grpc.ClientConn
+	engine EngineServiceClient
+	gameID string
+	last   arbiter.SearchInfo
+}
+
+// Dial connects to the engine server at addr, with or without Scheme in
+// front, and starts a game on it.
+func Dial(addr string) (*Client, error) {
+	conn, err := grpc.NewClient(strings.TrimPrefix(addr, Scheme), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{conn: conn, engine: NewEngineServiceClient(conn)}
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	reply, err := c.engine.NewGame(ctx, &NewGameRequest{})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("%s: %w", addr, err)
+	}
+	c.gameID, c.Name, c.Author = reply.GameId, reply.Name, reply.Author
+	return c, nil
+}
+
+// SetOption sets a UCI option of the client's engine.
+func (c *Client) SetOption(name, value string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	_, err := c.engine.SetOption(ctx, &SetOptionRequest{GameId: c.gameID, Name: name, Value: value})
+	return err
+}
+
+// GetMove sets pos on the server and searches it within the clock's
+// limits, or until ctx is done, when the server is told to stop and its
+// best move so far is still taken.
+func (c *Client) GetMove(ctx context.Context, pos *chess.Position, clock arbiter.ClockState) (*chess.Move, error) {
+	c.last = arbiter.SearchInfo{}
+	setCtx, cancel := context.WithTimeout(ctx, callTimeout)
+	_, err := c.engine.SetPosition(setCtx, &SetPositionRequest{GameId: c.gameID, Fen: pos.String()})
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	// The stream outlives ctx by as long as the server takes to answer
+	// Stop with its best move, up to callTimeout
+	streamCtx, cancelStream := context.WithCancel(context.Background())
+	defer cancelStream()
+	stream, err := c.engine.Search(streamCtx, &SearchRequest{
+		GameId:    c.gameID,
+		Wtime:     clock.WhiteTime.Milliseconds(),
+		Btime:     clock.BlackTime.Milliseconds(),
+		Winc:      clock.WhiteInc.Milliseconds(),
+		Binc:      clock.BlackInc.Milliseconds(),
+		Movestogo: int32(clock.MovesToGo),
+		Movetime:  clock.MoveTime.Milliseconds(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	stopped := context.AfterFunc(ctx, func() {
+		stopCtx, cancel := context.WithTimeout(context.Background(), callTimeout)
+		defer cancel()
+		c.engine.Stop(stopCtx, &GameRef{GameId: c.gameID})
+		time.AfterFunc(callTimeout, cancelStream)
+	})
+	defer stopped()
+
+	for {
+		update, err := stream.Recv()
+		if err == io.EOF {
+			return nil, errors.New("the search ended without a move")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if update.Depth > 0 {
+			c.last = arbiter.SearchInfo{
+				Depth:    int(update.Depth),
+				Nodes:    update.Nodes,
+				HasScore: true,
+				Score:    int(update.Score),
+				Mate:     int(update.Mate),
+				PV:       update.Pv,
+			}
+		}
+		if update.BestMove != "" {
+			return board.UCIToMove(pos, update.BestMove)
+		}
+	}
+}
+
+// LastSearch implements arbiter.SearchReporter: the last iteration the
+// server sent during the last search, empty for a book move.
+func (c *Client) LastSearch() arbiter.SearchInfo {
+	return c.last
+}
+
+// Close ends the game on the server and disconnects.
+func (c *Client) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	c.engine.EndGame(ctx, &GameRef{GameId: c.gameID})
+	c.conn.Close()
+}
@@ -0,0 +1,287 @@
+// Package enginerpc plays engines over gRPC instead of UCI, so that an
+// engine can run on another machine than the arbiter. Server serves the
+// built-in engine, each client game getting an engine of its own, and
+// Client plays a served engine as an arbiter.ChessEngine.
+//
+// The service is defined in engine.proto; after changing it, regenerate
+// engine.pb.go and engine_grpc.pb.go with protoc-gen-go and
+// protoc-gen-go-grpc:
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//	    --go-grpc_out=. --go-grpc_opt=paths=source_relative engine.proto
+package enginerpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"chessTomorrow/alphabeta"
+	"chessTomorrow/arbiter"
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server serves the built-in engine. Games nobody has used for its idle
+// time are ended, so that clients that go away don't keep their engines.
+type Server struct {
+	UnimplementedEngineServiceServer
+
+	idle time.Duration
+
+	mu    sync.Mutex
+	games map[string]*serverGame
+	done  chan struct{}
+}
+
+// serverGame is one client game: its engine, the position to search and
+// the search going on, if any.
+type serverGame struct {
+	mu       sync.Mutex // guards game and used
+	engine   *alphabeta.Engine
+	game     *chess.Game
+	used     time.Time
+	searchMu sync.Mutex // held by the running search
+	cancel   context.CancelFunc
+}
+
+// NewServer returns a Server that ends games unused for idle.
+func NewServer(idle time.Duration) *Server {
+	s := &Server{idle: idle, games: make(map[string]*serverGame), done: make(chan struct{})}
+	go s.expire()
+	return s
+}
+
+// Close ends every game.
+func (s *Server) Close() {
+	close(s.done)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, g := range s.games {
+		g.stop()
+		delete(s.games, id)
+	}
+}
+
+// expire ends idle games every so often.
+func (s *Server) expire() {
+	tick := time.NewTicker(max(s.idle/4, time.Second))
+	defer tick.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-tick.C:
+		}
+		s.mu.Lock()
+		for id, g := range s.games {
+			g.mu.Lock()
+			idle := time.Since(g.used) > s.idle
+			g.mu.Unlock()
+			if idle && g.searchMu.TryLock() {
+				g.searchMu.Unlock()
+				delete(s.games, id)
+				log.Printf("Game %s ended after %v unused", id, s.idle)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// find returns the game with the given ID, marked as used.
+func (s *Server) find(id string) (*serverGame, error) {
+	s.mu.Lock()
+	g := s.games[id]
+	s.mu.Unlock()
+	if g == nil {
+		return nil, status.Errorf(codes.NotFound, "no game %q", id)
+	}
+	g.mu.Lock()
+	g.used = time.Now()
+	g.mu.Unlock()
+	return g, nil
+}
+
+// stop cancels the game's search, if one is running.
+func (g *serverGame) stop() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.cancel != nil {
+		g.cancel()
+	}
+}
+
+func (s *Server) NewGame(ctx context.Context, req *NewGameRequest) (*NewGameReply, error) {
+	var b [16]byte
+	rand.Read(b[:])
+	id := hex.EncodeToString(b[:])
+	e := alphabeta.NewEngine()
+	g := &serverGame{engine: e, game: chess.NewGame(), used: time.Now()}
+	s.mu.Lock()
+	s.games[id] = g
+	s.mu.Unlock()
+	return &NewGameReply{GameId: id, Name: e.Name(), Author: e.Author()}, nil
+}
+
+func (s *Server) SetOption(ctx context.Context, req *SetOptionRequest) (*Empty, error) {
+	g, err := s.find(req.GameId)
+	if err != nil {
+		return nil, err
+	}
+	g.searchMu.Lock()
+	defer g.searchMu.Unlock()
+	cmd := "setoption name " + req.Name
+	if req.Value != "" {
+		cmd += " value " + req.Value
+	}
+	if err := g.engine.Options().SetOption(cmd); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &Empty{}, nil
+}
+
+func (s *Server) SetPosition(ctx context.Context, req *SetPositionRequest) (*Empty, error) {
+	g, err := s.find(req.GameId)
+	if err != nil {
+		return nil, err
+	}
+	game := chess.NewGame()
+	if req.Fen != "" {
+		pos, err := board.ParseFEN(req.Fen, board.StrictFEN)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		opt, _ := chess.FEN(pos.String())
+		game = chess.NewGame(opt)
+	}
+	for _, uci := range req.Moves {
+		move, err := board.UCIToMove(game.Position(), uci)
+		if err == nil {
+			err = game.Move(move)
+		}
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "move %s: %v", uci, err)
+		}
+	}
+	g.mu.Lock()
+	g.game = game
+	g.mu.Unlock()
+	return &Empty{}, nil
+}
+
+func (s *Server) Search(req *SearchRequest, stream grpc.ServerStreamingServer[SearchUpdate]) error {
+	g, err := s.find(req.GameId)
+	if err != nil {
+		return err
+	}
+	// One search at a time per game, as over UCI
+	if !g.searchMu.TryLock() {
+		return status.Error(codes.FailedPrecondition, "the game's engine is already searching")
+	}
+	defer g.searchMu.Unlock()
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	g.mu.Lock()
+	game := g.game
+	g.cancel = cancel
+	g.mu.Unlock()
+	defer func() {
+		g.mu.Lock()
+		g.cancel = nil
+		g.mu.Unlock()
+	}()
+
+	clock := arbiter.ClockState{
+		WhiteTime: time.Duration(req.Wtime) * time.Millisecond,
+		BlackTime: time.Duration(req.Btime) * time.Millisecond,
+		WhiteInc:  time.Duration(req.Winc) * time.Millisecond,
+		BlackInc:  time.Duration(req.Binc) * time.Millisecond,
+		MoveTime:  time.Duration(req.Movetime) * time.Millisecond,
+		MovesToGo: int(req.Movestogo),
+	}
+	// Iterations are sent as they complete, from the search itself
+	var sendErr error
+	info := func(si arbiter.SearchInfo) {
+		if sendErr == nil {
+			sendErr = stream.Send(updateOf(si))
+		}
+	}
+	best, ponder, err := g.engine.SearchGame(ctx, game, clock, int(req.Depth), info)
+	if err != nil {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if sendErr != nil {
+		return sendErr
+	}
+	last := updateOf(g.engine.LastSearch())
+	last.BestMove = board.MoveToUCI(best)
+	if ponder != nil {
+		last.Ponder = board.MoveToUCI(ponder)
+	}
+	return stream.Send(last)
+}
+
+func (s *Server) Stop(ctx context.Context, req *GameRef) (*Empty, error) {
+	g, err := s.find(req.GameId)
+	if err != nil {
+		return nil, err
+	}
+	g.stop()
+	return &Empty{}, nil
+}
+
+func (s *Server) EndGame(ctx context.Context, req *GameRef) (*Empty, error) {
+	g, err := s.find(req.GameId)
+	if err != nil {
+		return nil, err
+	}
+	g.stop()
+	s.mu.Lock()
+	delete(s.games, req.GameId)
+	s.mu.Unlock()
+	return &Empty{}, nil
+}
+
+// updateOf turns what a search found into its message.
+func updateOf(si arbiter.SearchInfo) *SearchUpdate {
+	return &SearchUpdate{
+		Depth: int32(si.Depth),
+		Nodes: si.Nodes,
+		Score: int32(si.Score),
+		Mate:  int32(si.Mate),
+		Pv:    si.PV,
+	}
+}
+
+// ServeMain runs the rpcserve command: the built-in engine served over
+// gRPC on -addr.
+func ServeMain(args []string) error {
+	fs := flag.NewFlagSet("rpcserve", flag.ExitOnError)
+	addr := fs.String("addr", ":50051", "address to serve on")
+	idle := fs.Duration("idle", 30*time.Minute, "time after which a game nobody has used is ended")
+	fs.Parse(args)
+
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	s := NewServer(*idle)
+	defer s.Close()
+	srv := grpc.NewServer()
+	RegisterEngineServiceServer(srv, s)
+	log.Printf("Serving the engine over gRPC on %s", lis.Addr())
+	if err := srv.Serve(lis); err != nil {
+		return fmt.Errorf("serve: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+// Package epd reads Extended Position Description files and runs engines
+// against the best-move tests they contain, as used by suites such as WAC
+// and STS.
+package epd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+)
+
+// Test is one EPD record: a position and its operations. BestMoves and
+// AvoidMoves are the "bm" and "am" operands resolved to legal moves.
+type Test struct {
+	ID         string
+	Position   *chess.Position
+	BestMoves  []*chess.Move
+	AvoidMoves []*chess.Move
+	Ops        map[string][]string // every operation, keyed by opcode
+}
+
+// ParseFile parses the EPD file at path.
+func ParseFile(path string) ([]Test, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads one EPD record per line. Blank lines and lines starting with
+// '#' are skipped.
+func Parse(r io.Reader) ([]Test, error) {
+	var tests []Test
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		t, err := ParseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("epd: line %d: %w", lineNum, err)
+		}
+		tests = append(tests, t)
+	}
+	return tests, scanner.Err()
+}
+
+// ParseLine parses a single EPD record such as
+//
+//	r1b1k2r/ppp2ppp/8/8/8/8/PPP2PPP/R1B1K2R w KQkq - bm Bg5; id "test.1";
+func ParseLine(line string) (Test, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 {
+		return Test{}, fmt.Errorf("need 4 position fields, got %d", len(fields))
+	}
+
+	// The first four fields are FEN without the move counters, which may be
+	// given by the hmvc and fmvn operations instead.
+	rest := line
+	for i := 0; i < 4; i++ {
+		rest = strings.TrimLeft(rest, " \t")
+		if j := strings.IndexAny(rest, " \t"); j >= 0 {
+			rest = rest[j:]
+		} else {
+			rest = ""
+		}
+	}
+	ops := parseOps(rest)
+
+	halfMoves, moveNum := "0", "1"
+	if v := ops["hmvc"]; len(v) == 1 {
+		halfMoves = v[0]
+	}
+	if v := ops["fmvn"]; len(v) == 1 {
+		moveNum = v[0]
+	}
+	fen := strings.Join(append(fields[:4:4], halfMoves, moveNum), " ")
+	pos, err := board.ParseFEN(fen, board.LenientFEN)
+	if err != nil {
+		return Test{}, err
+	}
+
+	t := Test{
+		Position: pos,
+		Ops:      ops,
+	}
+	if v := ops["id"]; len(v) > 0 {
+		t.ID = v[0]
+	}
+	if t.BestMoves, err = decodeMoves(t.Position, ops["bm"]); err != nil {
+		return Test{}, err
+	}
+	if t.AvoidMoves, err = decodeMoves(t.Position, ops["am"]); err != nil {
+		return Test{}, err
+	}
+	return t, nil
+}
+
+// parseOps splits the operation part of a record into opcodes and their
+// operands. Operations end with ';' and quoted operands may contain spaces
+// or semicolons.
+func parseOps(s string) map[string][]string {
+	ops := map[string][]string{}
+	var tokens []string
+	var cur strings.Builder
+	inQuote, quoted := false, false
+
+	flushToken := func() {
+		if cur.Len() > 0 || quoted {
+			tokens = append(tokens, cur.String())
+		}
+		cur.Reset()
+		quoted = false
+	}
+	flushOp := func() {
+		flushToken()
+		if len(tokens) > 0 {
+			ops[tokens[0]] = tokens[1:]
+		}
+		tokens = nil
+	}
+
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			quoted = true
+		case inQuote:
+			cur.WriteRune(r)
+		case r == ';':
+			flushOp()
+		case r == ' ' || r == '\t':
+			flushToken()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flushOp()
+	return ops
+}
+
+// decodeMoves resolves SAN operands against pos.
+func decodeMoves(pos *chess.Position, sans []string) ([]*chess.Move, error) {
+	var moves []*chess.Move
+	for _, san := range sans {
+		m, err := chess.AlgebraicNotation{}.Decode(pos, strings.TrimRight(san, "!?"))
+		if err != nil {
+			return nil, fmt.Errorf("move %q is not legal in %s", san, pos)
+		}
+		moves = append(moves, m)
+	}
+	return moves, nil
+}
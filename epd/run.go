@@ -0,0 +1,83 @@
+package epd
+
+import (
+	"context"
+	"time"
+
+	"chessTomorrow/arbiter"
+	"chessTomorrow/board"
+
+	"github.com/notnil/chess"
+)
+
+// searchGrace is how long past the move time an engine may take to answer
+// before the test counts as failed.
+const searchGrace = time.Second
+
+// Result is the outcome of one test.
+type Result struct {
+	Test   Test
+	Move   *chess.Move // the engine's move, nil if it failed to produce one
+	Solved bool
+	Err    error
+}
+
+// Report summarises a suite run.
+type Report struct {
+	Results []Result
+	Solved  int
+}
+
+// Run gives engine moveTime to search each test position and checks its
+// move against the bm and am operations: a test is solved when the move is
+// one of the best moves and none of the moves to avoid. Tests with neither
+// operation are skipped. If progress is not nil it is called after each
+// test.
+func Run(engine arbiter.ChessEngine, tests []Test, moveTime time.Duration, progress func(Result)) Report {
+	var rep Report
+	for _, t := range tests {
+		if len(t.BestMoves) == 0 && len(t.AvoidMoves) == 0 {
+			continue
+		}
+		r := runTest(engine, t, moveTime)
+		if r.Solved {
+			rep.Solved++
+		}
+		rep.Results = append(rep.Results, r)
+		if progress != nil {
+			progress(r)
+		}
+	}
+	return rep
+}
+
+func runTest(engine arbiter.ChessEngine, t Test, moveTime time.Duration) Result {
+	ctx, cancel := context.WithTimeout(context.Background(), moveTime+searchGrace)
+	defer cancel()
+
+	r := Result{Test: t}
+	move, err := engine.GetMove(ctx, t.Position, arbiter.ClockState{MoveTime: moveTime})
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	// Engines only name the squares; look up the legal move to compare.
+	if r.Move, r.Err = board.UCIToMove(t.Position, board.MoveToUCI(move)); r.Err != nil {
+		return r
+	}
+
+	r.Solved = len(t.BestMoves) == 0 || containsMove(t.BestMoves, r.Move)
+	if containsMove(t.AvoidMoves, r.Move) {
+		r.Solved = false
+	}
+	return r
+}
+
+func containsMove(moves []*chess.Move, m *chess.Move) bool {
+	for _, c := range moves {
+		if c.S1() == m.S1() && c.S2() == m.S2() && c.Promo() == m.Promo() {
+			return true
+		}
+	}
+	return false
+}
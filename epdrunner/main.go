@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"chessTomorrow/arbiter"
+	"chessTomorrow/epd"
+
+	"github.com/notnil/chess"
+)
+
+// epdrunner runs an engine binary against an EPD test suite and reports
+// how many best-move tests it solves, e.g.
+//
+//	go run ./epdrunner -ms 1000 ./chessEngine2/engine2 wac.epd
+func main() {
+	moveTime := flag.Int("ms", 1000, "search time per position in milliseconds")
+	flag.Parse()
+	if flag.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: epdrunner [-ms N] <engine path> <suite.epd>")
+		os.Exit(2)
+	}
+
+	tests, err := epd.ParseFile(flag.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	engine, err := arbiter.NewUCIEngineAdapter(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer engine.Close()
+
+	rep := epd.Run(engine, tests, time.Duration(*moveTime)*time.Millisecond, func(r epd.Result) {
+		status := "FAIL"
+		if r.Solved {
+			status = "ok  "
+		}
+		switch {
+		case r.Err != nil:
+			fmt.Printf("%s  %-20s error: %v\n", status, r.Test.ID, r.Err)
+		default:
+			fmt.Printf("%s  %-20s %s\n", status, r.Test.ID,
+				chess.AlgebraicNotation{}.Encode(r.Test.Position, r.Move))
+		}
+	})
+
+	fmt.Printf("\n%s: solved %d/%d\n", engine.Name, rep.Solved, len(rep.Results))
+}
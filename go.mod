@@ -4,5 +4,14 @@ go 1.24.2
 
 require (
 	github.com/notnil/chess v1.10.0
+	go.etcd.io/bbolt v1.4.3
 	golang.org/x/net v0.42.0
+	golang.org/x/sys v0.34.0
+	google.golang.org/grpc v1.75.1
+	google.golang.org/protobuf v1.36.11
+)
+
+require (
+	golang.org/x/text v0.27.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250707201910-8d1bb00bc6a7 // indirect
 )
@@ -1,2 +1,2 @@
 #!/bin/bash
-./lc0binary --weights=./maia-1900.pb.gz --backend=blas
\ No newline at end of file
+exec ./lc0binary --weights=./maia-1900.pb.gz --backend=blas
\ No newline at end of file
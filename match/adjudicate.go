@@ -0,0 +1,68 @@
+package match
+
+import (
+	"chessTomorrow/arbiter"
+	"github.com/notnil/chess"
+)
+
+// Adjudication ends games early on the scores the engines report, which
+// keeps long automated matches short. A rule with zero moves is off.
+type Adjudication struct {
+	// A game is drawn once both engines have reported scores within
+	// DrawScore centipawns of zero for DrawMoves moves each in a row, but
+	// not before ply DrawStart.
+	DrawMoves int
+	DrawScore int
+	DrawStart int
+
+	// A side wins once its engine has reported a score of at least
+	// ResignScore centipawns, or a mate, for ResignMoves moves in a row.
+	ResignMoves int
+	ResignScore int
+}
+
+// adjudicator applies an Adjudication to one game.
+type adjudicator struct {
+	Adjudication
+	drawPlies int    // consecutive drawish moves, both sides
+	winning   [3]int // consecutive winning moves, by chess.Color
+}
+
+// observe takes the search behind side's move, which made plies the
+// number of moves played in the game, and returns whether the game is over and who won it (chess.NoColor for
+// a draw). Engines that report no score reset the counts.
+func (a *adjudicator) observe(side chess.Color, eng arbiter.ChessEngine, plies int) (winner chess.Color, over bool) {
+	var info arbiter.SearchInfo
+	if reporter, ok := eng.(arbiter.SearchReporter); ok {
+		info = reporter.LastSearch()
+	}
+
+	switch {
+	case !info.HasScore:
+		a.winning[side] = 0
+	case info.Mate > 0 || info.Mate == 0 && info.Score >= a.ResignScore:
+		a.winning[side]++
+	default:
+		a.winning[side] = 0
+	}
+	if a.ResignMoves > 0 && a.winning[side] >= a.ResignMoves {
+		return side, true
+	}
+
+	if info.HasScore && info.Mate == 0 && abs(info.Score) <= a.DrawScore {
+		a.drawPlies++
+	} else {
+		a.drawPlies = 0
+	}
+	if a.DrawMoves > 0 && a.drawPlies >= 2*a.DrawMoves && plies >= a.DrawStart {
+		return chess.NoColor, true
+	}
+	return chess.NoColor, false
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
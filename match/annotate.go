@@ -0,0 +1,433 @@
+package match
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"chessTomorrow/alphabeta"
+	"chessTomorrow/arbiter"
+	"chessTomorrow/board"
+	"chessTomorrow/render"
+	"github.com/notnil/chess"
+)
+
+// Numeric annotation glyphs for the moves an Annotator marks.
+const (
+	nagMistake    = 2 // ?
+	nagBlunder    = 4 // ??
+	nagInaccuracy = 6 // ?!
+)
+
+// mateCP is what a forced mate counts as when scores are compared, less
+// the moves it takes.
+const mateCP = 10000
+
+// evalCap bounds the scores compared to judge a move: a side this far
+// ahead is winning either way, and a move that keeps it winning is not a
+// blunder however much it gives back.
+const evalCap = 1000
+
+// bestLineLength is how many moves of the engine's line a comment shows.
+const bestLineLength = 6
+
+// Thresholds are how many centipawns a move may lose against the engine's
+// best move before it is marked as an inaccuracy, a mistake or a blunder.
+type Thresholds struct {
+	Inaccuracy int
+	Mistake    int
+	Blunder    int
+}
+
+// classify returns the glyph for a move that lost loss centipawns, or 0
+// for a move that needs none.
+func (t Thresholds) classify(loss int) int {
+	switch {
+	case loss >= t.Blunder:
+		return nagBlunder
+	case loss >= t.Mistake:
+		return nagMistake
+	case loss >= t.Inaccuracy:
+		return nagInaccuracy
+	}
+	return 0
+}
+
+// Annotator comments games with an engine's evaluations. The engine must
+// be an arbiter.SearchReporter, since the scores come from its searches.
+type Annotator struct {
+	Engine     arbiter.ChessEngine
+	MoveTime   time.Duration // search time per position
+	Thresholds Thresholds
+
+	// Diagrams, if set, is a directory that gets an SVG diagram of the
+	// position before each marked move, with the move played in red and
+	// the engine's choice in green.
+	Diagrams string
+	games    int // annotated so far, to name the diagrams
+}
+
+// Arrow colors of the diagrams.
+var (
+	playedArrow = color.RGBA{0xc0, 0x20, 0x20, 0xc0}
+	bestArrow   = color.RGBA{0x15, 0x78, 0x1b, 0xc0}
+)
+
+// DefaultThresholds are the analyze command's thresholds, and those of
+// Annotate.
+var DefaultThresholds = Thresholds{Inaccuracy: 50, Mistake: 100, Blunder: 300}
+
+// Glyphs are the symbols of the glyphs an Annotator marks moves with.
+var Glyphs = map[int]string{nagInaccuracy: "?!", nagMistake: "?", nagBlunder: "??"}
+
+// Annotation is what an engine made of every move of a game.
+type Annotation struct {
+	Moves []MoveAnnotation `json:"moves"`
+	// Accuracy is each side's mean move accuracy in percent, White first;
+	// 0 for a side without moves.
+	Accuracy [2]float64 `json:"accuracy"`
+
+	game *chess.Game
+}
+
+// MoveAnnotation is the engine's verdict on one move.
+type MoveAnnotation struct {
+	SAN      string  `json:"san"`
+	UCI      string  `json:"uci"`
+	Eval     string  `json:"eval"`     // after the move, from White's point of view, such as "+0.35/12"
+	Loss     int     `json:"loss"`     // centipawns lost against the engine's best move
+	Accuracy float64 `json:"accuracy"` // percent
+	NAG      int     `json:"nag,omitempty"`
+	Glyph    string  `json:"glyph,omitempty"`
+	Best     string  `json:"best,omitempty"` // for a marked move, the engine's score and line instead
+}
+
+// Annotate has engine search every position of game for budget and
+// judges each move against the engine's choice with DefaultThresholds.
+// The engine must be an arbiter.SearchReporter.
+func Annotate(game *chess.Game, engine arbiter.ChessEngine, budget time.Duration) (*Annotation, error) {
+	a := &Annotator{Engine: engine, MoveTime: budget, Thresholds: DefaultThresholds}
+	return a.Annotate(game)
+}
+
+// Annotate searches every position of game and judges each move by the
+// centipawns it loses against the engine's best move. Moves that lose
+// enough are marked with a glyph and given the engine's line, and each
+// move's accuracy follows from how much of the mover's winning chances it
+// gave away.
+func (a *Annotator) Annotate(game *chess.Game) (*Annotation, error) {
+	positions, moves := game.Positions(), game.Moves()
+	a.games++
+	infos := make([]arbiter.SearchInfo, len(positions))
+	for i, pos := range positions {
+		info, err := a.evaluate(pos)
+		if err != nil {
+			return nil, fmt.Errorf("move %d: %w", i/2+1, err)
+		}
+		infos[i] = info
+	}
+
+	an := &Annotation{Moves: make([]MoveAnnotation, len(moves)), game: game}
+	var total [2]float64
+	var count [2]int
+	for i, move := range moves {
+		pos, next := positions[i], positions[i+1]
+
+		// Both scores from the mover's point of view. The engine's own
+		// choice loses nothing, whatever the next search says.
+		before := clamp(centipawns(infos[i]), evalCap)
+		after := clamp(-centipawns(infos[i+1]), evalCap)
+		best := len(infos[i].PV) > 0 && infos[i].PV[0] == board.MoveToUCI(move)
+		if best {
+			after = max(after, before)
+		}
+		m := MoveAnnotation{
+			SAN:      chess.AlgebraicNotation{}.Encode(pos, move),
+			UCI:      board.MoveToUCI(move),
+			Eval:     whiteView(infos[i+1], next.Turn()).String(),
+			Loss:     max(0, before-after),
+			Accuracy: moveAccuracy(before, after),
+		}
+		if nag := a.Thresholds.classify(m.Loss); nag != 0 {
+			m.NAG, m.Glyph = nag, Glyphs[nag]
+			if line := bestLine(pos, infos[i].PV); line != "" {
+				m.Best = fmt.Sprintf("%s %s", whiteView(infos[i], pos.Turn()), line)
+			}
+			if err := a.diagram(i, pos, move, infos[i].PV); err != nil {
+				return nil, err
+			}
+		}
+		an.Moves[i] = m
+
+		side := 0
+		if pos.Turn() == chess.Black {
+			side = 1
+		}
+		total[side] += m.Accuracy
+		count[side]++
+	}
+	for side := range total {
+		if count[side] > 0 {
+			an.Accuracy[side] = total[side] / float64(count[side])
+		}
+	}
+	return an, nil
+}
+
+// winChance turns a score in centipawns into the mover's chances in
+// percent, on the curve Lichess fitted to its players' games.
+func winChance(cp int) float64 {
+	return 50 + 50*(2/(1+math.Exp(-0.00368208*float64(cp)))-1)
+}
+
+// moveAccuracy rates a move from the mover's score before and after it:
+// 100 for giving nothing away, falling off with the winning chances lost,
+// again by Lichess's fit.
+func moveAccuracy(before, after int) float64 {
+	lost := max(0, winChance(before)-winChance(after))
+	return max(0, min(100, 103.1668*math.Exp(-0.04354*lost)-3.1669))
+}
+
+// Record returns the game with the evaluation after each move as a
+// comment, the glyphs of marked moves and the engine's line for them.
+func (an *Annotation) Record() *GameRecorder {
+	game := an.game
+	positions := game.Positions()
+	rec := NewGameRecorder(tagValue(game, "White", "?"), tagValue(game, "Black", "?"), positions[0].String())
+	rec.Event = tagValue(game, "Event", "?")
+	if date, err := time.Parse("2006.01.02", tagValue(game, "Date", "")); err == nil {
+		rec.Date = date
+	}
+	for i, move := range game.Moves() {
+		m := an.Moves[i]
+		rec.Record(positions[i], move)
+		comment := m.Eval
+		if m.NAG != 0 {
+			rec.Mark(m.NAG)
+			if m.Best != "" {
+				comment += "; best " + m.Best
+			}
+		}
+		rec.Annotate(comment)
+	}
+	rec.Finish(game.Outcome(), tagValue(game, "Termination", ""))
+	return rec
+}
+
+// evaluate searches pos and returns what the engine found, from the side
+// to move's point of view. A position without legal moves is not searched.
+func (a *Annotator) evaluate(pos *chess.Position) (arbiter.SearchInfo, error) {
+	switch pos.Status() {
+	case chess.Checkmate:
+		return arbiter.SearchInfo{Score: -mateCP}, nil
+	case chess.Stalemate:
+		return arbiter.SearchInfo{}, nil
+	}
+	reporter, ok := a.Engine.(arbiter.SearchReporter)
+	if !ok {
+		return arbiter.SearchInfo{}, errors.New("the engine reports no scores")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), a.MoveTime+hangTimeout)
+	defer cancel()
+	if _, err := a.Engine.GetMove(ctx, pos, arbiter.ClockState{MoveTime: a.MoveTime}); err != nil {
+		return arbiter.SearchInfo{}, err
+	}
+	info := reporter.LastSearch()
+	if !info.HasScore {
+		return arbiter.SearchInfo{}, errors.New("the engine reported no score")
+	}
+	return info, nil
+}
+
+// diagram writes the position before the move at ply to a.Diagrams, drawn
+// from the mover's side.
+func (a *Annotator) diagram(ply int, pos *chess.Position, move *chess.Move, pv []string) error {
+	if a.Diagrams == "" {
+		return nil
+	}
+	opts := render.Options{
+		Coordinates: true,
+		Flipped:     pos.Turn() == chess.Black,
+		Arrows:      []render.Arrow{{From: move.S1(), To: move.S2(), Color: playedArrow}},
+	}
+	if len(pv) > 0 {
+		if best, err := board.UCIToMove(pos, pv[0]); err == nil {
+			opts.Arrows = append(opts.Arrows, render.Arrow{From: best.S1(), To: best.S2(), Color: bestArrow})
+		}
+	}
+	side := "w"
+	if pos.Turn() == chess.Black {
+		side = "b"
+	}
+	name := fmt.Sprintf("game%03d-%d%s.svg", a.games, ply/2+1, side)
+	return os.WriteFile(filepath.Join(a.Diagrams, name), []byte(render.SVG(pos.Board(), opts)), 0o644)
+}
+
+// centipawns turns a score into centipawns for comparison.
+func centipawns(info arbiter.SearchInfo) int {
+	switch {
+	case info.Mate > 0:
+		return mateCP - info.Mate
+	case info.Mate < 0:
+		return -mateCP - info.Mate
+	}
+	return info.Score
+}
+
+// whiteView returns info with its score from White's point of view, given
+// the side to move in the searched position.
+func whiteView(info arbiter.SearchInfo, turn chess.Color) arbiter.SearchInfo {
+	if turn == chess.Black {
+		info.Score, info.Mate = -info.Score, -info.Mate
+	}
+	return info
+}
+
+// bestLine renders the start of a principal variation from pos in SAN.
+func bestLine(pos *chess.Position, pv []string) string {
+	var sans []string
+	for _, uci := range pv[:min(len(pv), bestLineLength)] {
+		move, err := board.UCIToMove(pos, uci)
+		if err != nil {
+			break
+		}
+		sans = append(sans, chess.AlgebraicNotation{}.Encode(pos, move))
+		pos = pos.Update(move)
+	}
+	return strings.Join(sans, " ")
+}
+
+func clamp(x, limit int) int {
+	return max(-limit, min(limit, x))
+}
+
+func tagValue(game *chess.Game, key, def string) string {
+	if pair := game.GetTagPair(key); pair != nil && pair.Value != "" {
+		return pair.Value
+	}
+	return def
+}
+
+// AnalyzeMain runs the analyze command. With -pgn it annotates every game
+// of the file with the engine's evaluations and marks inaccuracies,
+// mistakes and blunders; otherwise it analyzes the -fen position.
+func AnalyzeMain(args []string) error {
+	fs := flag.NewFlagSet("analyze", flag.ExitOnError)
+	pgnPath := fs.String("pgn", "", "PGN file of games to annotate")
+	out := fs.String("out", "", "file to write the annotated games to; empty for stdout")
+	engineName := fs.String("engine", nativeEngine, "registry engine or UCI binary to analyze with, \"alphabeta\" for the built-in one, or grpc://host:port for one served by rpcserve")
+	loadRegistry := registryFlag(fs)
+	moveTime := fs.Int("movetime", 500, "search time per position, in milliseconds")
+	inaccuracy := fs.Int("inaccuracy", DefaultThresholds.Inaccuracy, "centipawns lost that make a move an inaccuracy (?!)")
+	mistake := fs.Int("mistake", DefaultThresholds.Mistake, "centipawns lost that make a move a mistake (?)")
+	blunder := fs.Int("blunder", DefaultThresholds.Blunder, "centipawns lost that make a move a blunder (??)")
+	fen := fs.String("fen", startFEN, "position to analyze without -pgn")
+	depth := fs.Int("depth", 0, "without -pgn, search the built-in engine to this depth instead of for -movetime")
+	multiPV := fs.Int("multipv", 1, "without -pgn, number of best lines the built-in engine shows")
+	diagrams := fs.String("diagrams", "", "with -pgn, directory to write an SVG diagram of each marked move to")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: analyze [flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	searchTime := time.Duration(*moveTime) * time.Millisecond
+
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	if *pgnPath == "" && *engineName == nativeEngine && registry.Find(nativeEngine) == nil {
+		return alphabeta.Analyze(*fen, *depth, searchTime, *multiPV)
+	}
+
+	eng, _, closeEngine, err := openEngine(registry, *engineName)
+	if err != nil {
+		return err
+	}
+	defer closeEngine()
+	// Analysis wants the search's opinion, not the book's
+	switch e := eng.(type) {
+	case *alphabeta.Engine:
+		e.Options().SetOption("setoption name OwnBook value false")
+	case interface {
+		SetOption(name, value string) error
+	}:
+		if err := e.SetOption("OwnBook", "false"); err != nil {
+			return err
+		}
+	}
+	a := &Annotator{
+		Engine:     eng,
+		MoveTime:   searchTime,
+		Thresholds: Thresholds{Inaccuracy: *inaccuracy, Mistake: *mistake, Blunder: *blunder},
+		Diagrams:   *diagrams,
+	}
+	if a.Diagrams != "" {
+		if err := os.MkdirAll(a.Diagrams, 0o755); err != nil {
+			return err
+		}
+	}
+
+	if *pgnPath == "" {
+		pos, err := board.ParseFEN(*fen, board.StrictFEN)
+		if err != nil {
+			return err
+		}
+		info, err := a.evaluate(pos)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s %s\n", whiteView(info, pos.Turn()), bestLine(pos, info.PV))
+		return nil
+	}
+	return annotateFile(a, *pgnPath, *out)
+}
+
+// annotateFile annotates every game of the PGN file at path into out, or
+// stdout if out is empty, reporting progress on stderr.
+func annotateFile(a *Annotator, path, out string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var w io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	scanner := chess.NewScanner(in)
+	for n := 1; scanner.Scan(); n++ {
+		game := scanner.Next()
+		if len(game.Moves()) == 0 {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Game %d: %d moves\n", n, len(game.Moves()))
+		an, err := a.Annotate(game)
+		if err != nil {
+			return fmt.Errorf("game %d: %w", n, err)
+		}
+		fmt.Fprintf(os.Stderr, "Game %d: accuracy White %.1f%%, Black %.1f%%\n", n, an.Accuracy[0], an.Accuracy[1])
+		if err := an.Record().WritePGN(w); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil && err != io.EOF {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package match
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"chessTomorrow/arbiter"
+)
+
+// defaultEngines are played when no engines are named on the command line.
+var defaultEngines = []string{"Random", "Maia 1900"}
+
+// MatchMain runs the match command: a match between two UCI engines, or a
+// tournament between more, as configured by its flags.
+func MatchMain(args []string) error {
+	fs := flag.NewFlagSet("match", flag.ExitOnError)
+	games := fs.Int("games", 10, "number of games to play for each pair of engines, or the most to play with -sprt")
+	mode := fs.String("mode", "match", "match (two engines), roundrobin (all against all) or gauntlet (the first against each of the others)")
+	concurrency := fs.Int("concurrency", 1, "number of games to play in parallel, each with its own pair of engines")
+	sprt := fs.Bool("sprt", false, "stop as soon as a sequential probability ratio test accepts elo0 or elo1")
+	elo0 := fs.Float64("elo0", 0, "SPRT null hypothesis: Elo of engine 1 over engine 2")
+	elo1 := fs.Float64("elo1", 5, "SPRT alternative hypothesis: Elo of engine 1 over engine 2")
+	alpha := fs.Float64("alpha", 0.05, "SPRT chance of accepting elo1 when elo0 holds")
+	beta := fs.Float64("beta", 0.05, "SPRT chance of accepting elo0 when elo1 holds")
+	pgnOut := fs.String("pgnout", "games.pgn", "append every game to this PGN file, with the engines' scores and times as comments; empty for none")
+	tc := fs.String("tc", "", "time control as [moves/]seconds[+increment], e.g. 60+0.6 or 40/90; empty for none")
+	margin := fs.Duration("timemargin", moveOverhead, "how far an engine may overrun its time before losing")
+	drawMoves := fs.Int("drawmoves", 0, "adjudicate a draw after this many moves per side with scores near zero; 0 for never")
+	drawScore := fs.Int("drawscore", 10, "largest score in centipawns that counts as near zero for -drawmoves")
+	drawStart := fs.Int("drawstart", 80, "earliest ply at which -drawmoves applies")
+	resignMoves := fs.Int("resignmoves", 0, "adjudicate a win after this many moves in a row with a winning score from the winner; 0 for never")
+	resignScore := fs.Int("resignscore", 800, "smallest score in centipawns that counts as winning for -resignmoves")
+	negotiate := fs.Bool("negotiate", false, "let the engines offer and accept draws after move 40 with scores near zero, and resign after 5 moves 8 pawns down")
+	openings := fs.String("openings", "", "EPD or PGN file of start positions; each is played with both colors")
+	statePath := fs.String("state", "", "record finished games in this file, so that an interrupted run can be resumed with -resume")
+	resume := fs.Bool("resume", false, "skip the games already recorded in the -state file")
+	results := fs.String("results", "", "write a record of every game to this file, as CSV if it ends in .csv and as JSON lines otherwise")
+	eventLog := fs.String("eventlog", "", "append every game's result and termination to this file as PGN without moves, for rating tools such as Ordo and BayesElo")
+	cutechess := fs.Bool("cutechess", false, "print each game's start and result, the running score and the summary as cutechess-cli does")
+	debugLog := fs.String("debuglog", "", "write each game's engine dialogue and engine stderr to a log file in this directory")
+	cpuLimit := fs.Duration("cpulimit", 0, "kill an engine process, forfeiting its game, once it has used this much CPU time since it started (Linux); 0 for no limit")
+	memLimit := fs.Int64("memlimit", 0, "kill an engine process, forfeiting its game, once it uses more than this many MB of memory (Linux); 0 for no limit")
+	wallLimit := fs.Duration("walllimit", 0, "kill an engine process, forfeiting its game, if one search takes longer than this even after stop; 0 for no limit")
+	serve := fs.String("serve", "", "serve a live view of the match on this address, e.g. :8080")
+	coordinator := fs.String("coordinator", "", "also hand the match's games out to workers on other machines from this address, e.g. :8081; -concurrency 0 plays none here")
+	lease := fs.Duration("lease", 10*time.Minute, "time a worker has to report a game before it is handed out again")
+	secret := fs.String("secret", "", "shared secret the workers send to the -coordinator; give the same to -coordinator and -worker")
+	loadRegistry := registryFlag(fs)
+	worker := fs.String("worker", "", "play games for the coordinator at this URL instead of a match of its own; engines given replace the coordinator's")
+	var e1opts, e2opts optionFlags
+	fs.Var(&e1opts, "e1opt", "UCI option Name=Value for engine 1; repeat for more")
+	fs.Var(&e2opts, "e2opt", "UCI option Name=Value for engine 2; repeat for more")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: match [flags] [engine...]\n       match -worker URL [-secret S] [-concurrency N] [-debuglog dir] [engine1 engine2]\nan engine is a registry name or the path of a UCI binary")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+
+	if *worker != "" {
+		if fs.NArg() != 0 && fs.NArg() != 2 {
+			fs.Usage()
+			os.Exit(2)
+		}
+		if *debugLog != "" {
+			if err := os.MkdirAll(*debugLog, 0755); err != nil {
+				return err
+			}
+		}
+		return Work(*worker, *secret, registry, fs.Args(), *concurrency, *debugLog)
+	}
+
+	engines := fs.Args()
+	if len(engines) == 0 {
+		engines = defaultEngines
+	}
+
+	cfg := MatchConfig{
+		Games:              *games,
+		PGNPath:            *pgnOut,
+		IllegalMoveRetries: 2,
+		Concurrency:        *concurrency,
+		DebugLog:           *debugLog,
+		EngineOptions:      [2][]EngineOption{e1opts, e2opts},
+		Registry:           registry,
+		Cutechess:          *cutechess,
+		Limits:             arbiter.Limits{CPUTime: *cpuLimit, Memory: *memLimit << 20, WallTime: *wallLimit},
+		Adjudication: Adjudication{
+			DrawMoves:   *drawMoves,
+			DrawScore:   *drawScore,
+			DrawStart:   *drawStart,
+			ResignMoves: *resignMoves,
+			ResignScore: *resignScore,
+		},
+	}
+	if *negotiate {
+		cfg.Negotiation = arbiter.DefaultNegotiation
+	}
+	timeControl, err := ParseTimeControl(*tc)
+	if err != nil {
+		return err
+	}
+	timeControl.Margin = *margin
+	cfg.TimeControl = timeControl
+
+	if *openings != "" {
+		fens, err := LoadOpenings(*openings)
+		if err != nil {
+			return err
+		}
+		cfg.Openings = fens
+	}
+	switch {
+	case *statePath == "" && *resume:
+		return errors.New("-resume needs -state")
+	case *resume:
+		state, err := LoadMatchState(*statePath)
+		if err != nil {
+			return err
+		}
+		cfg.State = state
+	case *statePath != "":
+		cfg.State = NewMatchState(*statePath)
+	}
+	if *debugLog != "" {
+		if err := os.MkdirAll(*debugLog, 0755); err != nil {
+			return err
+		}
+	}
+	if *results != "" {
+		w, err := NewResultWriter(*results)
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+		cfg.Results = w
+	}
+	if *eventLog != "" {
+		l, err := NewEventLog(*eventLog)
+		if err != nil {
+			return err
+		}
+		defer l.Close()
+		cfg.EventLog = l
+	}
+	if *serve != "" {
+		cfg.Dashboard = NewDashboard()
+		cfg.Dashboard.Serve(*serve)
+	}
+	if *coordinator != "" {
+		if *mode != "match" {
+			return errors.New("-coordinator only distributes -mode match")
+		}
+		cfg.Coordinator = NewCoordinator(*lease, *secret)
+		cfg.Coordinator.Serve(*coordinator)
+		defer cfg.Coordinator.Close()
+	}
+	if *sprt {
+		cfg.SPRT = &SPRT{Elo0: *elo0, Elo1: *elo1, Alpha: *alpha, Beta: *beta}
+	}
+
+	switch {
+	case *mode == "match" && len(engines) == 2:
+		return Play(engines[0], engines[1], cfg)
+	case (*mode == "roundrobin" || *mode == "gauntlet") && len(engines) >= 2:
+		return Tournament(engines, *mode == "gauntlet", [][]EngineOption{e1opts, e2opts}, cfg)
+	}
+	fs.Usage()
+	os.Exit(2)
+	return nil
+}
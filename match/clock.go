@@ -0,0 +1,197 @@
+package match
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"chessTomorrow/arbiter"
+	"github.com/notnil/chess"
+)
+
+// moveOverhead is the default grace margin: how much an engine may exceed
+// its time before it loses, to absorb process and pipe latency.
+const moveOverhead = 50 * time.Millisecond
+
+// TimeControl is either a clock with Base time plus Increment per move, or a
+// fixed MoveTime per move. With Moves set, Base is added again every Moves
+// moves. The zero value means no time control.
+type TimeControl struct {
+	Base      time.Duration
+	Increment time.Duration
+	Moves     int
+	MoveTime  time.Duration
+
+	// Margin is how far an engine may overrun before it loses on time;
+	// zero means moveOverhead.
+	Margin time.Duration
+}
+
+// ParseTimeControl reads a time control in the usual match-runner form
+// [moves/]time[+increment], with times in seconds, the base optionally as
+// minutes:seconds. "60+0.6" is a minute plus 0.6 seconds a move, "40/90"
+// is 90 seconds for every 40 moves. An empty string or "inf" means no time
+// control.
+func ParseTimeControl(s string) (TimeControl, error) {
+	var tc TimeControl
+	if s == "" || s == "inf" {
+		return tc, nil
+	}
+	rest := s
+	if moves, base, ok := strings.Cut(rest, "/"); ok {
+		n, err := strconv.Atoi(moves)
+		if err != nil || n <= 0 {
+			return tc, fmt.Errorf("time control %q: bad number of moves", s)
+		}
+		tc.Moves, rest = n, base
+	}
+	base, inc, hasInc := strings.Cut(rest, "+")
+	if hasInc {
+		d, err := parseSeconds(inc)
+		if err != nil {
+			return tc, fmt.Errorf("time control %q: bad increment", s)
+		}
+		tc.Increment = d
+	}
+	minutes, seconds, hasMinutes := strings.Cut(base, ":")
+	if !hasMinutes {
+		minutes, seconds = "0", base
+	}
+	m, err := strconv.Atoi(minutes)
+	if err != nil {
+		return tc, fmt.Errorf("time control %q: bad time", s)
+	}
+	d, err := parseSeconds(seconds)
+	if err != nil || m < 0 {
+		return tc, fmt.Errorf("time control %q: bad time", s)
+	}
+	tc.Base = time.Duration(m)*time.Minute + d
+	if tc.Base <= 0 {
+		return tc, fmt.Errorf("time control %q: no time", s)
+	}
+	return tc, nil
+}
+
+func parseSeconds(s string) (time.Duration, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("bad seconds %q", s)
+	}
+	return time.Duration(f * float64(time.Second)), nil
+}
+
+// IsZero reports whether no time control is set.
+func (tc TimeControl) IsZero() bool {
+	return tc.Base == 0 && tc.MoveTime == 0
+}
+
+func (tc TimeControl) margin() time.Duration {
+	if tc.Margin == 0 {
+		return moveOverhead
+	}
+	return tc.Margin
+}
+
+// Clock tracks both sides' remaining time under a TimeControl, and the
+// moves each has made for repeating controls. The match runner charges it
+// each move's thinking time; the web arbiter runs it against the wall
+// clock.
+type Clock struct {
+	tc        TimeControl
+	remaining [3]time.Duration // indexed by chess.Color
+	moves     [3]int           // moves made, for repeating time controls
+}
+
+// NewClock returns a clock with the base time on both sides.
+func NewClock(tc TimeControl) *Clock {
+	c := &Clock{tc: tc}
+	c.remaining[chess.White] = tc.Base
+	c.remaining[chess.Black] = tc.Base
+	return c
+}
+
+// TimeControl returns the time control the clock keeps.
+func (c *Clock) TimeControl() TimeControl {
+	return c.tc
+}
+
+// State returns the clock to send to side, the engine about to move.
+func (c *Clock) State(side chess.Color) arbiter.ClockState {
+	if c.tc.MoveTime > 0 {
+		return arbiter.ClockState{MoveTime: c.tc.MoveTime}
+	}
+	if c.tc.IsZero() {
+		return arbiter.ClockState{}
+	}
+	state := arbiter.ClockState{
+		WhiteTime: c.remaining[chess.White],
+		BlackTime: c.remaining[chess.Black],
+		WhiteInc:  c.tc.Increment,
+		BlackInc:  c.tc.Increment,
+	}
+	if c.tc.Moves > 0 {
+		state.MovesToGo = c.tc.Moves - c.moves[side]%c.tc.Moves
+	}
+	return state
+}
+
+// Budget returns the longest side may think before losing on time, or
+// false if the game is untimed.
+func (c *Clock) Budget(side chess.Color) (time.Duration, bool) {
+	switch {
+	case c.tc.IsZero():
+		return 0, false
+	case c.tc.MoveTime > 0:
+		return c.tc.MoveTime + c.tc.margin(), true
+	}
+	return c.remaining[side] + c.tc.margin(), true
+}
+
+// Spend charges a move's thinking time to side and reports false if the
+// side ran out of time. An overrun within the margin leaves the side with
+// no time rather than losing it the game.
+func (c *Clock) Spend(side chess.Color, elapsed time.Duration) bool {
+	switch {
+	case c.tc.IsZero():
+		return true
+	case c.tc.MoveTime > 0:
+		return elapsed <= c.tc.MoveTime+c.tc.margin()
+	}
+
+	c.remaining[side] -= elapsed
+	if c.remaining[side] < -c.tc.margin() {
+		return false
+	}
+	c.remaining[side] = max(c.remaining[side], 0) + c.tc.Increment
+	c.moves[side]++
+	if c.tc.Moves > 0 && c.moves[side]%c.tc.Moves == 0 {
+		c.remaining[side] += c.tc.Base
+	}
+	return true
+}
+
+// Remaining returns side's time left, or false if the game has no clock
+// that runs down.
+func (c *Clock) Remaining(side chess.Color) (time.Duration, bool) {
+	if c.tc.IsZero() || c.tc.MoveTime > 0 {
+		return 0, false
+	}
+	return c.remaining[side], true
+}
+
+// Moves returns the number of moves side has made.
+func (c *Clock) Moves(side chess.Color) int {
+	return c.moves[side]
+}
+
+// Charge takes elapsed off side's time, down to no time at all, without
+// ending its move.
+func (c *Clock) Charge(side chess.Color, elapsed time.Duration) {
+	c.remaining[side] = max(c.remaining[side]-elapsed, 0)
+}
+
+// Set gives side remaining time and moves made, as when resuming a game.
+func (c *Clock) Set(side chess.Color, remaining time.Duration, moves int) {
+	c.remaining[side], c.moves[side] = remaining, moves
+}
@@ -0,0 +1,133 @@
+package match
+
+import (
+	"testing"
+	"time"
+
+	"chessTomorrow/arbiter"
+	"github.com/notnil/chess"
+)
+
+func TestParseTimeControl(t *testing.T) {
+	tests := []struct {
+		in   string
+		want TimeControl
+	}{
+		{"", TimeControl{}},
+		{"inf", TimeControl{}},
+		{"60", TimeControl{Base: time.Minute}},
+		{"60+0.6", TimeControl{Base: time.Minute, Increment: 600 * time.Millisecond}},
+		{"1:00+0.6", TimeControl{Base: time.Minute, Increment: 600 * time.Millisecond}},
+		{"2:30", TimeControl{Base: 150 * time.Second}},
+		{"40/90", TimeControl{Base: 90 * time.Second, Moves: 40}},
+		{"40/2:00+1", TimeControl{Base: 2 * time.Minute, Increment: time.Second, Moves: 40}},
+	}
+	for _, tt := range tests {
+		got, err := ParseTimeControl(tt.in)
+		if err != nil {
+			t.Errorf("ParseTimeControl(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseTimeControl(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"abc", "0", "-5", "0/60", "x/60", "40/", "60+x", "60+-1", "a:00", "-1:00", "1:xx", "+1"} {
+		if tc, err := ParseTimeControl(in); err == nil {
+			t.Errorf("ParseTimeControl(%q) = %+v, want an error", in, tc)
+		}
+	}
+}
+
+func TestClockSpend(t *testing.T) {
+	c := NewClock(TimeControl{Base: 10 * time.Second, Increment: time.Second})
+	if !c.Spend(chess.White, 3*time.Second) {
+		t.Fatal("White lost on time with time left")
+	}
+	if left, _ := c.Remaining(chess.White); left != 8*time.Second {
+		t.Errorf("White has %v after 3s of 10s+1, want 8s", left)
+	}
+	if left, _ := c.Remaining(chess.Black); left != 10*time.Second {
+		t.Errorf("Black has %v without moving, want 10s", left)
+	}
+
+	// An overrun within the margin leaves nothing but the increment.
+	if !c.Spend(chess.White, 8*time.Second+moveOverhead) {
+		t.Fatal("White lost on time within the margin")
+	}
+	if left, _ := c.Remaining(chess.White); left != time.Second {
+		t.Errorf("White has %v after overrunning within the margin, want 1s", left)
+	}
+	if budget, _ := c.Budget(chess.White); budget != time.Second+moveOverhead {
+		t.Errorf("White's budget is %v, want %v", budget, time.Second+moveOverhead)
+	}
+	if c.Spend(chess.White, time.Second+moveOverhead+time.Millisecond) {
+		t.Error("White did not lose on time beyond the margin")
+	}
+
+	c = NewClock(TimeControl{Base: time.Second, Margin: time.Second})
+	if !c.Spend(chess.Black, 2*time.Second) {
+		t.Error("Black lost on time within a margin of 1s")
+	}
+}
+
+func TestClockRepeating(t *testing.T) {
+	c := NewClock(TimeControl{Base: 40 * time.Second, Moves: 2})
+	if got := c.State(chess.White).MovesToGo; got != 2 {
+		t.Errorf("MovesToGo %d at the start, want 2", got)
+	}
+	c.Spend(chess.White, 10*time.Second)
+	if got := c.State(chess.White).MovesToGo; got != 1 {
+		t.Errorf("MovesToGo %d after one move, want 1", got)
+	}
+	if got := c.State(chess.Black).MovesToGo; got != 2 {
+		t.Errorf("Black's MovesToGo %d before moving, want 2", got)
+	}
+
+	// The second move completes the control and refills the clock.
+	c.Spend(chess.White, 10*time.Second)
+	if left, _ := c.Remaining(chess.White); left != 60*time.Second {
+		t.Errorf("White has %v after the control, want 60s", left)
+	}
+	if got := c.State(chess.White).MovesToGo; got != 2 {
+		t.Errorf("MovesToGo %d after the control, want 2", got)
+	}
+	state := c.State(chess.Black)
+	if state.WhiteTime != 60*time.Second || state.BlackTime != 40*time.Second {
+		t.Errorf("State %+v, want 60s for White and 40s for Black", state)
+	}
+}
+
+func TestClockMoveTime(t *testing.T) {
+	c := NewClock(TimeControl{MoveTime: time.Second})
+	if state := c.State(chess.White); state.MoveTime != time.Second || state.WhiteTime != 0 {
+		t.Errorf("State %+v, want a move time of 1s only", state)
+	}
+	if _, ok := c.Remaining(chess.White); ok {
+		t.Error("a move time clock has time remaining")
+	}
+	if !c.Spend(chess.White, time.Second+moveOverhead) {
+		t.Error("lost on time within the margin")
+	}
+	if c.Spend(chess.White, time.Second+moveOverhead+time.Millisecond) {
+		t.Error("did not lose on time beyond the margin")
+	}
+	// Each move gets the full move time, however long the last took.
+	if !c.Spend(chess.White, time.Second) {
+		t.Error("lost on time after a previous overrun")
+	}
+}
+
+func TestClockUntimed(t *testing.T) {
+	c := NewClock(TimeControl{})
+	if _, ok := c.Budget(chess.White); ok {
+		t.Error("an untimed game has a budget")
+	}
+	if !c.Spend(chess.White, time.Hour) {
+		t.Error("lost on time without a clock")
+	}
+	if state := c.State(chess.White); state != (arbiter.ClockState{}) {
+		t.Errorf("State %+v, want none", state)
+	}
+}
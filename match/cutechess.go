@@ -0,0 +1,146 @@
+package match
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// EventLog appends the result of every game to a file as a PGN game with
+// no moves: the tags, then the result with cutechess-cli's words for how
+// the game ended as a comment, such as
+//
+//	[Termination "time forfeit"]
+//	[PlyCount "57"]
+//
+//	{Black loses on time} 1-0
+//
+// Ordo and BayesElo read it as they read full PGN, so it can be rated
+// without keeping every game's moves. Its methods may be called on a nil
+// EventLog, which writes nothing.
+type EventLog struct {
+	f *os.File
+}
+
+// NewEventLog appends games to path, creating it if needed.
+func NewEventLog(path string) (*EventLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &EventLog{f: f}, nil
+}
+
+// Write records a finished game.
+func (l *EventLog) Write(g playedGame) error {
+	if l == nil {
+		return nil
+	}
+	var b strings.Builder
+	writeTag(&b, "Event", g.rec.Event)
+	writeTag(&b, "Site", "?")
+	writeTag(&b, "Date", g.rec.Date.Format("2006.01.02"))
+	writeTag(&b, "Round", strconv.Itoa(g.number+1))
+	writeTag(&b, "White", g.rec.White)
+	writeTag(&b, "Black", g.rec.Black)
+	writeTag(&b, "Result", string(g.result.Outcome))
+	if g.rec.StartFEN != "" && g.rec.StartFEN != startFEN {
+		writeTag(&b, "SetUp", "1")
+		writeTag(&b, "FEN", g.rec.StartFEN)
+	}
+	writeTag(&b, "Termination", g.result.Termination.Keyword())
+	writeTag(&b, "PlyCount", strconv.Itoa(len(g.result.Moves)))
+	fmt.Fprintf(&b, "\n{%s} %s\n\n", resultText(g.result), g.result.Outcome)
+	_, err := l.f.WriteString(b.String())
+	return err
+}
+
+// Close closes the file.
+func (l *EventLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.f.Close()
+}
+
+// resultText says how a game ended in cutechess-cli's words, such as
+// "White mates" or "Draw by 3-fold repetition".
+func resultText(res GameResult) string {
+	loser := "White"
+	if res.Winner == chess.White {
+		loser = "Black"
+	}
+	winner := "White"
+	if res.Winner == chess.Black {
+		winner = "Black"
+	}
+	switch res.Termination {
+	case Checkmate:
+		return winner + " mates"
+	case Stalemate:
+		return "Draw by stalemate"
+	case Repetition:
+		return "Draw by 3-fold repetition"
+	case FiftyMoveRule:
+		return "Draw by fifty moves rule"
+	case InsufficientMaterial:
+		return "Draw by insufficient mating material"
+	case IllegalMove:
+		return loser + " makes an illegal move"
+	case Timeout:
+		return loser + " loses on time"
+	case Resignation:
+		return loser + " resigns"
+	case EngineFailure:
+		return loser + " disconnects"
+	case DrawAgreement:
+		return "Draw by agreement"
+	case Adjudicated:
+		if res.Winner == chess.NoColor {
+			return "Draw by adjudication"
+		}
+		return winner + " wins by adjudication"
+	}
+	return "No result"
+}
+
+// cutechessStarted prints the line cutechess-cli prints as a game starts.
+func cutechessStarted(number, games int, rec *GameRecorder) {
+	fmt.Printf("Started game %d of %d (%s vs %s)\n", number+1, games, rec.White, rec.Black)
+}
+
+// cutechessFinished prints the lines cutechess-cli prints as a game ends:
+// its result, then the score of the match so far.
+func cutechessFinished(g playedGame, names [2]string, tally *Tally) {
+	fmt.Printf("Finished game %d (%s vs %s): %s {%s}\n", g.number+1, g.rec.White, g.rec.Black, g.result.Outcome, resultText(g.result))
+	printScore(names, tally)
+}
+
+// printScore prints engine 1's wins, losses and draws, its score and the
+// number of games.
+func printScore(names [2]string, tally *Tally) {
+	w, d, l := tally.Total()
+	fmt.Printf("Score of %s vs %s: %d - %d - %d  [%.3f] %d\n", names[0], names[1], w, l, d,
+		(float64(w)+float64(d)/2)/float64(max(1, w+d+l)), w+d+l)
+}
+
+// printCutechess prints the summary of a match as cutechess-cli does.
+func (m *matchSummary) printCutechess(cfg MatchConfig) {
+	printScore(m.names, &m.tallies[0])
+	w, d, l := m.tallies[0].Total()
+	elo, margin := m.tallies[0].Elo()
+	fmt.Printf("Elo difference: %s, LOS: %.1f %%, DrawRatio: %.1f %%\n",
+		formatElo(elo, margin), 100*m.tallies[0].LOS(), 100*float64(d)/float64(max(1, w+d+l)))
+	if sprt := cfg.SPRT; sprt != nil {
+		lower, upper := sprt.Bounds()
+		fmt.Printf("SPRT: llr %.3g, lbound %.3g, ubound %.3g", sprt.LLR(&m.tallies[0]), lower, upper)
+		if m.decision != "" {
+			fmt.Printf(" - %s was accepted", m.decision)
+		}
+		fmt.Println()
+	}
+	fmt.Println("Finished match")
+}
@@ -0,0 +1,206 @@
+package match
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+)
+
+// Dashboard follows a match for the -serve web page: the games being
+// played, with their boards, last comments and clocks, the games that have
+// ended and the running score. Its methods may be called on a nil
+// Dashboard, which does nothing.
+type Dashboard struct {
+	mu       sync.Mutex
+	live     map[*GameRecorder]*liveGame
+	finished []finishedGame
+	names    [2]string
+	tallies  [2]Tally
+}
+
+type liveGame struct {
+	Number   int       `json:"number"`
+	White    string    `json:"white"`
+	Black    string    `json:"black"`
+	FEN      string    `json:"fen"`
+	Board    string    `json:"board"`
+	Plies    int       `json:"plies"`
+	Comments [2]string `json:"comments"` // the last move's comment, White and Black
+	Clocks   [2]string `json:"clocks,omitempty"`
+}
+
+type finishedGame struct {
+	Number      int    `json:"number"`
+	White       string `json:"white"`
+	Black       string `json:"black"`
+	Result      string `json:"result"`
+	Termination string `json:"termination"`
+	Plies       int    `json:"plies"`
+}
+
+// NewDashboard returns an empty dashboard.
+func NewDashboard() *Dashboard {
+	return &Dashboard{live: make(map[*GameRecorder]*liveGame)}
+}
+
+// begin starts the score of a match between the named engines. The
+// finished games of earlier matches stay listed.
+func (d *Dashboard) begin(names [2]string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.names, d.tallies = names, [2]Tally{}
+}
+
+// started registers the game rec records, the number'th of the match.
+func (d *Dashboard) started(number int, rec *GameRecorder) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.live[rec] = &liveGame{Number: number + 1, White: rec.White, Black: rec.Black, FEN: rec.StartFEN}
+}
+
+// moved updates the game rec records after side's move, which led to pos.
+func (d *Dashboard) moved(rec *GameRecorder, pos *chess.Position, move *chess.Move, side chess.Color, comment string, clock *Clock) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	g := d.live[rec]
+	if g == nil {
+		return
+	}
+	g.FEN = pos.String()
+	g.Board = board.BoardToString(pos.Board(), board.RenderOptions{Unicode: true, Coordinates: true, LastMove: move})
+	g.Plies++
+	g.Comments[side-chess.White] = comment
+	for _, c := range []chess.Color{chess.White, chess.Black} {
+		if left, ok := clock.Remaining(c); ok {
+			g.Clocks[c-chess.White] = left.Round(100 * time.Millisecond).String()
+		}
+	}
+}
+
+// ended moves a game to the finished list and records the match score.
+func (d *Dashboard) ended(g playedGame, tallies [2]Tally) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.live, g.rec)
+	d.finished = append(d.finished, finishedGame{
+		Number:      g.number + 1,
+		White:       g.rec.White,
+		Black:       g.rec.Black,
+		Result:      string(g.result.Outcome),
+		Termination: g.result.Termination.String(),
+		Plies:       len(g.result.Moves),
+	})
+	d.tallies = tallies
+}
+
+// dashboardState is the JSON served at /state.
+type dashboardState struct {
+	Score    []engineScore  `json:"score"`
+	Live     []liveGame     `json:"live"`
+	Finished []finishedGame `json:"finished"`
+}
+
+type engineScore struct {
+	Name   string `json:"name"`
+	Wins   int    `json:"wins"`
+	Draws  int    `json:"draws"`
+	Losses int    `json:"losses"`
+}
+
+func (d *Dashboard) state() dashboardState {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st := dashboardState{Live: []liveGame{}, Finished: append([]finishedGame{}, d.finished...)}
+	for k, name := range d.names {
+		w, dr, l := d.tallies[k].Total()
+		st.Score = append(st.Score, engineScore{Name: name, Wins: w, Draws: dr, Losses: l})
+	}
+	for _, g := range d.live {
+		st.Live = append(st.Live, *g)
+	}
+	sort.Slice(st.Live, func(i, j int) bool { return st.Live[i].Number < st.Live[j].Number })
+	return st
+}
+
+// Serve serves the dashboard on addr in the background: the page at / and
+// its data at /state.
+func (d *Dashboard) Serve(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(dashboardPage))
+	})
+	mux.HandleFunc("/state", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(d.state())
+	})
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("dashboard: %v", err)
+		}
+	}()
+}
+
+const dashboardPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Match</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; }
+.games { display: flex; flex-wrap: wrap; gap: 2em; }
+pre { font-size: 1.2em; line-height: 1.1; }
+table { border-collapse: collapse; }
+td, th { padding: 0.2em 0.8em; text-align: left; }
+</style>
+</head>
+<body>
+<h1>Match</h1>
+<div id="score"></div>
+<h2>Now playing</h2>
+<div class="games" id="live"></div>
+<h2>Finished</h2>
+<table id="finished"></table>
+<script>
+function esc(s) {
+  return String(s).replace(/[&<>"]/g, c => ({"&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;"})[c]);
+}
+async function refresh() {
+  const st = await (await fetch("/state")).json();
+  document.getElementById("score").innerHTML = (st.score || []).map(e =>
+    "<b>" + esc(e.name) + "</b>: +" + e.wins + " =" + e.draws + " -" + e.losses).join("<br>");
+  document.getElementById("live").innerHTML = st.live.map(g =>
+    "<div><h3>Game " + g.number + ": " + esc(g.white) + " - " + esc(g.black) + "</h3>" +
+    "<pre>" + esc(g.board) + "</pre>" +
+    "<div>Ply " + g.plies + "</div>" +
+    "<div>White: " + esc(g.comments[0]) + " " + esc(g.clocks ? g.clocks[0] : "") + "</div>" +
+    "<div>Black: " + esc(g.comments[1]) + " " + esc(g.clocks ? g.clocks[1] : "") + "</div></div>").join("");
+  document.getElementById("finished").innerHTML =
+    "<tr><th>Game</th><th>White</th><th>Black</th><th>Result</th><th>Termination</th><th>Plies</th></tr>" +
+    st.finished.map(g => "<tr><td>" + g.number + "</td><td>" + esc(g.white) + "</td><td>" + esc(g.black) +
+      "</td><td>" + esc(g.result) + "</td><td>" + esc(g.termination) + "</td><td>" + g.plies + "</td></tr>").join("");
+}
+refresh();
+setInterval(refresh, 1000);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,496 @@
+package match
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"chessTomorrow/arbiter"
+)
+
+// A match can be spread over several machines: the coordinator runs the
+// match as usual and also hands its games out over HTTP to workers, which
+// play them with engines of their own and send back the results.
+//
+//	POST /work/register {"name": "host/1"}
+//	    -> {"worker": "<id>", "order": {<engines, time control, ...>}}
+//	POST /work/next     {"worker": "<id>"}
+//	    -> {"number": 12, "fen": "<start position>"}
+//	       204 when no game is ready yet, 410 once the match is over
+//	POST /work/result   {"worker": "<id>", "number": 12, "result": {...}, "record": {...}}
+//
+// A game not reported within the lease is handed out again, so a worker
+// that goes away costs only its games in progress. Only the worker a game
+// was handed to may report it. With a secret, every request must carry it
+// as "Authorization: Bearer <secret>".
+
+// workerPoll is how often an idle worker asks for a game.
+const workerPoll = time.Second
+
+// WorkOrder is what every game of a distributed match is played with.
+type WorkOrder struct {
+	Engines            [2]string // as named on the coordinator; workers may use their own
+	EngineOptions      [2][]EngineOption
+	Limits             arbiter.Limits
+	TimeControl        TimeControl
+	Adjudication       Adjudication
+	Negotiation        arbiter.Negotiation
+	IllegalMoveRetries int
+}
+
+// GameAssignment is a game handed to a worker. Engine 1 has White in even
+// games, as in a match played in one place.
+type GameAssignment struct {
+	Number int    `json:"number"`
+	FEN    string `json:"fen"`
+}
+
+// GameReport is a game a worker has played.
+type GameReport struct {
+	Worker string        `json:"worker"`
+	Number int           `json:"number"`
+	Result GameResult    `json:"result"`
+	Record *GameRecorder `json:"record"`
+}
+
+// Coordinator hands the games of a match out to remote workers. It takes
+// part in playMatch as one more worker: it takes game numbers from the
+// same channel as the local engine pairs and hands back finished games the
+// same way, so statistics, SPRT, PGN and state work as for local games.
+type Coordinator struct {
+	lease  time.Duration
+	secret string // workers must send it, if not empty
+	server *http.Server
+
+	mu       sync.Mutex
+	order    *WorkOrder // nil until the match starts
+	openings []string
+	games    <-chan int
+	finished chan<- playedGame
+	drained  bool  // games is closed
+	queue    []int // games whose lease ran out, to hand out first
+	leases   map[int]gameLease
+	lapsed   map[int]string    // the worker whose lease on a game ran out, which may still report it
+	workers  map[string]string // names by worker ID
+	over     chan struct{}
+}
+
+// gameLease is a game handed out and not yet reported.
+type gameLease struct {
+	worker  string
+	expires time.Time
+}
+
+// NewCoordinator returns a Coordinator that hands a game out again when it
+// hasn't been reported within lease, and answers only workers that send
+// secret, unless it is empty.
+func NewCoordinator(lease time.Duration, secret string) *Coordinator {
+	return &Coordinator{
+		lease:   lease,
+		secret:  secret,
+		leases:  make(map[int]gameLease),
+		lapsed:  make(map[int]string),
+		workers: make(map[string]string),
+		over:    make(chan struct{}),
+	}
+}
+
+// Serve answers workers on addr in the background, until Close.
+func (c *Coordinator) Serve(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /work/register", c.authorized(c.register))
+	mux.HandleFunc("POST /work/next", c.authorized(c.next))
+	mux.HandleFunc("POST /work/result", c.authorized(c.result))
+	c.server = &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		if err := c.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("coordinator: %v", err)
+		}
+	}()
+}
+
+// Close waits long enough for the idle workers to hear that the match is
+// over, so that they stop rather than wait for a coordinator that is gone,
+// and then shuts the server down.
+func (c *Coordinator) Close() {
+	if c.server == nil {
+		return
+	}
+	time.Sleep(3 * workerPoll)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.server.Shutdown(ctx); err != nil {
+		log.Printf("coordinator: %v", err)
+	}
+}
+
+// authorized answers 401 to requests without the coordinator's secret,
+// and passes the others on to h.
+func (c *Coordinator) authorized(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if c.secret != "" {
+			token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(c.secret)) != 1 {
+				http.Error(w, "wrong or missing secret", http.StatusUnauthorized)
+				return
+			}
+		}
+		h(w, r)
+	}
+}
+
+// workerCount returns how many workers have registered.
+func (c *Coordinator) workerCount() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.workers)
+}
+
+// run hands out the games of a match until games is closed once fed is
+// and every game handed out has been reported.
+func (c *Coordinator) run(order WorkOrder, openings []string, games <-chan int, fed <-chan struct{}, finished chan<- playedGame) {
+	c.mu.Lock()
+	c.order, c.openings, c.games, c.finished = &order, openings, games, finished
+	c.mu.Unlock()
+
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-fed:
+			fed = nil
+			c.mu.Lock()
+			c.drained = true
+			c.mu.Unlock()
+		case <-tick.C:
+		}
+		c.mu.Lock()
+		for number, l := range c.leases {
+			if time.Now().After(l.expires) {
+				log.Printf("Game %d was not reported by %s in time; handing it out again", number+1, c.workers[l.worker])
+				delete(c.leases, number)
+				c.lapsed[number] = l.worker
+				c.queue = append(c.queue, number)
+			}
+		}
+		if c.drained && len(c.leases) == 0 && len(c.queue) == 0 {
+			close(c.over)
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Unlock()
+	}
+}
+
+func (c *Coordinator) register(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.order == nil {
+		http.Error(w, "no match started yet", http.StatusServiceUnavailable)
+		return
+	}
+	var b [8]byte
+	rand.Read(b[:])
+	id := hex.EncodeToString(b[:])
+	c.workers[id] = req.Name
+	log.Printf("Worker %s registered", req.Name)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Worker string    `json:"worker"`
+		Order  WorkOrder `json:"order"`
+	}{id, *c.order})
+}
+
+func (c *Coordinator) next(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Worker string `json:"worker"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.over:
+		http.Error(w, "the match is over", http.StatusGone)
+		return
+	default:
+	}
+	if _, ok := c.workers[req.Worker]; !ok {
+		http.Error(w, "unknown worker; register first", http.StatusNotFound)
+		return
+	}
+
+	number := -1
+	if len(c.queue) > 0 {
+		number, c.queue = c.queue[0], c.queue[1:]
+	} else if !c.drained {
+		// Only take a game the match is ready to hand out, so that it is
+		// never held back from the local workers
+		select {
+		case i, ok := <-c.games:
+			if ok {
+				number = i
+			} else {
+				c.drained = true
+			}
+		default:
+		}
+	}
+	if number < 0 {
+		w.Header().Set("Retry-After", fmt.Sprint(workerPoll.Seconds()))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	c.leases[number] = gameLease{worker: req.Worker, expires: time.Now().Add(c.lease)}
+	fen := startFEN
+	if len(c.openings) > 0 {
+		fen = c.openings[number/2%len(c.openings)]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(GameAssignment{Number: number, FEN: fen})
+}
+
+func (c *Coordinator) result(w http.ResponseWriter, r *http.Request) {
+	var report GameReport
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		http.Error(w, "bad request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if report.Record == nil {
+		http.Error(w, "no game record", http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.workers[report.Worker]; !ok {
+		http.Error(w, "unknown worker; register first", http.StatusNotFound)
+		return
+	}
+	// A game handed out again may still be reported by its first worker
+	l, leased := c.leases[report.Number]
+	k := slices.Index(c.queue, report.Number)
+	switch {
+	case !leased && k < 0:
+		http.Error(w, fmt.Sprintf("game %d is not being played", report.Number+1), http.StatusConflict)
+		return
+	case !(leased && l.worker == report.Worker) && c.lapsed[report.Number] != report.Worker:
+		http.Error(w, fmt.Sprintf("game %d was not handed to this worker", report.Number+1), http.StatusForbidden)
+		return
+	case leased:
+		delete(c.leases, report.Number)
+	default:
+		c.queue = append(c.queue[:k], c.queue[k+1:]...)
+	}
+	delete(c.lapsed, report.Number)
+	// Sent with the lock held, so that run can't end the match meanwhile
+	c.finished <- playedGame{number: report.Number, result: report.Result, rec: report.Record}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// workerClient talks to a coordinator for one worker slot.
+type workerClient struct {
+	url    string
+	secret string // sent with every request, if not empty
+	id     string
+}
+
+// post sends body as JSON to path and decodes a JSON reply into reply. It
+// returns the reply's status code.
+func (wc *workerClient) post(path string, body, reply any) (int, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest(http.MethodPost, wc.url+path, bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if wc.secret != "" {
+		req.Header.Set("Authorization", "Bearer "+wc.secret)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusOK && reply != nil:
+		return resp.StatusCode, json.NewDecoder(resp.Body).Decode(reply)
+	case resp.StatusCode >= 400 && resp.StatusCode != http.StatusGone:
+		msg, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, fmt.Errorf("%s: %s: %s", path, resp.Status, bytes.TrimSpace(msg))
+	}
+	return resp.StatusCode, nil
+}
+
+// coordinatorTimeout is how long a worker keeps trying to reach a
+// coordinator that doesn't answer before it gives up.
+const coordinatorTimeout = time.Minute
+
+// Work plays games for the coordinator at url until its match is over,
+// concurrency at a time. The coordinator's engines are looked up in
+// registry, so that registry names work wherever each machine keeps the
+// engine; engines, if given, replace them. secret is the coordinator's, if
+// it has one. debugLog is as for a match.
+func Work(url, secret string, registry *arbiter.Registry, engines []string, concurrency int, debugLog string) error {
+	url = strings.TrimSuffix(url, "/")
+	if !strings.Contains(url, "://") {
+		url = "http://" + url
+	}
+	host, _ := os.Hostname()
+	slots := max(1, concurrency)
+	errs := make(chan error, slots)
+	for slot := range slots {
+		go func() {
+			wc := &workerClient{url: url, secret: secret}
+			errs <- workSlot(wc, fmt.Sprintf("%s/%d", host, slot+1), registry, engines, debugLog)
+		}()
+	}
+	var err error
+	for range slots {
+		err = errors.Join(err, <-errs)
+	}
+	return err
+}
+
+// workSlot registers with wc as one worker and plays its games with one
+// pair of engines.
+func workSlot(wc *workerClient, name string, registry *arbiter.Registry, engines []string, debugLog string) error {
+	var reg struct {
+		Worker string    `json:"worker"`
+		Order  WorkOrder `json:"order"`
+	}
+	if err := wc.retry(func() (bool, error) {
+		status, err := wc.post("/work/register", map[string]string{"name": name}, &reg)
+		return status == http.StatusServiceUnavailable, err
+	}); err != nil {
+		return err
+	}
+	wc.id = reg.Worker
+	order := reg.Order
+	if len(engines) == 2 {
+		order.Engines = [2]string{engines[0], engines[1]}
+	}
+
+	var pair [2]*arbiter.UCIEngineAdapter
+	for k, path := range order.Engines {
+		eng, err := registry.Lookup(path).Start(order.Limits)
+		if err != nil {
+			return err
+		}
+		defer eng.Close()
+		for _, opt := range order.EngineOptions[k] {
+			if err := eng.SetOption(opt.Name, opt.Value); err != nil {
+				return err
+			}
+		}
+		pair[k] = eng
+	}
+	cfg := MatchConfig{
+		IllegalMoveRetries: order.IllegalMoveRetries,
+		TimeControl:        order.TimeControl,
+		Adjudication:       order.Adjudication,
+		Negotiation:        order.Negotiation,
+	}
+
+	for {
+		var a GameAssignment
+		var status int
+		if err := wc.retry(func() (bool, error) {
+			var err error
+			status, err = wc.post("/work/next", map[string]string{"worker": wc.id}, &a)
+			return false, err
+		}); err != nil {
+			return err
+		}
+		switch status {
+		case http.StatusGone:
+			return nil
+		case http.StatusNoContent:
+			time.Sleep(workerPoll)
+			continue
+		}
+
+		white, black := pair[a.Number%2], pair[1-a.Number%2]
+		rec := NewGameRecorder(white.Name, black.Name, a.FEN)
+		logPath, closeLog := openGameLog(debugLog, a.Number, white, black)
+		res, err := RunMatch(white, black, cfg, rec)
+		closeLog()
+		if err != nil {
+			return fmt.Errorf("game %d: %w", a.Number+1, err)
+		}
+		log.Printf("%s: game %d %s", name, a.Number+1, res.Outcome)
+		if res.Violation != "" && logPath != "" {
+			log.Printf("%s: game %d forfeited: %s; engine log: %s", name, a.Number+1, res.Violation, logPath)
+		}
+		report := GameReport{Worker: wc.id, Number: a.Number, Result: res, Record: rec}
+		if err := wc.retry(func() (bool, error) {
+			status, err := wc.post("/work/result", report, nil)
+			if status == http.StatusConflict {
+				// Handed out again and already reported by another worker
+				log.Printf("%s: %v", name, err)
+				return false, nil
+			}
+			return false, err
+		}); err != nil {
+			return err
+		}
+
+		for _, eng := range pair {
+			if eng.Broken() {
+				if err := eng.Restart(); err != nil {
+					log.Printf("restarting %s: %v", eng.Name, err)
+				}
+			}
+		}
+	}
+}
+
+// retry calls f until it succeeds without asking to wait, waiting a few
+// seconds between calls, and gives up once the coordinator hasn't answered
+// for coordinatorTimeout.
+func (wc *workerClient) retry(f func() (wait bool, err error)) error {
+	start := time.Now()
+	for {
+		wait, err := f()
+		if err == nil && !wait {
+			return nil
+		}
+		if time.Since(start) > coordinatorTimeout {
+			if err == nil {
+				err = errors.New("the coordinator has no match to play")
+			}
+			return fmt.Errorf("coordinator %s: %w", wc.url, err)
+		}
+		if err != nil {
+			log.Printf("coordinator %s: %v", wc.url, err)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
@@ -0,0 +1,97 @@
+package match
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// post sends body to one of c's handlers and returns the status.
+func post(t *testing.T, h http.HandlerFunc, secret string, body any, reply any) int {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(data))
+	if secret != "" {
+		req.Header.Set("Authorization", "Bearer "+secret)
+	}
+	w := httptest.NewRecorder()
+	h(w, req)
+	if reply != nil && w.Code == http.StatusOK {
+		if err := json.NewDecoder(w.Body).Decode(reply); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w.Code
+}
+
+func TestCoordinatorReports(t *testing.T) {
+	c := NewCoordinator(time.Minute, "s3cret")
+	games := make(chan int, 1)
+	finished := make(chan playedGame, 1)
+	c.order, c.games, c.finished = &WorkOrder{}, games, finished
+	register, next, result := c.authorized(c.register), c.authorized(c.next), c.authorized(c.result)
+
+	if status := post(t, register, "", map[string]string{"name": "a"}, nil); status != http.StatusUnauthorized {
+		t.Fatalf("register without the secret: %d, want 401", status)
+	}
+	if status := post(t, register, "guess", map[string]string{"name": "a"}, nil); status != http.StatusUnauthorized {
+		t.Fatalf("register with a wrong secret: %d, want 401", status)
+	}
+	var a, b struct {
+		Worker string `json:"worker"`
+	}
+	post(t, register, "s3cret", map[string]string{"name": "a"}, &a)
+	post(t, register, "s3cret", map[string]string{"name": "b"}, &b)
+	if a.Worker == "" || b.Worker == "" {
+		t.Fatal("no worker IDs")
+	}
+
+	games <- 0
+	var assigned GameAssignment
+	if status := post(t, next, "s3cret", map[string]string{"worker": a.Worker}, &assigned); status != http.StatusOK || assigned.Number != 0 {
+		t.Fatalf("next: %d, game %d", status, assigned.Number)
+	}
+
+	report := GameReport{Number: 0, Record: NewGameRecorder("w", "b", startFEN)}
+	for _, tt := range []struct {
+		worker string
+		status int
+	}{
+		{"nobody", http.StatusNotFound},
+		{b.Worker, http.StatusForbidden},
+		{a.Worker, http.StatusNoContent},
+		{a.Worker, http.StatusConflict},
+	} {
+		report.Worker = tt.worker
+		if status := post(t, result, "s3cret", report, nil); status != tt.status {
+			t.Errorf("report by %s: %d, want %d", tt.worker, status, tt.status)
+		}
+	}
+	if len(finished) != 1 {
+		t.Errorf("%d games finished, want 1", len(finished))
+	}
+}
+
+func TestCoordinatorLapsedLease(t *testing.T) {
+	c := NewCoordinator(time.Minute, "")
+	finished := make(chan playedGame, 1)
+	c.order, c.finished = &WorkOrder{}, finished
+	c.workers["a"], c.workers["b"] = "a", "b"
+	// a's lease ran out and the game went to b; a may still report it
+	c.lapsed[3] = "a"
+	c.leases[3] = gameLease{worker: "b", expires: time.Now().Add(time.Minute)}
+
+	report := GameReport{Worker: "a", Number: 3, Record: NewGameRecorder("w", "b", startFEN)}
+	if status := post(t, c.authorized(c.result), "", report, nil); status != http.StatusNoContent {
+		t.Fatalf("report by the lapsed worker: %d, want 204", status)
+	}
+	if len(c.leases) != 0 || len(c.lapsed) != 0 {
+		t.Errorf("game still out: leases %v, lapsed %v", c.leases, c.lapsed)
+	}
+}
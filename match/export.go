@@ -0,0 +1,135 @@
+package match
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ResultWriter writes a record of every game to a file for analysis
+// outside the runner: as CSV if the file name ends in .csv, otherwise as
+// JSON lines. Its methods may be called on a nil ResultWriter, which
+// writes nothing.
+type ResultWriter struct {
+	f   *os.File
+	csv *csv.Writer // nil for JSON lines
+}
+
+// gameRecord is the record of one game. Depths and nodes per second are
+// averaged over the moves a side reported them for, and are 0 for an
+// engine that reports nothing.
+type gameRecord struct {
+	Game        int     `json:"game"`
+	White       string  `json:"white"`
+	Black       string  `json:"black"`
+	Opening     string  `json:"opening"`
+	Result      string  `json:"result"`
+	Termination string  `json:"termination"`
+	Plies       int     `json:"plies"`
+	WhiteDepth  float64 `json:"white_depth"`
+	BlackDepth  float64 `json:"black_depth"`
+	WhiteNPS    int64   `json:"white_nps"`
+	BlackNPS    int64   `json:"black_nps"`
+}
+
+var csvHeader = []string{"game", "white", "black", "opening", "result", "termination", "plies",
+	"white_depth", "black_depth", "white_nps", "black_nps"}
+
+// NewResultWriter appends records to path, creating it if needed. A new
+// CSV file starts with a header row.
+func NewResultWriter(path string) (*ResultWriter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	w := &ResultWriter{f: f}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		w.csv = csv.NewWriter(f)
+		if info, err := f.Stat(); err == nil && info.Size() == 0 {
+			w.csv.Write(csvHeader)
+		}
+	}
+	return w, nil
+}
+
+// Write records a finished game.
+func (w *ResultWriter) Write(g playedGame) error {
+	if w == nil {
+		return nil
+	}
+	r := newGameRecord(g)
+	if w.csv == nil {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		_, err = w.f.Write(append(data, '\n'))
+		return err
+	}
+	w.csv.Write([]string{
+		strconv.Itoa(r.Game), r.White, r.Black, r.Opening, r.Result, r.Termination, strconv.Itoa(r.Plies),
+		strconv.FormatFloat(r.WhiteDepth, 'f', 1, 64), strconv.FormatFloat(r.BlackDepth, 'f', 1, 64),
+		strconv.FormatInt(r.WhiteNPS, 10), strconv.FormatInt(r.BlackNPS, 10),
+	})
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+// Close closes the file.
+func (w *ResultWriter) Close() error {
+	if w == nil {
+		return nil
+	}
+	return w.f.Close()
+}
+
+func newGameRecord(g playedGame) gameRecord {
+	r := gameRecord{
+		Game:        g.number + 1,
+		White:       g.rec.White,
+		Black:       g.rec.Black,
+		Opening:     g.rec.StartFEN,
+		Result:      string(g.result.Outcome),
+		Termination: g.result.Termination.String(),
+		Plies:       len(g.result.Moves),
+	}
+
+	// Moves alternate from the side to move in the opening
+	side := 0
+	if fields := strings.Fields(g.rec.StartFEN); len(fields) > 1 && fields[1] == "b" {
+		side = 1
+	}
+	var depths, reports [2]int
+	var nodes [2]int64
+	var times [2]time.Duration
+	for i, search := range g.result.Searches {
+		if search.Depth > 0 {
+			depths[side] += search.Depth
+			reports[side]++
+		}
+		if search.Nodes > 0 {
+			nodes[side] += search.Nodes
+			times[side] += g.result.MoveTimes[i]
+		}
+		side = 1 - side
+	}
+	avgDepth := func(k int) float64 {
+		if reports[k] == 0 {
+			return 0
+		}
+		return float64(depths[k]) / float64(reports[k])
+	}
+	nps := func(k int) int64 {
+		if times[k] <= 0 {
+			return 0
+		}
+		return int64(float64(nodes[k]) / times[k].Seconds())
+	}
+	r.WhiteDepth, r.BlackDepth = avgDepth(0), avgDepth(1)
+	r.WhiteNPS, r.BlackNPS = nps(0), nps(1)
+	return r
+}
@@ -0,0 +1,590 @@
+// Package match plays chess engines against each other: single games,
+// matches with statistics and SPRT, and round-robin or gauntlet
+// tournaments, with time controls, openings, adjudication and PGN output.
+package match
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"chessTomorrow/arbiter"
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+)
+
+// drawOfferTimeout bounds how long an engine may think about a draw offer.
+const drawOfferTimeout = time.Second
+
+// hangTimeout is how long an engine may think in an untimed game before it
+// is taken to have hung and loses on time.
+const hangTimeout = time.Minute
+
+var errIllegalMove = errors.New("illegal move")
+
+// MatchConfig controls how Play and RunMatch run games.
+type MatchConfig struct {
+	Games   int
+	PGNPath string // append every game here if not empty
+
+	// IllegalMoveRetries is how many times an engine is asked again after
+	// returning an illegal move before it forfeits the game.
+	IllegalMoveRetries int
+
+	TimeControl  TimeControl
+	Adjudication Adjudication
+
+	// Negotiation has the engines that support it offer and accept draws
+	// and resign on their own scores; the zero Negotiation has none of it.
+	Negotiation arbiter.Negotiation
+
+	// Openings are the start positions as FENs. Each is played twice in a
+	// row, once with either engine as White, and they are reused in order
+	// when there are more games. Without openings every game starts from
+	// the initial position.
+	Openings []string
+
+	// EngineOptions are the UCI options set on engine 1 and engine 2.
+	EngineOptions [2][]EngineOption
+
+	// Registry, if set, names engines that are played by name instead of
+	// by path.
+	Registry *arbiter.Registry
+
+	// Limits caps the resources of every engine process; one that goes
+	// over them is killed and forfeits its game.
+	Limits arbiter.Limits
+
+	// DebugLog, if not empty, is a directory to write a log of every game
+	// to: what was sent to the engines and everything they wrote.
+	DebugLog string
+
+	// Concurrency is how many games Play runs in parallel; 0 means 1,
+	// or none but the Coordinator's.
+	Concurrency int
+
+	// Coordinator, if set, also hands the games of a match out to remote
+	// workers.
+	Coordinator *Coordinator
+
+	// State, if set, records finished games, and holds those of an
+	// earlier run to skip.
+	State *MatchState
+
+	// Results, if set, receives a record of every game.
+	Results *ResultWriter
+
+	// EventLog, if set, receives every game's result for rating tools.
+	EventLog *EventLog
+
+	// Cutechess prints the start and result of every game and the score
+	// so far, and the summary, as cutechess-cli does, for tools that read
+	// its output.
+	Cutechess bool
+
+	// Dashboard, if set, follows the games for the web page.
+	Dashboard *Dashboard
+
+	// SPRT, if set, ends the match as soon as it accepts a hypothesis;
+	// Games is then the most games to play.
+	SPRT *SPRT
+}
+
+// RunMatch plays one game between white and black, from the position the
+// recorder starts at. What the engines do only decides the game; an error
+// means the game couldn't be played at all.
+func RunMatch(white, black arbiter.ChessEngine, cfg MatchConfig, rec *GameRecorder) (GameResult, error) {
+	opt, err := chess.FEN(rec.StartFEN)
+	if err != nil {
+		return GameResult{}, fmt.Errorf("bad start position: %w", err)
+	}
+	game := chess.NewGame(opt)
+	for _, eng := range []arbiter.ChessEngine{white, black} {
+		if n, ok := eng.(arbiter.Negotiable); ok {
+			n.SetNegotiation(cfg.Negotiation)
+		}
+	}
+	clock := NewClock(cfg.TimeControl)
+	adjudicator := &adjudicator{Adjudication: cfg.Adjudication}
+	var res GameResult
+
+	for game.Outcome() == chess.NoOutcome {
+		pos := game.Position()
+		turn := pos.Turn()
+		eng, opp, name := white, black, rec.White
+		if turn == chess.Black {
+			eng, opp, name = black, white, rec.Black
+		}
+
+		if offerDraw(eng, opp, pos) {
+			game.Draw(chess.DrawOffer)
+			break
+		}
+
+		budget, timed := clock.Budget(turn)
+		if !timed {
+			budget = hangTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), budget)
+
+		var mv *chess.Move
+		var err error
+		start := time.Now()
+		for attempt := 0; attempt <= cfg.IllegalMoveRetries; attempt++ {
+			mv, err = eng.GetMove(ctx, pos, clock.State(turn))
+			if err != nil {
+				break
+			}
+			// Engines only name the squares; look up the legal move.
+			if mv, err = board.UCIToMove(pos, board.MoveToUCI(mv)); err == nil {
+				break
+			}
+			err = fmt.Errorf("%w: %v", errIllegalMove, err)
+		}
+		elapsed := time.Since(start)
+		cancel()
+
+		if errors.Is(err, arbiter.ErrResign) {
+			game.Resign(turn)
+			break
+		}
+
+		// In every case below the side to move forfeits.
+		if errors.Is(err, context.DeadlineExceeded) {
+			elapsed = budget
+		}
+		if errors.Is(err, context.DeadlineExceeded) || !clock.Spend(turn, elapsed) {
+			game.Resign(turn)
+			res.Violation = fmt.Sprintf("%s: lost on time after %v", name, elapsed.Round(time.Millisecond))
+			res.Termination = Timeout
+			break
+		}
+		if err != nil {
+			game.Resign(turn)
+			res.Violation = fmt.Sprintf("%s: %v", name, err)
+			res.Termination = IllegalMove
+			if !errors.Is(err, errIllegalMove) {
+				res.Termination = EngineFailure
+			}
+			break
+		}
+
+		comment := moveComment(eng, elapsed, clock, turn)
+		rec.Record(game.Position(), mv)
+		rec.Annotate(comment)
+		if err := game.Move(mv); err != nil {
+			return res, fmt.Errorf("illegal move played: %w", err)
+		}
+		cfg.Dashboard.moved(rec, game.Position(), mv, turn, comment, clock)
+		res.Moves = append(res.Moves, board.MoveToUCI(mv))
+		res.MoveTimes = append(res.MoveTimes, elapsed)
+		var search arbiter.SearchInfo
+		if reporter, ok := eng.(arbiter.SearchReporter); ok {
+			search = reporter.LastSearch()
+		}
+		res.Searches = append(res.Searches, search)
+
+		if game.Outcome() != chess.NoOutcome {
+			break
+		}
+		if winner, over := adjudicator.observe(turn, eng, len(res.Moves)); over {
+			if winner == chess.NoColor {
+				game.Draw(chess.DrawOffer)
+			} else {
+				game.Resign(winner.Other())
+			}
+			res.Termination = Adjudicated
+			break
+		}
+	}
+
+	res.Outcome = game.Outcome()
+	res.Winner = winnerOf(res.Outcome)
+	if res.Termination == Unterminated {
+		res.Termination = terminationFromMethod(game.Method())
+	}
+	res.FinalFEN = game.Position().String()
+
+	rec.Finish(res.Outcome, res.Termination.String())
+	return res, nil
+}
+
+// moveComment describes a move for the PGN the way GUIs show it: the
+// engine's score and depth if it reported them, the thinking time, and the
+// time left on the clock as a [%clk] command.
+func moveComment(eng arbiter.ChessEngine, elapsed time.Duration, clock *Clock, side chess.Color) string {
+	var parts []string
+	if reporter, ok := eng.(arbiter.SearchReporter); ok {
+		if eval := reporter.LastSearch().String(); eval != "" {
+			parts = append(parts, eval)
+		}
+	}
+	parts = append(parts, fmt.Sprintf("%.3fs", elapsed.Seconds()))
+	if left, ok := clock.Remaining(side); ok {
+		s := int(left.Seconds())
+		parts = append(parts, fmt.Sprintf("[%%clk %d:%02d:%02d]", s/3600, s/60%60, s%60))
+	}
+	return strings.Join(parts, " ")
+}
+
+// offerDraw lets eng offer a draw before it moves and forwards the offer to
+// opp. It reports whether both engines agreed.
+func offerDraw(eng, opp arbiter.ChessEngine, pos *chess.Position) bool {
+	offerer, ok := eng.(arbiter.DrawNegotiator)
+	if !ok {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), drawOfferTimeout)
+	defer cancel()
+	if !offerer.WantsDraw(ctx, pos) {
+		return false
+	}
+	receiver, ok := opp.(arbiter.DrawNegotiator)
+	return ok && receiver.AcceptDraw(ctx, pos)
+}
+
+// Play runs cfg.Games games and prints only the summary.
+func Play(enginePath1, enginePath2 string, cfg MatchConfig) error {
+	m, err := playMatch(enginePath1, enginePath2, cfg)
+	if err != nil {
+		return err
+	}
+	m.print(cfg)
+	return nil
+}
+
+// matchSummary is what a match between two engines came to.
+type matchSummary struct {
+	names    [2]string
+	tallies  [2]Tally // engine 1, engine 2
+	results  map[chess.Outcome]int
+	played   int
+	resumed  int    // of played, finished by an earlier run
+	decision string // the SPRT's, if any
+	workers  int
+	elapsed  time.Duration
+}
+
+// playMatch runs cfg.Games games, printing only forfeits and the SPRT's
+// progress. The engines swap colors after every game, so each plays White
+// as often as Black. With cfg.Concurrency above 1, that many engine pairs
+// play at once, each in its own goroutine with its own engine processes.
+// With cfg.Coordinator, remote workers play games too. An error that stops
+// the match, such as a PGN file that can't be written, lets the games in
+// progress end first, so that every engine is closed.
+func playMatch(enginePath1, enginePath2 string, cfg MatchConfig) (matchSummary, error) {
+	workers := max(1, min(cfg.Concurrency, cfg.Games))
+	if cfg.Coordinator != nil {
+		workers = min(cfg.Concurrency, cfg.Games)
+	}
+	pairs := make([][2]*arbiter.UCIEngineAdapter, workers)
+	for w := range pairs {
+		for k, path := range []string{enginePath1, enginePath2} {
+			eng, err := cfg.Registry.Lookup(path).Start(cfg.Limits)
+			if err != nil {
+				return matchSummary{}, err
+			}
+			defer eng.Close()
+			for _, opt := range cfg.EngineOptions[k] {
+				if err := eng.SetOption(opt.Name, opt.Value); err != nil {
+					return matchSummary{}, fmt.Errorf("%s: option %s: %w", eng.Name, opt.Name, err)
+				}
+			}
+			pairs[w][k] = eng
+		}
+	}
+
+	results := map[chess.Outcome]int{
+		chess.WhiteWon: 0,
+		chess.BlackWon: 0,
+		chess.Draw:     0,
+	}
+	var tallies [2]Tally
+	count := func(number int, outcome chess.Outcome) {
+		results[outcome]++
+		color1 := chess.White
+		if number%2 == 1 {
+			color1 = chess.Black
+		}
+		tallies[0].Add(color1, outcome)
+		tallies[1].Add(color1.Other(), outcome)
+	}
+	// Without local engines, the names come with the first remote game
+	names := [2]string{filepath.Base(enginePath1), filepath.Base(enginePath2)}
+	if len(pairs) > 0 {
+		names = [2]string{pairs[0][0].Name, pairs[0][1].Name}
+	}
+	cfg.Dashboard.begin(names)
+
+	// Games finished by an earlier run count as they were
+	done := make(map[int]bool)
+	for _, g := range cfg.State.games(enginePath1, enginePath2) {
+		done[g.Number] = true
+		count(g.Number, g.Outcome)
+	}
+	resumed, decision := len(done), ""
+	if resumed > 0 {
+		fmt.Printf("Resuming after %d games\n", resumed)
+		if cfg.SPRT != nil {
+			decision = cfg.SPRT.Decision(cfg.SPRT.LLR(&tallies[0]))
+		}
+	}
+
+	var pgnFile *os.File
+	if cfg.PGNPath != "" {
+		f, err := os.OpenFile(cfg.PGNPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return matchSummary{}, err
+		}
+		defer f.Close()
+		pgnFile = f
+	}
+
+	// Workers take game numbers from games and hand finished games back;
+	// only this goroutine touches the totals and the PGN file.
+	games := make(chan int)
+	stop := make(chan struct{}) // closed to hand out no more games
+	finished := make(chan playedGame)
+	fed := make(chan struct{}) // closed once games is
+	var wg sync.WaitGroup
+	for _, pair := range pairs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range games {
+				// Engine 1 has White in even games
+				white, black := pair[i%2], pair[1-i%2]
+				fen := startFEN
+				if len(cfg.Openings) > 0 {
+					fen = cfg.Openings[i/2%len(cfg.Openings)]
+				}
+				rec := NewGameRecorder(white.Name, black.Name, fen)
+				cfg.Dashboard.started(i, rec)
+				if cfg.Cutechess {
+					cutechessStarted(i, cfg.Games, rec)
+				}
+				logPath, closeLog := openGameLog(cfg.DebugLog, i, white, black)
+				res, err := RunMatch(white, black, cfg, rec)
+				closeLog()
+				finished <- playedGame{number: i, result: res, rec: rec, logPath: logPath, err: err}
+				if err != nil {
+					return
+				}
+
+				// An engine that crashed or hung has lost its game; give
+				// it a fresh process for the next one
+				for _, eng := range pair {
+					if eng.Broken() {
+						if err := eng.Restart(); err != nil {
+							log.Printf("restarting %s: %v", eng.Name, err)
+						}
+					}
+				}
+			}
+		}()
+	}
+	if c := cfg.Coordinator; c != nil {
+		order := WorkOrder{
+			Engines:            [2]string{enginePath1, enginePath2},
+			EngineOptions:      cfg.EngineOptions,
+			Limits:             cfg.Limits,
+			TimeControl:        cfg.TimeControl,
+			Adjudication:       cfg.Adjudication,
+			Negotiation:        cfg.Negotiation,
+			IllegalMoveRetries: cfg.IllegalMoveRetries,
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.run(order, cfg.Openings, games, fed, finished)
+		}()
+	}
+	toPlay := cfg.Games
+	if decision != "" {
+		toPlay = 0
+	}
+	go func() {
+	feed:
+		for i := 0; i < toPlay; i++ {
+			if done[i] {
+				continue
+			}
+			select {
+			case games <- i:
+			case <-stop:
+				break feed
+			}
+		}
+		close(games)
+		close(fed)
+		wg.Wait()
+		close(finished)
+	}()
+
+	// halt hands out no more games, once
+	halted := false
+	halt := func() {
+		if !halted {
+			halted = true
+			close(stop)
+		}
+	}
+	var matchErr error
+	start := time.Now()
+	played := resumed
+	for g := range finished {
+		if matchErr != nil {
+			// Only waiting for the games in progress
+			continue
+		}
+		if g.err != nil {
+			matchErr = fmt.Errorf("game %d: %w", g.number+1, g.err)
+			halt()
+			continue
+		}
+		if len(pairs) == 0 && played == resumed {
+			names = [2]string{g.rec.White, g.rec.Black}
+			if g.number%2 == 1 {
+				names[0], names[1] = names[1], names[0]
+			}
+		}
+		played++
+		count(g.number, g.result.Outcome)
+		cfg.Dashboard.ended(g, tallies)
+		if cfg.Cutechess {
+			cutechessFinished(g, names, &tallies[0])
+		}
+		if cfg.SPRT != nil && decision == "" {
+			// Games already running are still played and counted
+			llr := cfg.SPRT.LLR(&tallies[0])
+			lower, upper := cfg.SPRT.Bounds()
+			if !cfg.Cutechess {
+				fmt.Printf("SPRT: %d games, LLR %.2f [%.2f, %.2f]\n", played, llr, lower, upper)
+			}
+			if decision = cfg.SPRT.Decision(llr); decision != "" {
+				halt()
+			}
+		}
+		if g.result.Violation != "" {
+			// The cutechess lines give the reason already
+			if !cfg.Cutechess {
+				fmt.Printf("Game %d forfeited: %s\n", g.number+1, g.result.Violation)
+			}
+			if g.logPath != "" {
+				fmt.Printf("Engine log: %s\n", g.logPath)
+			}
+		}
+
+		if err := g.record(pgnFile, cfg, enginePath1, enginePath2); err != nil {
+			matchErr = err
+			halt()
+		}
+	}
+	if matchErr != nil {
+		return matchSummary{}, matchErr
+	}
+	return matchSummary{
+		names:    names,
+		tallies:  tallies,
+		results:  results,
+		played:   played,
+		resumed:  resumed,
+		decision: decision,
+		workers:  workers + cfg.Coordinator.workerCount(),
+		elapsed:  time.Since(start),
+	}, nil
+}
+
+// record writes g to the match's PGN file, if it has one, and to its
+// results, event log and state.
+func (g playedGame) record(pgnFile *os.File, cfg MatchConfig, enginePath1, enginePath2 string) error {
+	if pgnFile != nil {
+		if err := g.rec.WritePGN(pgnFile); err != nil {
+			return err
+		}
+	}
+	if err := cfg.Results.Write(g); err != nil {
+		return err
+	}
+	if err := cfg.EventLog.Write(g); err != nil {
+		return err
+	}
+	return cfg.State.record(enginePath1, enginePath2, g)
+}
+
+func (m *matchSummary) print(cfg MatchConfig) {
+	if cfg.Cutechess {
+		m.printCutechess(cfg)
+		return
+	}
+	fmt.Printf("\nResults after %d games:\n", m.played)
+	for k := range m.tallies {
+		fmt.Printf("Engine %d (%s): %v\n", k+1, m.names[k], &m.tallies[k])
+	}
+	fmt.Printf("White Wins: %d\n", m.results[chess.WhiteWon])
+	fmt.Printf("Black Wins: %d\n", m.results[chess.BlackWon])
+	fmt.Printf("Draws:      %d\n", m.results[chess.Draw])
+	elo, margin := m.tallies[0].Elo()
+	fmt.Printf("Elo:        %s (engine 1 vs engine 2, 95%% confidence)\n", formatElo(elo, margin))
+	fmt.Printf("LOS:        %.1f%%\n", 100*m.tallies[0].LOS())
+	if sprt := cfg.SPRT; sprt != nil {
+		decision := m.decision
+		if decision == "" {
+			decision = "no decision"
+		}
+		fmt.Printf("SPRT:       elo0 %g, elo1 %g, alpha %g, beta %g: %s\n", sprt.Elo0, sprt.Elo1, sprt.Alpha, sprt.Beta, decision)
+	}
+	if m.played > m.resumed {
+		fmt.Printf("Games/min:  %.1f (%d at a time, %v)\n",
+			float64(m.played-m.resumed)/m.elapsed.Minutes(), m.workers, m.elapsed.Round(time.Second))
+	}
+}
+
+// playedGame is a finished game on its way from a worker to playMatch.
+type playedGame struct {
+	number  int
+	result  GameResult
+	rec     *GameRecorder
+	logPath string // the game's engine log, if any
+	err     error  // why the game couldn't be played, if it couldn't
+}
+
+// openGameLog points the engines' logs at a new file for game i in dir and
+// returns its path and a function that detaches and closes it. With an
+// empty dir there is no log.
+func openGameLog(dir string, i int, white, black *arbiter.UCIEngineAdapter) (string, func()) {
+	if dir == "" {
+		return "", func() {}
+	}
+	name := fmt.Sprintf("game%03d-%s-%s.log", i+1, fileSafe(white.Name), fileSafe(black.Name))
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		log.Printf("engine log: %v", err)
+		return "", func() {}
+	}
+	white.SetLog(f)
+	black.SetLog(f)
+	return path, func() {
+		white.SetLog(nil)
+		black.SetLog(nil)
+		f.Close()
+	}
+}
+
+// fileSafe replaces the characters of an engine name that don't belong in
+// a file name.
+func fileSafe(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,188 @@
+package match
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"chessTomorrow/arbiter"
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+)
+
+// humanPlayer names the person at the keyboard on the play command line.
+const humanPlayer = "human"
+
+const humanHelp = `commands:
+  <move>       a move in SAN (Nf3) or UCI (g1f3) notation
+  undo         take back your last move and the engine's reply
+  hint         ask the engine for a move
+  save <file>  write the game so far to a PGN file
+  board        print the board again
+  resign       give up the game
+  quit         leave without finishing the game`
+
+// humanGame is a game between a person typing moves and an engine.
+type humanGame struct {
+	game     *chess.Game
+	startFEN string
+	human    chess.Color
+	eng      arbiter.ChessEngine
+	names    [2]string // White, Black
+	moveTime time.Duration
+	in       *bufio.Scanner
+	out      io.Writer
+}
+
+// play runs the game until it ends or the human quits.
+func (h *humanGame) play() error {
+	fmt.Fprintf(h.out, "%s vs %s. Type help for the commands.\n", h.names[0], h.names[1])
+	h.printBoard()
+	for h.game.Outcome() == chess.NoOutcome {
+		if h.game.Position().Turn() != h.human {
+			if err := h.engineMove(); err != nil {
+				return err
+			}
+			h.printBoard()
+			continue
+		}
+
+		fmt.Fprint(h.out, "> ")
+		if !h.in.Scan() {
+			return h.in.Err()
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimSpace(h.in.Text()), " ")
+		switch cmd {
+		case "":
+		case "help":
+			fmt.Fprintln(h.out, humanHelp)
+		case "board":
+			h.printBoard()
+		case "undo":
+			if !h.takeBack() {
+				fmt.Fprintln(h.out, "Nothing to undo")
+				continue
+			}
+			h.printBoard()
+		case "hint":
+			move, err := h.ask()
+			if err != nil {
+				fmt.Fprintf(h.out, "No hint: %v\n", err)
+				continue
+			}
+			fmt.Fprintf(h.out, "Hint: %s\n", chess.AlgebraicNotation{}.Encode(h.game.Position(), move))
+		case "save":
+			if err := h.save(strings.TrimSpace(arg)); err != nil {
+				fmt.Fprintln(h.out, err)
+				continue
+			}
+			fmt.Fprintf(h.out, "Saved to %s\n", strings.TrimSpace(arg))
+		case "resign":
+			h.game.Resign(h.human)
+		case "quit", "exit":
+			return nil
+		default:
+			move, err := parseMove(h.game.Position(), cmd)
+			if err != nil {
+				fmt.Fprintf(h.out, "%v; type help for the commands\n", err)
+				continue
+			}
+			h.game.Move(move)
+			h.printBoard()
+		}
+	}
+	fmt.Fprintf(h.out, "Game over: %s (%s)\n", h.game.Outcome(), terminationFromMethod(h.game.Method()))
+	return nil
+}
+
+// parseMove reads a move in SAN or UCI notation.
+func parseMove(pos *chess.Position, s string) (*chess.Move, error) {
+	if move, err := (chess.AlgebraicNotation{}).Decode(pos, s); err == nil {
+		return move, nil
+	}
+	if move, err := board.UCIToMove(pos, s); err == nil {
+		return move, nil
+	}
+	return nil, fmt.Errorf("%q is not a legal move", s)
+}
+
+// ask has the engine search the current position.
+func (h *humanGame) ask() (*chess.Move, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.moveTime+hangTimeout)
+	defer cancel()
+	move, err := h.eng.GetMove(ctx, h.game.Position(), arbiter.ClockState{MoveTime: h.moveTime})
+	if err != nil {
+		return nil, err
+	}
+	// Engines only name the squares; look up the legal move.
+	return board.UCIToMove(h.game.Position(), board.MoveToUCI(move))
+}
+
+func (h *humanGame) engineMove() error {
+	move, err := h.ask()
+	if err != nil {
+		return fmt.Errorf("%s failed to move: %w", h.engineName(), err)
+	}
+	fmt.Fprintf(h.out, "%s plays %s\n", h.engineName(), chess.AlgebraicNotation{}.Encode(h.game.Position(), move))
+	return h.game.Move(move)
+}
+
+func (h *humanGame) engineName() string {
+	if h.human == chess.White {
+		return h.names[1]
+	}
+	return h.names[0]
+}
+
+// takeBack undoes the human's last move and the engine's reply, by
+// replaying the game without them. It reports false if the human has not
+// moved yet.
+func (h *humanGame) takeBack() bool {
+	moves := h.game.Moves()
+	if len(moves) < 2 {
+		return false
+	}
+	opt, _ := chess.FEN(h.startFEN)
+	game := chess.NewGame(opt)
+	for _, move := range moves[:len(moves)-2] {
+		game.Move(move)
+	}
+	h.game = game
+	return true
+}
+
+func (h *humanGame) save(path string) error {
+	if path == "" {
+		return errors.New("usage: save <file>")
+	}
+	rec := NewGameRecorder(h.names[0], h.names[1], h.startFEN)
+	rec.Event = "Human vs engine"
+	positions := h.game.Positions()
+	for i, move := range h.game.Moves() {
+		rec.Record(positions[i], move)
+	}
+	if outcome := h.game.Outcome(); outcome != chess.NoOutcome {
+		rec.Finish(outcome, terminationFromMethod(h.game.Method()).String())
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return rec.WritePGN(f)
+}
+
+func (h *humanGame) printBoard() {
+	pos := h.game.Position()
+	opts := board.RenderOptions{Unicode: true, Coordinates: true, Flipped: h.human == chess.Black}
+	if moves := h.game.Moves(); len(moves) > 0 {
+		opts.LastMove = moves[len(moves)-1]
+	}
+	fmt.Fprint(h.out, board.BoardToString(pos.Board(), opts))
+	fmt.Fprintf(h.out, "%s to move\n", pos.Turn().Name())
+}
@@ -0,0 +1,47 @@
+package match
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"chessTomorrow/epd"
+	"github.com/notnil/chess"
+)
+
+// LoadOpenings reads the start positions of a match from an opening suite
+// and returns them as FENs. A .pgn file gives the position at the end of
+// each game; any other file is read as EPD, one position per line, and
+// its operations are ignored.
+func LoadOpenings(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var fens []string
+	if strings.EqualFold(filepath.Ext(path), ".pgn") {
+		scanner := chess.NewScanner(f)
+		for scanner.Scan() {
+			fens = append(fens, scanner.Next().Position().String())
+		}
+		if err := scanner.Err(); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	} else {
+		tests, err := epd.Parse(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, t := range tests {
+			fens = append(fens, t.Position.String())
+		}
+	}
+	if len(fens) == 0 {
+		return nil, fmt.Errorf("%s: no positions", path)
+	}
+	return fens, nil
+}
@@ -0,0 +1,27 @@
+package match
+
+import "strings"
+
+// EngineOption is a UCI option to set on an engine before the match.
+type EngineOption struct {
+	Name  string
+	Value string // empty for a button
+}
+
+// optionFlags collects repeated "Name=Value" command-line flags.
+type optionFlags []EngineOption
+
+func (f *optionFlags) String() string {
+	var parts []string
+	for _, opt := range *f {
+		parts = append(parts, opt.Name+"="+opt.Value)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Set takes one option. A flag without "=" names a button option.
+func (f *optionFlags) Set(s string) error {
+	name, value, _ := strings.Cut(s, "=")
+	*f = append(*f, EngineOption{Name: strings.TrimSpace(name), Value: strings.TrimSpace(value)})
+	return nil
+}
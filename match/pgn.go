@@ -0,0 +1,188 @@
+package match
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+const startFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+// GameRecorder accumulates the moves and metadata of a single game so it
+// can be archived as PGN.
+type GameRecorder struct {
+	Event       string
+	White       string
+	Black       string
+	Date        time.Time
+	StartFEN    string
+	Result      chess.Outcome
+	Termination string
+
+	moves    []string // SAN
+	comments []string // parallel to moves, empty for none
+	nags     []int    // parallel to moves, 0 for none
+}
+
+// NewGameRecorder starts recording a game between the named engines.
+func NewGameRecorder(white, black, fen string) *GameRecorder {
+	return &GameRecorder{
+		Event:    "Engine match",
+		White:    white,
+		Black:    black,
+		Date:     time.Now(),
+		StartFEN: fen,
+		Result:   chess.NoOutcome,
+	}
+}
+
+// Record appends a move played in pos. It must be called before the move
+// is applied, since SAN depends on the position the move is played from.
+func (r *GameRecorder) Record(pos *chess.Position, move *chess.Move) {
+	r.moves = append(r.moves, chess.AlgebraicNotation{}.Encode(pos, move))
+	r.comments = append(r.comments, "")
+	r.nags = append(r.nags, 0)
+}
+
+// Annotate sets the comment written after the last recorded move.
+func (r *GameRecorder) Annotate(comment string) {
+	if len(r.comments) > 0 {
+		r.comments[len(r.comments)-1] = strings.NewReplacer("{", "(", "}", ")").Replace(comment)
+	}
+}
+
+// Mark attaches a numeric annotation glyph to the last recorded move, such
+// as 2 for a mistake ("?") or 4 for a blunder ("??").
+func (r *GameRecorder) Mark(nag int) {
+	if len(r.nags) > 0 {
+		r.nags[len(r.nags)-1] = nag
+	}
+}
+
+// Finish stores the result and how the game ended.
+func (r *GameRecorder) Finish(outcome chess.Outcome, termination string) {
+	r.Result = outcome
+	r.Termination = termination
+}
+
+// recorderJSON is a GameRecorder with its moves, as distributed match
+// workers send it.
+type recorderJSON struct {
+	Event       string
+	White       string
+	Black       string
+	Date        time.Time
+	StartFEN    string
+	Result      chess.Outcome
+	Termination string
+	Moves       []string
+	Comments    []string
+	NAGs        []int
+}
+
+// MarshalJSON encodes the game with its moves, comments and glyphs.
+func (r *GameRecorder) MarshalJSON() ([]byte, error) {
+	return json.Marshal(recorderJSON{
+		Event: r.Event, White: r.White, Black: r.Black, Date: r.Date,
+		StartFEN: r.StartFEN, Result: r.Result, Termination: r.Termination,
+		Moves: r.moves, Comments: r.comments, NAGs: r.nags,
+	})
+}
+
+// UnmarshalJSON decodes a game encoded by MarshalJSON.
+func (r *GameRecorder) UnmarshalJSON(data []byte) error {
+	var j recorderJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if len(j.Comments) != len(j.Moves) || len(j.NAGs) != len(j.Moves) {
+		return fmt.Errorf("game record has %d moves but %d comments and %d glyphs", len(j.Moves), len(j.Comments), len(j.NAGs))
+	}
+	*r = GameRecorder{
+		Event: j.Event, White: j.White, Black: j.Black, Date: j.Date,
+		StartFEN: j.StartFEN, Result: j.Result, Termination: j.Termination,
+		moves: j.Moves, comments: j.Comments, nags: j.NAGs,
+	}
+	return nil
+}
+
+// WritePGN writes the game as a single PGN record followed by a blank line,
+// so several games can be appended to the same file.
+func (r *GameRecorder) WritePGN(w io.Writer) error {
+	var b strings.Builder
+
+	tag := func(key, value string) { writeTag(&b, key, value) }
+	tag("Event", r.Event)
+	tag("Site", "?")
+	tag("Date", r.Date.Format("2006.01.02"))
+	tag("Round", "-")
+	tag("White", r.White)
+	tag("Black", r.Black)
+	tag("Result", string(r.Result))
+	if r.StartFEN != "" && r.StartFEN != startFEN {
+		tag("SetUp", "1")
+		tag("FEN", r.StartFEN)
+	}
+	if r.Termination != "" {
+		tag("Termination", r.Termination)
+	}
+	b.WriteString("\n")
+
+	// Move numbers depend on who moves first in the start position.
+	moveNumber, blackFirst := 1, false
+	if fields := strings.Fields(r.StartFEN); len(fields) == 6 {
+		blackFirst = fields[1] == "b"
+		fmt.Sscan(fields[5], &moveNumber)
+	}
+
+	var tokens []string
+	for i, san := range r.moves {
+		ply := i
+		if blackFirst {
+			ply++
+		}
+		switch {
+		case ply%2 == 0:
+			tokens = append(tokens, fmt.Sprintf("%d.", moveNumber+ply/2))
+		case i == 0:
+			tokens = append(tokens, fmt.Sprintf("%d...", moveNumber))
+		}
+		tokens = append(tokens, san)
+		if r.nags[i] != 0 {
+			tokens = append(tokens, fmt.Sprintf("$%d", r.nags[i]))
+		}
+		if r.comments[i] != "" {
+			tokens = append(tokens, "{"+r.comments[i]+"}")
+		}
+	}
+	tokens = append(tokens, string(r.Result))
+
+	// Wrap movetext at 80 columns as the PGN export format asks.
+	line := 0
+	for i, t := range tokens {
+		if i > 0 {
+			if line+1+len(t) > 80 {
+				b.WriteString("\n")
+				line = 0
+			} else {
+				b.WriteString(" ")
+				line++
+			}
+		}
+		b.WriteString(t)
+		line += len(t)
+	}
+	b.WriteString("\n\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeTag writes one PGN tag pair.
+func writeTag(b *strings.Builder, key, value string) {
+	fmt.Fprintf(b, "[%s \"%s\"]\n", key, strings.ReplaceAll(value, `"`, `\"`))
+}
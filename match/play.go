@@ -0,0 +1,154 @@
+package match
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"chessTomorrow/alphabeta"
+	"chessTomorrow/arbiter"
+	"chessTomorrow/board"
+	"chessTomorrow/enginerpc"
+	"github.com/notnil/chess"
+)
+
+// nativeEngine names the built-in engine on the play command line.
+const nativeEngine = "alphabeta"
+
+// registryFlag defines the -engines flag of the commands that take engine
+// names, and returns a function that loads the registry it names.
+func registryFlag(fs *flag.FlagSet) func() (*arbiter.Registry, error) {
+	path := fs.String("engines", arbiter.DefaultRegistry, "JSON registry of engines that can be named instead of given by path")
+	return func() (*arbiter.Registry, error) {
+		return arbiter.LoadRegistry(*path)
+	}
+}
+
+// PlayMain runs the play command: one game between two engines, written
+// as PGN to stdout when it ends. Each engine is the path of a UCI binary,
+// or "alphabeta" for the built-in engine, played in-process. One of the
+// players may instead be "human", who then plays the engine at a prompt.
+func PlayMain(args []string) error {
+	fs := flag.NewFlagSet("play", flag.ExitOnError)
+	tc := fs.String("tc", "10+0.1", "time control as [moves/]seconds[+increment]")
+	fen := fs.String("fen", "", "start position; empty for the initial one")
+	moveTime := fs.Duration("movetime", time.Second, "engine thinking time per move against a human")
+	negotiate := fs.Bool("negotiate", false, "let the engines offer and accept draws after move 40 with scores near zero, and resign after 5 moves 8 pawns down")
+	loadRegistry := registryFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: play [flags] <white> <black>\na player is a registry engine, a UCI binary, %q, %shost:port or %q\n", nativeEngine, enginerpc.Scheme, humanPlayer)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	timeControl, err := ParseTimeControl(*tc)
+	if err != nil {
+		return err
+	}
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	start := startFEN
+	if *fen != "" {
+		if err := board.ValidateFEN(*fen); err != nil {
+			return err
+		}
+		start = *fen
+	}
+
+	var engines [2]arbiter.ChessEngine
+	var names [2]string
+	human := chess.NoColor
+	for k, name := range fs.Args() {
+		if name == humanPlayer {
+			if human != chess.NoColor {
+				return errors.New("only one player can be human")
+			}
+			human = chess.White + chess.Color(k)
+			names[k] = "Human"
+			continue
+		}
+		eng, engName, closeEngine, err := openEngine(registry, name)
+		if err != nil {
+			return err
+		}
+		defer closeEngine()
+		engines[k], names[k] = eng, engName
+	}
+
+	if human != chess.NoColor {
+		opt, _ := chess.FEN(start)
+		h := &humanGame{
+			game:     chess.NewGame(opt),
+			startFEN: start,
+			human:    human,
+			eng:      engines[human.Other()-chess.White],
+			names:    names,
+			moveTime: *moveTime,
+			in:       bufio.NewScanner(os.Stdin),
+			out:      os.Stdout,
+		}
+		return h.play()
+	}
+
+	rec := NewGameRecorder(names[0], names[1], start)
+	cfg := MatchConfig{IllegalMoveRetries: 2, TimeControl: timeControl}
+	if *negotiate {
+		cfg.Negotiation = arbiter.DefaultNegotiation
+	}
+	res, err := RunMatch(engines[0], engines[1], cfg, rec)
+	if err != nil {
+		return err
+	}
+	if res.Violation != "" {
+		fmt.Fprintf(os.Stderr, "Forfeited: %s\n", res.Violation)
+	}
+	return rec.WritePGN(os.Stdout)
+}
+
+// openEngine starts the engine a command line names: an engine of the
+// registry, the built-in engine for "alphabeta", an engine served over
+// gRPC for grpc://host:port, a UCI binary otherwise. It returns the
+// engine, its name and a function that shuts it down.
+func openEngine(registry *arbiter.Registry, name string) (arbiter.ChessEngine, string, func(), error) {
+	if name == nativeEngine && registry.Find(name) == nil {
+		eng := alphabeta.NewEngine()
+		return eng, eng.Name(), func() {}, nil
+	}
+	spec := registry.Lookup(name)
+	if spec.Protocol == arbiter.ProtocolGRPC {
+		eng, err := enginerpc.Dial(spec.Command)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		if err := setOptions(eng, spec.Options); err != nil {
+			eng.Close()
+			return nil, "", nil, err
+		}
+		return eng, eng.Name, eng.Close, nil
+	}
+	eng, err := spec.Start(arbiter.Limits{})
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return eng, eng.Name, eng.Close, nil
+}
+
+// setOptions sets a registry engine's options on an engine served over
+// gRPC, which Start can't start.
+func setOptions(eng *enginerpc.Client, options map[string]string) error {
+	for name, value := range options {
+		if err := eng.SetOption(name, value); err != nil {
+			return fmt.Errorf("option %s: %w", name, err)
+		}
+	}
+	return nil
+}
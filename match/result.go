@@ -0,0 +1,118 @@
+package match
+
+import (
+	"time"
+
+	"chessTomorrow/arbiter"
+	"github.com/notnil/chess"
+)
+
+// TerminationReason says how a game ended.
+type TerminationReason int
+
+const (
+	Unterminated TerminationReason = iota
+	Checkmate
+	Stalemate
+	Repetition
+	FiftyMoveRule
+	InsufficientMaterial
+	IllegalMove
+	Timeout
+	Resignation
+	EngineFailure
+	DrawAgreement
+	Adjudicated
+)
+
+func (t TerminationReason) String() string {
+	switch t {
+	case Checkmate:
+		return "checkmate"
+	case Stalemate:
+		return "stalemate"
+	case Repetition:
+		return "repetition"
+	case FiftyMoveRule:
+		return "50-move rule"
+	case InsufficientMaterial:
+		return "insufficient material"
+	case IllegalMove:
+		return "illegal move"
+	case Timeout:
+		return "timeout"
+	case Resignation:
+		return "resignation"
+	case EngineFailure:
+		return "engine failure"
+	case DrawAgreement:
+		return "draw agreement"
+	case Adjudicated:
+		return "adjudication"
+	}
+	return "unterminated"
+}
+
+// Keyword returns the PGN standard's word for how the game ended, as the
+// Termination tag of rating tools and cutechess-cli has it: "normal" for
+// a game that ended by the rules or a player's choice.
+func (t TerminationReason) Keyword() string {
+	switch t {
+	case Timeout:
+		return "time forfeit"
+	case IllegalMove:
+		return "rules infraction"
+	case EngineFailure:
+		return "abandoned"
+	case Adjudicated:
+		return "adjudication"
+	case Unterminated:
+		return "unterminated"
+	}
+	return "normal"
+}
+
+// terminationFromMethod maps the rule that ended a notnil/chess game onto
+// a TerminationReason.
+func terminationFromMethod(m chess.Method) TerminationReason {
+	switch m {
+	case chess.Checkmate:
+		return Checkmate
+	case chess.Stalemate:
+		return Stalemate
+	case chess.ThreefoldRepetition, chess.FivefoldRepetition:
+		return Repetition
+	case chess.FiftyMoveRule, chess.SeventyFiveMoveRule:
+		return FiftyMoveRule
+	case chess.InsufficientMaterial:
+		return InsufficientMaterial
+	case chess.Resignation:
+		return Resignation
+	case chess.DrawOffer:
+		return DrawAgreement
+	}
+	return Unterminated
+}
+
+// GameResult is everything RunMatch knows about a finished game.
+type GameResult struct {
+	Winner      chess.Color // chess.NoColor for a draw
+	Outcome     chess.Outcome
+	Termination TerminationReason
+	FinalFEN    string
+	Moves       []string             // UCI notation
+	MoveTimes   []time.Duration      // thinking time per move, parallel to Moves
+	Searches    []arbiter.SearchInfo // what the mover reported, parallel to Moves
+	Violation   string               // what the forfeiting engine did wrong, if anything
+}
+
+// winnerOf returns the side that won a decisive outcome.
+func winnerOf(o chess.Outcome) chess.Color {
+	switch o {
+	case chess.WhiteWon:
+		return chess.White
+	case chess.BlackWon:
+		return chess.Black
+	}
+	return chess.NoColor
+}
@@ -0,0 +1,372 @@
+package match
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"chessTomorrow/arbiter"
+	"github.com/notnil/chess"
+)
+
+// SPSA gain schedule exponents and the stability constant as a fraction of
+// the iterations, the values the usual chess tuners use.
+const (
+	spsaAlpha  = 0.602
+	spsaGamma  = 0.101
+	spsaStable = 0.1
+)
+
+// SPSAParam is one engine option being tuned. C is the perturbation at the
+// last iteration and R the learning rate there; the perturbation and the
+// step both shrink from larger values at the start.
+type SPSAParam struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	C     float64 `json:"c"`
+	R     float64 `json:"r"`
+}
+
+// clamp keeps v within the parameter's range.
+func (p *SPSAParam) clamp(v float64) float64 {
+	return math.Max(p.Min, math.Min(p.Max, v))
+}
+
+// option returns v as the engine is sent it: rounded, since UCI spin
+// options are integers.
+func (p *SPSAParam) option(v float64) string {
+	return strconv.Itoa(int(math.Round(p.clamp(v))))
+}
+
+// gains returns the perturbation c_k and the step a_k for iteration k of n.
+func (p *SPSAParam) gains(k, n int) (c, a float64) {
+	stable := spsaStable * float64(n)
+	c = p.C * math.Pow(float64(n), spsaGamma) / math.Pow(float64(k), spsaGamma)
+	aEnd := p.R * p.C * p.C
+	a = aEnd * math.Pow(stable+float64(n), spsaAlpha) / math.Pow(stable+float64(k), spsaAlpha)
+	return c, a
+}
+
+// parseSPSAParam reads "Name=value,min,max[,c[,r]]". c defaults to a
+// twentieth of the range and r to 0.002.
+func parseSPSAParam(s string) (SPSAParam, error) {
+	name, spec, ok := strings.Cut(s, "=")
+	fields := strings.Split(spec, ",")
+	if !ok || strings.TrimSpace(name) == "" || len(fields) < 3 || len(fields) > 5 {
+		return SPSAParam{}, fmt.Errorf("parameter %q is not Name=value,min,max[,c[,r]]", s)
+	}
+	var nums []float64
+	for _, f := range fields {
+		x, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return SPSAParam{}, fmt.Errorf("parameter %q: %w", s, err)
+		}
+		nums = append(nums, x)
+	}
+	p := SPSAParam{Name: strings.TrimSpace(name), Value: nums[0], Min: nums[1], Max: nums[2], R: 0.002}
+	p.C = (p.Max - p.Min) / 20
+	if len(nums) > 3 {
+		p.C = nums[3]
+	}
+	if len(nums) > 4 {
+		p.R = nums[4]
+	}
+	if p.Min >= p.Max || p.Value < p.Min || p.Value > p.Max || p.C <= 0 || p.R <= 0 {
+		return SPSAParam{}, fmt.Errorf("parameter %q: need min < max, the value between them and positive c and r", s)
+	}
+	return p, nil
+}
+
+// spsaParams collects repeated -param flags.
+type spsaParams []SPSAParam
+
+func (f *spsaParams) String() string {
+	var parts []string
+	for _, p := range *f {
+		parts = append(parts, fmt.Sprintf("%s=%g,%g,%g,%g,%g", p.Name, p.Value, p.Min, p.Max, p.C, p.R))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (f *spsaParams) Set(s string) error {
+	p, err := parseSPSAParam(s)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, p)
+	return nil
+}
+
+// SPSAState is a tuning run as saved after every iteration: the parameter
+// vector so far and how many iterations have updated it.
+type SPSAState struct {
+	path       string
+	Iteration  int         `json:"iteration"`
+	Iterations int         `json:"iterations"`
+	Params     []SPSAParam `json:"params"`
+}
+
+// loadSPSAState reads the state saved to path, or returns nil if there is
+// no such file.
+func loadSPSAState(path string) (*SPSAState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &SPSAState{path: path}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// save writes the state to its file, replacing it only once it is whole.
+func (s *SPSAState) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// spsaPair is one worker's two instances of the tuned engine.
+type spsaPair struct {
+	plus, minus *arbiter.UCIEngineAdapter
+}
+
+// playPair plays two games from fen, the plus engine with White in the
+// first, and returns its wins minus its losses.
+func (p spsaPair) playPair(fen string, cfg MatchConfig) (int, error) {
+	score := 0
+	for game := range 2 {
+		white, black := p.plus, p.minus
+		if game == 1 {
+			white, black = black, white
+		}
+		rec := NewGameRecorder(white.Name+" "+spsaSide(white == p.plus), black.Name+" "+spsaSide(black == p.plus), fen)
+		res, err := RunMatch(white, black, cfg, rec)
+		if err != nil {
+			return 0, err
+		}
+		if res.Winner != chess.NoColor {
+			if (res.Winner == chess.White) == (white == p.plus) {
+				score++
+			} else {
+				score--
+			}
+		}
+		for _, eng := range []*arbiter.UCIEngineAdapter{p.plus, p.minus} {
+			if eng.Broken() {
+				if err := eng.Restart(); err != nil {
+					log.Printf("restarting %s: %v", eng.Name, err)
+				}
+			}
+		}
+	}
+	return score, nil
+}
+
+// play sets the plus and minus values of iteration k on the engines and
+// plays the iteration's game pair from fen.
+func (p spsaPair) play(k int, fen string, plus, minus []EngineOption, cfg MatchConfig) (int, error) {
+	for i := range plus {
+		if err := p.plus.SetOption(plus[i].Name, plus[i].Value); err != nil {
+			return 0, fmt.Errorf("iteration %d: %w", k, err)
+		}
+		if err := p.minus.SetOption(minus[i].Name, minus[i].Value); err != nil {
+			return 0, fmt.Errorf("iteration %d: %w", k, err)
+		}
+	}
+	score, err := p.playPair(fen, cfg)
+	if err != nil {
+		return 0, fmt.Errorf("iteration %d: %w", k, err)
+	}
+	return score, nil
+}
+
+func spsaSide(plus bool) string {
+	if plus {
+		return "(+)"
+	}
+	return "(-)"
+}
+
+// SPSAMain tunes an engine's UCI options with simultaneous perturbation
+// stochastic approximation. Each iteration nudges every parameter up or
+// down at random, plays a pair of games between the engine with the
+// values nudged one way and the engine with them nudged the other, and
+// moves the values toward whichever side scored better.
+func SPSAMain(args []string) error {
+	fs := flag.NewFlagSet("spsa", flag.ExitOnError)
+	iterations := fs.Int("iterations", 1000, "game pairs to play")
+	tc := fs.String("tc", "5+0.05", "time control as [moves/]seconds[+increment]")
+	margin := fs.Duration("timemargin", moveOverhead, "how far an engine may overrun its time before losing")
+	openings := fs.String("openings", "", "EPD or PGN file of start positions, one for each game pair in turn")
+	concurrency := fs.Int("concurrency", 1, "game pairs to play in parallel, each with its own two engines")
+	out := fs.String("out", "spsa.json", "file the parameters are saved to after every iteration")
+	resume := fs.Bool("resume", false, "carry on from the parameters and iteration saved in -out, ignoring -param and -iterations")
+	var params spsaParams
+	fs.Var(&params, "param", "option to tune as Name=value,min,max[,c[,r]]: c is the final perturbation (default a twentieth of the range), r the final learning rate (default 0.002); repeat for more")
+	var fixed optionFlags
+	loadRegistry := registryFlag(fs)
+	fs.Var(&fixed, "option", "UCI option Name=Value to set on both engines and leave alone; repeat for more")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: spsa [flags] -param Name=value,min,max... <engine>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	enginePath := fs.Arg(0)
+	registry, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+
+	state := &SPSAState{path: *out, Iterations: *iterations, Params: params}
+	if *resume {
+		saved, err := loadSPSAState(*out)
+		if err != nil {
+			return err
+		}
+		if saved == nil {
+			return fmt.Errorf("-resume: no state in %s", *out)
+		}
+		state = saved
+		fmt.Printf("Resuming after iteration %d of %d\n", state.Iteration, state.Iterations)
+	} else if len(params) == 0 {
+		return errors.New("no -param to tune")
+	}
+
+	timeControl, err := ParseTimeControl(*tc)
+	if err != nil {
+		return err
+	}
+	timeControl.Margin = *margin
+	cfg := MatchConfig{TimeControl: timeControl, IllegalMoveRetries: 2}
+	fens := []string{startFEN}
+	if *openings != "" {
+		if fens, err = LoadOpenings(*openings); err != nil {
+			return err
+		}
+	}
+
+	workers := max(1, min(*concurrency, state.Iterations-state.Iteration))
+	pairs := make([]spsaPair, workers)
+	for w := range pairs {
+		var engs [2]*arbiter.UCIEngineAdapter
+		for k := range engs {
+			eng, err := registry.Lookup(enginePath).Start(arbiter.Limits{})
+			if err != nil {
+				return err
+			}
+			defer eng.Close()
+			for _, opt := range fixed {
+				if err := eng.SetOption(opt.Name, opt.Value); err != nil {
+					return err
+				}
+			}
+			engs[k] = eng
+		}
+		pairs[w] = spsaPair{plus: engs[0], minus: engs[1]}
+	}
+
+	// Workers read the parameters and apply their updates under mu; an
+	// update lands on whatever the others have moved the values to since
+	var mu sync.Mutex
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	iters := make(chan int)
+	stop := make(chan struct{}) // closed on the first error, to hand out no more iterations
+	var wg sync.WaitGroup
+	var runErr error
+	fail := func(err error) { // with mu held
+		if runErr == nil {
+			runErr = err
+			close(stop)
+		}
+	}
+	start, first := time.Now(), state.Iteration
+	for _, pair := range pairs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range iters {
+				mu.Lock()
+				n := state.Iterations
+				signs := make([]float64, len(state.Params))
+				var plus, minus []EngineOption
+				for i, p := range state.Params {
+					signs[i] = float64(2*rng.Intn(2) - 1)
+					c, _ := p.gains(k, n)
+					plus = append(plus, EngineOption{p.Name, p.option(p.Value + c*signs[i])})
+					minus = append(minus, EngineOption{p.Name, p.option(p.Value - c*signs[i])})
+				}
+				mu.Unlock()
+				score, err := pair.play(k, fens[(k-1)%len(fens)], plus, minus, cfg)
+				mu.Lock()
+				if err != nil {
+					fail(err)
+					mu.Unlock()
+					return
+				}
+				for i := range state.Params {
+					p := &state.Params[i]
+					c, a := p.gains(k, n)
+					p.Value = p.clamp(p.Value + a/c*float64(score)*signs[i])
+				}
+				state.Iteration++
+				if err := state.save(); err != nil {
+					fail(err)
+				}
+				done := state.Iteration
+				var values []string
+				for _, p := range state.Params {
+					values = append(values, fmt.Sprintf("%s=%.2f", p.Name, p.Value))
+				}
+				mu.Unlock()
+				perPair := time.Since(start) / time.Duration(done-first)
+				fmt.Printf("Iteration %d/%d: %+d  %s  (%v a pair)\n", done, n, score, strings.Join(values, " "), perPair.Round(time.Millisecond))
+			}
+		}()
+	}
+feed:
+	for k := state.Iteration + 1; k <= state.Iterations; k++ {
+		select {
+		case iters <- k:
+		case <-stop:
+			break feed
+		}
+	}
+	close(iters)
+	wg.Wait()
+	if runErr != nil {
+		return runErr
+	}
+
+	fmt.Printf("\nTuned values after %d iterations, saved to %s:\n", state.Iteration, *out)
+	for _, p := range state.Params {
+		fmt.Printf("  -e1opt %s=%s\n", p.Name, p.option(p.Value))
+	}
+	return nil
+}
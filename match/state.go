@@ -0,0 +1,107 @@
+package match
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+
+	"github.com/notnil/chess"
+)
+
+// MatchState records the finished games of a run in a file, so that a run
+// that was killed can be resumed without replaying them. A game is known
+// by its number within its match, which also fixes its colors and opening,
+// so the resumed run plays the remaining games as the first run would have.
+// Its methods may be called on a nil MatchState, which records nothing.
+type MatchState struct {
+	path    string
+	Matches []stateMatch `json:"matches"`
+}
+
+// stateMatch holds the finished games between two engines, named by path.
+type stateMatch struct {
+	Engines [2]string   `json:"engines"`
+	Games   []stateGame `json:"games"`
+}
+
+type stateGame struct {
+	Number      int           `json:"number"`
+	Outcome     chess.Outcome `json:"outcome"`
+	Termination string        `json:"termination"`
+}
+
+// NewMatchState returns an empty state that is saved to path.
+func NewMatchState(path string) *MatchState {
+	return &MatchState{path: path}
+}
+
+// LoadMatchState reads the state saved to path, or returns an empty one if
+// there is no such file yet.
+func LoadMatchState(path string) (*MatchState, error) {
+	s := NewMatchState(path)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// games returns the finished games of the match between two engines.
+func (s *MatchState) games(path1, path2 string) []stateGame {
+	if s == nil {
+		return nil
+	}
+	if m := s.match(path1, path2); m != nil {
+		return m.Games
+	}
+	return nil
+}
+
+func (s *MatchState) match(path1, path2 string) *stateMatch {
+	for i := range s.Matches {
+		if s.Matches[i].Engines == [2]string{path1, path2} {
+			return &s.Matches[i]
+		}
+	}
+	return nil
+}
+
+// record adds a finished game of the match between two engines and saves
+// the state.
+func (s *MatchState) record(path1, path2 string, g playedGame) error {
+	if s == nil {
+		return nil
+	}
+	m := s.match(path1, path2)
+	if m == nil {
+		s.Matches = append(s.Matches, stateMatch{Engines: [2]string{path1, path2}})
+		m = &s.Matches[len(s.Matches)-1]
+	}
+	m.Games = append(m.Games, stateGame{
+		Number:      g.number,
+		Outcome:     g.result.Outcome,
+		Termination: g.result.Termination.String(),
+	})
+	return s.save()
+}
+
+// save writes the state through a temporary file, so that a run killed
+// while saving leaves the previous state intact.
+func (s *MatchState) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,147 @@
+package match
+
+import (
+	"fmt"
+	"math"
+
+	"chessTomorrow/ratings"
+	"github.com/notnil/chess"
+)
+
+// Tally counts one engine's results in a match, by the color it played.
+type Tally struct {
+	Wins, Draws, Losses [3]int // indexed by chess.Color
+}
+
+// Add counts a game the engine played as color.
+func (t *Tally) Add(color chess.Color, outcome chess.Outcome) {
+	switch winnerOf(outcome) {
+	case color:
+		t.Wins[color]++
+	case chess.NoColor:
+		t.Draws[color]++
+	default:
+		t.Losses[color]++
+	}
+}
+
+// Total returns the results over both colors.
+func (t *Tally) Total() (wins, draws, losses int) {
+	for _, c := range []chess.Color{chess.White, chess.Black} {
+		wins += t.Wins[c]
+		draws += t.Draws[c]
+		losses += t.Losses[c]
+	}
+	return wins, draws, losses
+}
+
+// String renders the tally as "+W =D -L", then by color.
+func (t *Tally) String() string {
+	w, d, l := t.Total()
+	return fmt.Sprintf("+%d =%d -%d (White +%d =%d -%d, Black +%d =%d -%d)", w, d, l,
+		t.Wins[chess.White], t.Draws[chess.White], t.Losses[chess.White],
+		t.Wins[chess.Black], t.Draws[chess.Black], t.Losses[chess.Black])
+}
+
+// scoreVariance returns the mean score per game and its variance, with
+// prior games of each result added to those played. Without a prior, at
+// least one game must have been played.
+func (t *Tally) scoreVariance(prior float64) (score, variance float64) {
+	wins, draws, losses := t.Total()
+	w, d, l := float64(wins)+prior, float64(draws)+prior, float64(losses)+prior
+	n := w + d + l
+	score = (w + d/2) / n
+	variance = (w*math.Pow(1-score, 2) + d*math.Pow(0.5-score, 2) + l*math.Pow(score, 2)) / n
+	return score, variance
+}
+
+// Elo estimates the engine's Elo difference to its opponent from the score
+// and the margin of the 95% confidence interval around it, using the
+// per-game variance of the results. A perfect or zero score gives an
+// infinite estimate.
+func (t *Tally) Elo() (elo, margin float64) {
+	w, d, l := t.Total()
+	n := float64(w + d + l)
+	if n == 0 {
+		return 0, math.Inf(1)
+	}
+	score, variance := t.scoreVariance(0)
+	delta := ratings.Z95 * math.Sqrt(variance/n)
+	lo, hi := eloFromScore(score-delta), eloFromScore(score+delta)
+	return eloFromScore(score), (hi - lo) / 2
+}
+
+// eloFromScore is the Elo difference at which the expected score is score.
+func eloFromScore(score float64) float64 {
+	return 400 * math.Log10(score/(1-score))
+}
+
+// LOS is the likelihood of superiority: the probability that the engine
+// is stronger than its opponent, given its wins and losses. Draws say
+// nothing about it.
+func (t *Tally) LOS() float64 {
+	w, _, l := t.Total()
+	if w+l == 0 {
+		return 0.5
+	}
+	return 0.5 + 0.5*math.Erf(float64(w-l)/math.Sqrt(2*float64(w+l)))
+}
+
+// formatElo renders an Elo estimate as "+35.2 +/- 60.1". The margin is
+// unbounded when the interval reaches a perfect or zero score.
+func formatElo(elo, margin float64) string {
+	switch {
+	case math.IsInf(elo, 0):
+		return fmt.Sprintf("%+.0f", elo)
+	case math.IsNaN(margin) || math.IsInf(margin, 0):
+		return fmt.Sprintf("%+.1f +/- inf", elo)
+	}
+	return fmt.Sprintf("%+.1f +/- %.1f", elo, margin)
+}
+
+// SPRT is a sequential probability ratio test of H0, engine 1 is Elo0
+// stronger than engine 2, against H1, it is Elo1 stronger. Alpha and Beta
+// are the acceptable chances of accepting H1 when H0 holds and H0 when H1
+// holds.
+type SPRT struct {
+	Elo0, Elo1  float64
+	Alpha, Beta float64
+}
+
+// Bounds returns the log-likelihood ratios at which H0 (lower) and H1
+// (upper) are accepted.
+func (s *SPRT) Bounds() (lower, upper float64) {
+	return math.Log(s.Beta / (1 - s.Alpha)), math.Log((1 - s.Beta) / s.Alpha)
+}
+
+// LLR is the log-likelihood ratio of H1 to H0 given engine 1's tally, in
+// the normal approximation of the per-game score. Half a game of each
+// result is added to the tally so that a one-sided match, which has no
+// variance, still reaches a decision.
+func (s *SPRT) LLR(t *Tally) float64 {
+	w, d, l := t.Total()
+	n := float64(w + d + l)
+	if n == 0 {
+		return 0
+	}
+	score, variance := t.scoreVariance(0.5)
+	s0, s1 := scoreFromElo(s.Elo0), scoreFromElo(s.Elo1)
+	return n * (s1 - s0) * (2*score - s0 - s1) / (2 * variance)
+}
+
+// Decision returns "H0" or "H1" once llr crosses a bound, and "" before.
+func (s *SPRT) Decision(llr float64) string {
+	lower, upper := s.Bounds()
+	switch {
+	case llr <= lower:
+		return "H0"
+	case llr >= upper:
+		return "H1"
+	}
+	return ""
+}
+
+// scoreFromElo is the expected score at an Elo difference.
+func scoreFromElo(elo float64) float64 {
+	return 1 / (1 + math.Pow(10, -elo/400))
+}
@@ -0,0 +1,123 @@
+package match
+
+import (
+	"math"
+	"testing"
+
+	"github.com/notnil/chess"
+)
+
+// tally returns a Tally of wins, draws and losses, all with White.
+func tally(w, d, l int) *Tally {
+	var t Tally
+	t.Wins[chess.White], t.Draws[chess.White], t.Losses[chess.White] = w, d, l
+	return &t
+}
+
+func near(got, want, tolerance float64) bool {
+	return math.Abs(got-want) <= tolerance
+}
+
+// TestElo checks Elo and LOS against what cutechess-cli prints for the
+// same results, as "Elo difference: 34.9 +/- 48.5, LOS: 92.1 %".
+func TestElo(t *testing.T) {
+	tests := []struct {
+		w, d, l     int
+		elo, margin float64
+		los         float64
+	}{
+		{30, 50, 20, 34.86, 48.47, 0.9214},
+		{100, 200, 100, 0, 24.11, 0.5},
+		{10, 0, 5, 120.41, 221.17, 0.9016},
+		{20, 50, 30, -34.86, 48.47, 0.0786},
+	}
+	for _, tt := range tests {
+		tl := tally(tt.w, tt.d, tt.l)
+		elo, margin := tl.Elo()
+		if !near(elo, tt.elo, 0.01) || !near(margin, tt.margin, 0.01) {
+			t.Errorf("+%d =%d -%d: Elo %.2f +/- %.2f, want %.2f +/- %.2f", tt.w, tt.d, tt.l, elo, margin, tt.elo, tt.margin)
+		}
+		if los := tl.LOS(); !near(los, tt.los, 0.0001) {
+			t.Errorf("+%d =%d -%d: LOS %.4f, want %.4f", tt.w, tt.d, tt.l, los, tt.los)
+		}
+	}
+
+	if _, margin := tally(0, 0, 0).Elo(); !math.IsInf(margin, 1) {
+		t.Errorf("no games: margin %v, want +Inf", margin)
+	}
+	if elo, _ := tally(5, 0, 0).Elo(); !math.IsInf(elo, 1) {
+		t.Errorf("all won: Elo %v, want +Inf", elo)
+	}
+	if los := tally(0, 7, 0).LOS(); los != 0.5 {
+		t.Errorf("all drawn: LOS %v, want 0.5", los)
+	}
+}
+
+func TestFormatElo(t *testing.T) {
+	tests := []struct {
+		elo, margin float64
+		want        string
+	}{
+		{34.86, 48.47, "+34.9 +/- 48.5"},
+		{-12, math.Inf(1), "-12.0 +/- inf"},
+		{math.Inf(1), math.NaN(), "+Inf"},
+	}
+	for _, tt := range tests {
+		if got := formatElo(tt.elo, tt.margin); got != tt.want {
+			t.Errorf("formatElo(%v, %v) = %q, want %q", tt.elo, tt.margin, got, tt.want)
+		}
+	}
+}
+
+// TestSPRT checks the bounds fishtest shows for alpha = beta = 0.05, and
+// LLRs from its normal approximation of the per-game score for elo0 0,
+// elo1 5.
+func TestSPRT(t *testing.T) {
+	s := &SPRT{Elo0: 0, Elo1: 5, Alpha: 0.05, Beta: 0.05}
+	lower, upper := s.Bounds()
+	if !near(lower, -2.944, 0.001) || !near(upper, 2.944, 0.001) {
+		t.Errorf("bounds [%.3f, %.3f], want [-2.944, 2.944]", lower, upper)
+	}
+
+	tests := []struct {
+		w, d, l  int
+		llr      float64
+		decision string
+	}{
+		{0, 0, 0, 0, ""},
+		{1000, 2000, 950, 0.6286, ""},
+		{500, 1000, 520, -0.9837, ""},
+		{3000, 6000, 2900, 0.4170, ""},
+		{3200, 6000, 2900, 6.0843, "H1"},
+		{2900, 6000, 3200, -11.0604, "H0"},
+		{40, 0, 0, 9.3436, "H1"}, // one-sided, yet decided
+	}
+	for _, tt := range tests {
+		llr := s.LLR(tally(tt.w, tt.d, tt.l))
+		if !near(llr, tt.llr, 0.0001) {
+			t.Errorf("+%d =%d -%d: LLR %.4f, want %.4f", tt.w, tt.d, tt.l, llr, tt.llr)
+		}
+		if got := s.Decision(llr); got != tt.decision {
+			t.Errorf("+%d =%d -%d: decision %q, want %q", tt.w, tt.d, tt.l, got, tt.decision)
+		}
+	}
+}
+
+func TestTally(t *testing.T) {
+	var tl Tally
+	tl.Add(chess.White, chess.WhiteWon)
+	tl.Add(chess.Black, chess.WhiteWon)
+	tl.Add(chess.Black, chess.Draw)
+	tl.Add(chess.Black, chess.BlackWon)
+	if w, d, l := tl.Total(); w != 2 || d != 1 || l != 1 {
+		t.Errorf("total +%d =%d -%d, want +2 =1 -1", w, d, l)
+	}
+	if got, want := tl.String(), "+2 =1 -1 (White +1 =0 -0, Black +1 =1 -1)"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	for _, elo := range []float64{-300, -5, 0, 5, 300} {
+		if got := eloFromScore(scoreFromElo(elo)); !near(got, elo, 1e-9) {
+			t.Errorf("eloFromScore(scoreFromElo(%v)) = %v", elo, got)
+		}
+	}
+}
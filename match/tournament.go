@@ -0,0 +1,96 @@
+package match
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"chessTomorrow/ratings"
+)
+
+// Tournament plays a match of cfg.Games games for every pairing of the
+// engines: each against each other in a round-robin, or the first against
+// each of the others in a gauntlet. options are the UCI options of each
+// engine, by index. It prints every match as it ends, then a crosstable
+// ordered by the engines' maximum-likelihood ratings.
+func Tournament(paths []string, gauntlet bool, options [][]EngineOption, cfg MatchConfig) error {
+	cfg.SPRT = nil
+	n := len(paths)
+	t := ratings.NewCrosstable(n)
+
+	for i := range n {
+		for j := i + 1; j < n; j++ {
+			if gauntlet && i > 0 {
+				break
+			}
+			pairCfg := cfg
+			pairCfg.EngineOptions = [2][]EngineOption{optionsOf(options, i), optionsOf(options, j)}
+			m, err := playMatch(paths[i], paths[j], pairCfg)
+			if err != nil {
+				return err
+			}
+			m.print(pairCfg)
+
+			t.Names[i], t.Names[j] = m.names[0], m.names[1]
+			w, d, l := m.tallies[0].Total()
+			t.Add(i, j, float64(w)+float64(d)/2, w+d+l)
+		}
+	}
+	printCrosstable(t)
+	return nil
+}
+
+func optionsOf(options [][]EngineOption, i int) []EngineOption {
+	if i < len(options) {
+		return options[i]
+	}
+	return nil
+}
+
+// printCrosstable prints the results of a tournament ordered by rating,
+// with the margin of error of each rating, then every pairing's score.
+func printCrosstable(t *ratings.Crosstable) {
+	rated := t.Fit()
+	order := make([]int, len(rated))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		switch {
+		case rated[a].Elo > rated[b].Elo:
+			return -1
+		case rated[a].Elo < rated[b].Elo:
+			return 1
+		}
+		return 0
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n%-4s %-28s %7s %6s %9s", "Rank", "Engine", "Elo", "+/-", "Points")
+	for k := range order {
+		fmt.Fprintf(&b, " %7d", k+1)
+	}
+	b.WriteString("\n")
+	for rank, i := range order {
+		r := rated[i]
+		margin := "inf"
+		if !math.IsInf(r.Error, 0) {
+			margin = fmt.Sprintf("%.0f", r.Error)
+		}
+		name := fmt.Sprintf("%d %s", i+1, t.Names[i])
+		fmt.Fprintf(&b, "%-4d %-28.28s %+7.0f %6s %9s", rank+1, name, r.Elo, margin, fmt.Sprintf("%g/%d", r.Points, r.Games))
+		for _, j := range order {
+			switch {
+			case i == j:
+				fmt.Fprintf(&b, " %7s", "---")
+			case t.Games[i][j] == 0:
+				fmt.Fprintf(&b, " %7s", "")
+			default:
+				fmt.Fprintf(&b, " %7s", fmt.Sprintf("%g/%d", t.Points[i][j], t.Games[i][j]))
+			}
+		}
+		b.WriteString("\n")
+	}
+	fmt.Print(b.String())
+}
@@ -0,0 +1,48 @@
+package ratings
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// RatingsMain rates the players of the PGN files given, printing a table
+// of their ratings and errors.
+func RatingsMain(args []string) error {
+	fs := flag.NewFlagSet("ratings", flag.ExitOnError)
+	average := fs.Float64("average", 0, "rating the players average")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ratings [flags] <file.pgn>...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	t := NewCrosstable(0)
+	games := 0
+	for _, path := range fs.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		n, err := t.ReadPGN(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		games += n
+	}
+	if games == 0 {
+		return fmt.Errorf("no finished games in %d files", fs.NArg())
+	}
+
+	ratings := t.Fit()
+	for i := range ratings {
+		ratings[i].Elo += *average
+	}
+	fmt.Printf("%d games, %d players\n\n", games, len(ratings))
+	return Write(os.Stdout, ratings)
+}
@@ -0,0 +1,108 @@
+package ratings
+
+import "math"
+
+// Glicko-2 constants: the scale between Glicko and Glicko-2 ratings, the
+// system constant that bounds how fast volatility changes, and the
+// tolerance of the volatility iteration.
+const (
+	glickoScale = 173.7178
+	glickoTau   = 0.5
+	glickoEps   = 0.000001
+)
+
+// Glicko is a Glicko-2 rating: the rating, its deviation (how unsure it
+// is) and the volatility (how erratic the player's results are).
+type Glicko struct {
+	Rating     float64 `json:"rating"`
+	RD         float64 `json:"rd"`
+	Volatility float64 `json:"volatility"`
+}
+
+// NewGlicko returns the rating of a player with no games.
+func NewGlicko() Glicko {
+	return Glicko{Rating: 1500, RD: 350, Volatility: 0.06}
+}
+
+// GlickoGame is one game of a rating period: the opponent's rating before
+// it and the score, 1 for a win, 0.5 for a draw, 0 for a loss.
+type GlickoGame struct {
+	Opponent Glicko
+	Score    float64
+}
+
+// Update returns the rating after a game against opp, taken as a rating
+// period of its own, that scored score. Both players' ratings should be
+// updated from their ratings before the game.
+func (g Glicko) Update(opp Glicko, score float64) Glicko {
+	return g.Rate(GlickoGame{Opponent: opp, Score: score})
+}
+
+// Rate returns the rating after a rating period with games, following
+// Glickman's description of Glicko-2. A period without games only widens
+// the deviation.
+func (g Glicko) Rate(games ...GlickoGame) Glicko {
+	mu, phi := (g.Rating-1500)/glickoScale, g.RD/glickoScale
+	if len(games) == 0 {
+		g.RD = glickoScale * math.Sqrt(phi*phi+g.Volatility*g.Volatility)
+		return g
+	}
+
+	var vInv, sum float64
+	for _, game := range games {
+		muJ, phiJ := (game.Opponent.Rating-1500)/glickoScale, game.Opponent.RD/glickoScale
+		gJ := 1 / math.Sqrt(1+3*phiJ*phiJ/(math.Pi*math.Pi))
+		e := 1 / (1 + math.Exp(-gJ*(mu-muJ)))
+		vInv += gJ * gJ * e * (1 - e)
+		sum += gJ * (game.Score - e)
+	}
+	v := 1 / vInv
+	delta := v * sum
+
+	sigma := newVolatility(phi, v, delta, g.Volatility)
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phiNew := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	muNew := mu + phiNew*phiNew*sum
+
+	return Glicko{
+		Rating:     glickoScale*muNew + 1500,
+		RD:         glickoScale * phiNew,
+		Volatility: sigma,
+	}
+}
+
+// newVolatility finds the new volatility by the Illinois algorithm, as in
+// step 5 of Glickman's description of Glicko-2.
+func newVolatility(phi, v, delta, sigma float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-d)/(2*d*d) - (x-a)/(glickoTau*glickoTau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*glickoTau) < 0 {
+			k++
+		}
+		B = a - k*glickoTau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > glickoEps {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
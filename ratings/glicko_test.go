@@ -0,0 +1,42 @@
+package ratings
+
+import (
+	"math"
+	"testing"
+)
+
+// TestGlickoExample checks Rate against the worked example in Glickman's
+// "Example of the Glicko-2 system".
+func TestGlickoExample(t *testing.T) {
+	player := Glicko{Rating: 1500, RD: 200, Volatility: 0.06}
+	got := player.Rate(
+		GlickoGame{Opponent: Glicko{Rating: 1400, RD: 30, Volatility: 0.06}, Score: 1},
+		GlickoGame{Opponent: Glicko{Rating: 1550, RD: 100, Volatility: 0.06}, Score: 0},
+		GlickoGame{Opponent: Glicko{Rating: 1700, RD: 300, Volatility: 0.06}, Score: 0},
+	)
+	if math.Abs(got.Rating-1464.06) > 0.01 || math.Abs(got.RD-151.52) > 0.01 || math.Abs(got.Volatility-0.05999) > 0.00001 {
+		t.Errorf("Rate = %+v, want 1464.06, 151.52, 0.05999", got)
+	}
+}
+
+func TestGlickoUpdate(t *testing.T) {
+	a, b := NewGlicko(), NewGlicko()
+	a2, b2 := a.Update(b, 1), b.Update(a, 0)
+	if a2.Rating <= a.Rating || b2.Rating >= b.Rating {
+		t.Errorf("winner %.1f, loser %.1f from 1500 each", a2.Rating, b2.Rating)
+	}
+	if math.Abs(a2.Rating-1500-(1500-b2.Rating)) > 1e-9 {
+		t.Errorf("winner gained %.4f, loser lost %.4f", a2.Rating-1500, 1500-b2.Rating)
+	}
+	if a2.RD >= a.RD {
+		t.Errorf("RD %.1f after a game, want less than %.1f", a2.RD, a.RD)
+	}
+	if d := a.Update(b, 0.5); math.Abs(d.Rating-1500) > 1e-9 {
+		t.Errorf("draw between equals moved the rating to %.4f", d.Rating)
+	}
+
+	idle := Glicko{Rating: 1500, RD: 50, Volatility: 0.06}.Rate()
+	if want := math.Hypot(50/glickoScale, 0.06) * glickoScale; math.Abs(idle.RD-want) > 1e-9 || idle.Rating != 1500 {
+		t.Errorf("period without games: %+v, want RD %.4f", idle, want)
+	}
+}
@@ -0,0 +1,84 @@
+package ratings
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadPGN adds the games of a PGN collection to t, from their White,
+// Black and Result tags alone, so that full games, cutechess-cli's output
+// and the match runner's -eventlog read alike. Games without a result are
+// skipped. It returns the number of games added.
+func (t *Crosstable) ReadPGN(r io.Reader) (int, error) {
+	var white, black, result string
+	games := 0
+	inTags := false
+	commit := func() {
+		if white != "" && black != "" && white != black {
+			score := -1.0
+			switch result {
+			case "1-0":
+				score = 1
+			case "0-1":
+				score = 0
+			case "1/2-1/2":
+				score = 0.5
+			}
+			if score >= 0 {
+				t.Add(t.player(white), t.player(black), score, 1)
+				games++
+			}
+		}
+		white, black, result = "", "", ""
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		// Comments in movetext can hold commands such as [%clk 0:01:00]
+		if !strings.HasPrefix(text, "[") || strings.HasPrefix(text, "[%") || !strings.HasSuffix(text, "]") {
+			if text != "" {
+				inTags = false
+			}
+			continue
+		}
+		// A tag after movetext starts the next game
+		if !inTags {
+			commit()
+			inTags = true
+		}
+		key, value, err := parseTag(text)
+		if err != nil {
+			return games, fmt.Errorf("line %d: %w", line, err)
+		}
+		switch key {
+		case "White":
+			white = value
+		case "Black":
+			black = value
+		case "Result":
+			result = value
+		}
+	}
+	commit()
+	return games, scanner.Err()
+}
+
+// parseTag splits a tag pair such as [White "Stockfish"].
+func parseTag(text string) (key, value string, err error) {
+	inner := strings.TrimSuffix(strings.TrimPrefix(text, "["), "]")
+	key, quoted, ok := strings.Cut(inner, " ")
+	if !ok {
+		return "", "", fmt.Errorf("malformed tag %s", text)
+	}
+	// PGN escapes quotes and backslashes as Go does
+	value, err = strconv.Unquote(strings.TrimSpace(quoted))
+	if err != nil {
+		return "", "", fmt.Errorf("malformed tag %s", text)
+	}
+	return key, value, nil
+}
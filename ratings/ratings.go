@@ -0,0 +1,249 @@
+// Package ratings fits Elo ratings to the results of games between many
+// players at once, as Ordo and BayesElo do: the ratings under which the
+// results are most likely, with the margin of error of each. It also keeps
+// Glicko-2 ratings, updated game by game, for the web arbiter's players.
+package ratings
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+)
+
+// Z95 is the normal quantile of a two-sided 95% interval.
+const Z95 = 1.959964
+
+// eloPerNat converts a difference in natural-log strength to Elo.
+const eloPerNat = 400 / math.Ln10
+
+// fitIterations bounds the minorization-maximization steps of Fit, which
+// converge long before for any real tournament.
+const fitIterations = 1000
+
+// Crosstable holds the points and games of every player against every
+// other, indexed [player][opponent].
+type Crosstable struct {
+	Names  []string
+	Points [][]float64
+	Games  [][]int
+}
+
+// NewCrosstable returns an empty crosstable of n players, to be named.
+func NewCrosstable(n int) *Crosstable {
+	t := &Crosstable{
+		Names:  make([]string, n),
+		Points: make([][]float64, n),
+		Games:  make([][]int, n),
+	}
+	for i := range n {
+		t.Points[i] = make([]float64, n)
+		t.Games[i] = make([]int, n)
+	}
+	return t
+}
+
+// Add counts games between players i and j in which i scored points.
+func (t *Crosstable) Add(i, j int, points float64, games int) {
+	t.Points[i][j] += points
+	t.Games[i][j] += games
+	t.Points[j][i] += float64(games) - points
+	t.Games[j][i] += games
+}
+
+// player returns the index of the named player, adding them if needed.
+func (t *Crosstable) player(name string) int {
+	if i := slices.Index(t.Names, name); i >= 0 {
+		return i
+	}
+	t.Names = append(t.Names, name)
+	for i := range t.Points {
+		t.Points[i] = append(t.Points[i], 0)
+		t.Games[i] = append(t.Games[i], 0)
+	}
+	n := len(t.Names)
+	t.Points = append(t.Points, make([]float64, n))
+	t.Games = append(t.Games, make([]int, n))
+	return n - 1
+}
+
+// Rating is one player's fitted rating.
+type Rating struct {
+	Name   string
+	Elo    float64 // relative to the average of all players
+	Error  float64 // margin of the 95% confidence interval; +Inf if unknown
+	Points float64
+	Games  int
+}
+
+// Fit returns the maximum-likelihood ratings of the players, in their
+// order in t, averaging zero. The expected score of a player against
+// another is 1/(1+10^(-d/400)) at an Elo difference d, a draw counting as
+// half a win (the Bradley-Terry model); the ratings are fitted to all
+// results at once by minorization-maximization, so a player is rated by
+// whom they scored against as well as how much. Every pairing counts one
+// extra draw, which keeps perfect scores finite.
+//
+// The errors come from the curvature of the likelihood at its maximum.
+// Players who can't be compared, because no chain of games joins them,
+// have an infinite error.
+func (t *Crosstable) Fit() []Rating {
+	n := len(t.Names)
+	gamma := make([]float64, n)
+	for i := range gamma {
+		gamma[i] = 1
+	}
+	for iter := 0; iter < fitIterations; iter++ {
+		for i := range n {
+			num, den := 0.0, 0.0
+			for j := range n {
+				if t.Games[i][j] == 0 {
+					continue
+				}
+				num += t.Points[i][j] + 0.5
+				den += float64(t.Games[i][j]+1) / (gamma[i] + gamma[j])
+			}
+			if den > 0 {
+				gamma[i] = num / den
+			}
+		}
+	}
+
+	ratings := make([]Rating, n)
+	mean := 0.0
+	for i, g := range gamma {
+		ratings[i] = Rating{Name: t.Names[i], Elo: eloPerNat * math.Log(g)}
+		mean += ratings[i].Elo / float64(n)
+		for j := range n {
+			ratings[i].Points += t.Points[i][j]
+			ratings[i].Games += t.Games[i][j]
+		}
+	}
+	covariance := t.covariance(gamma)
+	for i := range ratings {
+		ratings[i].Elo -= mean
+		ratings[i].Error = math.Inf(1)
+		if covariance != nil && covariance[i][i] >= 0 {
+			ratings[i].Error = Z95 * eloPerNat * math.Sqrt(covariance[i][i])
+		}
+	}
+	return ratings
+}
+
+// covariance returns the covariance of the log-strengths about their
+// mean: the pseudo-inverse of the Fisher information of the likelihood,
+// which is a graph Laplacian and so singular along the all-ones vector.
+// It returns nil if the players fall into groups with no games between
+// them.
+func (t *Crosstable) covariance(gamma []float64) [][]float64 {
+	n := len(gamma)
+	if n == 0 {
+		return nil
+	}
+	// Adding J/n makes the Laplacian of a connected graph invertible, and
+	// the inverse less J/n is its pseudo-inverse
+	info := make([][]float64, n)
+	for i := range info {
+		info[i] = make([]float64, n)
+		for j := range info[i] {
+			info[i][j] = 1 / float64(n)
+		}
+	}
+	for i := range n {
+		for j := range n {
+			if i == j || t.Games[i][j] == 0 {
+				continue
+			}
+			p := gamma[i] / (gamma[i] + gamma[j])
+			w := float64(t.Games[i][j]+1) * p * (1 - p)
+			info[i][i] += w
+			info[i][j] -= w
+		}
+	}
+	inv := invert(info)
+	if inv == nil {
+		return nil
+	}
+	for i := range inv {
+		for j := range inv[i] {
+			inv[i][j] -= 1 / float64(n)
+		}
+	}
+	return inv
+}
+
+// invert returns the inverse of the square matrix m by Gauss-Jordan
+// elimination, or nil if it is singular. m is overwritten.
+func invert(m [][]float64) [][]float64 {
+	n := len(m)
+	inv := make([][]float64, n)
+	for i := range inv {
+		inv[i] = make([]float64, n)
+		inv[i][i] = 1
+	}
+	for col := range n {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-12 {
+			return nil
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		inv[col], inv[pivot] = inv[pivot], inv[col]
+		scale := 1 / m[col][col]
+		for k := range n {
+			m[col][k] *= scale
+			inv[col][k] *= scale
+		}
+		for row := range n {
+			if row == col || m[row][col] == 0 {
+				continue
+			}
+			f := m[row][col]
+			for k := range n {
+				m[row][k] -= f * m[col][k]
+				inv[row][k] -= f * inv[col][k]
+			}
+		}
+	}
+	return inv
+}
+
+// Ranked returns the ratings from highest to lowest.
+func Ranked(ratings []Rating) []Rating {
+	ranked := slices.Clone(ratings)
+	slices.SortStableFunc(ranked, func(a, b Rating) int {
+		switch {
+		case a.Elo > b.Elo:
+			return -1
+		case a.Elo < b.Elo:
+			return 1
+		}
+		return 0
+	})
+	return ranked
+}
+
+// Write prints the ratings as a table, highest first, as Ordo does.
+func Write(w io.Writer, ratings []Rating) error {
+	if _, err := fmt.Fprintf(w, "%4s %-28s %7s %7s %8s %6s %6s\n", "#", "Player", "Elo", "Error", "Points", "Games", "(%)"); err != nil {
+		return err
+	}
+	for rank, r := range Ranked(ratings) {
+		errText := "inf"
+		if !math.IsInf(r.Error, 0) {
+			errText = fmt.Sprintf("%.1f", r.Error)
+		}
+		percent := 0.0
+		if r.Games > 0 {
+			percent = 100 * r.Points / float64(r.Games)
+		}
+		if _, err := fmt.Fprintf(w, "%4d %-28.28s %+7.1f %7s %8g %6d %6.1f\n", rank+1, r.Name, r.Elo, errText, r.Points, r.Games, percent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
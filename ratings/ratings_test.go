@@ -0,0 +1,69 @@
+package ratings
+
+import (
+	"math"
+	"testing"
+)
+
+// TestFitTwoPlayers checks Fit against the closed form for two players:
+// with the extra draw, A's expected score is (points+0.5)/(games+1), and
+// the error comes from the one game weight w = (games+1)p(1-p), each
+// player's log-strength varying by 1/(4w) about the mean.
+func TestFitTwoPlayers(t *testing.T) {
+	tests := []struct {
+		points   float64
+		games    int
+		elo, err float64 // A's
+	}{
+		{7.5, 10, 85.19, 115.25},
+		{5, 10, 0, 102.66},
+		{5, 5, 208.28, 251.46},  // all wins
+		{0, 5, -208.28, 251.46}, // all losses
+	}
+	for _, tt := range tests {
+		c := NewCrosstable(2)
+		c.Names[0], c.Names[1] = "A", "B"
+		c.Add(0, 1, tt.points, tt.games)
+		r := c.Fit()
+		if math.Abs(r[0].Elo-tt.elo) > 0.01 || math.Abs(r[1].Elo+tt.elo) > 0.01 {
+			t.Errorf("%v/%d: Elo %.2f and %.2f, want %.2f and %.2f", tt.points, tt.games, r[0].Elo, r[1].Elo, tt.elo, -tt.elo)
+		}
+		if math.Abs(r[0].Error-tt.err) > 0.01 || math.Abs(r[1].Error-tt.err) > 0.01 {
+			t.Errorf("%v/%d: errors %.2f and %.2f, want %.2f", tt.points, tt.games, r[0].Error, r[1].Error, tt.err)
+		}
+		if r[0].Points != tt.points || r[0].Games != tt.games || r[1].Points != float64(tt.games)-tt.points {
+			t.Errorf("%v/%d: A has %v/%d, B %v/%d", tt.points, tt.games, r[0].Points, r[0].Games, r[1].Points, r[1].Games)
+		}
+	}
+}
+
+// TestFitChain checks a chain A-B-C with no game between A and C: A and
+// C are rated through B, 400·log10(7/3) either side of it, and the errors
+// are those of a path of two equal weights w = 5·0.7·0.3, whose
+// Laplacian's pseudo-inverse has 5/9, 2/9 and 5/9 on its diagonal.
+func TestFitChain(t *testing.T) {
+	c := NewCrosstable(3)
+	c.Names = []string{"A", "B", "C"}
+	c.Add(0, 1, 3, 4)
+	c.Add(1, 2, 3, 4)
+	want := []struct{ elo, err float64 }{{147.19, 247.66}, {0, 156.64}, {-147.19, 247.66}}
+	for i, r := range c.Fit() {
+		if math.Abs(r.Elo-want[i].elo) > 0.01 || math.Abs(r.Error-want[i].err) > 0.01 {
+			t.Errorf("%s: %.2f ± %.2f, want %.2f ± %.2f", r.Name, r.Elo, r.Error, want[i].elo, want[i].err)
+		}
+	}
+}
+
+// TestFitDisconnected checks that players who can't be compared get an
+// infinite error rather than a made-up one.
+func TestFitDisconnected(t *testing.T) {
+	c := NewCrosstable(4)
+	c.Names = []string{"A", "B", "C", "D"}
+	c.Add(0, 1, 2, 2)
+	c.Add(2, 3, 1, 2)
+	for _, r := range c.Fit() {
+		if !math.IsInf(r.Error, 1) {
+			t.Errorf("%s: error %.2f between groups that never met, want +Inf", r.Name, r.Error)
+		}
+	}
+}
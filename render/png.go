@@ -0,0 +1,229 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/notnil/chess"
+)
+
+// The standard library has no font rasterizer, so PNG pieces are drawn
+// from these silhouettes, scaled to the square and outlined. Coordinates
+// are not drawn.
+var silhouettes = map[chess.PieceType][12]string{
+	chess.King: {
+		".....##.....",
+		"....####....",
+		".....##.....",
+		"..########..",
+		".##########.",
+		".##########.",
+		"..########..",
+		"...######...",
+		"...######...",
+		"..########..",
+		".##########.",
+		"............",
+	},
+	chess.Queen: {
+		".#...##...#.",
+		".##..##..##.",
+		".###.##.###.",
+		".##########.",
+		"..########..",
+		"..########..",
+		"...######...",
+		"...######...",
+		"..########..",
+		".##########.",
+		".##########.",
+		"............",
+	},
+	chess.Rook: {
+		"............",
+		".##.####.##.",
+		".##.####.##.",
+		".##########.",
+		"..########..",
+		"...######...",
+		"...######...",
+		"...######...",
+		"..########..",
+		".##########.",
+		".##########.",
+		"............",
+	},
+	chess.Bishop: {
+		".....##.....",
+		"....####....",
+		"...###.##...",
+		"...##.###...",
+		"...######...",
+		"....####....",
+		".....##.....",
+		"....####....",
+		"...######...",
+		".##########.",
+		".##########.",
+		"............",
+	},
+	chess.Knight: {
+		"............",
+		"....##......",
+		"...#####....",
+		"..#######...",
+		".####.####..",
+		".###..####..",
+		"......####..",
+		".....#####..",
+		"....######..",
+		"...#######..",
+		"..########..",
+		"............",
+	},
+	chess.Pawn: {
+		"............",
+		"............",
+		".....##.....",
+		"....####....",
+		"....####....",
+		".....##.....",
+		"....####....",
+		"....####....",
+		"...######...",
+		"..########..",
+		"..########..",
+		"............",
+	},
+}
+
+var (
+	white = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	black = color.RGBA{0x11, 0x11, 0x11, 0xff}
+)
+
+// Image draws b.
+func Image(b *chess.Board, opts Options) *image.RGBA {
+	size, theme := opts.squareSize(), opts.theme()
+	img := image.NewRGBA(image.Rect(0, 0, 8*size, 8*size))
+
+	for sq := chess.A1; sq <= chess.H8; sq++ {
+		x, y := opts.origin(sq)
+		fill := theme.Light
+		if (int(sq.File())+int(sq.Rank()))%2 == 0 {
+			fill = theme.Dark
+		}
+		fillRect(img, x, y, size, fill)
+		if opts.highlighted(sq) {
+			fillRect(img, x, y, size, theme.Highlight)
+		}
+		if p := b.Piece(sq); p != chess.NoPiece {
+			drawPiece(img, x, y, size, p)
+		}
+	}
+
+	for _, a := range opts.Arrows {
+		drawArrow(img, opts, a)
+	}
+	return img
+}
+
+// PNG writes b to w as a PNG image.
+func PNG(w io.Writer, b *chess.Board, opts Options) error {
+	return png.Encode(w, Image(b, opts))
+}
+
+func fillRect(img *image.RGBA, x0, y0, size int, c color.RGBA) {
+	for y := y0; y < y0+size; y++ {
+		for x := x0; x < x0+size; x++ {
+			blend(img, x, y, c)
+		}
+	}
+}
+
+// drawPiece scales the piece's silhouette into the square at x0, y0 and
+// outlines it in the opposite color.
+func drawPiece(img *image.RGBA, x0, y0, size int, p chess.Piece) {
+	shape := silhouettes[p.Type()]
+	cell := float64(size) / 14
+	inside := func(x, y int) bool {
+		if x < 0 || y < 0 || x >= size || y >= size {
+			return false
+		}
+		col := int((float64(x) - cell) / cell)
+		row := int((float64(y) - cell) / cell)
+		if float64(x) < cell || float64(y) < cell || col >= 12 || row >= 12 {
+			return false
+		}
+		return shape[row][col] == '#'
+	}
+
+	fill, edge := white, black
+	if p.Color() == chess.Black {
+		fill, edge = black, white
+	}
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			switch {
+			case inside(x, y):
+				img.SetRGBA(x0+x, y0+y, fill)
+			case inside(x-1, y) || inside(x+1, y) || inside(x, y-1) || inside(x, y+1):
+				img.SetRGBA(x0+x, y0+y, edge)
+			}
+		}
+	}
+}
+
+// drawArrow draws a shaft from the center of a.From to just short of the
+// center of a.To, and a triangular head over the rest.
+func drawArrow(img *image.RGBA, opts Options, a Arrow) {
+	size := float64(opts.squareSize())
+	fx, fy := opts.origin(a.From)
+	tx, ty := opts.origin(a.To)
+	x1, y1 := float64(fx)+size/2, float64(fy)+size/2
+	x2, y2 := float64(tx)+size/2, float64(ty)+size/2
+	length := math.Hypot(x2-x1, y2-y1)
+	if length == 0 {
+		return
+	}
+	ux, uy := (x2-x1)/length, (y2-y1)/length
+	width, head := size/6, size/2.5
+	if head > length {
+		head = length
+	}
+	c := opts.arrowColor(a)
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			// Position along and across the arrow, from its tail
+			dx, dy := float64(x)+0.5-x1, float64(y)+0.5-y1
+			along := dx*ux + dy*uy
+			across := math.Abs(dx*uy - dy*ux)
+			if along < 0 || along > length {
+				continue
+			}
+			inShaft := along <= length-head && across <= width/2
+			inHead := along > length-head && across <= (length-along)/head*width*1.5
+			if inShaft || inHead {
+				blend(img, x, y, c)
+			}
+		}
+	}
+}
+
+// blend paints c over the pixel at x, y using c's alpha.
+func blend(img *image.RGBA, x, y int, c color.RGBA) {
+	if c.A == 0xff {
+		img.SetRGBA(x, y, c)
+		return
+	}
+	under := img.RGBAAt(x, y)
+	mix := func(top, bottom uint8) uint8 {
+		return uint8((int(top)*int(c.A) + int(bottom)*(255-int(c.A))) / 255)
+	}
+	img.SetRGBA(x, y, color.RGBA{mix(c.R, under.R), mix(c.G, under.G), mix(c.B, under.B), 0xff})
+}
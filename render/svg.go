@@ -0,0 +1,175 @@
+// Package render draws chess positions as images: SVG diagrams for web
+// pages and PGN viewers, and PNG pictures where SVG won't do. Both take
+// the same Options, with a color theme, orientation, highlighted squares
+// and arrows drawn over the board.
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// Theme colors the board.
+type Theme struct {
+	Light     color.RGBA
+	Dark      color.RGBA
+	Highlight color.RGBA // over the highlighted squares; alpha blends
+	Arrow     color.RGBA // for arrows without a color of their own
+}
+
+// The themes that come with the package.
+var (
+	Brown = Theme{
+		Light:     color.RGBA{0xf0, 0xd9, 0xb5, 0xff},
+		Dark:      color.RGBA{0xb5, 0x88, 0x63, 0xff},
+		Highlight: color.RGBA{0xcd, 0xd2, 0x6a, 0xc0},
+		Arrow:     color.RGBA{0x15, 0x78, 0x1b, 0xc0},
+	}
+	Green = Theme{
+		Light:     color.RGBA{0xee, 0xee, 0xd2, 0xff},
+		Dark:      color.RGBA{0x76, 0x96, 0x56, 0xff},
+		Highlight: color.RGBA{0xf6, 0xf6, 0x69, 0xc0},
+		Arrow:     color.RGBA{0xe0, 0x6c, 0x10, 0xc0},
+	}
+	Blue = Theme{
+		Light:     color.RGBA{0xde, 0xe3, 0xe6, 0xff},
+		Dark:      color.RGBA{0x8c, 0xa2, 0xad, 0xff},
+		Highlight: color.RGBA{0x9b, 0xc7, 0x00, 0xa0},
+		Arrow:     color.RGBA{0x00, 0x30, 0x88, 0xc0},
+	}
+)
+
+// Themes names the themes for command-line flags and URL parameters.
+var Themes = map[string]Theme{"brown": Brown, "green": Green, "blue": Blue}
+
+// Arrow is drawn from the center of one square to the center of another.
+type Arrow struct {
+	From, To chess.Square
+	Color    color.RGBA // the theme's arrow color if zero
+}
+
+// Options control a drawing. The zero value draws a 45-pixel-square board
+// in the Brown theme from White's side.
+type Options struct {
+	SquareSize  int            // pixels per square
+	Theme       *Theme         // Brown if nil
+	Flipped     bool           // Black at the bottom
+	Coordinates bool           // file letters and rank numbers in the edge squares (SVG only)
+	Highlight   []chess.Square // usually the last move's squares
+	Arrows      []Arrow
+}
+
+func (o Options) squareSize() int {
+	if o.SquareSize <= 0 {
+		return 45
+	}
+	return o.SquareSize
+}
+
+func (o Options) theme() Theme {
+	if o.Theme == nil {
+		return Brown
+	}
+	return *o.Theme
+}
+
+// origin returns the top-left pixel of sq.
+func (o Options) origin(sq chess.Square) (x, y int) {
+	file, rank := int(sq.File()), 7-int(sq.Rank())
+	if o.Flipped {
+		file, rank = 7-file, 7-rank
+	}
+	return file * o.squareSize(), rank * o.squareSize()
+}
+
+func (o Options) highlighted(sq chess.Square) bool {
+	for _, h := range o.Highlight {
+		if h == sq {
+			return true
+		}
+	}
+	return false
+}
+
+func (o Options) arrowColor(a Arrow) color.RGBA {
+	if a.Color == (color.RGBA{}) {
+		return o.theme().Arrow
+	}
+	return a.Color
+}
+
+// glyphs are the solid chess symbols; both colors use them, filled in the
+// piece's color.
+var glyphs = map[chess.PieceType]string{
+	chess.King: "♚", chess.Queen: "♛", chess.Rook: "♜",
+	chess.Bishop: "♝", chess.Knight: "♞", chess.Pawn: "♟",
+}
+
+// SVG draws b as a standalone SVG document.
+func SVG(b *chess.Board, opts Options) string {
+	size, theme := opts.squareSize(), opts.theme()
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", 8*size, 8*size, 8*size, 8*size)
+	fmt.Fprintf(&sb, `<defs><marker id="head" viewBox="0 0 10 10" refX="5" refY="5" markerWidth="3" markerHeight="3" orient="auto"><path d="M0,0 L10,5 L0,10 z" fill="context-stroke"/></marker></defs>`+"\n")
+
+	for sq := chess.A1; sq <= chess.H8; sq++ {
+		x, y := opts.origin(sq)
+		fill := theme.Light
+		if (int(sq.File())+int(sq.Rank()))%2 == 0 {
+			fill = theme.Dark
+		}
+		fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n", x, y, size, size, hex(fill))
+		if opts.highlighted(sq) {
+			fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s" fill-opacity="%.2f"/>`+"\n",
+				x, y, size, size, hex(theme.Highlight), float64(theme.Highlight.A)/255)
+		}
+	}
+
+	if opts.Coordinates {
+		font := size / 5
+		for i := 0; i < 8; i++ {
+			fileSq, rankSq := chess.NewSquare(chess.File(i), chess.Rank1), chess.NewSquare(chess.FileA, chess.Rank(i))
+			if opts.Flipped {
+				fileSq, rankSq = chess.NewSquare(chess.File(i), chess.Rank8), chess.NewSquare(chess.FileH, chess.Rank(i))
+			}
+			x, y := opts.origin(fileSq)
+			fmt.Fprintf(&sb, `<text x="%d" y="%d" font-size="%d" font-family="sans-serif" fill="#333" text-anchor="end">%s</text>`+"\n",
+				x+size-2, y+size-2, font, fileSq.File())
+			x, y = opts.origin(rankSq)
+			fmt.Fprintf(&sb, `<text x="%d" y="%d" font-size="%d" font-family="sans-serif" fill="#333">%s</text>`+"\n",
+				x+2, y+font+1, font, rankSq.Rank())
+		}
+	}
+
+	for sq := chess.A1; sq <= chess.H8; sq++ {
+		p := b.Piece(sq)
+		if p == chess.NoPiece {
+			continue
+		}
+		x, y := opts.origin(sq)
+		fill, stroke := "#000", "#fff"
+		if p.Color() == chess.White {
+			fill, stroke = "#fff", "#000"
+		}
+		fmt.Fprintf(&sb, `<text x="%d" y="%d" font-size="%d" text-anchor="middle" dominant-baseline="central" fill="%s" stroke="%s" stroke-width="%.1f">%s</text>`+"\n",
+			x+size/2, y+size/2+size/20, size*4/5, fill, stroke, float64(size)/45, glyphs[p.Type()])
+	}
+
+	for _, a := range opts.Arrows {
+		x1, y1 := opts.origin(a.From)
+		x2, y2 := opts.origin(a.To)
+		c := opts.arrowColor(a)
+		fmt.Fprintf(&sb, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s" stroke-opacity="%.2f" stroke-width="%d" stroke-linecap="round" marker-end="url(#head)"/>`+"\n",
+			x1+size/2, y1+size/2, x2+size/2, y2+size/2, hex(c), float64(c.A)/255, size/6)
+	}
+
+	sb.WriteString("</svg>\n")
+	return sb.String()
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,178 @@
+package tablebase
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+// GenerateMain builds the tables named in args, or all of them, checks
+// each against the move generator of github.com/notnil/chess and writes
+// them to a directory. With -probe it looks a position up in the
+// directory's tables instead.
+func GenerateMain(args []string) error {
+	fs := flag.NewFlagSet("tablebase", flag.ExitOnError)
+	dir := fs.String("dir", "tablebases", "directory of the table files")
+	verify := fs.Int("verify", 10000, "random positions of each new table to check against the move generator; 0 to skip")
+	probe := fs.String("probe", "", "look this FEN up in the tables in -dir instead of generating")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: tablebase [flags] [ending...]\nendings: %s\n", strings.Join(names(Endings), " "))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *probe != "" {
+		return probeMain(*dir, *probe)
+	}
+
+	want := map[string]bool{}
+	for _, name := range fs.Args() {
+		if _, ok := FindEnding(strings.ToUpper(name)); !ok {
+			return fmt.Errorf("no ending %q; there are %s", name, strings.Join(names(Endings), " "))
+		}
+		want[strings.ToUpper(name)] = true
+	}
+	if len(want) == 0 {
+		for _, e := range Endings {
+			want[e.Name] = true
+		}
+	}
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		return err
+	}
+
+	tb := &Tablebase{tables: map[string]*Table{}}
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for _, e := range Endings {
+		path := filepath.Join(*dir, e.Name+fileExt)
+		if !want[e.Name] {
+			// Tables that a wanted table promotes into are loaded, or built
+			// if they aren't there yet
+			if !promotedInto(e, want) {
+				continue
+			}
+			if t, err := load(path, e); err == nil {
+				tb.tables[e.Name] = t
+				continue
+			}
+		}
+
+		start := time.Now()
+		t := generate(e, tb.promotions(e))
+		tb.tables[e.Name] = t
+		positions, wins, longest := t.stats()
+		fmt.Printf("%s: White wins %d of %d positions with White to move, the longest mate in %d (%s)\n",
+			e.Name, wins, positions, longest, time.Since(start).Round(time.Millisecond))
+		if *verify > 0 {
+			if err := tb.Verify(e.Name, *verify, rng); err != nil {
+				return fmt.Errorf("%s does not check out: %w", e.Name, err)
+			}
+			fmt.Printf("%s: %d random positions agree with the move generator\n", e.Name, *verify)
+		}
+		if err := t.save(path); err != nil {
+			return err
+		}
+		if info, err := os.Stat(path); err == nil {
+			fmt.Printf("%s: written to %s (%d KB)\n", e.Name, path, info.Size()/1024)
+		}
+	}
+	return nil
+}
+
+// names lists the endings' names.
+func names(endings []Ending) []string {
+	var list []string
+	for _, e := range endings {
+		list = append(list, e.Name)
+	}
+	return list
+}
+
+// promotedInto reports whether a wanted ending promotes into e.
+func promotedInto(e Ending, want map[string]bool) bool {
+	for _, w := range Endings {
+		if !want[w.Name] {
+			continue
+		}
+		for _, p := range promotionEndings(w) {
+			if p.Name == e.Name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// promotionEndings returns the tables e's pawn promotes into: e with the
+// pawn turned into a queen, rook, bishop or knight, where there is such a
+// table. The others, such as KBK, are draws.
+func promotionEndings(e Ending) []Ending {
+	var list []Ending
+	for i, pt := range e.Pieces {
+		if pt != chess.Pawn {
+			continue
+		}
+		for _, promo := range []chess.PieceType{chess.Queen, chess.Rook, chess.Bishop, chess.Knight} {
+			pieces := append([]chess.PieceType(nil), e.Pieces...)
+			pieces[i] = promo
+			for _, other := range Endings {
+				if samePieces(other.Pieces, pieces) {
+					list = append(list, other)
+				}
+			}
+		}
+	}
+	return list
+}
+
+func samePieces(a, b []chess.PieceType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// promotions returns the loaded tables e promotes into.
+func (tb *Tablebase) promotions(e Ending) []*Table {
+	var tables []*Table
+	for _, p := range promotionEndings(e) {
+		if t := tb.tables[p.Name]; t != nil {
+			tables = append(tables, t)
+		}
+	}
+	return tables
+}
+
+// probeMain prints what the tables in dir say about fen, and about each
+// of its moves.
+func probeMain(dir, fen string) error {
+	tb, err := Open(dir)
+	if err != nil {
+		return err
+	}
+	opt, err := chess.FEN(fen)
+	if err != nil {
+		return err
+	}
+	pos := chess.NewGame(opt).Position()
+	r, ok := tb.Probe(pos)
+	if !ok {
+		return fmt.Errorf("no table for this position (loaded: %s)", strings.Join(tb.Names(), " "))
+	}
+	fmt.Println(r)
+	moves, results := tb.Moves(pos)
+	for i, move := range moves {
+		fmt.Printf("  %-8s %v\n", chess.AlgebraicNotation{}.Encode(pos, move), results[i])
+	}
+	return nil
+}
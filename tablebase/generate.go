@@ -0,0 +1,375 @@
+package tablebase
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// Steps as (file, rank) offsets.
+var (
+	kingSteps   = [8][2]int{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}}
+	knightSteps = [8][2]int{{1, 2}, {2, 1}, {2, -1}, {1, -2}, {-1, -2}, {-2, -1}, {-2, 1}, {-1, 2}}
+	rookSteps   = [][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+	bishopSteps = [][2]int{{1, 1}, {-1, 1}, {-1, -1}, {1, -1}}
+)
+
+// kingAttacks and knightAttacks are bitboards of the squares a king or a
+// knight on each square attacks.
+var kingAttacks, knightAttacks [64]uint64
+
+func init() {
+	for sq := range 64 {
+		for _, st := range kingSteps {
+			if to, ok := step(sq, st); ok {
+				kingAttacks[sq] |= 1 << to
+			}
+		}
+		for _, st := range knightSteps {
+			if to, ok := step(sq, st); ok {
+				knightAttacks[sq] |= 1 << to
+			}
+		}
+	}
+}
+
+// step returns the square st away from sq, or false off the board.
+func step(sq int, st [2]int) (int, bool) {
+	file, rank := sq&7+st[0], sq>>3+st[1]
+	if file < 0 || file > 7 || rank < 0 || rank > 7 {
+		return 0, false
+	}
+	return rank<<3 | file, true
+}
+
+// slide returns the squares a slider attacks from sq along steps, up to
+// and including the first occupied square each way.
+func slide(sq int, steps [][2]int, occupied uint64) uint64 {
+	var bb uint64
+	for _, st := range steps {
+		for to, ok := step(sq, st); ok; to, ok = step(to, st) {
+			bb |= 1 << to
+			if occupied&(1<<to) != 0 {
+				break
+			}
+		}
+	}
+	return bb
+}
+
+// attacks returns the squares a white piece of type pt on sq attacks.
+func attacks(pt chess.PieceType, sq int, occupied uint64) uint64 {
+	switch pt {
+	case chess.King:
+		return kingAttacks[sq]
+	case chess.Knight:
+		return knightAttacks[sq]
+	case chess.Bishop:
+		return slide(sq, bishopSteps, occupied)
+	case chess.Rook:
+		return slide(sq, rookSteps, occupied)
+	case chess.Queen:
+		return slide(sq, rookSteps, occupied) | slide(sq, bishopSteps, occupied)
+	case chess.Pawn:
+		var bb uint64
+		for _, st := range [][2]int{{-1, 1}, {1, 1}} {
+			if to, ok := step(sq, st); ok {
+				bb |= 1 << to
+			}
+		}
+		return bb
+	}
+	return 0
+}
+
+// generator builds one table by retrograde analysis over every placement
+// of the ending's pieces, the white king anywhere.
+type generator struct {
+	Ending
+	n        int      // squares per placement
+	wtm, btm []uint8  // plies to mate plus one, 0 while not known to be lost for Black
+	moves    []uint8  // with Black to move, legal moves not yet known to lose
+	promote  []uint8  // with White to move, plies to mate plus one through a promotion
+	promoted []*Table // the tables the pawn promotes into
+	scratch  []int
+}
+
+// pieceType returns the type of the piece on sqs[i].
+func (g *generator) pieceType(i int) chess.PieceType {
+	if i < 2 {
+		return chess.King
+	}
+	return g.Pieces[i-2]
+}
+
+// occupancy returns the bitboard of the placement's squares.
+func occupancy(sqs []int) uint64 {
+	var bb uint64
+	for _, sq := range sqs {
+		bb |= 1 << sq
+	}
+	return bb
+}
+
+// whiteAttacks returns the squares White attacks, leaving out the piece
+// at index skip (-1 for none), with occupied as the blockers.
+func (g *generator) whiteAttacks(sqs []int, skip int, occupied uint64) uint64 {
+	bb := kingAttacks[sqs[0]]
+	for i := 2; i < g.n; i++ {
+		if i != skip {
+			bb |= attacks(g.Pieces[i-2], sqs[i], occupied)
+		}
+	}
+	return bb
+}
+
+// legal reports whether a placement is a position that can happen with
+// the given side to move: no two pieces on a square, kings apart, no pawn
+// on the first or last rank, and with White to move Black not in check.
+func (g *generator) legal(sqs []int, whiteToMove bool) bool {
+	if bits.OnesCount64(occupancy(sqs)) != g.n {
+		return false
+	}
+	if kingAttacks[sqs[0]]&(1<<sqs[1]) != 0 {
+		return false
+	}
+	for i := 2; i < g.n; i++ {
+		if g.Pieces[i-2] == chess.Pawn && (sqs[i] < 8 || sqs[i] >= 56) {
+			return false
+		}
+	}
+	return !whiteToMove || g.whiteAttacks(sqs, -1, occupancy(sqs))&(1<<sqs[1]) == 0
+}
+
+// blackMoves counts Black's legal king moves, captures included, and
+// reports whether Black is in check.
+func (g *generator) blackMoves(sqs []int) (moves int, check bool) {
+	occupied := occupancy(sqs)
+	bk := sqs[1]
+	without := occupied &^ (1 << bk)
+	for targets := kingAttacks[bk]; targets != 0; targets &= targets - 1 {
+		to := bits.TrailingZeros64(targets)
+		captured := -1
+		for i := 2; i < g.n; i++ {
+			if sqs[i] == to {
+				captured = i
+			}
+		}
+		if to == sqs[0] {
+			continue
+		}
+		// Sliders see through the square the king leaves
+		if g.whiteAttacks(sqs, captured, without|1<<to)&(1<<to) == 0 {
+			moves++
+		}
+	}
+	return moves, g.whiteAttacks(sqs, -1, occupied)&(1<<bk) != 0
+}
+
+// generate builds the table for e. Endings with pawns need the tables
+// their promotions lead to in promoted; promotions into anything else are
+// taken to draw.
+func generate(e Ending, promoted []*Table) *Table {
+	g := &generator{Ending: e, n: 2 + len(e.Pieces), promoted: promoted}
+	g.scratch = make([]int, g.n)
+	size := e.size()
+	g.wtm, g.btm = make([]uint8, size), make([]uint8, size)
+	g.moves, g.promote = make([]uint8, size), make([]uint8, size)
+
+	// Checkmates, Black's move counts and promotions into won positions
+	sqs := make([]int, g.n)
+	for idx := range size {
+		squares(idx, g.n, sqs)
+		if g.legal(sqs, false) {
+			moves, check := g.blackMoves(sqs)
+			g.moves[idx] = uint8(moves)
+			if moves == 0 && check {
+				g.btm[idx] = 1
+			}
+		}
+		if e.hasPawns() && g.legal(sqs, true) {
+			g.promote[idx] = g.promotion(sqs)
+		}
+	}
+
+	// Ply by ply: positions where White mates in d plies lead back to
+	// Black positions lost in d+1 once all Black's moves are known to lose,
+	// and Black positions lost in d to White positions won in d+1.
+	for v := uint8(1); ; v++ {
+		if v == 255 {
+			panic("tablebase: " + e.Name + " mates too long to store")
+		}
+		found := false
+		for idx := range size {
+			if g.promote[idx] == v && g.wtm[idx] == 0 {
+				g.wtm[idx] = v
+			}
+			if g.promote[idx] > v {
+				found = true
+			}
+		}
+		for idx := range size {
+			if g.btm[idx] == v {
+				found = true
+				g.unmoveWhite(idx, v+1)
+			}
+			if g.wtm[idx] == v {
+				found = true
+				g.unmoveBlack(idx, v+1)
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	return g.table()
+}
+
+// promotion returns the plies to mate plus one through promoting the pawn
+// with White to move, or 0 if no promotion wins. The promoted piece takes
+// the pawn's place, so its table lists the pieces in the same order.
+func (g *generator) promotion(sqs []int) uint8 {
+	best := uint8(0)
+	for i := 2; i < g.n; i++ {
+		to := sqs[i] + 8
+		if g.Pieces[i-2] != chess.Pawn || sqs[i] < 48 || occupancy(sqs)&(1<<to) != 0 {
+			continue
+		}
+		after := append([]int(nil), sqs...)
+		after[i] = to
+		for _, t := range g.promoted {
+			r := t.value(after, false)
+			if r.Outcome == Loss && (best == 0 || uint8(r.Plies+2) < best) {
+				best = uint8(r.Plies + 2)
+			}
+		}
+	}
+	return best
+}
+
+// unmoveWhite marks the White-to-move positions that lead to the lost
+// Black-to-move position idx as won in v-1 plies, unless they are won
+// faster.
+func (g *generator) unmoveWhite(idx int, v uint8) {
+	sqs := g.scratch
+	squares(idx, g.n, sqs)
+	occupied := occupancy(sqs)
+	for i := range g.n {
+		if i == 1 {
+			continue
+		}
+		from := sqs[i]
+		var origins uint64
+		switch pt := g.pieceType(i); pt {
+		case chess.Pawn:
+			// A pawn came from one square back, or two from its first rank
+			if back := from - 8; back >= 8 && occupied&(1<<back) == 0 {
+				origins |= 1 << back
+				if from>>3 == 3 && occupied&(1<<(from-16)) == 0 {
+					origins |= 1 << (from - 16)
+				}
+			}
+		default:
+			origins = attacks(pt, from, occupied) &^ occupied
+		}
+		for ; origins != 0; origins &= origins - 1 {
+			sqs[i] = bits.TrailingZeros64(origins)
+			if before := index(sqs); g.wtm[before] == 0 && g.legal(sqs, true) {
+				g.wtm[before] = v
+			}
+		}
+		sqs[i] = from
+	}
+}
+
+// unmoveBlack counts a losing move off each Black-to-move position that
+// leads to the won White-to-move position idx, marking those with no
+// other moves left as lost in v-1 plies.
+func (g *generator) unmoveBlack(idx int, v uint8) {
+	sqs := g.scratch
+	squares(idx, g.n, sqs)
+	from := sqs[1]
+	for origins := kingAttacks[from] &^ occupancy(sqs); origins != 0; origins &= origins - 1 {
+		sqs[1] = bits.TrailingZeros64(origins)
+		before := index(sqs)
+		if g.btm[before] != 0 || g.moves[before] == 0 {
+			continue // already lost, or not a legal position
+		}
+		if g.moves[before]--; g.moves[before] == 0 {
+			g.btm[before] = v
+		}
+	}
+}
+
+// table keeps the placements with the white king on its stored squares.
+func (g *generator) table() *Table {
+	t := &Table{Ending: g.Ending, data: make([]byte, 2*g.stored())}
+	slots := g.kingSlots()
+	perKing := g.size() / 64
+	for idx := range g.size() {
+		slot := slots[idx&63]
+		if slot < 0 {
+			continue
+		}
+		offset := slot*perKing + idx>>6
+		t.data[offset] = g.btm[idx]
+		t.data[g.stored()+offset] = g.wtm[idx]
+	}
+	return t
+}
+
+// stats summarizes a table: its legal positions with White to move, how
+// many White wins, and the longest mate.
+func (t *Table) stats() (positions, wins, longest int) {
+	g := &generator{Ending: t.Ending, n: 2 + len(t.Pieces)}
+	sqs := make([]int, g.n)
+	for idx := range t.size() {
+		squares(idx, g.n, sqs)
+		if t.kingSlots()[sqs[0]] < 0 || !g.legal(sqs, true) {
+			continue
+		}
+		positions++
+		if r := t.value(sqs, true); r.Outcome == Win {
+			wins++
+			longest = max(longest, r.Moves())
+		}
+	}
+	return positions, wins, longest
+}
+
+// fen writes a placement as a FEN, White to move or not.
+func (g *generator) fen(sqs []int, whiteToMove bool) string {
+	var board [64]byte
+	board[sqs[0]], board[sqs[1]] = 'K', 'k'
+	for i := 2; i < g.n; i++ {
+		board[sqs[i]] = strings.ToUpper(g.Pieces[i-2].String())[0]
+	}
+	fen := ""
+	for rank := 7; rank >= 0; rank-- {
+		empty := 0
+		for file := range 8 {
+			c := board[rank<<3|file]
+			if c == 0 {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				fen += fmt.Sprint(empty)
+				empty = 0
+			}
+			fen += string(c)
+		}
+		if empty > 0 {
+			fen += fmt.Sprint(empty)
+		}
+		if rank > 0 {
+			fen += "/"
+		}
+	}
+	if whiteToMove {
+		return fen + " w - - 0 1"
+	}
+	return fen + " b - - 0 1"
+}
@@ -0,0 +1,363 @@
+// Package tablebase generates and probes endgame tablebases: for every
+// position of an ending, how many plies the stronger side needs to mate
+// with best play, or that it can't win. The tables hold this distance to
+// mate (DTM) for KQK, KRK, KPK and KBNK, with the stronger side either
+// color.
+//
+// Tables are built by retrograde analysis, from the checkmates backwards
+// one ply at a time by un-making moves. The generator has its own move
+// generation for these few pieces, and Verify checks finished tables
+// against github.com/notnil/chess, so each is a check on the other.
+package tablebase
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/notnil/chess"
+)
+
+// fileExt is the extension of table files, after the ending's name.
+const fileExt = ".dtm"
+
+// Ending is a table's material: the stronger side's pieces besides its
+// king, against a lone king. In the table the stronger side is White.
+type Ending struct {
+	Name   string
+	Pieces []chess.PieceType
+}
+
+// Endings are the tables there are, each after the tables its promotions
+// lead to.
+var Endings = []Ending{
+	{"KQK", []chess.PieceType{chess.Queen}},
+	{"KRK", []chess.PieceType{chess.Rook}},
+	{"KPK", []chess.PieceType{chess.Pawn}},
+	{"KBNK", []chess.PieceType{chess.Bishop, chess.Knight}},
+}
+
+// FindEnding returns the ending with the given name, such as "KQK".
+func FindEnding(name string) (Ending, bool) {
+	for _, e := range Endings {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return Ending{}, false
+}
+
+// hasPawns reports whether the ending has a pawn, which rules out the
+// symmetries that turn the board over.
+func (e Ending) hasPawns() bool {
+	for _, pt := range e.Pieces {
+		if pt == chess.Pawn {
+			return true
+		}
+	}
+	return false
+}
+
+// size is the number of placements of the ending's pieces, squares
+// allowed to clash, with either side to move counted once.
+func (e Ending) size() int {
+	return 1 << (6 * (2 + len(e.Pieces)))
+}
+
+// kingSlots numbers the squares the white king is stored on: the a1-d1-d4
+// triangle for endings without pawns, and files a to d with them. Other
+// squares are -1.
+func (e Ending) kingSlots() *[64]int {
+	if e.hasPawns() {
+		return &halfBoard
+	}
+	return &triangle
+}
+
+var triangle, halfBoard [64]int
+
+func init() {
+	t, h := 0, 0
+	for sq := range 64 {
+		file, rank := sq&7, sq>>3
+		triangle[sq], halfBoard[sq] = -1, -1
+		if file <= 3 && rank <= 3 && rank <= file {
+			triangle[sq] = t
+			t++
+		}
+		if file <= 3 {
+			halfBoard[sq] = h
+			h++
+		}
+	}
+}
+
+// stored is the number of bytes a table holds per side to move.
+func (e Ending) stored() int {
+	slots := 0
+	for _, s := range e.kingSlots() {
+		if s >= 0 {
+			slots++
+		}
+	}
+	return slots * e.size() / 64
+}
+
+// canonical turns the board so the white king stands on one of the stored
+// squares: over the d/e line, then for endings without pawns over the 4th/5th
+// rank line and the a1-h8 diagonal.
+func (e Ending) canonical(sqs []int) {
+	turn := func(f func(int) int) {
+		for i := range sqs {
+			sqs[i] = f(sqs[i])
+		}
+	}
+	if sqs[0]&7 > 3 {
+		turn(func(sq int) int { return sq ^ 7 })
+	}
+	if e.hasPawns() {
+		return
+	}
+	if sqs[0]>>3 > 3 {
+		turn(func(sq int) int { return sq ^ 56 })
+	}
+	if sqs[0]>>3 > sqs[0]&7 {
+		turn(func(sq int) int { return sq>>3 | (sq&7)<<3 })
+	}
+}
+
+// index packs a placement, the white king first, the black king second
+// and then the pieces in Ending order, six bits a square.
+func index(sqs []int) int {
+	idx := 0
+	for i, sq := range sqs {
+		idx |= sq << (6 * i)
+	}
+	return idx
+}
+
+// squares unpacks an index of n squares into sqs.
+func squares(idx, n int, sqs []int) {
+	for i := range n {
+		sqs[i] = idx >> (6 * i) & 63
+	}
+}
+
+// Outcome is how a position ends with best play, for the side to move.
+type Outcome int
+
+const (
+	Draw Outcome = iota
+	Win
+	Loss
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case Win:
+		return "win"
+	case Loss:
+		return "loss"
+	}
+	return "draw"
+}
+
+// Result is what a table says about a position: the outcome for the side
+// to move and, unless it is a draw, the plies until mate.
+type Result struct {
+	Outcome Outcome
+	Plies   int
+}
+
+// Moves returns the moves until mate, counting the mating move.
+func (r Result) Moves() int {
+	return (r.Plies + 1) / 2
+}
+
+func (r Result) String() string {
+	switch r.Outcome {
+	case Win:
+		return fmt.Sprintf("win, mate in %d", r.Moves())
+	case Loss:
+		if r.Plies == 0 {
+			return "loss, checkmated"
+		}
+		return fmt.Sprintf("loss, mated in %d", r.Moves())
+	}
+	return "draw"
+}
+
+// Table is one ending's results. Each byte is 0 for a draw, or the plies
+// to mate plus one: mating for White to move, being mated for Black.
+type Table struct {
+	Ending
+	data []byte // Black to move, then White to move, by the king's slot
+}
+
+// value looks up a placement in White's terms, sqs as index orders them.
+func (t *Table) value(sqs []int, whiteToMove bool) Result {
+	var turned [4]int
+	n := copy(turned[:], sqs)
+	t.canonical(turned[:n])
+	offset := t.kingSlots()[turned[0]]*(t.size()/64) + index(turned[:n])>>6
+	outcome := Loss
+	if whiteToMove {
+		offset += t.stored()
+		outcome = Win
+	}
+	v := t.data[offset]
+	if v == 0 {
+		return Result{}
+	}
+	return Result{Outcome: outcome, Plies: int(v) - 1}
+}
+
+// Tablebase is a set of tables, found by the material on the board.
+type Tablebase struct {
+	tables map[string]*Table
+}
+
+// Open loads the tables in dir, named after their endings, such as
+// KQK.dtm. It is an error if there are none.
+func Open(dir string) (*Tablebase, error) {
+	tb := &Tablebase{tables: map[string]*Table{}}
+	for _, e := range Endings {
+		t, err := load(filepath.Join(dir, e.Name+fileExt), e)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		tb.tables[e.Name] = t
+	}
+	if len(tb.tables) == 0 {
+		return nil, fmt.Errorf("no tables in %s", dir)
+	}
+	return tb, nil
+}
+
+// Names lists the loaded tables in Endings order.
+func (tb *Tablebase) Names() []string {
+	var names []string
+	for _, e := range Endings {
+		if tb.tables[e.Name] != nil {
+			names = append(names, e.Name)
+		}
+	}
+	return names
+}
+
+// load reads a table written by save.
+func load(path string, e Ending) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if zr.Name != e.Name {
+		return nil, fmt.Errorf("%s: holds %q, not %s", path, zr.Name, e.Name)
+	}
+	t := &Table{Ending: e, data: make([]byte, 2*e.stored())}
+	if _, err := io.ReadFull(zr, t.data); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// save writes the table to path, compressed.
+func (t *Table) save(path string) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	zw, err := gzip.NewWriterLevel(f, gzip.BestCompression)
+	if err == nil {
+		zw.Name = t.Name
+		if _, err = zw.Write(t.data); err == nil {
+			err = zw.Close()
+		}
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// Probe looks pos up. It reports false if no loaded table has its
+// material, or if castling is still allowed, which the tables don't play.
+func (tb *Tablebase) Probe(pos *chess.Position) (Result, bool) {
+	pieces := pos.Board().SquareMap()
+	if len(pieces) > 4 || pos.CastleRights() != "-" {
+		return Result{}, false
+	}
+	strong := chess.NoColor
+	for _, p := range pieces {
+		if p.Type() == chess.King {
+			continue
+		}
+		if strong != chess.NoColor && p.Color() != strong {
+			return Result{}, false
+		}
+		strong = p.Color()
+	}
+	if strong == chess.NoColor {
+		return Result{}, false
+	}
+	for _, t := range tb.tables {
+		if len(t.Pieces) != len(pieces)-2 {
+			continue
+		}
+		if sqs, ok := t.place(pieces, strong); ok {
+			return t.value(sqs, pos.Turn() == strong), true
+		}
+	}
+	return Result{}, false
+}
+
+// place orders the squares of pieces for the table, turning the board
+// over if the stronger side is Black. It reports false if the pieces
+// aren't the table's.
+func (t *Table) place(pieces map[chess.Square]chess.Piece, strong chess.Color) ([]int, bool) {
+	sqs := make([]int, 2+len(t.Pieces))
+	used := map[chess.Square]bool{}
+	find := func(want chess.Piece) (int, bool) {
+		for sq, p := range pieces {
+			if p == want && !used[sq] {
+				used[sq] = true
+				if strong == chess.Black {
+					return int(sq) ^ 56, true
+				}
+				return int(sq), true
+			}
+		}
+		return 0, false
+	}
+	want := []chess.Piece{chess.NewPiece(chess.King, strong), chess.NewPiece(chess.King, strong.Other())}
+	for _, pt := range t.Pieces {
+		want = append(want, chess.NewPiece(pt, strong))
+	}
+	for i, p := range want {
+		sq, ok := find(p)
+		if !ok {
+			return nil, false
+		}
+		sqs[i] = sq
+	}
+	return sqs, true
+}
@@ -0,0 +1,98 @@
+package tablebase
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/notnil/chess"
+)
+
+// after returns the result one ply earlier, for the side that played the
+// move leading to a position with result r.
+func (r Result) after() Result {
+	switch r.Outcome {
+	case Win:
+		return Result{Outcome: Loss, Plies: r.Plies + 1}
+	case Loss:
+		return Result{Outcome: Win, Plies: r.Plies + 1}
+	}
+	return Result{}
+}
+
+// better reports whether a is a better result than b for the side to
+// move: a faster win, or a slower loss.
+func better(a, b Result) bool {
+	rank := func(r Result) int {
+		switch r.Outcome {
+		case Win:
+			return 1000 - r.Plies
+		case Loss:
+			return -1000 + r.Plies
+		}
+		return 0
+	}
+	return rank(a) > rank(b)
+}
+
+// Moves returns the legal moves of pos with the result each leads to for
+// the side to move, best first. Moves to positions no table has, such as
+// captures of the last piece, are draws.
+func (tb *Tablebase) Moves(pos *chess.Position) ([]*chess.Move, []Result) {
+	moves := pos.ValidMoves()
+	results := make([]Result, len(moves))
+	for i, move := range moves {
+		r, _ := tb.Probe(pos.Update(move))
+		results[i] = r.after()
+	}
+	// Insertion sort keeps the move generator's order among equals
+	for i := 1; i < len(moves); i++ {
+		for j := i; j > 0 && better(results[j], results[j-1]); j-- {
+			moves[j], moves[j-1] = moves[j-1], moves[j]
+			results[j], results[j-1] = results[j-1], results[j]
+		}
+	}
+	return moves, results
+}
+
+// solve works out the result of pos from the results of its moves.
+func (tb *Tablebase) solve(pos *chess.Position) Result {
+	moves, results := tb.Moves(pos)
+	if len(moves) == 0 {
+		if pos.Status() == chess.Checkmate {
+			return Result{Outcome: Loss}
+		}
+		return Result{}
+	}
+	return results[0]
+}
+
+// Verify checks samples random legal positions of the named table, either
+// side to move, against github.com/notnil/chess: each result has to follow
+// from the results of the moves that package generates.
+func (tb *Tablebase) Verify(name string, samples int, rng *rand.Rand) error {
+	t := tb.tables[name]
+	if t == nil {
+		return fmt.Errorf("no %s table", name)
+	}
+	g := &generator{Ending: t.Ending, n: 2 + len(t.Pieces)}
+	sqs := make([]int, g.n)
+	for checked := 0; checked < samples; {
+		squares(rng.Intn(t.size()), g.n, sqs)
+		whiteToMove := rng.Intn(2) == 0
+		if !g.legal(sqs, whiteToMove) {
+			continue
+		}
+		checked++
+		fen := g.fen(sqs, whiteToMove)
+		opt, err := chess.FEN(fen)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fen, err)
+		}
+		pos := chess.NewGame(opt).Position()
+		got, _ := tb.Probe(pos)
+		if want := tb.solve(pos); got != want {
+			return fmt.Errorf("%s: the table says %v, but its moves say %v", fen, got, want)
+		}
+	}
+	return nil
+}
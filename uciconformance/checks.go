@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+)
+
+const (
+	replyTimeout  = 2 * time.Second
+	searchTimeout = 10 * time.Second
+)
+
+type check struct {
+	name string
+	run  func(c *engineClient) error
+}
+
+var suite = []check{
+	{"handshake", checkHandshake},
+	{"options echo", checkOptions},
+	{"long move list", checkLongMoveList},
+	{"stop during search", checkStop},
+	{"isready under load", checkIsReadyUnderLoad},
+	{"malformed input", checkMalformedInput},
+}
+
+// === Helpers ===
+
+// handshake performs uci/isready and returns the lines printed before uciok.
+func handshake(c *engineClient) ([]string, error) {
+	if err := c.Send("uci"); err != nil {
+		return nil, err
+	}
+	_, lines, err := c.WaitFor("uciok", replyTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := expectReady(c); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+func expectReady(c *engineClient) error {
+	if err := c.Send("isready"); err != nil {
+		return err
+	}
+	_, _, err := c.WaitFor("readyok", replyTimeout)
+	return err
+}
+
+// expectLegalBestMove waits for bestmove and checks it is legal in pos.
+func expectLegalBestMove(c *engineClient, pos *chess.Position, timeout time.Duration) error {
+	line, _, err := c.WaitFor("bestmove", timeout)
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return fmt.Errorf("malformed bestmove line %q", line)
+	}
+
+	_, err = board.UCIToMove(pos, fields[1])
+	return err
+}
+
+// scriptedGame plays a deterministic sequence of legal moves from the start
+// position and returns them in UCI notation with the resulting position.
+func scriptedGame(plies int) ([]string, *chess.Position) {
+	game := chess.NewGame()
+	var moves []string
+	for i := 0; i < plies && game.Outcome() == chess.NoOutcome; i++ {
+		valid := game.ValidMoves()
+		mv := valid[(i*7)%len(valid)]
+		moves = append(moves, board.MoveToUCI(mv))
+		game.Move(mv)
+	}
+	return moves, game.Position()
+}
+
+// === Checks ===
+
+func checkHandshake(c *engineClient) error {
+	if err := c.Send("uci"); err != nil {
+		return err
+	}
+	_, lines, err := c.WaitFor("uciok", replyTimeout)
+	if err != nil {
+		return err
+	}
+
+	hasName := false
+	for _, line := range lines {
+		if strings.HasPrefix(line, "id name ") {
+			hasName = true
+		}
+	}
+	if !hasName {
+		return fmt.Errorf("no \"id name\" line before uciok")
+	}
+
+	if err := expectReady(c); err != nil {
+		return err
+	}
+	if err := c.Send("ucinewgame"); err != nil {
+		return err
+	}
+	return expectReady(c)
+}
+
+func checkOptions(c *engineClient) error {
+	lines, err := handshake(c)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "option ") {
+			continue
+		}
+		name, typ, def, err := parseOption(line)
+		if err != nil {
+			return err
+		}
+		if typ == "button" {
+			continue
+		}
+		if err := c.Send("setoption name " + name + " value " + def); err != nil {
+			return err
+		}
+	}
+
+	return expectReady(c)
+}
+
+// parseOption extracts name, type and default from an "option" line.
+func parseOption(line string) (name, typ, def string, err error) {
+	fields := strings.Fields(line)
+	var section string
+	values := map[string][]string{}
+	for _, f := range fields[1:] {
+		switch f {
+		case "name", "type", "default", "min", "max", "var":
+			_, seen := values[f]
+			if seen && f != "var" {
+				return "", "", "", fmt.Errorf("duplicate %q in %q", f, line)
+			}
+			if !seen {
+				values[f] = []string{}
+			}
+			section = f
+			continue
+		}
+		if section == "" {
+			return "", "", "", fmt.Errorf("unexpected token %q in %q", f, line)
+		}
+		values[section] = append(values[section], f)
+	}
+
+	name = strings.Join(values["name"], " ")
+	typ = strings.Join(values["type"], " ")
+	def = strings.Join(values["default"], " ")
+	if name == "" {
+		return "", "", "", fmt.Errorf("option without name: %q", line)
+	}
+
+	switch typ {
+	case "check":
+		if def != "true" && def != "false" {
+			return "", "", "", fmt.Errorf("check option %s has default %q", name, def)
+		}
+	case "spin":
+		if _, ok := values["min"]; !ok {
+			return "", "", "", fmt.Errorf("spin option %s has no min", name)
+		}
+		if _, ok := values["max"]; !ok {
+			return "", "", "", fmt.Errorf("spin option %s has no max", name)
+		}
+	case "combo", "string", "button":
+	default:
+		return "", "", "", fmt.Errorf("option %s has unknown type %q", name, typ)
+	}
+	return name, typ, def, nil
+}
+
+func checkLongMoveList(c *engineClient) error {
+	if _, err := handshake(c); err != nil {
+		return err
+	}
+
+	moves, pos := scriptedGame(120)
+	if err := c.Send("position startpos moves " + strings.Join(moves, " ")); err != nil {
+		return err
+	}
+	if err := c.Send("go movetime 200"); err != nil {
+		return err
+	}
+	return expectLegalBestMove(c, pos, searchTimeout)
+}
+
+func checkStop(c *engineClient) error {
+	if _, err := handshake(c); err != nil {
+		return err
+	}
+
+	if err := c.Send("position startpos"); err != nil {
+		return err
+	}
+	if err := c.Send("go infinite"); err != nil {
+		return err
+	}
+	time.Sleep(200 * time.Millisecond)
+	if err := c.Send("stop"); err != nil {
+		return err
+	}
+	return expectLegalBestMove(c, chess.StartingPosition(), replyTimeout)
+}
+
+func checkIsReadyUnderLoad(c *engineClient) error {
+	if _, err := handshake(c); err != nil {
+		return err
+	}
+
+	if err := c.Send("position startpos moves e2e4 e7e5"); err != nil {
+		return err
+	}
+	if err := c.Send("go infinite"); err != nil {
+		return err
+	}
+	if err := c.Send("isready"); err != nil {
+		return err
+	}
+	_, seen, err := c.WaitFor("readyok", replyTimeout)
+	if err != nil {
+		return fmt.Errorf("while searching: %v", err)
+	}
+	for _, line := range seen {
+		if strings.HasPrefix(line, "bestmove") {
+			return fmt.Errorf("%q sent before stop in infinite mode", line)
+		}
+	}
+	if err := c.Send("stop"); err != nil {
+		return err
+	}
+
+	pos := chess.StartingPosition()
+	for _, s := range []string{"e2e4", "e7e5"} {
+		mv, _ := board.UCIToMove(pos, s)
+		pos = pos.Update(mv)
+	}
+	return expectLegalBestMove(c, pos, replyTimeout)
+}
+
+func checkMalformedInput(c *engineClient) error {
+	if _, err := handshake(c); err != nil {
+		return err
+	}
+
+	garbage := []string{
+		"",
+		"   ",
+		"x",
+		"hello engine",
+		"position",
+		"position fen not/a/fen w - - 0 1",
+		"position startpos moves e2e5",
+		"setoption",
+		"setoption name",
+		"debug maybe",
+	}
+	for _, line := range garbage {
+		if err := c.Send(line); err != nil {
+			return err
+		}
+	}
+	if err := expectReady(c); err != nil {
+		return fmt.Errorf("after malformed input: %v", err)
+	}
+
+	if err := c.Send("position startpos"); err != nil {
+		return err
+	}
+	if err := c.Send("go movetime 100"); err != nil {
+		return err
+	}
+	return expectLegalBestMove(c, chess.StartingPosition(), searchTimeout)
+}
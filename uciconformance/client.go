@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// engineClient drives a single UCI engine process. Unlike the arbiters'
+// UCIEngine it never blocks forever: every read goes through a deadline
+// so a hung or crashed engine shows up as a failed check.
+type engineClient struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	lines chan string
+	log   []string
+}
+
+func startEngine(path string) (*engineClient, error) {
+	cmd := exec.Command(path)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	c := &engineClient{
+		cmd:   cmd,
+		stdin: stdin,
+		lines: make(chan string, 1024),
+	}
+
+	// Pump stdout into a channel; it is closed when the engine exits.
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			c.lines <- scanner.Text()
+		}
+		close(c.lines)
+	}()
+
+	return c, nil
+}
+
+// Send writes a single command line to the engine.
+func (c *engineClient) Send(cmd string) error {
+	c.log = append(c.log, "> "+cmd)
+	_, err := fmt.Fprintf(c.stdin, "%s\n", cmd)
+	return err
+}
+
+// WaitFor reads lines until one starts with prefix and returns it, together
+// with all lines read before it.
+func (c *engineClient) WaitFor(prefix string, timeout time.Duration) (string, []string, error) {
+	var seen []string
+	deadline := time.After(timeout)
+	for {
+		select {
+		case line, ok := <-c.lines:
+			if !ok {
+				return "", seen, fmt.Errorf("engine exited while waiting for %q", prefix)
+			}
+			c.log = append(c.log, "< "+line)
+			if strings.HasPrefix(line, prefix) {
+				return line, seen, nil
+			}
+			seen = append(seen, line)
+		case <-deadline:
+			return "", seen, fmt.Errorf("no %q within %v", prefix, timeout)
+		}
+	}
+}
+
+// Close asks the engine to quit and kills it if it does not exit in time.
+func (c *engineClient) Close() {
+	c.Send("quit")
+	c.stdin.Close()
+
+	done := make(chan struct{})
+	go func() {
+		c.cmd.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		c.cmd.Process.Kill()
+		<-done
+	}
+}
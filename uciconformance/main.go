@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// uciconformance runs a scripted UCI conformance suite against an engine
+// binary and prints a pass/fail report, e.g.
+//
+//	go run ./uciconformance ./chessEngine2/engine2
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: uciconformance <engine path>")
+		os.Exit(2)
+	}
+	enginePath := os.Args[1]
+
+	failed := 0
+	for _, chk := range suite {
+		start := time.Now()
+		dialogue, err := runCheck(enginePath, chk)
+		elapsed := time.Since(start).Round(time.Millisecond)
+
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL  %-20s %v (%v)\n", chk.name, err, elapsed)
+			printTail(dialogue, 10)
+			continue
+		}
+		fmt.Printf("PASS  %-20s (%v)\n", chk.name, elapsed)
+	}
+
+	fmt.Printf("\n%d/%d checks passed\n", len(suite)-failed, len(suite))
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// runCheck starts a fresh engine process for a single check so a crash in
+// one check cannot affect the others. The UCI dialogue is returned so
+// failures can be diagnosed.
+func runCheck(enginePath string, chk check) ([]string, error) {
+	c, err := startEngine(enginePath)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	err = chk.run(c)
+	return c.log, err
+}
+
+func printTail(lines []string, n int) {
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	for _, line := range lines {
+		fmt.Printf("      %s\n", line)
+	}
+}
@@ -0,0 +1,323 @@
+package webarbiter
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"chessTomorrow/arbiter"
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+	"golang.org/x/net/websocket"
+)
+
+// The analysis board has its own WebSocket, /analysis. The page sends the
+// position to look at as a start FEN and the moves played from it, and the
+// server keeps an engine of its own thinking about it without limit,
+// passing on every line the engine reports until the page steps to
+// another position or stops it. The engine can be chosen with ?engine= or
+// in any message; the first in the registry is the default.
+
+// maxMultiPV bounds the number of lines the page may ask for.
+const maxMultiPV = 5
+
+// AnalysisRequest is a message from the analysis board.
+type AnalysisRequest struct {
+	Type    string   `json:"type"`              // "position" (the default) or "stop"
+	FEN     string   `json:"fen,omitempty"`     // where the moves start; empty for the start position
+	Moves   []string `json:"moves,omitempty"`   // UCI moves to the position to analyse
+	MultiPV int      `json:"multipv,omitempty"` // lines to show, 1 if 0
+	Engine  string   `json:"engine,omitempty"`  // empty keeps the engine running
+}
+
+// AnalysisLine is one of the engine's lines, sent whenever the engine
+// reports it.
+type AnalysisLine struct {
+	Type    string   `json:"type"` // "line"
+	FEN     string   `json:"fen"`  // the position analysed, to tell lines for an earlier one
+	MultiPV int      `json:"multipv"`
+	Depth   int      `json:"depth"`
+	Nodes   int64    `json:"nodes,omitempty"`
+	Score   int      `json:"score"`          // centipawns, from White's side
+	Mate    int      `json:"mate,omitempty"` // moves to mate, positive when White mates
+	PV      []string `json:"pv"`             // UCI
+	SAN     []string `json:"san"`
+}
+
+// analysisPosition answers a position message with the game up to it, as
+// the play page gets it.
+type analysisPosition struct {
+	Type string   `json:"type"` // "position"
+	SAN  []string `json:"san"`  // the moves, as the page lists them
+	State
+}
+
+// analyser runs one analysis board's engine.
+type analyser struct {
+	ws      *websocket.Conn
+	engine  *arbiter.UCIEngineAdapter
+	conf    *EngineConfig
+	multiPV int             // as set on the engine
+	pos     *chess.Position // being analysed, nil while the engine is idle
+}
+
+func handleAnalysis(ws *websocket.Conn) {
+	defer ws.Close()
+
+	a := &analyser{ws: ws}
+	if err := a.setEngine(ws.Request().URL.Query().Get("engine")); err != nil {
+		log.Printf("Analysis: %v", err)
+		a.sendPosition(State{Error: "The engine could not be started"})
+		return
+	}
+	defer func() { pool.Put(a.conf, a.engine) }()
+
+	// Messages are read apart, so that the engine's lines are passed on
+	// while the page is quiet
+	requests := make(chan AnalysisRequest)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(requests)
+		for {
+			var req AnalysisRequest
+			if err := websocket.JSON.Receive(ws, &req); err != nil {
+				return
+			}
+			select {
+			case requests <- req:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case req, ok := <-requests:
+			if !ok {
+				a.stop()
+				return
+			}
+			if !a.handle(req) {
+				return
+			}
+		case line, ok := <-a.engine.Lines():
+			if !ok {
+				log.Println("Analysis engine has exited, restarting it")
+				a.pos = nil
+				if err := a.engine.Restart(); err != nil {
+					log.Printf("Analysis: %v", err)
+					a.sendPosition(State{Error: "The engine stopped and could not be restarted"})
+					return
+				}
+				if !a.sendPosition(State{Error: "The engine stopped; choose the position again"}) {
+					return
+				}
+				continue
+			}
+			if a.pos == nil {
+				continue
+			}
+			if strings.HasPrefix(line, "bestmove") {
+				// The search ended by itself, as at a forced mate
+				a.pos = nil
+				continue
+			}
+			if l, ok := parseLine(a.pos, line); ok && !a.send(l) {
+				return
+			}
+		}
+	}
+}
+
+// handle carries out one message from the page, reporting whether the
+// connection is still usable.
+func (a *analyser) handle(req AnalysisRequest) bool {
+	switch req.Type {
+	case "stop":
+		a.stop()
+		return true
+	case "", "position":
+	default:
+		return a.sendPosition(State{Error: "Unknown message type " + req.Type})
+	}
+
+	game, err := analysisGame(req.FEN, req.Moves)
+	if err != nil {
+		return a.sendPosition(State{Error: err.Error()})
+	}
+	a.stop()
+	if req.Engine != "" && req.Engine != a.conf.Name {
+		if err := a.setEngine(req.Engine); err != nil {
+			log.Printf("Analysis: %v", err)
+			return a.sendPosition(State{Error: "Cannot analyse with that engine: " + err.Error()})
+		}
+	}
+
+	state := newState(game)
+	state.Engine = a.conf.Name
+	if !a.send(analysisPosition{Type: "position", SAN: sanMoves(game), State: state}) {
+		return false
+	}
+	if game.Outcome() != chess.NoOutcome {
+		// Nothing to think about
+		return true
+	}
+
+	if multiPV := min(max(req.MultiPV, 1), maxMultiPV); multiPV != a.multiPV {
+		if err := a.engine.SetOption("MultiPV", strconv.Itoa(multiPV)); err != nil {
+			log.Printf("Analysis: %v", err)
+		}
+		a.multiPV = multiPV
+	}
+	// The moves go along, so that the engine knows about repetitions
+	cmd := "position fen " + game.Positions()[0].String()
+	if len(state.Moves) > 0 {
+		cmd += " moves " + strings.Join(state.Moves, " ")
+	}
+	a.engine.Send(cmd)
+	a.engine.Send("go infinite")
+	a.pos = game.Position()
+	return true
+}
+
+// setEngine switches to the named registry engine, or the first if name is
+// empty.
+func (a *analyser) setEngine(name string) error {
+	conf, _, err := sessions.registry.find(name, "")
+	if err != nil {
+		return err
+	}
+	engine, err := pool.Get(conf)
+	if err != nil {
+		return err
+	}
+	if a.engine != nil {
+		pool.Put(a.conf, a.engine)
+	}
+	a.engine, a.conf, a.multiPV = engine, conf, 0
+	return nil
+}
+
+// stop ends the search, if there is one, and waits for the engine's move,
+// so that nothing more the engine says is about the old position.
+func (a *analyser) stop() {
+	if a.pos == nil {
+		return
+	}
+	a.pos = nil
+	a.engine.Send("stop")
+	if err := a.engine.Expect("bestmove"); err != nil {
+		log.Printf("Analysis: %v", err)
+		if err := a.engine.Restart(); err != nil {
+			log.Printf("Restarting engine: %v", err)
+		}
+	}
+}
+
+// sendPosition answers the page with state, reporting whether the
+// connection is still usable.
+func (a *analyser) sendPosition(state State) bool {
+	return a.send(analysisPosition{Type: "position", SAN: []string{}, State: state})
+}
+
+// send writes msg to the page, reporting whether the connection is still
+// usable.
+func (a *analyser) send(msg any) bool {
+	if err := websocket.JSON.Send(a.ws, msg); err != nil {
+		log.Printf("Failed to send message: %v\n", err)
+		return false
+	}
+	return true
+}
+
+// analysisGame plays moves from fen, or from the start position if fen is
+// empty. The FEN is read as a new game's is.
+func analysisGame(fen string, moves []string) (*chess.Game, error) {
+	pos, _, err := startPosition(fen, nil)
+	if err != nil {
+		return nil, err
+	}
+	opt, _ := chess.FEN(pos.String())
+	game := chess.NewGame(opt)
+	for _, s := range moves {
+		mv, err := board.UCIToMove(game.Position(), s)
+		if err == nil {
+			err = game.Move(mv)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return game, nil
+}
+
+// sanMoves returns g's moves in SAN.
+func sanMoves(g *chess.Game) []string {
+	san := []string{}
+	positions := g.Positions()
+	for i, mv := range g.Moves() {
+		san = append(san, chess.AlgebraicNotation{}.Encode(positions[i], mv))
+	}
+	return san
+}
+
+// parseLine reads an engine's "info" line about pos. Lines without both a
+// score and a PV, such as "info string" or progress reports, are left out.
+func parseLine(pos *chess.Position, line string) (AnalysisLine, bool) {
+	l := AnalysisLine{Type: "line", FEN: pos.String(), MultiPV: 1}
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != "info" {
+		return l, false
+	}
+	hasScore := false
+	for i := 1; i+1 < len(fields); i++ {
+		switch fields[i] {
+		case "string":
+			return l, false
+		case "multipv":
+			l.MultiPV, _ = strconv.Atoi(fields[i+1])
+		case "depth":
+			l.Depth, _ = strconv.Atoi(fields[i+1])
+		case "nodes":
+			l.Nodes, _ = strconv.ParseInt(fields[i+1], 10, 64)
+		case "score":
+			if i+2 >= len(fields) {
+				continue
+			}
+			n, err := strconv.Atoi(fields[i+2])
+			if err != nil {
+				continue
+			}
+			hasScore = true
+			switch fields[i+1] {
+			case "cp":
+				l.Score, l.Mate = n, 0
+			case "mate":
+				l.Score, l.Mate = 0, n
+			}
+		case "pv":
+			l.PV = fields[i+1:]
+			i = len(fields)
+		}
+	}
+	if !hasScore || len(l.PV) == 0 {
+		return l, false
+	}
+	if pos.Turn() == chess.Black {
+		l.Score, l.Mate = -l.Score, -l.Mate
+	}
+
+	// An engine's PV can run past a move it got wrong; stop there
+	for _, s := range l.PV {
+		mv, err := board.UCIToMove(pos, s)
+		if err != nil {
+			break
+		}
+		l.SAN = append(l.SAN, chess.AlgebraicNotation{}.Encode(pos, mv))
+		pos = pos.Update(mv)
+	}
+	l.PV = l.PV[:len(l.SAN)]
+	return l, len(l.PV) > 0
+}
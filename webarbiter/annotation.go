@@ -0,0 +1,69 @@
+package webarbiter
+
+import (
+	"sync"
+	"time"
+
+	"chessTomorrow/arbiter"
+	"chessTomorrow/match"
+)
+
+// Annotations has an engine judge the moves of kept games, one game at a
+// time since every position is searched. Finished games don't change, so
+// their annotations are kept.
+type Annotations struct {
+	engine *EngineConfig
+	budget time.Duration // search time per position
+	slot   chan struct{} // held by the game being annotated
+
+	mu   sync.Mutex
+	done map[string]*match.Annotation // finished games, by game ID
+}
+
+// NewAnnotations returns Annotations that search each position with
+// engine for budget.
+func NewAnnotations(engine *EngineConfig, budget time.Duration) *Annotations {
+	return &Annotations{
+		engine: engine,
+		budget: budget,
+		slot:   make(chan struct{}, 1),
+		done:   make(map[string]*match.Annotation),
+	}
+}
+
+// Annotate returns the engine's verdict on every move of g.
+func (as *Annotations) Annotate(g *StoredGame) (*match.Annotation, error) {
+	as.mu.Lock()
+	an := as.done[g.ID]
+	as.mu.Unlock()
+	if an != nil {
+		return an, nil
+	}
+	game, err := analysisGame(g.StartFEN, g.Moves)
+	if err != nil {
+		return nil, err
+	}
+
+	as.slot <- struct{}{}
+	defer func() { <-as.slot }()
+	// The games' engines are busy with their own searches, so the
+	// annotation gets an engine of its own that reports its scores
+	engine, err := as.engine.Start(arbiter.Limits{})
+	if err != nil {
+		return nil, err
+	}
+	defer engine.Close()
+	// A book move comes without a score
+	if err := engine.SetOption("OwnBook", "false"); err != nil {
+		return nil, err
+	}
+	if an, err = match.Annotate(game, engine, as.budget); err != nil {
+		return nil, err
+	}
+	if g.Result != "" {
+		as.mu.Lock()
+		as.done[g.ID] = an
+		as.mu.Unlock()
+	}
+	return an, nil
+}
@@ -0,0 +1,335 @@
+package webarbiter
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"chessTomorrow/match"
+)
+
+// The REST API plays the same games as the page, for scripts and clients
+// other than browsers. Every game is a session, so the page can join one
+// started here with its ID. Requests and replies are the WebSocket's Move
+// and State as JSON.
+//
+//	POST /api/game             start a game; the body is a "new" message, or empty
+//	POST /api/game/{id}/move   play a move, or send any other message such as "resign"
+//	GET  /api/game/{id}        the game as it stands
+//	GET  /api/game/{id}/pgn    the game as PGN
+//
+// With -games the server keeps every game played, finished or not:
+//
+//	GET  /api/games            the kept games, the most recently played first; like
+//	                           /api/games/{id}, without the session that plays them
+//	GET  /api/games/{id}       a kept game, by the game ID in its states
+//	GET  /api/games/{id}/pgn   a kept game as PGN
+//	GET  /api/games/{id}/annotation  an engine's verdict on each move of a kept game:
+//	                           its glyph and centipawn loss, and each side's accuracy
+//
+// Players can have identities, so that their games are rated. A new one
+// gets a token, which later requests send as "Authorization: Bearer
+// TOKEN" (and pages as ?user=TOKEN on their WebSockets):
+//
+//	POST /api/users            become a player; the body may give a name: {"name": "..."}
+//	GET  /api/me               the player the token belongs to, and their rating
+//	GET  /api/leaderboard      the Glicko-2 ratings of players and engine levels, highest first
+//
+// With -puzzles players can solve tactics; a player with a token has a
+// puzzle rating, counted on their first try at each puzzle:
+//
+//	POST /api/puzzles                 try a puzzle picked for the player, or {"id": "..."}
+//	GET  /api/puzzles/{attempt}       the try as it stands
+//	POST /api/puzzles/{attempt}/move  play a move, answered by the opponent's reply
+//	GET  /api/puzzles/leaderboard     the players' puzzle ratings, highest first
+//
+// A message that can't be carried out gets 400 Bad Request, with the
+// reason in the state's error.
+func registerAPI(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/game", apiNewGame)
+	mux.HandleFunc("POST /api/game/{id}/move", apiMove)
+	mux.HandleFunc("GET /api/game/{id}", apiGame)
+	mux.HandleFunc("GET /api/game/{id}/pgn", apiPGN)
+	mux.HandleFunc("GET /api/games", apiGames)
+	mux.HandleFunc("GET /api/games/{id}", apiStoredGame)
+	mux.HandleFunc("GET /api/games/{id}/pgn", apiStoredPGN)
+	mux.HandleFunc("GET /api/games/{id}/annotation", apiAnnotation)
+	mux.HandleFunc("POST /api/users", apiNewUser)
+	mux.HandleFunc("GET /api/me", apiMe)
+	mux.HandleFunc("GET /api/leaderboard", apiLeaderboard)
+	mux.HandleFunc("POST /api/puzzles", apiNewPuzzle)
+	mux.HandleFunc("GET /api/puzzles/leaderboard", apiPuzzleLeaderboard)
+	mux.HandleFunc("GET /api/puzzles/{attempt}", apiPuzzle)
+	mux.HandleFunc("POST /api/puzzles/{attempt}/move", apiPuzzleMove)
+	// Not the page for anything else under /api/
+	mux.HandleFunc("/api/", http.NotFound)
+}
+
+func apiNewGame(w http.ResponseWriter, r *http.Request) {
+	var msg Move
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil && err != io.EOF {
+		http.Error(w, "bad request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	msg.Type = "new"
+
+	sess, err := sessions.Open("")
+	if errors.Is(err, errTooManySessions) || errors.Is(err, errPoolFull) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, "the engine could not be started", http.StatusServiceUnavailable)
+		return
+	}
+	defer sessions.Release(sess)
+	sess.identify(users.Find(bearerToken(r)))
+	writeState(w, answer(sess, msg), http.StatusCreated)
+}
+
+func apiMove(w http.ResponseWriter, r *http.Request) {
+	var msg Move
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		http.Error(w, "bad request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	sess := joinGame(w, r)
+	if sess == nil {
+		return
+	}
+	defer sessions.Release(sess)
+	writeState(w, answer(sess, msg), http.StatusOK)
+}
+
+// answer carries out msg and, if the engine is then to move, waits for
+// its move, so that a script gets the reply with its own move. Notes on a
+// new game's position are kept.
+func answer(sess *Session, msg Move) State {
+	state := sess.handle(msg)
+	if state.Error != "" || !state.Thinking {
+		return state
+	}
+	reply := sess.wait()
+	reply.Notes = state.Notes
+	return reply
+}
+
+func apiGame(w http.ResponseWriter, r *http.Request) {
+	sess := joinGame(w, r)
+	if sess == nil {
+		return
+	}
+	defer sessions.Release(sess)
+	sess.mu.Lock()
+	state := sess.state()
+	sess.mu.Unlock()
+	writeState(w, state, http.StatusOK)
+}
+
+func apiPGN(w http.ResponseWriter, r *http.Request) {
+	sess := joinGame(w, r)
+	if sess == nil {
+		return
+	}
+	defer sessions.Release(sess)
+	sess.mu.Lock()
+	rec, err := sess.stored(time.Now()).record()
+	sess.mu.Unlock()
+	writePGN(w, rec, err)
+}
+
+func apiGames(w http.ResponseWriter, r *http.Request) {
+	games := sessions.store.List()
+	if games == nil {
+		games = []GameInfo{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(games)
+}
+
+func apiStoredGame(w http.ResponseWriter, r *http.Request) {
+	g := loadGame(w, r)
+	if g == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(g.GameInfo)
+}
+
+func apiStoredPGN(w http.ResponseWriter, r *http.Request) {
+	g := loadGame(w, r)
+	if g == nil {
+		return
+	}
+	rec, err := g.record()
+	writePGN(w, rec, err)
+}
+
+func apiAnnotation(w http.ResponseWriter, r *http.Request) {
+	g := loadGame(w, r)
+	if g == nil {
+		return
+	}
+	an, err := annotations.Annotate(g)
+	if err != nil {
+		log.Printf("Annotating game %s: %v", g.ID, err)
+		http.Error(w, "the game could not be annotated", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(an)
+}
+
+func apiNewUser(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "bad request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	user, token, err := users.Create(req.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("User %s (%s) created", user.ID, user.Name)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"id": user.ID, "name": user.Name, "token": token})
+}
+
+func apiMe(w http.ResponseWriter, r *http.Request) {
+	user := users.Find(bearerToken(r))
+	if user == nil {
+		http.Error(w, "no such player; send the token from POST /api/users", http.StatusUnauthorized)
+		return
+	}
+	rating := users.Rating(user.ID)
+	rating.Name = user.Name
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rating)
+}
+
+func apiLeaderboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users.Leaderboard())
+}
+
+func apiNewPuzzle(w http.ResponseWriter, r *http.Request) {
+	if puzzles == nil {
+		http.Error(w, "no puzzles; start the server with -puzzles", http.StatusNotFound)
+		return
+	}
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "bad request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	a, err := puzzles.Start(req.ID, users.Find(bearerToken(r)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writePuzzle(w, a.State(), http.StatusCreated)
+}
+
+func apiPuzzle(w http.ResponseWriter, r *http.Request) {
+	if a := findAttempt(w, r); a != nil {
+		writePuzzle(w, a.State(), http.StatusOK)
+	}
+}
+
+func apiPuzzleMove(w http.ResponseWriter, r *http.Request) {
+	var msg Move
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		http.Error(w, "bad request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if a := findAttempt(w, r); a != nil {
+		writePuzzle(w, a.Play(msg), http.StatusOK)
+	}
+}
+
+func apiPuzzleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users.PuzzleLeaderboard())
+}
+
+// findAttempt returns the puzzle attempt named in the request's path, or
+// answers 404 and returns nil.
+func findAttempt(w http.ResponseWriter, r *http.Request) *Attempt {
+	var a *Attempt
+	if puzzles != nil {
+		a = puzzles.Get(r.PathValue("attempt"))
+	}
+	if a == nil {
+		http.Error(w, "no such puzzle attempt", http.StatusNotFound)
+	}
+	return a
+}
+
+// writePuzzle answers with state, as a failure if it carries an error.
+func writePuzzle(w http.ResponseWriter, state PuzzleState, status int) {
+	if state.Error != "" {
+		status = http.StatusBadRequest
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(state)
+}
+
+// bearerToken returns the token of the request's Authorization header,
+// or "".
+func bearerToken(r *http.Request) string {
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return strings.TrimSpace(token)
+}
+
+// joinGame joins the session named in the request's path, or answers 404
+// and returns nil. A joined session must be released.
+func joinGame(w http.ResponseWriter, r *http.Request) *Session {
+	sess := sessions.Join(r.PathValue("id"))
+	if sess == nil {
+		http.Error(w, "no such game", http.StatusNotFound)
+	}
+	return sess
+}
+
+// loadGame returns the kept game named in the request's path, or answers
+// 404 and returns nil. Its session must not be sent back.
+func loadGame(w http.ResponseWriter, r *http.Request) *StoredGame {
+	g, err := sessions.store.Load(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "no such game", http.StatusNotFound)
+		return nil
+	}
+	return g
+}
+
+// writePGN answers with rec, unless the game couldn't be replayed.
+func writePGN(w http.ResponseWriter, rec *match.GameRecorder, err error) {
+	if err != nil {
+		log.Printf("Recording game: %v", err)
+		http.Error(w, "the game could not be replayed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-chess-pgn")
+	rec.WritePGN(w)
+}
+
+// writeState answers with state, as a failure if it carries an error.
+func writeState(w http.ResponseWriter, state State, status int) {
+	if state.Error != "" {
+		status = http.StatusBadRequest
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(state)
+}
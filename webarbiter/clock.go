@@ -0,0 +1,165 @@
+package webarbiter
+
+import (
+	"fmt"
+	"time"
+
+	"chessTomorrow/match"
+	"github.com/notnil/chess"
+)
+
+// clockPresets are the time controls offered on the page by name; any
+// other time control can be given as match.ParseTimeControl reads it.
+var clockPresets = map[string]string{
+	"bullet": "1:00+0",
+	"blitz":  "3:00+2",
+	"rapid":  "10:00+5",
+	"long":   "30:00+20",
+}
+
+// flagMargin absorbs the network delay of a move before a flag falls.
+const flagMargin = 300 * time.Millisecond
+
+// Clock is a game's chess clock, running for one side at a time. A nil
+// Clock is an untimed game; its methods do nothing.
+type Clock struct {
+	clock   *match.Clock // both sides' time as of started
+	turn    chess.Color  // whose clock runs, NoColor once stopped
+	started time.Time
+}
+
+// ClockState is a clock as the page shows it.
+type ClockState struct {
+	White   int64  `json:"white"` // milliseconds left
+	Black   int64  `json:"black"`
+	Running string `json:"running,omitempty"` // "white" or "black", counting down
+}
+
+// newClock parses a preset name or time control and starts the clock for
+// turn. An empty string is an untimed game.
+func newClock(s string, turn chess.Color, now time.Time) (*Clock, error) {
+	if preset, ok := clockPresets[s]; ok {
+		s = preset
+	}
+	tc, err := match.ParseTimeControl(s)
+	if err != nil || tc.IsZero() {
+		return nil, err
+	}
+	if tc.MoveTime > 0 {
+		return nil, fmt.Errorf("time control %q: no fixed move times against a person", s)
+	}
+	tc.Margin = flagMargin
+	return &Clock{clock: match.NewClock(tc), turn: turn, started: now}, nil
+}
+
+// resumeClock starts a saved clock again for turn.
+func resumeClock(saved *savedClock, turn chess.Color, now time.Time) *Clock {
+	tc := saved.TimeControl
+	tc.Margin = flagMargin
+	c := &Clock{clock: match.NewClock(tc), turn: turn, started: now}
+	c.clock.Set(chess.White, saved.White, saved.Moves[0])
+	c.clock.Set(chess.Black, saved.Black, saved.Moves[1])
+	return c
+}
+
+// saved returns the clock as it stands at now, to resume later.
+func (c *Clock) saved(now time.Time) *savedClock {
+	tc := c.clock.TimeControl()
+	tc.Margin = 0
+	return &savedClock{
+		TimeControl: tc,
+		White:       max(c.left(chess.White, now), 0),
+		Black:       max(c.left(chess.Black, now), 0),
+		Moves:       [2]int{c.clock.Moves(chess.White), c.clock.Moves(chess.Black)},
+	}
+}
+
+// left returns side's time at now.
+func (c *Clock) left(side chess.Color, now time.Time) time.Duration {
+	left, _ := c.clock.Remaining(side)
+	if side == c.turn {
+		left -= now.Sub(c.started)
+	}
+	return left
+}
+
+// flagged returns the side whose time has run out, or NoColor.
+func (c *Clock) flagged(now time.Time) chess.Color {
+	if c == nil || c.turn == chess.NoColor || c.left(c.turn, now) >= -flagMargin {
+		return chess.NoColor
+	}
+	return c.turn
+}
+
+// punch ends the running side's move at now, adds its increment and starts
+// the other side's clock. It reports false, and stops, if the side's flag
+// fell first.
+func (c *Clock) punch(now time.Time) bool {
+	if c == nil || c.turn == chess.NoColor {
+		return true
+	}
+	if c.flagged(now) != chess.NoColor {
+		c.stop(now)
+		return false
+	}
+	side := c.turn
+	c.clock.Spend(side, now.Sub(c.started))
+	c.turn, c.started = side.Other(), now
+	return true
+}
+
+// restart runs turn's clock from now, as after a take-back, without
+// charging the time spent so far to anyone.
+func (c *Clock) restart(turn chess.Color, now time.Time) {
+	if c == nil {
+		return
+	}
+	c.stop(now)
+	c.turn, c.started = turn, now
+}
+
+// stop charges the running side's time up to now and stops the clock.
+func (c *Clock) stop(now time.Time) {
+	if c == nil || c.turn == chess.NoColor {
+		return
+	}
+	c.clock.Charge(c.turn, now.Sub(c.started))
+	c.turn = chess.NoColor
+}
+
+// goCommand returns the UCI command that searches with the clock, within
+// the limits of level other than its move time.
+func (c *Clock) goCommand(level Level, now time.Time) string {
+	clock := c.clock.State(c.turn)
+	cmd := fmt.Sprintf("go wtime %d btime %d winc %d binc %d",
+		c.left(chess.White, now).Milliseconds(), c.left(chess.Black, now).Milliseconds(),
+		clock.WhiteInc.Milliseconds(), clock.BlackInc.Milliseconds())
+	if clock.MovesToGo > 0 {
+		cmd += fmt.Sprintf(" movestogo %d", clock.MovesToGo)
+	}
+	if level.Nodes > 0 {
+		cmd += fmt.Sprintf(" nodes %d", level.Nodes)
+	}
+	if level.Depth > 0 {
+		cmd += fmt.Sprintf(" depth %d", level.Depth)
+	}
+	return cmd
+}
+
+// state returns the clock for the page, or nil for an untimed game.
+func (c *Clock) state(now time.Time) *ClockState {
+	if c == nil {
+		return nil
+	}
+	s := &ClockState{
+		White: max(c.left(chess.White, now), 0).Milliseconds(),
+		Black: max(c.left(chess.Black, now), 0).Milliseconds(),
+	}
+	switch c.turn {
+	case chess.White:
+		s.Running = "white"
+	case chess.Black:
+		s.Running = "black"
+	}
+	return s
+}
@@ -0,0 +1,143 @@
+package webarbiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+var clockStart = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func mustClock(t *testing.T, s string) *Clock {
+	t.Helper()
+	c, err := newClock(s, chess.White, clockStart)
+	if err != nil || c == nil {
+		t.Fatalf("newClock(%q) = %v, %v", s, c, err)
+	}
+	return c
+}
+
+func TestNewClock(t *testing.T) {
+	if c, err := newClock("", chess.White, clockStart); c != nil || err != nil {
+		t.Errorf("newClock(\"\") = %v, %v, want an untimed game", c, err)
+	}
+	if c := mustClock(t, "blitz"); c.left(chess.White, clockStart) != 3*time.Minute {
+		t.Errorf("blitz starts with %v, want 3m", c.left(chess.White, clockStart))
+	}
+	for _, s := range []string{"abc", "0"} {
+		if _, err := newClock(s, chess.White, clockStart); err == nil {
+			t.Errorf("newClock(%q) took a bad time control", s)
+		}
+	}
+}
+
+func TestClockPunch(t *testing.T) {
+	c := mustClock(t, "1:00+2")
+	now := clockStart.Add(10 * time.Second)
+	if !c.punch(now) {
+		t.Fatal("White's flag fell with time left")
+	}
+	if got := c.left(chess.White, now); got != 52*time.Second {
+		t.Errorf("White has %v after 10s of 1:00+2, want 52s", got)
+	}
+	if c.turn != chess.Black {
+		t.Errorf("%v's clock runs after White moved, want Black's", c.turn)
+	}
+	if got := c.left(chess.Black, now.Add(5*time.Second)); got != 55*time.Second {
+		t.Errorf("Black has %v after thinking 5s, want 55s", got)
+	}
+
+	state := c.state(now.Add(5 * time.Second))
+	if state.White != 52000 || state.Black != 55000 || state.Running != "black" {
+		t.Errorf("state %+v, want White 52000, Black 55000, Black running", *state)
+	}
+
+	// A nil Clock is an untimed game.
+	var untimed *Clock
+	if !untimed.punch(now) || untimed.flagged(now) != chess.NoColor || untimed.state(now) != nil {
+		t.Error("an untimed game has a clock")
+	}
+}
+
+func TestClockFlagged(t *testing.T) {
+	c := mustClock(t, "1:00+2")
+	if side := c.flagged(clockStart.Add(time.Minute + flagMargin)); side != chess.NoColor {
+		t.Errorf("%v flagged within the margin", side)
+	}
+	// A move played within the margin leaves only the increment.
+	late := clockStart.Add(time.Minute + flagMargin/2)
+	if !c.punch(late) {
+		t.Fatal("White's flag fell within the margin")
+	}
+	if got := c.left(chess.White, late); got != 2*time.Second {
+		t.Errorf("White has %v after moving within the margin, want 2s", got)
+	}
+
+	over := late.Add(time.Minute + flagMargin + time.Millisecond)
+	if side := c.flagged(over); side != chess.Black {
+		t.Errorf("flagged %v beyond the margin, want Black", side)
+	}
+	if c.punch(over) {
+		t.Error("Black moved after the flag fell")
+	}
+	if c.turn != chess.NoColor {
+		t.Error("the clock runs on after a flag fell")
+	}
+	if got := c.left(chess.Black, over); got != 0 {
+		t.Errorf("Black has %v after the flag fell, want none", got)
+	}
+	if side := c.flagged(over.Add(time.Hour)); side != chess.NoColor {
+		t.Errorf("%v flagged on a stopped clock", side)
+	}
+}
+
+func TestClockRepeating(t *testing.T) {
+	c := mustClock(t, "2/1:00")
+	now := clockStart
+	want := "go wtime 60000 btime 60000 winc 0 binc 0 movestogo 2"
+	if got := c.goCommand(Level{}, now); got != want {
+		t.Errorf("goCommand = %q at the start, want %q", got, want)
+	}
+	for range 4 {
+		now = now.Add(10 * time.Second)
+		c.punch(now)
+	}
+	// White and Black have each made the control of two moves and had the
+	// base time again.
+	if got := c.left(chess.White, now); got != 100*time.Second {
+		t.Errorf("White has %v after the control, want 1:40", got)
+	}
+	want = "go wtime 100000 btime 100000 winc 0 binc 0 movestogo 2 depth 5"
+	if got := c.goCommand(Level{Depth: 5}, now); got != want {
+		t.Errorf("goCommand = %q, want %q", got, want)
+	}
+}
+
+func TestClockStopResume(t *testing.T) {
+	c := mustClock(t, "1:00+1")
+	c.punch(clockStart.Add(5 * time.Second))
+	now := clockStart.Add(15 * time.Second)
+	c.restart(chess.White, now)
+	if got := c.left(chess.Black, now); got != 50*time.Second {
+		t.Errorf("Black has %v after a take-back, want 50s", got)
+	}
+
+	saved := c.saved(now.Add(20 * time.Second))
+	if saved.White != 36*time.Second || saved.Black != 50*time.Second || saved.Moves != [2]int{1, 0} {
+		t.Errorf("saved %+v, want 36s and 50s with one move by White", *saved)
+	}
+	r := resumeClock(saved, chess.White, now)
+	if got := r.left(chess.White, now); got != 36*time.Second {
+		t.Errorf("resumed White with %v, want 36s", got)
+	}
+	r.punch(now.Add(time.Second))
+	if got := r.left(chess.White, now); got != 36*time.Second {
+		t.Errorf("White has %v after a resumed move, want 36s", got)
+	}
+
+	r.stop(now.Add(time.Minute))
+	if r.turn != chess.NoColor || r.left(chess.Black, now.Add(time.Hour)) != 0 {
+		t.Errorf("stopped clock runs on: %+v", *r.state(now))
+	}
+}
@@ -0,0 +1,99 @@
+package webarbiter
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"chessTomorrow/board"
+	"chessTomorrow/render"
+	"github.com/notnil/chess"
+)
+
+// serveDiagram draws a position for /board.svg and /board.png, so pages
+// can embed it with an img tag. The query parameters are all optional:
+//
+//	fen       the position
+//	session   without fen, draw this session's game; the start position
+//	          if neither is given
+//	flip      any value draws Black at the bottom
+//	theme     brown, green or blue
+//	size      pixels per square
+//	lastmove  a UCI move whose squares are highlighted
+//	arrows    comma-separated UCI moves drawn as arrows
+func serveDiagram(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	pos := chess.NewGame().Position()
+	if sess := sessions.Get(q.Get("session")); sess != nil {
+		sess.mu.Lock()
+		pos = sess.game.Position()
+		sess.mu.Unlock()
+	}
+	if fen := q.Get("fen"); fen != "" {
+		var err error
+		if pos, err = board.ParseFEN(fen, board.LenientFEN); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	opts := render.Options{Coordinates: true, Flipped: q.Has("flip")}
+	if name := q.Get("theme"); name != "" {
+		theme, ok := render.Themes[name]
+		if !ok {
+			http.Error(w, "unknown theme "+name, http.StatusBadRequest)
+			return
+		}
+		opts.Theme = &theme
+	}
+	if size := q.Get("size"); size != "" {
+		if _, err := fmt.Sscan(size, &opts.SquareSize); err != nil || opts.SquareSize > 200 {
+			http.Error(w, "bad size "+size, http.StatusBadRequest)
+			return
+		}
+	}
+	if s := q.Get("lastmove"); s != "" {
+		from, to, ok := squares(s)
+		if !ok {
+			http.Error(w, "bad move "+s, http.StatusBadRequest)
+			return
+		}
+		opts.Highlight = []chess.Square{from, to}
+	}
+	if list := q.Get("arrows"); list != "" {
+		for _, s := range strings.Split(list, ",") {
+			from, to, ok := squares(s)
+			if !ok {
+				http.Error(w, "bad arrow "+s, http.StatusBadRequest)
+				return
+			}
+			opts.Arrows = append(opts.Arrows, render.Arrow{From: from, To: to})
+		}
+	}
+
+	if strings.HasSuffix(r.URL.Path, ".png") {
+		w.Header().Set("Content-Type", "image/png")
+		render.PNG(w, pos.Board(), opts)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Write([]byte(render.SVG(pos.Board(), opts)))
+}
+
+// squares returns the squares of a UCI move such as "e2e4".
+func squares(move string) (from, to chess.Square, ok bool) {
+	if len(move) < 4 {
+		return 0, 0, false
+	}
+	from, ok1 := square(move[0:2])
+	to, ok2 := square(move[2:4])
+	return from, to, ok1 && ok2
+}
+
+func square(name string) (chess.Square, bool) {
+	file, rank := name[0], name[1]
+	if file < 'a' || file > 'h' || rank < '1' || rank > '8' {
+		return 0, false
+	}
+	return chess.NewSquare(chess.File(file-'a'), chess.Rank(rank-'1')), true
+}
@@ -0,0 +1,36 @@
+package webarbiter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"chessTomorrow/arbiter"
+)
+
+// moveTimeout bounds the wait for "bestmove" once the engine's thinking
+// time is up.
+const moveTimeout = 5 * time.Second
+
+// bestMove asks engine for a move in the position with the given "go"
+// command, restarting the engine first if it has died or ignored a
+// "stop". An engine that doesn't answer within moveTimeout of its
+// thinking time being up is stopped. If ctx is done first the search is
+// stopped, and bestMove returns within about a second even if the engine
+// ignores the stop.
+func bestMove(ctx context.Context, engine *arbiter.UCIEngineAdapter, fen, goCmd string, thinking time.Duration) (string, error) {
+	if engine.Broken() {
+		log.Println("Engine has exited or stopped answering, restarting it")
+		if err := engine.Restart(); err != nil {
+			return "", err
+		}
+	}
+	timeout, cancel := context.WithTimeout(ctx, moveTimeout+thinking)
+	defer cancel()
+	move, err := engine.BestMove(timeout, "position fen "+fen, goCmd)
+	if err != nil && ctx.Err() == nil && timeout.Err() != nil {
+		return "", fmt.Errorf("no bestmove from the engine after %v", moveTimeout+thinking)
+	}
+	return move, err
+}
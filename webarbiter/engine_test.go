@@ -0,0 +1,87 @@
+package webarbiter
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"chessTomorrow/arbiter"
+	"github.com/notnil/chess"
+)
+
+// fakeEngineEnv makes the test binary a UCI engine, as fakeEngine
+// describes, for the tests that need an engine process.
+const fakeEngineEnv = "WEBARBITER_FAKE_ENGINE"
+
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(fakeEngineEnv); mode != "" {
+		fakeEngine(mode)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// fakeEngine answers the UCI handshake and plays e2e4 at once. In the
+// "deaf" mode it never moves, and ignores "stop".
+func fakeEngine(mode string) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		switch fields := strings.Fields(scanner.Text()); {
+		case len(fields) == 0:
+		case fields[0] == "uci":
+			fmt.Println("id name fake")
+			fmt.Println("uciok")
+		case fields[0] == "isready":
+			fmt.Println("readyok")
+		case fields[0] == "go" && mode != "deaf":
+			fmt.Println("bestmove e2e4")
+		case fields[0] == "quit":
+			return
+		}
+	}
+}
+
+// startFakeEngine starts the test binary as an engine in mode.
+func startFakeEngine(t *testing.T, mode string) *arbiter.UCIEngineAdapter {
+	t.Helper()
+	t.Setenv(fakeEngineEnv, mode)
+	e, err := arbiter.EngineSpec{Name: "fake", Command: os.Args[0]}.Start(arbiter.Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// TestCancelDeafEngine checks that dropping the search of an engine that
+// ignores "stop" doesn't hold the session up, and that the engine works
+// again for the next search.
+func TestCancelDeafEngine(t *testing.T) {
+	sessions = &Sessions{}
+	defer func() { sessions = nil }()
+	e := startFakeEngine(t, "deaf")
+	defer e.Close()
+
+	s := &Session{ID: "test", engine: e, game: chess.NewGame(), human: chess.Black,
+		conf: &EngineConfig{EngineSpec: arbiter.EngineSpec{Name: "fake"}}}
+	s.mu.Lock()
+	s.think()
+	start := time.Now()
+	s.cancel()
+	s.mu.Unlock()
+	// The adapter gives an engine a second to stop
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("cancel took %v with an engine ignoring stop", elapsed)
+	}
+	if !e.Broken() {
+		t.Fatal("an engine ignoring stop is trusted with another search")
+	}
+
+	// It is started again for the next search
+	t.Setenv(fakeEngineEnv, "ok")
+	if move, err := bestMove(t.Context(), e, chess.StartingPosition().String(), "go nodes 1", 0); err != nil || move != "e2e4" {
+		t.Errorf("bestMove after the engine ignored stop = %q, %v", move, err)
+	}
+}
@@ -0,0 +1,599 @@
+package webarbiter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"chessTomorrow/arbiter"
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+)
+
+// drawMoves and drawMargin decide a draw offer: the engine agrees once
+// this many moves have been played with material within drawMargin
+// centipawns, or whenever a draw could be claimed anyway.
+const (
+	drawMoves  = 30
+	drawMargin = 100
+)
+
+// pieceValues count material for draw offers.
+var pieceValues = map[chess.PieceType]int{
+	chess.Pawn: 100, chess.Knight: 300, chess.Bishop: 300, chess.Rook: 500, chess.Queen: 900,
+}
+
+// handle carries out one message from the frontend and returns the game as
+// it then stands. If the message can't be carried out the state says why.
+func (s *Session) handle(msg Move) State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.save()
+	defer s.rate()
+
+	// A flag can fall between messages
+	if side := s.clock.flagged(time.Now()); side != chess.NoColor && !s.over() {
+		s.timeout = side
+		s.cancel()
+	}
+
+	switch msg.Type {
+	case "", "move":
+		return s.play(msg)
+	case "stop":
+		if s.thinking == nil {
+			return s.errorState("The engine is not thinking")
+		}
+		// The engine answers with the best move it has found
+		s.engine.Send("stop")
+		return s.state()
+	case "new":
+		s.cancel()
+		engine, level := msg.Engine, msg.Level
+		if engine == "" {
+			engine = s.conf.Name
+			if level == "" {
+				level = s.level.Name
+			}
+		}
+		// Keep what was played of a game given up for the new one
+		abandoned := s.abandoned()
+		if err := s.setEngine(sessions.registry, engine, level); err != nil {
+			log.Printf("Session %s: %v", s.ID, err)
+			return s.errorState("Cannot play that engine: " + err.Error())
+		}
+		state := s.newGame(msg.FEN, msg.Setup, msg.Color, msg.Clock)
+		if abandoned != nil && abandoned.ID != s.gameID {
+			if err := sessions.store.Save(abandoned); err != nil {
+				log.Printf("Session %s: %v", s.ID, err)
+			}
+		}
+		return state
+	case "undo":
+		return s.undo()
+	case "time":
+		// The page's clock ran out; the flag was checked above
+		return s.state()
+	case "resign":
+		if s.over() {
+			return s.errorState("The game is over")
+		}
+		s.cancel()
+		s.game.Resign(s.human)
+		return s.state()
+	case "draw":
+		return s.offerDraw()
+	}
+	return s.errorState("Unknown message type " + msg.Type)
+}
+
+// play makes the human's move and sets the engine thinking about its
+// reply.
+func (s *Session) play(move Move) State {
+	if s.over() {
+		return s.errorState("The game is over")
+	}
+	if s.game.Position().Turn() != s.human {
+		return s.errorState("It is the engine's move")
+	}
+
+	// Construct the move string like "e2e4" or "e7e8q"
+	moveStr, err := uciMove(move)
+	if err != nil {
+		return s.errorState("Invalid promotion: " + err.Error())
+	}
+
+	// Decode the human move from UCI notation
+	mv, err := board.UCIToMove(s.game.Position(), moveStr)
+	if err != nil {
+		// Invalid move, inform the frontend
+		log.Printf("Invalid move from human: %v", err)
+		if _, promo := board.UCIToMove(s.game.Position(), moveStr+"q"); promo == nil {
+			return s.errorState("Choose a piece to promote to")
+		}
+		if len(moveStr) == 5 {
+			if _, plain := board.UCIToMove(s.game.Position(), moveStr[:4]); plain == nil {
+				return s.errorState("Only a pawn reaching the last rank promotes")
+			}
+		}
+		return s.errorState("Invalid move, please try again")
+	}
+
+	// Apply the human's valid move
+	if err := s.game.Move(mv); err != nil {
+		// If the move is somehow invalid, again send the error message
+		log.Printf("Illegal move played: %v", err)
+		return s.errorState("Illegal move, please try again")
+	}
+
+	s.clock.punch(time.Now())
+
+	// A move that ends the game gets no reply
+	if !s.over() {
+		s.think()
+	}
+	return s.state()
+}
+
+// uciMove returns the player's move in UCI. The promotion piece can be
+// given in Promotion or, as older pages did, on the end of To.
+func uciMove(move Move) (string, error) {
+	s := move.From + move.To
+	if move.Promotion == "" || len(s) < 4 {
+		return s, nil
+	}
+	promo := strings.ToLower(move.Promotion)
+	if len(promo) != 1 || !strings.Contains("qrbn", promo) {
+		return "", fmt.Errorf("cannot promote to %q; choose q, r, b or n", move.Promotion)
+	}
+	if len(s) == 5 && s[4:] != promo {
+		return "", fmt.Errorf("%s promotes to %s, not %s", s, s[4:], promo)
+	}
+	return s[:4] + promo, nil
+}
+
+// search is the engine thinking about its move in the background.
+type search struct {
+	stop    context.CancelFunc
+	done    chan struct{} // closed once the engine has answered, or given up
+	applied chan struct{} // closed once the answer is played, or dropped
+	state   State         // the game after the engine's move, set before applied is closed
+}
+
+// think starts the engine searching for its move. When it answers, the
+// move is played and the game sent to every page watching the session.
+// An engine that fails has the player's move taken back; one that runs out
+// of time loses the game.
+func (s *Session) think() {
+	goCmd, thinking := s.level.goCommand(), s.level.moveTime()
+	if s.clock != nil {
+		now := time.Now()
+		goCmd = s.clock.goCommand(s.level, now)
+		thinking = s.clock.left(s.human.Other(), now)
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	t := &search{stop: stop, done: make(chan struct{}), applied: make(chan struct{})}
+	s.thinking = t
+	engine, fen := s.engine, s.game.Position().String()
+
+	go func() {
+		defer close(t.applied)
+		defer stop()
+		bestMove, err := bestMove(ctx, engine, fen, goCmd, thinking)
+		close(t.done)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.thinking != t {
+			// Cancelled; the game has moved on
+			return
+		}
+		s.thinking = nil
+		t.state = s.reply(bestMove, err)
+		s.rate()
+		s.save()
+		s.publish(t.state)
+	}()
+}
+
+// reply plays the engine's answer from its search and returns the game as
+// it then stands.
+func (s *Session) reply(bestMove string, err error) State {
+	if !s.clock.punch(time.Now()) {
+		s.timeout = s.human.Other()
+		return s.state()
+	}
+	var mv *chess.Move
+	if err == nil {
+		mv, err = board.UCIToMove(s.game.Position(), bestMove)
+	}
+	if err == nil {
+		err = s.game.Move(mv)
+	}
+	if err != nil {
+		log.Printf("Engine failed: %v", err)
+		if len(s.game.Moves()) == 0 {
+			return s.errorState("The engine failed to make its first move, please start again")
+		}
+		// Take the human move back so it can be played again
+		s.game = truncate(s.game, len(s.game.Moves())-1)
+		return s.errorState("The engine failed to answer, please play your move again")
+	}
+	state := s.state()
+	state.Move = bestMove
+	return state
+}
+
+// cancel drops the engine's search, if there is one, once the engine has
+// stopped. An engine that ignores "stop" holds it up for no more than
+// about a second, and is restarted for the next search.
+func (s *Session) cancel() {
+	t := s.thinking
+	if t == nil {
+		return
+	}
+	s.thinking = nil
+	t.stop()
+	<-t.done
+}
+
+// wait returns the game once the engine has made the move it is thinking
+// about, if it is thinking.
+func (s *Session) wait() State {
+	s.mu.Lock()
+	t := s.thinking
+	if t == nil {
+		defer s.mu.Unlock()
+		return s.state()
+	}
+	s.mu.Unlock()
+
+	<-t.applied
+	if t.state.FEN != "" {
+		return t.state
+	}
+	// Dropped for a later message; show where that left the game
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state()
+}
+
+// watch returns a channel that gets the game whenever the engine moves,
+// until it is passed to unwatch.
+func (s *Session) watch() chan State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch := make(chan State, 8)
+	if s.watchers == nil {
+		s.watchers = make(map[chan State]bool)
+	}
+	s.watchers[ch] = true
+	return ch
+}
+
+func (s *Session) unwatch(ch chan State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.watchers, ch)
+}
+
+// publish sends state to every watcher. One that is still behind with
+// earlier states misses it, and catches up with the next.
+func (s *Session) publish(state State) {
+	for ch := range s.watchers {
+		select {
+		case ch <- state:
+		default:
+		}
+	}
+}
+
+// setEngine switches to the named engine and level, starting the engine if
+// it isn't the one already running. Empty names pick the first of each.
+func (s *Session) setEngine(r *Registry, name, level string) error {
+	conf, l, err := r.find(name, level)
+	if err != nil {
+		return err
+	}
+	if conf != s.conf {
+		engine, err := pool.Get(conf)
+		if err != nil {
+			return err
+		}
+		if s.engine != nil {
+			pool.Put(s.conf, s.engine)
+		}
+		s.engine, s.conf, s.level = engine, conf, Level{}
+	}
+
+	switch {
+	case l.Elo > 0:
+		if err := s.engine.SetOption("UCI_LimitStrength", "true"); err != nil {
+			return err
+		}
+		if err := s.engine.SetOption("UCI_Elo", strconv.Itoa(l.Elo)); err != nil {
+			return err
+		}
+	case s.level.Elo > 0:
+		if err := s.engine.SetOption("UCI_LimitStrength", "false"); err != nil {
+			return err
+		}
+	}
+	s.level = l
+	return nil
+}
+
+// startEngine starts the engine of a registry entry with its options set.
+func startEngine(conf *EngineConfig) (*arbiter.UCIEngineAdapter, error) {
+	return conf.EngineSpec.Start(arbiter.Limits{})
+}
+
+// newGame starts a game from the board editor's setup, or fen, or the
+// start position if both are empty, with the human playing color
+// ("white", "black", or white if empty) on clock, a preset or time
+// control; an empty clock is an untimed game. If the engine is to move it
+// starts thinking at once.
+func (s *Session) newGame(fen string, setup *BoardSetup, color, clock string) State {
+	human := chess.White
+	switch color {
+	case "", "white":
+	case "black":
+		human = chess.Black
+	default:
+		return s.errorState("Unknown color " + color)
+	}
+
+	pos, notes, err := startPosition(fen, setup)
+	if err != nil {
+		return s.errorState(err.Error())
+	}
+	opt, _ := chess.FEN(pos.String())
+	game := chess.NewGame(opt)
+	c, err := newClock(clock, game.Position().Turn(), time.Now())
+	if err != nil {
+		return s.errorState(err.Error())
+	}
+	s.game, s.human, s.clock, s.timeout = game, human, c, chess.NoColor
+	// Only games from the start position are rated; any other could be
+	// one the player has won already
+	s.gameID, s.started, s.unrated = newID(), time.Now(), fen != "" || setup != nil
+	s.engine.Send("ucinewgame")
+
+	if game.Outcome() == chess.NoOutcome && game.Position().Turn() != human {
+		s.think()
+	}
+	state := s.state()
+	state.Notes = notes
+	return state
+}
+
+// undo takes back the human's last move and the engine's reply to it.
+func (s *Session) undo() State {
+	switch s.game.Method() {
+	case chess.Resignation, chess.DrawOffer:
+		return s.errorState("The game is over")
+	}
+	if s.timeout != chess.NoColor {
+		return s.errorState("The game is over")
+	}
+	s.cancel()
+
+	// Back to the last position where the human had moved already and
+	// is to move again
+	n := len(s.game.Moves())
+	if s.game.Position().Turn() == s.human {
+		n -= 2
+	} else {
+		n--
+	}
+	if n < 0 {
+		return s.errorState("There is no move to take back")
+	}
+	s.game = truncate(s.game, n)
+	s.clock.restart(s.game.Position().Turn(), time.Now())
+	// A game with moves taken back is played for fun
+	s.unrated = true
+	return s.state()
+}
+
+// offerDraw ends the game drawn if the engine accepts the human's offer.
+// UCI has no draw offers, so the server judges for the engine.
+func (s *Session) offerDraw() State {
+	if s.over() {
+		return s.errorState("The game is over")
+	}
+
+	if len(s.game.EligibleDraws()) > 1 {
+		// A repetition or the fifty-move rule; claim it
+		s.cancel()
+		s.game.Draw(s.game.EligibleDraws()[1])
+		return s.state()
+	}
+	if len(s.game.Moves())/2 >= drawMoves && abs(material(s.game.Position())) <= drawMargin {
+		s.cancel()
+		s.game.Draw(chess.DrawOffer)
+		return s.state()
+	}
+	return s.errorState("The engine declines the draw")
+}
+
+// timeoutResult is the result when loser's flag falls: a loss, unless the
+// other side couldn't possibly mate.
+func timeoutResult(pos *chess.Position, loser chess.Color) (result, reason string) {
+	if !canMate(pos, loser.Other()) {
+		return chess.Draw.String(), "Timeout vs insufficient material"
+	}
+	if loser == chess.White {
+		return chess.BlackWon.String(), "Timeout"
+	}
+	return chess.WhiteWon.String(), "Timeout"
+}
+
+// canMate reports whether side has more than a bare king or a king and a
+// single minor piece.
+func canMate(pos *chess.Position, side chess.Color) bool {
+	minors := 0
+	for _, p := range pos.Board().SquareMap() {
+		if p.Color() != side {
+			continue
+		}
+		switch p.Type() {
+		case chess.King:
+		case chess.Knight, chess.Bishop:
+			minors++
+		default:
+			return true
+		}
+	}
+	return minors > 1
+}
+
+// material returns White's material less Black's, in centipawns.
+func material(pos *chess.Position) int {
+	total := 0
+	for _, p := range pos.Board().SquareMap() {
+		v := pieceValues[p.Type()]
+		if p.Color() == chess.Black {
+			v = -v
+		}
+		total += v
+	}
+	return total
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// truncate returns g with only its first n moves, from the same start.
+func truncate(g *chess.Game, n int) *chess.Game {
+	opt, _ := chess.FEN(g.Positions()[0].String())
+	prev := chess.NewGame(opt)
+	for _, mv := range g.Moves()[:n] {
+		prev.Move(mv)
+	}
+	return prev
+}
+
+// over reports whether the game has ended, on the board or the clock.
+func (s *Session) over() bool {
+	return s.game.Outcome() != chess.NoOutcome || s.timeout != chess.NoColor
+}
+
+// state describes the session's game. A game that is over has its clock
+// stopped.
+func (s *Session) state() State {
+	now := time.Now()
+	if s.over() {
+		s.clock.stop(now)
+	}
+	state := newState(s.game)
+	if s.timeout != chess.NoColor {
+		state.Legal = []string{}
+		state.Result, state.Reason = timeoutResult(s.game.Position(), s.timeout)
+		state.Draw = state.Result == chess.Draw.String()
+	}
+	state.Clock = s.clock.state(now)
+	state.Session = s.ID
+	state.Color = strings.ToLower(s.human.Name())
+	state.Engine, state.Level = s.conf.Name, s.level.Name
+	state.Thinking = s.thinking != nil
+	if sessions.store != nil {
+		state.Game = s.gameID
+	}
+	return state
+}
+
+// stored returns the game for the store, as of now.
+func (s *Session) stored(now time.Time) *StoredGame {
+	state := s.state()
+	g := &StoredGame{GameInfo: GameInfo{
+		ID:       s.gameID,
+		Human:    state.Color,
+		Engine:   state.Engine,
+		Level:    state.Level,
+		StartFEN: s.game.Positions()[0].String(),
+		Moves:    state.Moves,
+		Result:   state.Result,
+		Reason:   state.Reason,
+		Started:  s.started,
+		Updated:  now,
+	}, Session: s.ID}
+	if s.user != nil {
+		g.Player, g.PlayerName = s.user.ID, s.user.Name
+	}
+	if s.clock != nil {
+		g.Clock = s.clock.saved(now)
+	}
+	return g
+}
+
+// save keeps the game in the store once it has a move or is over, unless
+// the store has it as it stands already.
+func (s *Session) save() {
+	if sessions.store == nil || len(s.game.Moves()) == 0 && !s.over() {
+		return
+	}
+	g := s.stored(time.Now())
+	saved := strings.Join(g.Moves, " ") + " " + g.Result
+	if saved == s.saved {
+		return
+	}
+	if err := sessions.store.Save(g); err != nil {
+		log.Printf("Session %s: %v", s.ID, err)
+		return
+	}
+	s.saved = saved
+}
+
+// identify makes user the session's player, if it has none yet. A game
+// that is over by then doesn't count for their rating.
+func (s *Session) identify(user *User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if user == nil || s.user != nil {
+		return
+	}
+	s.user, s.unrated = user, s.unrated || s.over()
+}
+
+// rate counts the game for the player's and the engine's ratings once it
+// is over, if the player has an identity. A game counts only once.
+func (s *Session) rate() {
+	if s.unrated || !s.over() || s.user == nil {
+		return
+	}
+	s.unrated = true
+	white, black := s.user.player(), enginePlayer(s.conf, s.level)
+	if s.human == chess.Black {
+		white, black = black, white
+	}
+	if err := users.Rate(white, black, s.state().Result); err != nil {
+		log.Printf("Session %s: cannot rate the game: %v", s.ID, err)
+	}
+}
+
+// abandoned returns the game marked as given up, or nil if it hasn't
+// begun or is over.
+func (s *Session) abandoned() *StoredGame {
+	if len(s.game.Moves()) == 0 || s.over() {
+		return nil
+	}
+	g := s.stored(time.Now())
+	g.Result, g.Reason = string(chess.NoOutcome), "Abandoned"
+	return g
+}
+
+// errorState tells the frontend what went wrong, along with the game as
+// it stands, so the page can be put right.
+func (s *Session) errorState(msg string) State {
+	state := s.state()
+	state.Error = msg
+	return state
+}
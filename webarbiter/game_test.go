@@ -0,0 +1,44 @@
+package webarbiter
+
+import (
+	"testing"
+
+	"chessTomorrow/arbiter"
+	"github.com/notnil/chess"
+)
+
+// TestCustomPositionUnrated checks that a game set up as already won
+// counts for nobody's rating.
+func TestCustomPositionUnrated(t *testing.T) {
+	var err error
+	if users, err = LoadUsers(""); err != nil {
+		t.Fatal(err)
+	}
+	sessions = &Sessions{}
+	defer func() { users, sessions = nil, nil }()
+	user, _, err := users.Create("tester")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := startFakeEngine(t, "ok")
+	defer engine.Close()
+	s := &Session{
+		ID:     "test",
+		engine: engine,
+		conf:   &EngineConfig{EngineSpec: arbiter.EngineSpec{Name: "test"}},
+		user:   user,
+	}
+	// Black is mated before a move is played
+	state := s.newGame("7k/6Q1/6K1/8/8/8/8/8 b - - 0 1", nil, "white", "")
+	if state.Error != "" {
+		t.Fatal(state.Error)
+	}
+	if s.game.Outcome() != chess.WhiteWon {
+		t.Fatalf("outcome %s, want White to have won", s.game.Outcome())
+	}
+	s.rate()
+	if board := users.Leaderboard(); len(board) != 0 {
+		t.Errorf("a game from a custom FEN is on the leaderboard: %+v", board)
+	}
+}
@@ -0,0 +1,220 @@
+package webarbiter
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"chessTomorrow/arbiter"
+)
+
+// errPoolFull is Get's error when as many engines are running as the pool
+// allows.
+var errPoolFull = errors.New("too many engines running")
+
+// Pool keeps started engines of every registry entry ready, so that a game
+// doesn't wait for its engine to start, and takes back engines that games
+// are done with. Idle engines are checked every so often, and any that
+// have crashed or stopped answering are replaced. No more than a maximum
+// of engines run at once, counting those handed out.
+type Pool struct {
+	registry *Registry
+	spare    int // idle engines kept ready per registry entry
+	max      int // engines running at once, idle or not; 0 for no limit
+
+	mu     sync.Mutex
+	idle   map[*EngineConfig][]*arbiter.UCIEngineAdapter
+	live   int // engines started and not yet closed, or being started
+	closed bool
+	done   chan struct{}
+}
+
+// NewPool starts spare engines of each of registry's entries, and checks
+// the idle ones every interval. At most max engines run at once, or any
+// number if max is 0.
+func NewPool(registry *Registry, spare, max int, interval time.Duration) *Pool {
+	p := &Pool{
+		registry: registry,
+		spare:    spare,
+		max:      max,
+		idle:     make(map[*EngineConfig][]*arbiter.UCIEngineAdapter),
+		done:     make(chan struct{}),
+	}
+	go func() {
+		p.fill()
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-tick.C:
+				p.check()
+				p.fill()
+			}
+		}
+	}()
+	return p
+}
+
+// Get returns an engine of conf with its options set: an idle one if there
+// is one, otherwise a newly started one. It fails with errPoolFull if
+// there is none idle and no room to start one. The engine must be given
+// back with Put or Drop.
+func (p *Pool) Get(conf *EngineConfig) (*arbiter.UCIEngineAdapter, error) {
+	p.mu.Lock()
+	if n := len(p.idle[conf]); n > 0 {
+		e := p.idle[conf][n-1]
+		p.idle[conf] = p.idle[conf][:n-1]
+		p.mu.Unlock()
+		go p.fill()
+		return e, nil
+	}
+	ok := p.reserve()
+	p.mu.Unlock()
+	if !ok {
+		return nil, errPoolFull
+	}
+	e, err := startEngine(conf)
+	if err != nil {
+		p.release()
+	}
+	return e, err
+}
+
+// reserve counts an engine about to be started, and reports false if
+// there is no room for it. The pool's mutex must be held.
+func (p *Pool) reserve() bool {
+	if p.max > 0 && p.live >= p.max {
+		return false
+	}
+	p.live++
+	return true
+}
+
+// release uncounts an engine that has been closed or failed to start.
+func (p *Pool) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.live--
+}
+
+// Drop closes an engine from Get that is done with, such as one that
+// can't be trusted with another game.
+func (p *Pool) Drop(e *arbiter.UCIEngineAdapter) {
+	e.Close()
+	p.release()
+}
+
+// Put takes back an engine of conf that isn't searching. It is kept for
+// the next game if it answers, still has just conf's options and there
+// aren't enough spares already; otherwise it is closed.
+func (p *Pool) Put(conf *EngineConfig, e *arbiter.UCIEngineAdapter) {
+	if !sameOptions(e, conf) || !healthy(e) {
+		p.Drop(e)
+		return
+	}
+	e.Send("ucinewgame")
+	p.keep(conf, e)
+}
+
+// Close quits every idle engine and stops checking them.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle, p.closed = make(map[*EngineConfig][]*arbiter.UCIEngineAdapter), true
+	p.mu.Unlock()
+	close(p.done)
+	for _, engines := range idle {
+		for _, e := range engines {
+			p.Drop(e)
+		}
+	}
+}
+
+// keep adds e to the idle engines of conf, or closes it if there are
+// enough of those.
+func (p *Pool) keep(conf *EngineConfig, e *arbiter.UCIEngineAdapter) {
+	p.mu.Lock()
+	if p.closed || len(p.idle[conf]) >= p.spare {
+		p.mu.Unlock()
+		p.Drop(e)
+		return
+	}
+	p.idle[conf] = append(p.idle[conf], e)
+	p.mu.Unlock()
+}
+
+// fill starts engines until every registry entry has its spares, or as
+// many engines run as the pool allows.
+func (p *Pool) fill() {
+	for i := range p.registry.Engines {
+		conf := &p.registry.Engines[i]
+		p.mu.Lock()
+		missing := p.spare - len(p.idle[conf])
+		if p.closed {
+			missing = 0
+		}
+		p.mu.Unlock()
+		for ; missing > 0; missing-- {
+			p.mu.Lock()
+			ok := p.reserve()
+			p.mu.Unlock()
+			if !ok {
+				return
+			}
+			e, err := startEngine(conf)
+			if err != nil {
+				p.release()
+				log.Printf("Pool: cannot start %s: %v", conf.Name, err)
+				break
+			}
+			p.keep(conf, e)
+		}
+	}
+}
+
+// check asks every idle engine whether it is ready, closing those that
+// don't answer; fill replaces them.
+func (p *Pool) check() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = make(map[*EngineConfig][]*arbiter.UCIEngineAdapter)
+	p.mu.Unlock()
+
+	for conf, engines := range idle {
+		for _, e := range engines {
+			if healthy(e) {
+				p.keep(conf, e)
+				continue
+			}
+			log.Printf("Pool: %s stopped answering, replacing it", conf.Name)
+			p.Drop(e)
+		}
+	}
+}
+
+// healthy reports whether e is running and answers "isready".
+func healthy(e *arbiter.UCIEngineAdapter) bool {
+	if e.Broken() {
+		return false
+	}
+	e.Send("isready")
+	return e.Expect("readyok") == nil
+}
+
+// sameOptions reports whether the options set on e are just conf's, so
+// that another game gets the engine as conf describes it.
+func sameOptions(e *arbiter.UCIEngineAdapter, conf *EngineConfig) bool {
+	options := e.Options()
+	if len(options) != len(conf.Options) {
+		return false
+	}
+	for _, opt := range options {
+		if value, ok := conf.Options[opt[0]]; !ok || value != opt[1] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,35 @@
+package webarbiter
+
+import (
+	"errors"
+	"testing"
+
+	"chessTomorrow/arbiter"
+)
+
+// TestPoolLimit checks that a full pool refuses to start another engine,
+// and makes room again as engines are closed.
+func TestPoolLimit(t *testing.T) {
+	conf := &EngineConfig{}
+	p := &Pool{max: 2, idle: make(map[*EngineConfig][]*arbiter.UCIEngineAdapter)}
+	p.mu.Lock()
+	for range 2 {
+		if !p.reserve() {
+			t.Fatal("no room in a pool with room")
+		}
+	}
+	p.mu.Unlock()
+	if _, err := p.Get(conf); !errors.Is(err, errPoolFull) {
+		t.Errorf("Get from a full pool: %v, want %v", err, errPoolFull)
+	}
+
+	p.release()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.reserve() {
+		t.Error("no room after an engine was closed")
+	}
+	if p.reserve() {
+		t.Error("room beyond the limit")
+	}
+}
@@ -0,0 +1,516 @@
+package webarbiter
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"chessTomorrow/arbiter"
+	"chessTomorrow/board"
+	"chessTomorrow/epd"
+	"chessTomorrow/ratings"
+	"github.com/notnil/chess"
+)
+
+// A move other than the solution's solves a puzzle if the engine scores
+// it no more than puzzleMargin centipawns below the solution's move, each
+// searched for puzzleCheckTime. Mates count as mateScore less their
+// length.
+const (
+	puzzleMargin    = 100
+	puzzleCheckTime = 500 * time.Millisecond
+	mateScore       = 100000
+)
+
+// puzzleBand is how far from the solver's rating a puzzle picked for them
+// may be rated, if there are any that close.
+const puzzleBand = 200
+
+// Puzzle is a tactics position and the line that solves it.
+type Puzzle struct {
+	ID       string
+	FEN      string         // the position before Setup, or the solver's if there is none
+	Setup    string         // the opponent's move that sets the puzzle, in UCI, if any
+	Solution []string       // UCI: each of the solver's moves, followed by the opponent's reply but for the last
+	Rating   ratings.Glicko // the puzzle's rating when it has no rated tries
+	Themes   []string
+}
+
+// PuzzleSet serves the puzzles of a file. Each try at a puzzle is an
+// Attempt played through the server, which checks the solver's moves
+// against the solution and has an engine judge other moves. An attempt
+// nobody has touched for the idle time is dropped.
+type PuzzleSet struct {
+	puzzles []*Puzzle
+	byID    map[string]*Puzzle
+	engine  *EngineConfig // judges moves that aren't the solution's
+	idle    time.Duration
+
+	mu       sync.Mutex
+	attempts map[string]*Attempt
+	done     chan struct{}
+}
+
+// Attempt is one try at a puzzle.
+type Attempt struct {
+	ID     string
+	puzzle *Puzzle
+	user   *User // the solver, if they have an identity
+
+	mu     sync.Mutex // held while a move is checked
+	game   *chess.Game
+	next   int     // the index in the solution of the solver's next move
+	status string  // "playing", "solved" or "failed"
+	rating *Rating // the solver's puzzle rating once the attempt is over
+
+	// Guarded by the PuzzleSet's mutex
+	lastSeen time.Time
+}
+
+// PuzzleState is an attempt as the solver sees it. The embedded State is
+// the board, with Color the solver's side and Move the opponent's reply.
+type PuzzleState struct {
+	State
+	Attempt  string   `json:"attempt"`
+	Puzzle   string   `json:"puzzle"`
+	Rating   int      `json:"rating"` // the puzzle's
+	Themes   []string `json:"themes,omitempty"`
+	Status   string   `json:"status"`             // "playing", "solved" or "failed"
+	Solution []string `json:"solution,omitempty"` // once the attempt is over, in UCI
+	Player   *Rating  `json:"player,omitempty"`   // the solver's puzzle rating after the attempt
+}
+
+// LoadPuzzles reads the puzzles of a file: Lichess's puzzle CSV
+// (PuzzleId,FEN,Moves,Rating,RatingDeviation,Popularity,NbPlays,Themes,...),
+// where the first of the moves is the opponent's that sets the puzzle, or
+// for any other extension an EPD file, where the "pv" operation, or else
+// the first "bm", is the solution. engine judges moves that aren't the
+// solution's.
+func LoadPuzzles(path string, engine *EngineConfig, idle time.Duration) (*PuzzleSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var puzzles []*Puzzle
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		puzzles, err = readPuzzleCSV(f)
+	} else {
+		puzzles, err = readPuzzleEPD(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(puzzles) == 0 {
+		return nil, fmt.Errorf("%s: no puzzles", path)
+	}
+
+	ps := &PuzzleSet{
+		puzzles:  puzzles,
+		byID:     make(map[string]*Puzzle),
+		engine:   engine,
+		idle:     idle,
+		attempts: make(map[string]*Attempt),
+		done:     make(chan struct{}),
+	}
+	for _, p := range puzzles {
+		if _, err := p.start(); err != nil {
+			return nil, fmt.Errorf("%s: puzzle %s: %w", path, p.ID, err)
+		}
+		if ps.byID[p.ID] != nil {
+			return nil, fmt.Errorf("%s: two puzzles called %s", path, p.ID)
+		}
+		ps.byID[p.ID] = p
+	}
+	go ps.expireLoop()
+	return ps, nil
+}
+
+func readPuzzleCSV(r io.Reader) ([]*Puzzle, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	var puzzles []*Puzzle
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return puzzles, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) > 0 && rec[0] == "PuzzleId" {
+			continue
+		}
+		line, _ := cr.FieldPos(0)
+		if len(rec) < 3 {
+			return nil, fmt.Errorf("line %d: want at least an ID, a FEN and the moves", line)
+		}
+		moves := strings.Fields(rec[2])
+		if len(moves) < 2 {
+			return nil, fmt.Errorf("line %d: want the opponent's move and at least one of the solver's", line)
+		}
+		p := &Puzzle{ID: rec[0], FEN: rec[1], Setup: moves[0], Solution: moves[1:], Rating: ratings.NewGlicko()}
+		if len(rec) > 3 && rec[3] != "" {
+			if p.Rating.Rating, err = strconv.ParseFloat(rec[3], 64); err != nil {
+				return nil, fmt.Errorf("line %d: rating %q is not a number", line, rec[3])
+			}
+		}
+		if len(rec) > 4 && rec[4] != "" {
+			if p.Rating.RD, err = strconv.ParseFloat(rec[4], 64); err != nil {
+				return nil, fmt.Errorf("line %d: rating deviation %q is not a number", line, rec[4])
+			}
+		}
+		if len(rec) > 7 {
+			p.Themes = strings.Fields(rec[7])
+		}
+		puzzles = append(puzzles, p)
+	}
+}
+
+func readPuzzleEPD(r io.Reader) ([]*Puzzle, error) {
+	tests, err := epd.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	var puzzles []*Puzzle
+	for i, t := range tests {
+		p := &Puzzle{ID: t.ID, FEN: t.Position.String(), Rating: ratings.NewGlicko()}
+		if p.ID == "" {
+			p.ID = strconv.Itoa(i + 1)
+		}
+		pos := t.Position
+		for _, san := range t.Ops["pv"] {
+			mv, err := chess.AlgebraicNotation{}.Decode(pos, strings.TrimRight(san, "!?+#"))
+			if err != nil {
+				return nil, fmt.Errorf("puzzle %s: pv move %q is not legal", p.ID, san)
+			}
+			p.Solution = append(p.Solution, board.MoveToUCI(mv))
+			pos = pos.Update(mv)
+		}
+		if len(p.Solution) == 0 && len(t.BestMoves) > 0 {
+			p.Solution = []string{board.MoveToUCI(t.BestMoves[0])}
+		}
+		if len(p.Solution) == 0 {
+			return nil, fmt.Errorf("puzzle %s has neither pv nor bm", p.ID)
+		}
+		puzzles = append(puzzles, p)
+	}
+	return puzzles, nil
+}
+
+// start returns the game the solver faces, checking that the puzzle's
+// moves can all be played.
+func (p *Puzzle) start() (*chess.Game, error) {
+	pos, err := board.ParseFEN(p.FEN, board.LenientFEN)
+	if err != nil {
+		return nil, err
+	}
+	opt, _ := chess.FEN(pos.String())
+	game := chess.NewGame(opt)
+	if p.Setup != "" {
+		if err := playUCI(game, p.Setup); err != nil {
+			return nil, fmt.Errorf("move %s: %w", p.Setup, err)
+		}
+	}
+	check := truncate(game, len(game.Moves()))
+	for _, s := range p.Solution {
+		if err := playUCI(check, s); err != nil {
+			return nil, fmt.Errorf("move %s: %w", s, err)
+		}
+	}
+	return game, nil
+}
+
+// playUCI plays a move given in UCI.
+func playUCI(game *chess.Game, s string) error {
+	mv, err := board.UCIToMove(game.Position(), s)
+	if err != nil {
+		return err
+	}
+	return game.Move(mv)
+}
+
+// player is the puzzle as the solver's opponent in ratings.
+func (p *Puzzle) player() player {
+	return player{key: "puzzle:" + p.ID, name: p.ID, puzzle: true}
+}
+
+// Start begins an attempt at the puzzle with the given ID, or at one
+// picked for user if the ID is empty.
+func (ps *PuzzleSet) Start(id string, user *User) (*Attempt, error) {
+	p := ps.byID[id]
+	if id == "" {
+		p = ps.pick(user)
+	}
+	if p == nil {
+		return nil, fmt.Errorf("there is no puzzle %s", id)
+	}
+	game, err := p.start()
+	if err != nil {
+		return nil, err
+	}
+	a := &Attempt{ID: newID(), puzzle: p, user: user, game: game, status: "playing", lastSeen: time.Now()}
+	ps.mu.Lock()
+	ps.attempts[a.ID] = a
+	ps.mu.Unlock()
+	return a, nil
+}
+
+// Get returns the attempt with the given ID, or nil.
+func (ps *PuzzleSet) Get(id string) *Attempt {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	a := ps.attempts[id]
+	if a != nil {
+		a.lastSeen = time.Now()
+	}
+	return a
+}
+
+// Close stops expiring attempts.
+func (ps *PuzzleSet) Close() {
+	close(ps.done)
+}
+
+// pick chooses a puzzle for user: one they haven't tried, rated near
+// them if there are any such, otherwise the nearest. Anyone without an
+// identity is taken to be rated as a new player.
+func (ps *PuzzleSet) pick(user *User) *Puzzle {
+	target := ratings.NewGlicko().Rating
+	var tried map[string]bool
+	if user != nil {
+		target = users.PuzzleRating(user.ID, ratings.NewGlicko()).Rating
+		tried = users.Tried(user.ID)
+	}
+	var near []*Puzzle
+	var nearest *Puzzle
+	nearestGap := math.Inf(1)
+	for _, p := range ps.puzzles {
+		if tried[p.player().key] {
+			continue
+		}
+		gap := math.Abs(users.PuzzleRating(p.player().key, p.Rating).Rating - target)
+		if gap <= puzzleBand {
+			near = append(near, p)
+		}
+		if gap < nearestGap {
+			nearest, nearestGap = p, gap
+		}
+	}
+	switch {
+	case len(near) > 0:
+		return near[rand.Intn(len(near))]
+	case nearest != nil:
+		return nearest
+	}
+	// Every puzzle has been tried; any will do, unrated
+	return ps.puzzles[rand.Intn(len(ps.puzzles))]
+}
+
+// expireLoop drops idle attempts until the set is closed.
+func (ps *PuzzleSet) expireLoop() {
+	tick := time.NewTicker(ps.idle / 4)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ps.done:
+			return
+		case now := <-tick.C:
+			ps.mu.Lock()
+			for id, a := range ps.attempts {
+				if now.Sub(a.lastSeen) > ps.idle {
+					delete(ps.attempts, id)
+				}
+			}
+			ps.mu.Unlock()
+		}
+	}
+}
+
+// Play checks the solver's move. The solution's move, or any mate, is
+// answered with the opponent's reply, or solves the puzzle if it was the
+// last; a move the engine finds as good as the solution's solves it too,
+// as the solution no longer follows; any other move fails it. A move that
+// can't be played leaves the attempt as it was, with the reason in the
+// state's error.
+func (a *Attempt) Play(move Move) PuzzleState {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.status != "playing" {
+		return a.errorState("The puzzle is over")
+	}
+
+	moveStr, err := uciMove(move)
+	if err != nil {
+		return a.errorState("Invalid promotion: " + err.Error())
+	}
+	pos := a.game.Position()
+	mv, err := board.UCIToMove(pos, moveStr)
+	if err != nil {
+		if _, promo := board.UCIToMove(pos, moveStr+"q"); promo == nil {
+			return a.errorState("Choose a piece to promote to")
+		}
+		return a.errorState("Invalid move, please try again")
+	}
+
+	solution := a.puzzle.Solution
+	right := board.MoveToUCI(mv) == solution[a.next] || pos.Update(mv).Status() == chess.Checkmate
+	alternative := false
+	if !right {
+		expected, _ := board.UCIToMove(pos, solution[a.next])
+		if alternative, err = puzzles.judge(pos, mv, expected); err != nil {
+			log.Printf("Puzzle %s: %v", a.puzzle.ID, err)
+			return a.errorState("The engine could not check the move, please try again")
+		}
+	}
+	if err := a.game.Move(mv); err != nil {
+		return a.errorState("Illegal move, please try again")
+	}
+
+	var reply string
+	switch {
+	case right && a.next+1 < len(solution) && board.MoveToUCI(mv) == solution[a.next]:
+		reply = solution[a.next+1]
+		playUCI(a.game, reply)
+		a.next += 2
+		if a.next >= len(solution) {
+			a.finish(true)
+		}
+	case right, alternative:
+		a.finish(true)
+	default:
+		a.finish(false)
+	}
+	state := a.state()
+	state.Move = reply
+	return state
+}
+
+// State returns the attempt as it stands.
+func (a *Attempt) State() PuzzleState {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.state()
+}
+
+// finish ends the attempt and counts it for the solver's puzzle rating.
+func (a *Attempt) finish(solved bool) {
+	a.status = "failed"
+	if solved {
+		a.status = "solved"
+	}
+	if a.user == nil {
+		return
+	}
+	r, err := users.RatePuzzle(a.user, a.puzzle.player(), a.puzzle.Rating, solved)
+	if err != nil {
+		log.Printf("Puzzle %s: cannot rate the attempt: %v", a.puzzle.ID, err)
+	}
+	a.rating = &r
+}
+
+func (a *Attempt) state() PuzzleState {
+	p := a.puzzle
+	s := PuzzleState{
+		State:   newState(a.game),
+		Attempt: a.ID,
+		Puzzle:  p.ID,
+		Rating:  int(math.Round(users.PuzzleRating(p.player().key, p.Rating).Rating)),
+		Themes:  p.Themes,
+		Status:  a.status,
+		Player:  a.rating,
+	}
+	// The solver's side is the one to move at the start
+	solver := a.game.Positions()[0].Turn()
+	if p.Setup != "" {
+		solver = solver.Other()
+	}
+	s.Color = strings.ToLower(solver.Name())
+	if a.status != "playing" {
+		s.Solution = p.Solution
+		s.Legal = []string{}
+	}
+	return s
+}
+
+func (a *Attempt) errorState(msg string) PuzzleState {
+	s := a.state()
+	s.Error = msg
+	return s
+}
+
+// judge reports whether mv scores about as well as expected, the
+// solution's move, in pos for the side to move there.
+func (ps *PuzzleSet) judge(pos *chess.Position, mv, expected *chess.Move) (bool, error) {
+	engine, err := pool.Get(ps.engine)
+	if err != nil {
+		return false, err
+	}
+	defer pool.Put(ps.engine, engine)
+
+	solver := pos.Turn()
+	want, err := evaluate(engine, pos.Update(expected), solver)
+	if err != nil {
+		return false, err
+	}
+	got, err := evaluate(engine, pos.Update(mv), solver)
+	if err != nil {
+		return false, err
+	}
+	return got >= want-puzzleMargin, nil
+}
+
+// evaluate returns the engine's score of pos, in centipawns for side,
+// with mates as mateScore less their length.
+func evaluate(engine *arbiter.UCIEngineAdapter, pos *chess.Position, side chess.Color) (int, error) {
+	score := 0
+	switch pos.Status() {
+	case chess.Checkmate:
+		score = mateScore
+		if pos.Turn() == side {
+			score = -score
+		}
+		return score, nil
+	case chess.Stalemate:
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), puzzleCheckTime+moveTimeout)
+	defer cancel()
+	_, err := engine.BestMove(ctx, "position fen "+pos.String(), fmt.Sprintf("go movetime %d", puzzleCheckTime.Milliseconds()))
+	if ctx.Err() != nil {
+		return 0, fmt.Errorf("no bestmove from the engine after %v", puzzleCheckTime+moveTimeout)
+	}
+	if err != nil {
+		return 0, err
+	}
+	info := engine.LastSearch()
+	if !info.HasScore {
+		return 0, errors.New("the engine gave no score")
+	}
+	// The engine scores for the side to move
+	switch {
+	case info.Mate > 0:
+		score = mateScore - info.Mate
+	case info.Mate < 0:
+		score = -mateScore - info.Mate
+	default:
+		score = info.Score
+	}
+	if pos.Turn() != side {
+		score = -score
+	}
+	return score, nil
+}
@@ -0,0 +1,164 @@
+package webarbiter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"chessTomorrow/arbiter"
+)
+
+// Registry lists the engines players can choose from, as read from an
+// engine registry file (see arbiter.Registry) whose engines may also list
+// their levels:
+//
+//	{"engines": [
+//	    {"name": "Maia 1900", "command": "./maia1900.sh", "levels": [{"name": "Maia", "nodes": 1}]},
+//	    {"name": "Alpha-beta", "command": "./bin/chessEngine2", "options": {"Hash": "64"}}
+//	]}
+//
+// The first engine, at its first level, is the one a new player meets.
+type Registry struct {
+	Engines []EngineConfig `json:"engines"`
+}
+
+// EngineConfig is an engine players can choose.
+type EngineConfig struct {
+	arbiter.EngineSpec
+	Levels []Level `json:"levels,omitempty"` // easiest first; defaultLevels if empty
+}
+
+// Level is a difficulty: how far the engine may search for each move, and
+// for engines that support UCI_LimitStrength, the Elo it plays at. A
+// level with no limit at all searches for a second.
+type Level struct {
+	Name     string `json:"name"`
+	Nodes    int    `json:"nodes,omitempty"`
+	Depth    int    `json:"depth,omitempty"`
+	MoveTime int    `json:"movetime,omitempty"` // milliseconds
+	Elo      int    `json:"elo,omitempty"`
+}
+
+// defaultLevels are the levels of an engine that doesn't list its own.
+var defaultLevels = []Level{
+	{Name: "Easy", Depth: 1},
+	{Name: "Medium", MoveTime: 200},
+	{Name: "Hard", MoveTime: 1000},
+}
+
+// LoadRegistry reads a registry file.
+func LoadRegistry(path string) (*Registry, error) {
+	engines, err := arbiter.LoadRegistry(path)
+	if err != nil {
+		return nil, err
+	}
+	// The levels are the web arbiter's own
+	var levels struct {
+		Engines []struct {
+			Levels []Level `json:"levels"`
+		} `json:"engines"`
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &levels); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	r := &Registry{}
+	for i, spec := range engines.Engines {
+		conf := EngineConfig{EngineSpec: spec}
+		if i < len(levels.Engines) {
+			conf.Levels = levels.Engines[i].Levels
+		}
+		r.Engines = append(r.Engines, conf)
+	}
+	if err := r.check(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+// singleEngine is the registry of just the named engine: the one called
+// name in the default registry, with its levels, or the UCI binary at
+// name.
+func singleEngine(name string) (*Registry, error) {
+	if _, err := os.Stat(arbiter.DefaultRegistry); err == nil {
+		r, err := LoadRegistry(arbiter.DefaultRegistry)
+		if err != nil {
+			return nil, err
+		}
+		for _, conf := range r.Engines {
+			if conf.Name == name {
+				r.Engines = []EngineConfig{conf}
+				return r, nil
+			}
+		}
+	}
+	spec := arbiter.EngineSpec{Name: filepath.Base(name), Command: name, Protocol: arbiter.ProtocolUCI}
+	r := &Registry{Engines: []EngineConfig{{EngineSpec: spec}}}
+	return r, r.check()
+}
+
+// check fills in default levels and rejects registries players couldn't
+// use.
+func (r *Registry) check() error {
+	if len(r.Engines) == 0 {
+		return errors.New("no engines")
+	}
+	for i := range r.Engines {
+		e := &r.Engines[i]
+		if e.Protocol != arbiter.ProtocolUCI {
+			return fmt.Errorf("%s: players can only play UCI engines", e.Name)
+		}
+		if len(e.Levels) == 0 {
+			e.Levels = defaultLevels
+		}
+	}
+	return nil
+}
+
+// find returns the named engine and level. An empty name picks the first.
+func (r *Registry) find(engine, level string) (*EngineConfig, Level, error) {
+	var conf *EngineConfig
+	for i := range r.Engines {
+		if engine == "" || r.Engines[i].Name == engine {
+			conf = &r.Engines[i]
+			break
+		}
+	}
+	if conf == nil {
+		return nil, Level{}, fmt.Errorf("no engine called %q", engine)
+	}
+	for _, l := range conf.Levels {
+		if level == "" || l.Name == level {
+			return conf, l, nil
+		}
+	}
+	return nil, Level{}, fmt.Errorf("%s has no level called %q", conf.Name, level)
+}
+
+// goCommand returns the UCI command that searches for a move at l.
+func (l Level) goCommand() string {
+	cmd := "go"
+	if l.Nodes > 0 {
+		cmd += " nodes " + strconv.Itoa(l.Nodes)
+	}
+	if l.Depth > 0 {
+		cmd += " depth " + strconv.Itoa(l.Depth)
+	}
+	if l.MoveTime > 0 || cmd == "go" {
+		cmd += " movetime " + strconv.Itoa(int(l.moveTime()/time.Millisecond))
+	}
+	return cmd
+}
+
+// moveTime is how long the engine may think at l, if it is timed.
+func (l Level) moveTime() time.Duration {
+	if l.MoveTime == 0 && l.Nodes == 0 && l.Depth == 0 {
+		return time.Second
+	}
+	return time.Duration(l.MoveTime) * time.Millisecond
+}
@@ -0,0 +1,365 @@
+package webarbiter
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+	"golang.org/x/net/websocket"
+)
+
+// Room is a game between two people, played through the server so that
+// every move is checked, with any number of spectators watching. Each
+// change is broadcast to everyone in the room.
+type Room struct {
+	ID string
+
+	mu      sync.Mutex // held while a message from anyone in the room is handled
+	game    *chess.Game
+	clock   *Clock                          // nil for an untimed game; it starts with the first move
+	timeout chess.Color                     // the side that lost on time, if any
+	offer   chess.Color                     // the side offering a draw, if any
+	tokens  [3]string                       // by color: what a player's page keeps to take its seat again
+	players [3]*User                        // by color: who sits there, if they have an identity
+	rated   bool                            // the result has counted for ratings
+	conns   map[*websocket.Conn]chess.Color // NoColor for spectators
+
+	// Guarded by the Rooms' mutex
+	lastSeen time.Time // when the room was last left empty
+}
+
+// RoomState is a Room as one of its connections sees it.
+type RoomState struct {
+	State
+	Room       string `json:"room"`
+	Token      string `json:"token,omitempty"`     // to take the same seat after a reload
+	WhiteName  string `json:"whiteName,omitempty"` // the players' names, if they have identities
+	BlackName  string `json:"blackName,omitempty"`
+	DrawOffer  string `json:"drawOffer,omitempty"` // "white" or "black", offering a draw
+	White      bool   `json:"white"`               // whether the player is connected
+	Black      bool   `json:"black"`
+	Spectators int    `json:"spectators"`
+}
+
+// Rooms creates and expires Rooms. A room nobody has been in for the idle
+// time is dropped.
+type Rooms struct {
+	idle time.Duration
+
+	mu    sync.Mutex
+	rooms map[string]*Room
+	done  chan struct{}
+}
+
+// NewRooms starts a room manager.
+func NewRooms(idle time.Duration) *Rooms {
+	m := &Rooms{idle: idle, rooms: make(map[string]*Room), done: make(chan struct{})}
+	go m.expireLoop()
+	return m
+}
+
+// Open returns the room with the given ID, or nil if there is none. An
+// empty ID opens a new room with clock, a preset or time control; an empty
+// clock is an untimed game.
+func (m *Rooms) Open(id, clock string) (*Room, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id != "" {
+		return m.rooms[id], nil
+	}
+
+	now := time.Now()
+	c, err := newClock(clock, chess.White, now)
+	if err != nil {
+		return nil, err
+	}
+	c.stop(now)
+	r := &Room{ID: newID(), game: chess.NewGame(), clock: c,
+		conns: make(map[*websocket.Conn]chess.Color), lastSeen: now}
+	m.rooms[r.ID] = r
+	log.Printf("Room %s opened", r.ID)
+	return r, nil
+}
+
+// Close stops expiring rooms.
+func (m *Rooms) Close() {
+	close(m.done)
+}
+
+// expireLoop drops empty rooms until the manager is closed.
+func (m *Rooms) expireLoop() {
+	tick := time.NewTicker(m.idle / 4)
+	defer tick.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case now := <-tick.C:
+			m.expire(now)
+		}
+	}
+}
+
+// expire drops the rooms that have been empty since before now less the
+// idle time.
+func (m *Rooms) expire(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, r := range m.rooms {
+		r.mu.Lock()
+		empty := len(r.conns) == 0
+		r.mu.Unlock()
+		if empty && now.Sub(r.lastSeen) > m.idle {
+			log.Printf("Room %s expired", id)
+			delete(m.rooms, id)
+		}
+	}
+}
+
+// handleRoom plays a room's game over a WebSocket. The query names the
+// room (none opens a new one, with ?clock= for a timed game), the seat
+// wanted ("white", "black", "watch", or any free one if empty), the
+// token of a seat taken before and the user token of a player with an
+// identity. A seat already taken makes a spectator. The game is rated if
+// both players have identities.
+func handleRoom(ws *websocket.Conn) {
+	defer ws.Close()
+
+	q := ws.Request().URL.Query()
+	r, err := rooms.Open(q.Get("room"), q.Get("clock"))
+	if err != nil || r == nil {
+		msg := "There is no such room"
+		if err != nil {
+			msg = err.Error()
+		}
+		websocket.JSON.Send(ws, RoomState{State: State{Error: msg}})
+		return
+	}
+
+	seat := r.join(ws, q.Get("seat"), q.Get("token"), users.Find(q.Get("user")))
+	defer r.leave(ws)
+	if seat == chess.NoColor {
+		log.Printf("Room %s: a spectator joined", r.ID)
+	} else {
+		log.Printf("Room %s: %s joined", r.ID, seatName(seat))
+	}
+
+	for {
+		var msg Move
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			return
+		}
+		r.handle(ws, seat, msg)
+	}
+}
+
+// join seats a connection and shows everyone in the room who is there. A
+// seat newly taken is user's; one taken back keeps whoever took it.
+func (r *Room) join(ws *websocket.Conn, want, token string, user *User) chess.Color {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seat := chess.NoColor
+	for _, c := range []chess.Color{chess.White, chess.Black} {
+		if token != "" && token == r.tokens[c] {
+			seat = c
+		}
+	}
+	if seat == chess.NoColor {
+		for _, c := range []chess.Color{chess.White, chess.Black} {
+			if r.tokens[c] == "" && (want == "" || want == seatName(c)) {
+				seat, r.tokens[c] = c, newID()
+				r.players[c] = user
+				break
+			}
+		}
+	}
+	r.conns[ws] = seat
+	r.broadcast()
+	return seat
+}
+
+// leave removes a connection and shows the others that it has gone. Its
+// seat stays taken, for the player to come back to.
+func (r *Room) leave(ws *websocket.Conn) {
+	r.mu.Lock()
+	delete(r.conns, ws)
+	empty := len(r.conns) == 0
+	r.broadcast()
+	r.mu.Unlock()
+
+	if empty {
+		rooms.mu.Lock()
+		r.lastSeen = time.Now()
+		rooms.mu.Unlock()
+	}
+}
+
+// handle carries out a message from the connection in seat. A change is
+// broadcast to the room; a message that can't be carried out is answered
+// with the reason, to the sender alone.
+func (r *Room) handle(ws *websocket.Conn, seat chess.Color, msg Move) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// A flag can fall between messages
+	if side := r.clock.flagged(time.Now()); side != chess.NoColor && !r.over() {
+		r.timeout = side
+	}
+
+	var err string
+	switch {
+	case msg.Type == "time":
+		// The page's clock ran out; the flag was checked above
+	case seat == chess.NoColor:
+		err = "Spectators cannot play"
+	case r.over():
+		err = "The game is over"
+	case msg.Type == "" || msg.Type == "move":
+		err = r.play(seat, msg)
+	case msg.Type == "resign":
+		r.game.Resign(seat)
+	case msg.Type == "draw":
+		r.draw(seat)
+	default:
+		err = "Unknown message type " + msg.Type
+	}
+	if err != "" {
+		state := r.state(seat)
+		state.Error = err
+		websocket.JSON.Send(ws, state)
+		return
+	}
+	r.rate()
+	r.broadcast()
+}
+
+// play makes a player's move, returning why it can't be made if it can't.
+func (r *Room) play(seat chess.Color, move Move) string {
+	if r.game.Position().Turn() != seat {
+		return "It is your opponent's move"
+	}
+	if r.tokens[seat.Other()] == "" {
+		return "Wait for an opponent to join"
+	}
+	moveStr, err := uciMove(move)
+	if err != nil {
+		return "Invalid promotion: " + err.Error()
+	}
+	mv, err := board.UCIToMove(r.game.Position(), moveStr)
+	if err != nil {
+		if _, promo := board.UCIToMove(r.game.Position(), moveStr+"q"); promo == nil {
+			return "Choose a piece to promote to"
+		}
+		return "Invalid move, please try again"
+	}
+	if err := r.game.Move(mv); err != nil {
+		return "Illegal move, please try again"
+	}
+
+	// Moving on declines the opponent's draw offer
+	if r.offer == seat.Other() {
+		r.offer = chess.NoColor
+	}
+
+	now := time.Now()
+	switch {
+	case r.clock == nil:
+	case r.clock.turn == chess.NoColor:
+		// The first move starts the clocks
+		r.clock.restart(r.game.Position().Turn(), now)
+	case !r.clock.punch(now):
+		r.timeout = seat
+	}
+	return ""
+}
+
+// draw offers a draw, or agrees to the opponent's offer. A draw that can be
+// claimed is claimed at once.
+func (r *Room) draw(seat chess.Color) {
+	switch {
+	case len(r.game.EligibleDraws()) > 1:
+		r.game.Draw(r.game.EligibleDraws()[1])
+	case r.offer == seat.Other():
+		r.game.Draw(chess.DrawOffer)
+	default:
+		r.offer = seat
+	}
+}
+
+// rate counts the game for both players' ratings once it is over. A game
+// counts only once.
+func (r *Room) rate() {
+	if r.rated || !r.over() {
+		return
+	}
+	r.rated = true
+	white, black := r.players[chess.White].player(), r.players[chess.Black].player()
+	if err := users.Rate(white, black, r.state(chess.NoColor).Result); err != nil {
+		log.Printf("Room %s: cannot rate the game: %v", r.ID, err)
+	}
+}
+
+// over reports whether the game has ended, on the board or the clock.
+func (r *Room) over() bool {
+	return r.game.Outcome() != chess.NoOutcome || r.timeout != chess.NoColor
+}
+
+// state describes the room for the connection in seat. A game that is over
+// has its clock stopped.
+func (r *Room) state(seat chess.Color) RoomState {
+	now := time.Now()
+	if r.over() {
+		r.clock.stop(now)
+		r.offer = chess.NoColor
+	}
+	state := RoomState{State: newState(r.game), Room: r.ID, Token: r.tokens[seat]}
+	if r.timeout != chess.NoColor {
+		state.Legal = []string{}
+		state.Result, state.Reason = timeoutResult(r.game.Position(), r.timeout)
+		state.Draw = state.Result == chess.Draw.String()
+	}
+	state.Clock = r.clock.state(now)
+	state.Color = seatName(seat)
+	if p := r.players[chess.White]; p != nil {
+		state.WhiteName = p.Name
+	}
+	if p := r.players[chess.Black]; p != nil {
+		state.BlackName = p.Name
+	}
+	if r.offer != chess.NoColor {
+		state.DrawOffer = seatName(r.offer)
+	}
+	for _, s := range r.conns {
+		switch s {
+		case chess.White:
+			state.White = true
+		case chess.Black:
+			state.Black = true
+		default:
+			state.Spectators++
+		}
+	}
+	return state
+}
+
+// broadcast sends everyone in the room the game as it stands.
+func (r *Room) broadcast() {
+	for ws, seat := range r.conns {
+		if err := websocket.JSON.Send(ws, r.state(seat)); err != nil {
+			log.Printf("Room %s: %v", r.ID, err)
+		}
+	}
+}
+
+// seatName names a seat as pages do: "white", "black", or "" for a
+// spectator.
+func seatName(c chess.Color) string {
+	if c == chess.NoColor {
+		return ""
+	}
+	return strings.ToLower(c.Name())
+}
@@ -0,0 +1,341 @@
+// Package webarbiter serves a web page for playing against a UCI engine
+// in the browser.
+package webarbiter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"golang.org/x/net/websocket"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"chessTomorrow/arbiter"
+)
+
+// shutdownTimeout bounds the wait for connections to finish on shutdown.
+const shutdownTimeout = 10 * time.Second
+
+// sessions holds every player's game.
+var sessions *Sessions
+
+// rooms holds the games people play each other.
+var rooms *Rooms
+
+// users are the players with identities, and everyone's ratings.
+var users *Users
+
+// puzzles are the tactics positions players can solve, or nil without
+// -puzzles.
+var puzzles *PuzzleSet
+
+// annotations judges the moves of kept games for the history page.
+var annotations *Annotations
+
+// pool keeps engines ready for sessions and the analysis board.
+var pool *Pool
+
+// sockets are the open WebSocket connections, closed on shutdown.
+var sockets = &socketSet{conns: make(map[*websocket.Conn]bool)}
+
+type socketSet struct {
+	mu    sync.Mutex
+	conns map[*websocket.Conn]bool
+	wg    sync.WaitGroup // one per running handler
+}
+
+// tracked serves WebSocket connections with h, keeping them in sockets.
+func tracked(h func(*websocket.Conn)) websocket.Handler {
+	return func(ws *websocket.Conn) {
+		sockets.mu.Lock()
+		sockets.conns[ws] = true
+		sockets.wg.Add(1)
+		sockets.mu.Unlock()
+		defer func() {
+			sockets.mu.Lock()
+			delete(sockets.conns, ws)
+			sockets.mu.Unlock()
+			sockets.wg.Done()
+		}()
+		h(ws)
+	}
+}
+
+// closeAll closes every connection, ending their handlers.
+func (ss *socketSet) closeAll() {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	for ws := range ss.conns {
+		ws.Close()
+	}
+}
+
+// wait waits for the handlers to finish, or for ctx to be done.
+func (ss *socketSet) wait(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		ss.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+// Move struct to communicate with frontend. Type says what the player
+// wants: "move" (or empty) plays From-To; "new" starts a game from the
+// board editor's Setup, or FEN, or the start position if both are empty
+// (castling rights and en passant squares the board doesn't allow are
+// dropped, and the state's notes say so), with the player on Color ("white" or
+// "black") against Engine at Level, both named in the registry and
+// unchanged if empty, on Clock, a preset such as "blitz" or a time control
+// such as "5:00+3" (untimed if empty); "time" asks whether a flag has fallen;
+// "undo" takes back the player's last move and the reply;
+// "resign" resigns; "draw" offers a draw; and "stop" cuts the engine's
+// thinking short, for it to play the best move it has found.
+type Move struct {
+	Type      string      `json:"type,omitempty"`
+	From      string      `json:"from"`
+	To        string      `json:"to"`
+	Piece     string      `json:"piece"`
+	Promotion string      `json:"promotion,omitempty"`
+	FEN       string      `json:"fen,omitempty"`
+	Color     string      `json:"color,omitempty"`
+	Engine    string      `json:"engine,omitempty"`
+	Level     string      `json:"level,omitempty"`
+	Clock     string      `json:"clock,omitempty"`
+	Setup     *BoardSetup `json:"setup,omitempty"`
+}
+
+// WebSocket handler to interact with the game
+func handleWS(ws *websocket.Conn) {
+	// Defer cleanup for the WebSocket connection
+	defer ws.Close()
+
+	q := ws.Request().URL.Query()
+	sess, err := sessions.Open(q.Get("session"))
+	if err != nil {
+		log.Printf("Failed to start a session: %v", err)
+		if errors.Is(err, errTooManySessions) || errors.Is(err, errPoolFull) {
+			send(ws, State{Error: "The server is playing as many games as it can; please try again later"})
+		} else {
+			send(ws, State{Error: "The engine could not be started"})
+		}
+		return
+	}
+	defer sessions.Release(sess)
+	// A player with a token plays rated games
+	sess.identify(users.Find(q.Get("user")))
+
+	log.Printf("New WebSocket connection established for session %s.", sess.ID)
+
+	// The engine's moves come whenever it has thought of them
+	updates := sess.watch()
+	defer sess.unwatch(updates)
+
+	// Show the page where the game stands
+	sess.mu.Lock()
+	state := sess.state()
+	sess.mu.Unlock()
+	if !send(ws, state) {
+		return
+	}
+
+	// Messages are read apart, so that the engine's moves are sent while
+	// the player thinks
+	moves := make(chan Move)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(moves)
+		for {
+			var move Move
+
+			// Receive human move from WebSocket
+			if err := websocket.JSON.Receive(ws, &move); err != nil {
+				log.Printf("WebSocket Error: %v\n", err)
+				return
+			}
+
+			log.Printf("Received move: %+v\n", move)
+
+			select {
+			case moves <- move:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case move, ok := <-moves:
+			if !ok || !send(ws, sess.handle(move)) {
+				return
+			}
+		case state := <-updates:
+			if !send(ws, state) {
+				return
+			}
+		}
+	}
+}
+
+// send writes s to the frontend, reporting whether the connection is
+// still usable.
+func send(ws *websocket.Conn, s State) bool {
+	if err := websocket.JSON.Send(ws, s); err != nil {
+		log.Printf("Failed to send message: %v\n", err)
+		return false
+	}
+	return true
+}
+
+// serveEngines lists the names of the engines players can choose and
+// their levels, easiest first. The paths stay on the server.
+func serveEngines(w http.ResponseWriter, r *http.Request) {
+	type choice struct {
+		Name   string   `json:"name"`
+		Levels []string `json:"levels"`
+	}
+	var choices []choice
+	for _, e := range sessions.registry.Engines {
+		c := choice{Name: e.Name}
+		for _, l := range e.Levels {
+			c.Levels = append(c.Levels, l.Name)
+		}
+		choices = append(choices, c)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(choices)
+}
+
+// ServeMain runs the serve command: a web page to play against a UCI
+// engine in the browser. It runs until interrupted, then closes every
+// connection, saves the games and quits the engines.
+func ServeMain(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "address to serve on")
+	engineName := fs.String("engine", "Maia 1900", "engine to play against without -engines: one of "+arbiter.DefaultRegistry+" or the path of a UCI binary")
+	registryPath := fs.String("engines", "", "engine registry of the engines and levels players can choose from")
+	idle := fs.Duration("idle", 30*time.Minute, "time after which a game nobody is connected to is dropped")
+	maxGames := fs.Int("max-games", 100, "games played against engines at once, each with its own engine process; 0 for no limit")
+	gamesDir := fs.String("games", "", "directory to keep the database of played games in, so they can be listed and resumed after a restart")
+	maxEngines := fs.Int("max-engines", 120, "engine processes running at once, for games, analysis and puzzles together; 0 for no limit")
+	spare := fs.Int("spare", 1, "started engines kept ready for new games, per registry engine")
+	health := fs.Duration("health", time.Minute, "time between checks that the ready engines still answer")
+	puzzlePath := fs.String("puzzles", "", "puzzle file to serve: Lichess's puzzle CSV, or EPD with bm or pv")
+	puzzleEngine := fs.String("puzzle-engine", "", "registry engine that judges puzzle moves other than the solution's; the first if empty")
+	annotateEngine := fs.String("annotate-engine", "", "registry engine that annotates kept games on the history page; the first if empty")
+	annotateTime := fs.Duration("annotate-time", 300*time.Millisecond, "search time per position when annotating a game")
+	usersPath := fs.String("users", "", "JSON file to keep players and ratings in; without it they last until the server stops")
+	staticDir := fs.String("static", "", "directory to serve the frontend files from instead of those built in, for working on them")
+	fs.Parse(args)
+
+	var registry *Registry
+	var err error
+	if *registryPath != "" {
+		registry, err = LoadRegistry(*registryPath)
+	} else {
+		registry, err = singleEngine(*engineName)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *staticDir != "" {
+		frontend = os.DirFS(*staticDir)
+	}
+
+	if users, err = LoadUsers(*usersPath); err != nil {
+		return err
+	}
+
+	var store *GameStore
+	if *gamesDir != "" {
+		if store, err = OpenGameStore(*gamesDir); err != nil {
+			return err
+		}
+		defer store.Close()
+	}
+
+	// Every connection plays its own game against its own engine, taken
+	// from the pool
+	pool = NewPool(registry, *spare, *maxEngines, *health)
+	defer pool.Close()
+	sessions = NewSessions(registry, *idle, *maxGames, store)
+	defer sessions.Close() // Cleanup when server stops
+	rooms = NewRooms(*idle)
+	defer rooms.Close()
+	if *puzzlePath != "" {
+		conf, _, err := registry.find(*puzzleEngine, "")
+		if err != nil {
+			return err
+		}
+		if puzzles, err = LoadPuzzles(*puzzlePath, conf, *idle); err != nil {
+			return err
+		}
+		defer puzzles.Close()
+	}
+	conf, _, err := registry.find(*annotateEngine, "")
+	if err != nil {
+		return err
+	}
+	annotations = NewAnnotations(conf, *annotateTime)
+
+	// Serve index.html on root path
+	http.HandleFunc("/", serveIndex)
+
+	// Serve other static files (CSS, JS)
+	http.HandleFunc("/static/", serveStatic)
+
+	// Diagrams of a session's game or any position
+	http.HandleFunc("/board.svg", serveDiagram)
+	http.HandleFunc("/board.png", serveDiagram)
+
+	// The engines and levels to choose from
+	http.HandleFunc("/engines", serveEngines)
+
+	// The same games for scripts
+	registerAPI(http.DefaultServeMux)
+
+	// WebSocket handler
+	http.Handle("/ws", tracked(handleWS))
+
+	// The analysis board's engine lines
+	http.Handle("/analysis", tracked(handleAnalysis))
+
+	// Games between two people, and their spectators
+	http.Handle("/room", tracked(handleRoom))
+
+	// Start the server
+	srv := &http.Server{Addr: *addr}
+	srv.RegisterOnShutdown(sockets.closeAll)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+	fmt.Printf("Server is running on %s\n", *addr)
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	log.Println("Shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Shutdown: %v", err)
+	}
+	sockets.wait(ctx)
+	return nil
+}
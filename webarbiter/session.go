@@ -0,0 +1,263 @@
+package webarbiter
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"chessTomorrow/arbiter"
+	"github.com/notnil/chess"
+)
+
+// Session is one player's game and the engine process playing it. The
+// browser keeps the session's ID, so a reload or a dropped connection
+// picks the game up where it was.
+type Session struct {
+	ID string
+
+	mu      sync.Mutex // held while a message from the player is handled
+	game    *chess.Game
+	human   chess.Color // the side the player has
+	engine  *arbiter.UCIEngineAdapter
+	conf    *EngineConfig // the engine's registry entry
+	level   Level
+	clock   *Clock      // nil for an untimed game
+	timeout chess.Color // the side that lost on time, if any
+	gameID  string      // the game's name in the store
+	started time.Time   // when the game began
+	saved   string      // what the store last got, to skip saving it again
+	user    *User       // the player, if they have an identity
+	unrated bool        // the game doesn't count for ratings (any more)
+
+	thinking *search             // the engine's search for its move, if it is searching
+	watchers map[chan State]bool // connections to tell about the engine's moves
+
+	// Guarded by the Sessions' mutex
+	clients  int       // connections using the session; it never expires while there are any
+	lastSeen time.Time // when the last client left
+}
+
+// errTooManySessions is Open's error when the server plays as many games
+// as it may.
+var errTooManySessions = errors.New("too many games in progress")
+
+// Sessions creates and expires Sessions. A session nobody has been
+// connected to for the idle time is closed, along with its engine.
+type Sessions struct {
+	registry *Registry
+	idle     time.Duration
+	max      int        // sessions at once, each with an engine; 0 for no limit
+	store    *GameStore // nil keeps no games
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+	starting int // sessions being opened, counted against max
+	done     chan struct{}
+}
+
+// NewSessions starts a session manager whose sessions play the engines of
+// registry, the first one until the player picks another, and keep their
+// games in store, which may be nil. At most max sessions are open at
+// once, or any number if max is 0.
+func NewSessions(registry *Registry, idle time.Duration, max int, store *GameStore) *Sessions {
+	m := &Sessions{
+		registry: registry,
+		idle:     idle,
+		max:      max,
+		store:    store,
+		sessions: make(map[string]*Session),
+		done:     make(chan struct{}),
+	}
+	go m.expireLoop()
+	return m
+}
+
+// Open joins the session with the given ID, or starts a new one if there
+// is none. An expired ID, or one from before a restart, gets its game back
+// from the store if the game was unfinished; any other ID gets a new
+// session. Every Open must be matched by a Release. When as many
+// sessions are open as the limit allows, a new one is refused with
+// errTooManySessions.
+func (m *Sessions) Open(id string) (*Session, error) {
+	if s := m.Join(id); s != nil {
+		return s, nil
+	}
+	if !m.reserve() {
+		return nil, errTooManySessions
+	}
+	defer m.unreserve()
+	if id != "" {
+		if g := m.store.unfinished(id); g != nil {
+			s, err := m.resume(g)
+			if err == nil {
+				return s, nil
+			}
+			log.Printf("Session %s: cannot resume game %s: %v", id, g.ID, err)
+		}
+	}
+
+	// Start the engine without holding the lock; it can take a while
+	now := time.Now()
+	s := &Session{ID: newID(), game: chess.NewGame(), human: chess.White,
+		gameID: newID(), started: now, clients: 1}
+	if err := s.setEngine(m.registry, "", ""); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.sessions[s.ID] = s
+	m.mu.Unlock()
+	log.Printf("Session %s started", s.ID)
+	return s, nil
+}
+
+// reserve counts a session about to be opened against the limit, before
+// its engine is started, and reports false if there is no room for it.
+// Every reserve that succeeds must be matched by an unreserve once the
+// session is open, or has failed to open.
+func (m *Sessions) reserve() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.max > 0 && len(m.sessions)+m.starting >= m.max {
+		return false
+	}
+	m.starting++
+	return true
+}
+
+func (m *Sessions) unreserve() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.starting--
+}
+
+// resume brings back a stored game in a session with its old ID, with the
+// clock as it was saved. If the engine is to move it starts thinking.
+func (m *Sessions) resume(g *StoredGame) (*Session, error) {
+	game, err := g.replay()
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{ID: g.Session, game: game, human: chess.White,
+		gameID: g.ID, started: g.Started, clients: 1}
+	if g.Human == "black" {
+		s.human = chess.Black
+	}
+	if err := s.setEngine(m.registry, g.Engine, g.Level); err != nil {
+		return nil, err
+	}
+	if c := g.Clock; c != nil {
+		s.clock = resumeClock(c, game.Position().Turn(), time.Now())
+	}
+	// Another connection may have resumed it meanwhile
+	m.mu.Lock()
+	if other := m.sessions[s.ID]; other != nil {
+		other.clients++
+		m.mu.Unlock()
+		pool.Put(s.conf, s.engine)
+		return other, nil
+	}
+	m.sessions[s.ID] = s
+	m.mu.Unlock()
+	log.Printf("Session %s resumed game %s", s.ID, s.gameID)
+
+	s.mu.Lock()
+	if game.Outcome() == chess.NoOutcome && game.Position().Turn() != s.human {
+		s.think()
+	}
+	s.mu.Unlock()
+	return s, nil
+}
+
+// Join joins the session with the given ID, or returns nil if there is
+// none. Every Join that returns a session must be matched by a Release.
+func (m *Sessions) Join(id string) *Session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.sessions[id]
+	if s != nil {
+		s.clients++
+	}
+	return s
+}
+
+// Release marks the end of a connection to s.
+func (m *Sessions) Release(s *Session) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s.clients--
+	s.lastSeen = time.Now()
+}
+
+// Get returns the session with the given ID, or nil.
+func (m *Sessions) Get(id string) *Session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sessions[id]
+}
+
+// Close ends every session and stops expiring them. Games are saved as
+// they stand, with any search dropped, so that they resume after a
+// restart.
+func (m *Sessions) Close() {
+	close(m.done)
+	m.mu.Lock()
+	sessions := m.sessions
+	m.sessions = make(map[string]*Session)
+	m.mu.Unlock()
+	for _, s := range sessions {
+		s.mu.Lock()
+		s.cancel()
+		s.save()
+		s.mu.Unlock()
+		pool.Drop(s.engine)
+	}
+}
+
+// expireLoop closes idle sessions until the manager is closed.
+func (m *Sessions) expireLoop() {
+	tick := time.NewTicker(m.idle / 4)
+	defer tick.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case now := <-tick.C:
+			m.expire(now)
+		}
+	}
+}
+
+// expire closes the sessions that have been idle since before now less
+// the idle time.
+func (m *Sessions) expire(now time.Time) {
+	var idle []*Session
+	m.mu.Lock()
+	for id, s := range m.sessions {
+		if s.clients == 0 && now.Sub(s.lastSeen) > m.idle {
+			idle = append(idle, s)
+			delete(m.sessions, id)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, s := range idle {
+		log.Printf("Session %s expired", s.ID)
+		s.mu.Lock()
+		s.cancel()
+		s.mu.Unlock()
+		pool.Put(s.conf, s.engine)
+	}
+}
+
+// newID returns a random, unguessable session or game ID.
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("webarbiter: no randomness: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
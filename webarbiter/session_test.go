@@ -0,0 +1,25 @@
+package webarbiter
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestSessionLimit checks that a session over the limit is refused before
+// an engine is started for it: the manager has no registry or pool to
+// start one from.
+func TestSessionLimit(t *testing.T) {
+	m := &Sessions{max: 2, sessions: map[string]*Session{"a": {ID: "a"}}}
+	if !m.reserve() {
+		t.Fatal("no room for a second session")
+	}
+	if _, err := m.Open(""); !errors.Is(err, errTooManySessions) {
+		t.Errorf("Open over the limit: %v, want %v", err, errTooManySessions)
+	}
+	m.unreserve()
+
+	// Joining a session that is open already needs no room
+	if s, err := m.Open("a"); err != nil || s.ID != "a" {
+		t.Errorf("Open(%q) = %v, %v", "a", s, err)
+	}
+}
@@ -0,0 +1,118 @@
+package webarbiter
+
+import (
+	"fmt"
+	"strings"
+
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+)
+
+// BoardSetup is a position from the board editor: the pieces by square,
+// and the rest of what a FEN says.
+type BoardSetup struct {
+	Pieces    map[string]string `json:"pieces"`              // FEN letters by square, such as "e1": "K"
+	Turn      string            `json:"turn,omitempty"`      // "white" or "black"; White if empty
+	Castling  string            `json:"castling,omitempty"`  // such as "KQkq" or "-"; every right the board allows if empty
+	EnPassant string            `json:"enPassant,omitempty"` // the square a pawn that just moved two squares passed
+}
+
+// startPosition returns the position a new game starts from: setup if
+// the board editor sent one, otherwise fen, or the start position if that
+// is empty too. FENs are read leniently, as other programs write them.
+// Castling rights and an en passant square that the board doesn't allow
+// are dropped, with notes saying why; anything else that couldn't be
+// played is an error.
+func startPosition(fen string, setup *BoardSetup) (*chess.Position, []string, error) {
+	var s *board.Setup
+	var notes []string
+	var err error
+	switch {
+	case setup != nil:
+		if s, err = setup.read(); err != nil {
+			return nil, nil, err
+		}
+		notes = s.Tidy()
+	case fen != "":
+		if s, err = board.ParseSetup(fen, board.LenientFEN); err != nil {
+			return nil, nil, err
+		}
+		notes = s.Tidy()
+	default:
+		return chess.NewGame().Position(), nil, nil
+	}
+	pos, err := s.Position()
+	if err != nil {
+		return nil, nil, err
+	}
+	return pos, notes, nil
+}
+
+// read turns the editor's position into a board.Setup. Without castling
+// rights given it gets every one the board allows.
+func (bs *BoardSetup) read() (*board.Setup, error) {
+	var placement [8][8]byte // by rank from the eighth, then file
+	for name, letter := range bs.Pieces {
+		sq, ok := chess.NoSquare, len(name) == 2
+		if ok {
+			sq, ok = square(name)
+		}
+		if !ok {
+			return nil, fmt.Errorf("%q is not a square", name)
+		}
+		if len(letter) != 1 || !strings.Contains("pnbrqkPNBRQK", letter) {
+			return nil, fmt.Errorf("%s has %q, which is not a piece; use a FEN letter such as K or p", name, letter)
+		}
+		placement[7-sq.Rank()][sq.File()] = letter[0]
+	}
+	ranks := make([]string, 8)
+	for i, rank := range placement {
+		empty := 0
+		for _, c := range rank {
+			if c == 0 {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				ranks[i] += fmt.Sprint(empty)
+				empty = 0
+			}
+			ranks[i] += string(c)
+		}
+		if empty > 0 {
+			ranks[i] += fmt.Sprint(empty)
+		}
+	}
+
+	turn := "w"
+	switch bs.Turn {
+	case "", "white":
+	case "black":
+		turn = "b"
+	default:
+		return nil, fmt.Errorf("unknown side to move %q", bs.Turn)
+	}
+	castling := bs.Castling
+	if castling == "" {
+		castling = "KQkq"
+	}
+	s, err := board.ParseSetup(strings.Join(ranks, "/")+" "+turn+" "+castling, board.LenientFEN)
+	if err != nil {
+		return nil, err
+	}
+	if bs.Castling == "" {
+		// Whatever the board doesn't allow was never asked for
+		s.Tidy()
+	}
+	if bs.EnPassant != "" {
+		sq, ok := chess.NoSquare, len(bs.EnPassant) == 2
+		if ok {
+			sq, ok = square(bs.EnPassant)
+		}
+		if !ok {
+			return nil, fmt.Errorf("en passant square %q is not a square", bs.EnPassant)
+		}
+		s.SetEnPassant(sq)
+	}
+	return s, nil
+}
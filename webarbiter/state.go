@@ -0,0 +1,122 @@
+package webarbiter
+
+import (
+	"strings"
+
+	"chessTomorrow/board"
+	"github.com/notnil/chess"
+)
+
+// State is sent to the frontend after every move: the position, the moves
+// that can be played in it and whether the game is over, so the page can
+// show legal destinations and the end of the game.
+type State struct {
+	Session   string      `json:"session,omitempty"` // to rejoin the game after a reload
+	Game      string      `json:"game,omitempty"`    // the game's ID in the store, if games are kept
+	Color     string      `json:"color,omitempty"`   // the player's side
+	Engine    string      `json:"engine,omitempty"`  // the opponent's name in the registry
+	Level     string      `json:"level,omitempty"`
+	FEN       string      `json:"fen"`
+	Moves     []string    `json:"moves"`          // the game so far, in UCI
+	Move      string      `json:"move,omitempty"` // the engine's reply, in UCI
+	Thinking  bool        `json:"thinking"`       // the engine is searching for its move
+	LastMove  *LastMove   `json:"lastMove,omitempty"`
+	Legal     []string    `json:"legal"` // the side to move's moves, in UCI
+	Check     bool        `json:"check"`
+	Checkmate bool        `json:"checkmate"`
+	Stalemate bool        `json:"stalemate"`
+	Draw      bool        `json:"draw"`
+	Result    string      `json:"result,omitempty"` // 1-0, 0-1 or 1/2-1/2 once the game is over
+	Reason    string      `json:"reason,omitempty"` // how it ended, such as Checkmate or Resignation
+	Captured  Captured    `json:"captured"`
+	Clock     *ClockState `json:"clock,omitempty"`
+	Notes     []string    `json:"notes,omitempty"` // what was dropped from a new game's position, such as castling rights its rooks don't allow
+	Error     string      `json:"error,omitempty"`
+}
+
+// LastMove describes the last move of the game, whoever made it.
+type LastMove struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	SAN       string `json:"san"`
+	Promotion string `json:"promotion,omitempty"` // the piece a pawn became, as a FEN letter of its color
+}
+
+// Captured lists the pieces each side has lost, as FEN letters, in the
+// order they were taken.
+type Captured struct {
+	White []string `json:"white"`
+	Black []string `json:"black"`
+}
+
+// newState describes g.
+func newState(g *chess.Game) State {
+	pos := g.Position()
+	s := State{
+		FEN:      pos.String(),
+		Moves:    []string{},
+		Legal:    []string{},
+		Check:    board.CheckersBitboard(pos) != 0,
+		Captured: captured(g),
+	}
+	for _, mv := range g.Moves() {
+		s.Moves = append(s.Moves, board.MoveToUCI(mv))
+	}
+	if moves := g.Moves(); len(moves) > 0 {
+		s.LastMove = lastMove(g.Positions()[len(moves)-1], moves[len(moves)-1])
+	}
+	if g.Outcome() == chess.NoOutcome {
+		for _, mv := range pos.ValidMoves() {
+			s.Legal = append(s.Legal, board.MoveToUCI(mv))
+		}
+		return s
+	}
+
+	s.Result, s.Reason = g.Outcome().String(), g.Method().String()
+	switch g.Method() {
+	case chess.Checkmate:
+		s.Checkmate = true
+	case chess.Stalemate:
+		s.Stalemate = true
+	}
+	s.Draw = g.Outcome() == chess.Draw
+	return s
+}
+
+// lastMove describes mv, played in pos.
+func lastMove(pos *chess.Position, mv *chess.Move) *LastMove {
+	last := &LastMove{
+		From: mv.S1().String(),
+		To:   mv.S2().String(),
+		SAN:  chess.AlgebraicNotation{}.Encode(pos, mv),
+	}
+	if mv.Promo() != chess.NoPieceType {
+		last.Promotion = mv.Promo().String()
+		if pos.Turn() == chess.White {
+			last.Promotion = strings.ToUpper(last.Promotion)
+		}
+	}
+	return last
+}
+
+// captured replays g's captures.
+func captured(g *chess.Game) Captured {
+	c := Captured{White: []string{}, Black: []string{}}
+	positions := g.Positions()
+	for i, mv := range g.Moves() {
+		if !mv.HasTag(chess.Capture) {
+			continue
+		}
+		taken := positions[i].Board().Piece(mv.S2())
+		if mv.HasTag(chess.EnPassant) {
+			taken = chess.NewPiece(chess.Pawn, positions[i].Turn().Other())
+		}
+		letter := taken.Type().String()
+		if taken.Color() == chess.White {
+			c.White = append(c.White, strings.ToUpper(letter))
+		} else {
+			c.Black = append(c.Black, letter)
+		}
+	}
+	return c
+}
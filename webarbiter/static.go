@@ -0,0 +1,72 @@
+package webarbiter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// The frontend is built into the binary, so the server runs from any
+// directory.
+//
+//go:embed static
+var static embed.FS
+
+// frontend holds the pages and their assets: the built-in ones, or a
+// directory given with -static.
+var frontend fs.FS = mustSub(static, "static")
+
+// Serve the index.html file directly
+func serveIndex(w http.ResponseWriter, r *http.Request) {
+	serveFrontend(w, r, "index.html")
+}
+
+// Serve other static assets (CSS, JS)
+func serveStatic(w http.ResponseWriter, r *http.Request) {
+	serveFrontend(w, r, strings.TrimPrefix(r.URL.Path, "/static/"))
+}
+
+// serveFrontend answers with a frontend file. Browsers may keep it, but
+// must check with its ETag that it hasn't changed before using it again,
+// since the names stay the same from one version to the next.
+func serveFrontend(w http.ResponseWriter, r *http.Request, name string) {
+	if !fs.ValidPath(name) {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := fs.ReadFile(frontend, name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	ctype := mime.TypeByExtension(path.Ext(name))
+	if ctype == "" {
+		ctype = http.DetectContentType(data)
+	}
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", etag(data))
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+}
+
+// etag returns a strong ETag for data.
+func etag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic("webarbiter: " + err.Error())
+	}
+	return sub
+}
@@ -0,0 +1,315 @@
+package webarbiter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"chessTomorrow/board"
+	"chessTomorrow/match"
+	"github.com/notnil/chess"
+	bolt "go.etcd.io/bbolt"
+)
+
+// GameStore keeps every game played on the server in a BoltDB file, so
+// that finished games can be looked up later and a session's unfinished
+// game survives a restart. Each save writes the game and its session's
+// entry in one transaction, synced to disk before Save returns, so a crash
+// leaves either the old game or the new one and never a session pointing
+// at a game that isn't there. Its methods may be called on a nil
+// GameStore, which keeps nothing.
+type GameStore struct {
+	db *bolt.DB
+}
+
+// The store's buckets: games by ID, and the ID of each session's latest
+// game by session.
+var (
+	gamesBucket    = []byte("games")
+	sessionsBucket = []byte("sessions")
+)
+
+// GameInfo is a stored game as anyone may see it. It leaves out the
+// session, which is what plays the game.
+type GameInfo struct {
+	ID         string      `json:"id"`
+	Human      string      `json:"human"`            // the player's color
+	Player     string      `json:"player,omitempty"` // the player's user ID, if they had one
+	PlayerName string      `json:"playerName,omitempty"`
+	Engine     string      `json:"engine"`
+	Level      string      `json:"level"`
+	StartFEN   string      `json:"startFen"`
+	Moves      []string    `json:"moves"`            // UCI
+	Result     string      `json:"result,omitempty"` // empty while the game goes on, * if abandoned
+	Reason     string      `json:"reason,omitempty"`
+	Clock      *savedClock `json:"clock,omitempty"`
+	Started    time.Time   `json:"started"`
+	Updated    time.Time   `json:"updated"`
+}
+
+// StoredGame is a game as the store keeps it. Only GameInfo may be sent
+// to clients: the session ID moves the player's pieces and resumes the
+// game.
+type StoredGame struct {
+	GameInfo
+	Session string `json:"session"` // resumes the game if it is unfinished
+}
+
+// savedClock is a Clock between moves.
+type savedClock struct {
+	TimeControl match.TimeControl `json:"timeControl"`
+	White       time.Duration     `json:"white"`
+	Black       time.Duration     `json:"black"`
+	Moves       [2]int            `json:"moves"` // White's and Black's
+}
+
+// OpenGameStore keeps games in games.db in dir, creating both if need be.
+// Games kept as JSON files in dir by earlier versions are copied into it
+// the first time.
+func OpenGameStore(dir string) (*GameStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(filepath.Join(dir, "games.db"), 0o644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	st := &GameStore{db: db}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(gamesBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(sessionsBucket)
+		return err
+	})
+	if err == nil {
+		err = st.importFiles(dir)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return st, nil
+}
+
+// importFiles copies the games kept as JSON files in dir into the store,
+// leaving out any it has already.
+func (st *GameStore) importFiles(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil || len(paths) == 0 {
+		return err
+	}
+	return st.db.Update(func(tx *bolt.Tx) error {
+		for _, path := range paths {
+			g, err := readGame(path)
+			if err != nil {
+				log.Printf("Skipping kept game %s: %v", path, err)
+				continue
+			}
+			if tx.Bucket(gamesBucket).Get([]byte(g.ID)) != nil {
+				continue
+			}
+			if err := put(tx, g); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// readGame reads the game kept in path.
+func readGame(path string) (*StoredGame, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var g StoredGame
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, err
+	}
+	if id := strings.TrimSuffix(filepath.Base(path), ".json"); g.ID != id {
+		return nil, fmt.Errorf("the file holds game %q", g.ID)
+	}
+	return &g, nil
+}
+
+// put writes g in tx, and makes it its session's latest game unless that
+// one is newer.
+func put(tx *bolt.Tx, g *StoredGame) error {
+	data, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(gamesBucket).Put([]byte(g.ID), data); err != nil {
+		return err
+	}
+	if g.Session == "" {
+		return nil
+	}
+	sessions := tx.Bucket(sessionsBucket)
+	if id := sessions.Get([]byte(g.Session)); id != nil && string(id) != g.ID {
+		if latest, err := get(tx, string(id)); err == nil && latest.Updated.After(g.Updated) {
+			return nil
+		}
+	}
+	return sessions.Put([]byte(g.Session), []byte(g.ID))
+}
+
+// get reads the game with the given ID in tx.
+func get(tx *bolt.Tx, id string) (*StoredGame, error) {
+	data := tx.Bucket(gamesBucket).Get([]byte(id))
+	if data == nil {
+		return nil, fs.ErrNotExist
+	}
+	var g StoredGame
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, fmt.Errorf("game %s: %w", id, err)
+	}
+	return &g, nil
+}
+
+// Save writes g, replacing any earlier save of the same game.
+func (st *GameStore) Save(g *StoredGame) error {
+	if st == nil {
+		return nil
+	}
+	return st.db.Update(func(tx *bolt.Tx) error { return put(tx, g) })
+}
+
+// Load returns the game with the given ID.
+func (st *GameStore) Load(id string) (*StoredGame, error) {
+	if st == nil || !validID(id) {
+		return nil, fs.ErrNotExist
+	}
+	var g *StoredGame
+	err := st.db.View(func(tx *bolt.Tx) (err error) {
+		g, err = get(tx, id)
+		return err
+	})
+	return g, err
+}
+
+// List returns every stored game as clients may see it, the most recently
+// played first. A game that can't be read is left out with a log line
+// rather than failing the others.
+func (st *GameStore) List() []GameInfo {
+	if st == nil {
+		return nil
+	}
+	var games []GameInfo
+	err := st.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(gamesBucket).ForEach(func(id, data []byte) error {
+			var g StoredGame
+			if err := json.Unmarshal(data, &g); err != nil {
+				log.Printf("Skipping kept game %s: %v", id, err)
+				return nil
+			}
+			games = append(games, g.GameInfo)
+			return nil
+		})
+	})
+	if err != nil {
+		log.Printf("Listing kept games: %v", err)
+	}
+	sort.Slice(games, func(i, j int) bool { return games[i].Updated.After(games[j].Updated) })
+	return games
+}
+
+// unfinished returns the session's latest game if it is still going on,
+// or nil.
+func (st *GameStore) unfinished(session string) *StoredGame {
+	if st == nil || session == "" {
+		return nil
+	}
+	var g *StoredGame
+	st.db.View(func(tx *bolt.Tx) error {
+		if id := tx.Bucket(sessionsBucket).Get([]byte(session)); id != nil {
+			g, _ = get(tx, string(id))
+		}
+		return nil
+	})
+	if g == nil || g.Result != "" {
+		return nil
+	}
+	return g
+}
+
+// Close closes the store's file.
+func (st *GameStore) Close() error {
+	if st == nil {
+		return nil
+	}
+	return st.db.Close()
+}
+
+// replay plays g's moves from its start position.
+func (g *StoredGame) replay() (*chess.Game, error) {
+	opt, err := chess.FEN(g.StartFEN)
+	if err != nil {
+		return nil, fmt.Errorf("game %s: %w", g.ID, err)
+	}
+	game := chess.NewGame(opt)
+	for _, s := range g.Moves {
+		mv, err := board.UCIToMove(game.Position(), s)
+		if err == nil {
+			err = game.Move(mv)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("game %s: move %s: %w", g.ID, s, err)
+		}
+	}
+	return game, nil
+}
+
+// record returns g for a PGN file.
+func (g *StoredGame) record() (*match.GameRecorder, error) {
+	game, err := g.replay()
+	if err != nil {
+		return nil, err
+	}
+	white, black := "Human", g.Engine
+	if g.PlayerName != "" {
+		white = g.PlayerName
+	}
+	if g.Human == "black" {
+		white, black = black, white
+	}
+	positions := game.Positions()
+	rec := match.NewGameRecorder(white, black, g.StartFEN)
+	rec.Event = "Web game"
+	rec.Date = g.Started
+	for i, mv := range game.Moves() {
+		rec.Record(positions[i], mv)
+	}
+
+	switch {
+	case g.Result == "" || g.Result == string(chess.NoOutcome):
+	case strings.HasPrefix(g.Reason, "Timeout"):
+		rec.Finish(chess.Outcome(g.Result), "time forfeit")
+	default:
+		rec.Finish(chess.Outcome(g.Result), "normal")
+	}
+	if g.Reason == "Abandoned" {
+		rec.Termination = "abandoned"
+	}
+	return rec, nil
+}
+
+// validID reports whether id could name a stored game.
+func validID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
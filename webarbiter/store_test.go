@@ -0,0 +1,86 @@
+package webarbiter
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGameStore(t *testing.T) {
+	dir := t.TempDir()
+	st, err := OpenGameStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	older := &StoredGame{GameInfo: GameInfo{ID: "a1", Moves: []string{"e2e4"}, Updated: start}, Session: "s"}
+	newer := &StoredGame{GameInfo: GameInfo{ID: "b2", Moves: []string{"d2d4"}, Updated: start.Add(time.Minute)}, Session: "s"}
+	for _, g := range []*StoredGame{newer, older} {
+		if err := st.Save(g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if st, err = OpenGameStore(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	// Saving the older game again leaves the session on the newer one.
+	if g := st.unfinished("s"); g == nil || g.ID != "b2" {
+		t.Errorf("the session resumes %+v, want game b2", g)
+	}
+	games := st.List()
+	if len(games) != 2 || games[0].ID != "b2" || games[1].ID != "a1" {
+		t.Errorf("List = %+v, want b2 then a1", games)
+	}
+	if g, err := st.Load("a1"); err != nil || g.Moves[0] != "e2e4" {
+		t.Errorf("Load(a1) = %+v, %v", g, err)
+	}
+	for _, id := range []string{"c3", "../a1", ""} {
+		if _, err := st.Load(id); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Load(%q) = %v, want ErrNotExist", id, err)
+		}
+	}
+
+	newer.Result = "1-0"
+	if err := st.Save(newer); err != nil {
+		t.Fatal(err)
+	}
+	if g := st.unfinished("s"); g != nil {
+		t.Errorf("the session resumes finished game %s", g.ID)
+	}
+}
+
+func TestGameStoreImport(t *testing.T) {
+	dir := t.TempDir()
+	g := StoredGame{GameInfo: GameInfo{ID: "c3"}, Session: "s"}
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "c3.json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A file holding another game is left out.
+	if err := os.WriteFile(filepath.Join(dir, "d4.json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	st, err := OpenGameStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if games := st.List(); len(games) != 1 || games[0].ID != "c3" {
+		t.Errorf("imported %+v, want game c3", games)
+	}
+	if g := st.unfinished("s"); g == nil || g.ID != "c3" {
+		t.Errorf("the session resumes %+v, want game c3", g)
+	}
+}
@@ -0,0 +1,358 @@
+package webarbiter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"chessTomorrow/ratings"
+	"github.com/notnil/chess"
+)
+
+// maxNameLength bounds a player's name, in characters.
+const maxNameLength = 32
+
+// Users are the people who play on the server, each known by a token their
+// browser keeps, along with the Glicko-2 ratings of them and of every
+// engine level they have played. A game counts once it is over, if both
+// players are rated: someone without a token plays unrated. Puzzle
+// solving is rated apart, with each puzzle rated as the solvers' opponent.
+// With a file, users and ratings survive a restart.
+type Users struct {
+	path string // "" keeps them in memory only
+
+	mu      sync.Mutex
+	users   map[string]*User           // by the hash of their token
+	ratings map[string]*Rating         // by player key
+	puzzles map[string]*Rating         // by user ID, and by puzzle key for puzzles
+	tried   map[string]map[string]bool // the puzzles each user has been rated on, by user ID
+}
+
+// User is someone who plays on the server. Only a hash of their token is
+// kept, so the file doesn't give anyone away.
+type User struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Token   string    `json:"token"` // SHA-256 of the token, in hex
+	Created time.Time `json:"created"`
+}
+
+// Rating is a player's line on the leaderboard.
+type Rating struct {
+	Player string `json:"player"` // the user's ID, or the engine level's key
+	Name   string `json:"name"`
+	Engine bool   `json:"engine,omitempty"`
+	Puzzle bool   `json:"puzzle,omitempty"`
+	ratings.Glicko
+	Games  int `json:"games"`
+	Wins   int `json:"wins"`
+	Draws  int `json:"draws"`
+	Losses int `json:"losses"`
+}
+
+// player is a side of a game as ratings see it: a user, an engine at a
+// level, a puzzle, or nobody if the key is empty.
+type player struct {
+	key, name      string
+	engine, puzzle bool
+}
+
+// usersFile is the file Users are kept in.
+type usersFile struct {
+	Users   []*User             `json:"users"`
+	Ratings []*Rating           `json:"ratings"`
+	Puzzles []*Rating           `json:"puzzles,omitempty"`
+	Tried   map[string][]string `json:"tried,omitempty"`
+}
+
+// LoadUsers reads the users and ratings kept in path, which need not exist
+// yet. An empty path keeps them in memory only.
+func LoadUsers(path string) (*Users, error) {
+	u := &Users{
+		path:    path,
+		users:   make(map[string]*User),
+		ratings: make(map[string]*Rating),
+		puzzles: make(map[string]*Rating),
+		tried:   make(map[string]map[string]bool),
+	}
+	if path == "" {
+		return u, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return u, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f usersFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, user := range f.Users {
+		u.users[user.Token] = user
+	}
+	for _, r := range f.Ratings {
+		u.ratings[r.Player] = r
+	}
+	for _, r := range f.Puzzles {
+		u.puzzles[r.Player] = r
+	}
+	for id, puzzles := range f.Tried {
+		u.tried[id] = make(map[string]bool)
+		for _, p := range puzzles {
+			u.tried[id][p] = true
+		}
+	}
+	return u, nil
+}
+
+// Create makes a user called name, or a name made up from their ID if it
+// is empty, and returns them with the token that identifies them.
+func (u *Users) Create(name string) (*User, string, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return nil, "", fmt.Errorf("a name can have at most %d characters", maxNameLength)
+	}
+	user := &User{ID: newID(), Name: name, Created: time.Now()}
+	if name == "" {
+		user.Name = "Player " + user.ID[:6]
+	}
+	token := newID()
+	user.Token = hashToken(token)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.users[user.Token] = user
+	if err := u.save(); err != nil {
+		delete(u.users, user.Token)
+		return nil, "", err
+	}
+	return user, token, nil
+}
+
+// Find returns the user with token, or nil if there is none.
+func (u *Users) Find(token string) *User {
+	if token == "" {
+		return nil
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.users[hashToken(token)]
+}
+
+// Rating returns the rating of the player with key as it stands, or that
+// of a new player if they haven't finished a rated game.
+func (u *Users) Rating(key string) Rating {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if r := u.ratings[key]; r != nil {
+		return *r
+	}
+	return Rating{Player: key, Glicko: ratings.NewGlicko()}
+}
+
+// Rate updates the ratings of white and black after a game that ended
+// with result, such as 1-0. A game with nobody on either side, one played against
+// oneself, or one with no result changes nothing.
+func (u *Users) Rate(white, black player, result string) error {
+	var score float64
+	switch chess.Outcome(result) {
+	case chess.WhiteWon:
+		score = 1
+	case chess.Draw:
+		score = 0.5
+	case chess.BlackWon:
+	default:
+		return nil
+	}
+	if white.key == "" || black.key == "" || white.key == black.key {
+		return nil
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	w, b := rating(u.ratings, white, ratings.NewGlicko()), rating(u.ratings, black, ratings.NewGlicko())
+	w.Glicko, b.Glicko = w.Glicko.Update(b.Glicko, score), b.Glicko.Update(w.Glicko, 1-score)
+	w.count(score)
+	b.count(1 - score)
+	return u.save()
+}
+
+// RatePuzzle updates the puzzle ratings of user and of the puzzle after
+// user's try at it, and returns user's new rating. Only a user's first
+// try at each puzzle counts; later ones leave the ratings as they are.
+// A puzzle's first rating is start, such as the one its file gives.
+func (u *Users) RatePuzzle(user *User, puzzle player, start ratings.Glicko, solved bool) (Rating, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	solver := rating(u.puzzles, user.player(), ratings.NewGlicko())
+	if u.tried[user.ID][puzzle.key] {
+		return *solver, nil
+	}
+	if u.tried[user.ID] == nil {
+		u.tried[user.ID] = make(map[string]bool)
+	}
+	u.tried[user.ID][puzzle.key] = true
+
+	p := rating(u.puzzles, puzzle, start)
+	score := 0.0
+	if solved {
+		score = 1
+	}
+	solver.Glicko, p.Glicko = solver.Glicko.Update(p.Glicko, score), p.Glicko.Update(solver.Glicko, 1-score)
+	solver.count(score)
+	p.count(1 - score)
+	return *solver, u.save()
+}
+
+// PuzzleRating returns the puzzle rating of the user or puzzle with key,
+// or start if they haven't been rated yet.
+func (u *Users) PuzzleRating(key string, start ratings.Glicko) Rating {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if r := u.puzzles[key]; r != nil {
+		return *r
+	}
+	return Rating{Player: key, Glicko: start}
+}
+
+// Tried returns the puzzles user has been rated on, by puzzle key.
+func (u *Users) Tried(id string) map[string]bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	tried := make(map[string]bool)
+	for p := range u.tried[id] {
+		tried[p] = true
+	}
+	return tried
+}
+
+// Leaderboard returns every rated player, the highest rated first.
+func (u *Users) Leaderboard() []Rating {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return leaderboard(u.ratings, func(*Rating) bool { return true })
+}
+
+// PuzzleLeaderboard returns every user with a puzzle rating, the highest
+// rated first.
+func (u *Users) PuzzleLeaderboard() []Rating {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return leaderboard(u.puzzles, func(r *Rating) bool { return !r.Puzzle })
+}
+
+// leaderboard returns the ratings that keep accepts, the highest first.
+func leaderboard(ratings map[string]*Rating, keep func(*Rating) bool) []Rating {
+	board := []Rating{}
+	for _, r := range ratings {
+		if keep(r) {
+			board = append(board, *r)
+		}
+	}
+	sort.Slice(board, func(i, j int) bool {
+		if board[i].Rating != board[j].Rating {
+			return board[i].Rating > board[j].Rating
+		}
+		return board[i].Name < board[j].Name
+	})
+	return board
+}
+
+// rating returns p's rating in ratings, adding one that starts at start
+// if p has none. The name is brought up to date, for engine levels
+// renamed in the registry.
+func rating(ratings map[string]*Rating, p player, start ratings.Glicko) *Rating {
+	r := ratings[p.key]
+	if r == nil {
+		r = &Rating{Player: p.key, Engine: p.engine, Puzzle: p.puzzle, Glicko: start}
+		ratings[p.key] = r
+	}
+	r.Name = p.name
+	return r
+}
+
+// count adds a game that scored score to r's tally.
+func (r *Rating) count(score float64) {
+	r.Games++
+	switch score {
+	case 1:
+		r.Wins++
+	case 0:
+		r.Losses++
+	default:
+		r.Draws++
+	}
+}
+
+// save writes the users and ratings to the file, if there is one. The
+// file is written whole and renamed into place, so a crash leaves the old
+// one. The caller holds the mutex.
+func (u *Users) save() error {
+	if u.path == "" {
+		return nil
+	}
+	var f usersFile
+	for _, user := range u.users {
+		f.Users = append(f.Users, user)
+	}
+	for _, r := range u.ratings {
+		f.Ratings = append(f.Ratings, r)
+	}
+	for _, r := range u.puzzles {
+		f.Puzzles = append(f.Puzzles, r)
+	}
+	if len(u.tried) > 0 {
+		f.Tried = make(map[string][]string)
+	}
+	for id, puzzles := range u.tried {
+		for p := range puzzles {
+			f.Tried[id] = append(f.Tried[id], p)
+		}
+		sort.Strings(f.Tried[id])
+	}
+	sort.Slice(f.Users, func(i, j int) bool { return f.Users[i].Created.Before(f.Users[j].Created) })
+	sort.Slice(f.Ratings, func(i, j int) bool { return f.Ratings[i].Player < f.Ratings[j].Player })
+	sort.Slice(f.Puzzles, func(i, j int) bool { return f.Puzzles[i].Player < f.Puzzles[j].Player })
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(u.path+".tmp", data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(u.path+".tmp", u.path)
+}
+
+// player returns the user as a side of a game; a nil user is nobody.
+func (user *User) player() player {
+	if user == nil {
+		return player{}
+	}
+	return player{key: user.ID, name: user.Name}
+}
+
+// enginePlayer returns an engine at a level as a side of a game. Each
+// level is rated apart, as it plays as a different opponent.
+func enginePlayer(conf *EngineConfig, level Level) player {
+	return player{
+		key:    "engine:" + conf.Name + "/" + level.Name,
+		name:   conf.Name + ", " + level.Name,
+		engine: true,
+	}
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}